@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/process"
+)
+
+// batchCommand dithers every image of a directory or glob pattern with the same settings, into an output directory
+func batchCommand(args []string) error {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	input := flags.String("p", "", "directory with the input images, or a glob pattern of them (like 'photos/*.jpg')")
+	outputDir := flags.String("o", "output", "directory to write the dithered images to")
+	name := flags.String("name", "{name}.png", "file name of the outputs: {name} is the name of the input without extension, {ext} its extension")
+	format := flags.String("format", "", "format of the output images: "+strings.Join(imgutil.Formats, ", ")+" (by default from the extension of -name)")
+	size := addSizeFlags(flags, "factor by which the images are scaled down before dithering")
+	depth := flags.Int("depth", 8, "bits per channel of the output images: 8, or 16 for png outputs with direct colors")
+	skipDuplicates := flags.Bool("skip-duplicates", false, "skip the images that are near-identical to an earlier one (in the sorted order of the inputs)")
+	shared := flags.Bool("shared", false, "create one palette from all of the images, instead of one for every image")
+	workers := flags.Int("workers", 0, "amount of images that are dithered at the same time (by default -j)")
+	watchInput := flags.Bool("watch", false, "dither the images again every time one of them changes, or one is added or removed, until an interrupt")
+	ditherName := addDitherFlag(flags)
+	options := addPaletteFlags(flags)
+	options.addCreateFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither batch -p 'photos/*.jpg' -o output/ [-name '{name}.png'] [-shared] [-k 8]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *input == "" {
+		problems.add("provide a directory or glob pattern of input images (-p)")
+	}
+	if *workers < 0 {
+		problems.add("the amount of workers (-workers) can't be negative")
+	}
+	if !strings.Contains(*name, "{name}") {
+		problems.add("the file name of the outputs (-name) needs {name}, or all images are written to the same file")
+	}
+	settings := imageSettings{size: *size, depth: *depth}
+	settings.setup(problems, *format, *name, *ditherName)
+	settings.checkColors(problems, options)
+	options.check(problems, givenFlags(flags))
+	if *shared && (options.duotone != "" || options.quantizer != "kmeans") {
+		problems.add("a shared palette (-shared) is created with kmeans, it can't be combined with -duotone or -quantizer")
+	}
+	if *shared && !options.creates() {
+		problems.add("-shared creates a palette, which -palette and -palette-file choose instead, give only one of them")
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+
+	if *workers == 0 {
+		*workers = runtime.GOMAXPROCS(0)
+	}
+
+	dither := func() error {
+		// set up on every run, so that with -watch the same images get the same palettes
+		if err := options.setup(); err != nil {
+			return err
+		}
+		// the workers create their palettes at the same time, which would garble the progress
+		options.quiet = true
+
+		inputs, err := batchInputs(*input)
+		if err != nil {
+			return err
+		}
+		if *skipDuplicates {
+			inputs, err = withoutDuplicates(inputs, size, imgutil.NewDeduper())
+			if err != nil {
+				return err
+			}
+		}
+
+		outputs := make([]string, len(inputs))
+		seen := map[string]string{}
+		for i, path := range inputs {
+			ext := filepath.Ext(path)
+			replacer := strings.NewReplacer(
+				"{name}", strings.TrimSuffix(filepath.Base(path), ext),
+				"{ext}", strings.TrimPrefix(ext, "."),
+			)
+			outputs[i] = filepath.Join(*outputDir, replacer.Replace(*name))
+
+			if other, ok := seen[outputs[i]]; ok {
+				return fmt.Errorf("%s and %s would both be written to %s, use {ext} in -name", other, path, outputs[i])
+			}
+			seen[outputs[i]] = path
+		}
+
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			return err
+		}
+
+		ctx, stop := interruptContext()
+		defer stop()
+
+		timer := newTiming()
+
+		palette, index, err := options.named()
+		if err != nil {
+			return err
+		}
+		if palette == nil && *shared {
+			palette, err = sharedPalette(ctx, inputs, size, options.settings, options.k, timer)
+			if err != nil {
+				return err
+			}
+		}
+		var sharedIndex process.ColorIndex
+		if palette != nil {
+			sharedIndex = options.colorIndex(palette, index)
+		}
+
+		// the palette of every image is created with its own source of randomness,
+		// so that every image gets the same palette whichever worker handles it
+		imagePalette := func(i int, scaledImage *image.RGBA) (color.Palette, process.ColorIndex, error) {
+			if palette != nil {
+				return palette, sharedIndex, nil
+			}
+
+			created, err := options.create(ctx, scaledImage, seededRand(i))
+			if err != nil {
+				return nil, nil, err
+			}
+			return created, options.colorIndex(created, nil), nil
+		}
+
+		jobs := make(chan int)
+		failures := make([]error, len(inputs))
+		wg := sync.WaitGroup{}
+		for w := 0; w < *workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					failures[i] = batchImage(ctx, &settings, inputs[i], outputs[i], timer, func(scaledImage *image.RGBA) (color.Palette, process.ColorIndex, error) {
+						return imagePalette(i, scaledImage)
+					})
+				}
+			}()
+		}
+
+		for i := range inputs {
+			if ctx.Err() != nil {
+				break
+			}
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		failed := 0
+		for i, err := range failures {
+			if err != nil {
+				logf(0, "%s: %v", inputs[i], err)
+				failed++
+			}
+		}
+		if ctx.Err() != nil {
+			return errors.New("interrupted")
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of the %d images failed", failed, len(inputs))
+		}
+
+		timer.summary()
+		return nil
+	}
+
+	if *watchInput {
+		return watch(*input, dither)
+	}
+
+	return dither()
+}
+
+// batchImage dithers the image at inputPath with the palette that palette returns for it, and saves it at outputPath.
+// The stages are timed with timer.
+func batchImage(ctx context.Context, settings *imageSettings, inputPath, outputPath string, timer *timing, palette func(*image.RGBA) (color.Palette, process.ColorIndex, error)) error {
+	done := timer.stage("decode", inputPath)
+	img, err := openInput(inputPath)
+	done()
+	if err != nil {
+		return err
+	}
+
+	done = timer.stage("downscale", inputPath)
+	scaledImage, err := settings.size.apply(img)
+	done()
+	if err != nil {
+		return err
+	}
+
+	done = timer.stage("palette", inputPath)
+	imagePalette, index, err := palette(scaledImage)
+	done()
+	if err != nil {
+		return err
+	}
+
+	done = timer.stage("dither", inputPath)
+	dithered, err := settings.ditherImage(ctx, img, scaledImage, imagePalette, index, nil)
+	done()
+	if err != nil {
+		return err
+	}
+
+	done = timer.stage("encode", inputPath)
+	defer done()
+
+	return saveImage(dithered, outputPath, settings.format)
+}
+
+// batchInputs returns the images in the directory at input, or the files that match the glob pattern input, sorted
+func batchInputs(input string) ([]string, error) {
+	var inputs []string
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			// only the files that look like images, a directory often holds other files as well
+			if !entry.IsDir() && imgutil.FormatOf(entry.Name()) != "" {
+				inputs = append(inputs, filepath.Join(input, entry.Name()))
+			}
+		}
+	} else {
+		matches, err := filepath.Glob(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				inputs = append(inputs, match)
+			}
+		}
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no images found at %s", input)
+	}
+	sort.Strings(inputs)
+
+	return inputs, nil
+}
+
+// withoutDuplicates returns the paths of the images that deduper hasn't seen a near-identical image of before,
+// comparing the images resized to size. The images are compared in order, so the first of the duplicates is kept.
+func withoutDuplicates(paths []string, size *sizeOptions, deduper *imgutil.Deduper) ([]string, error) {
+	var kept []string
+	for _, path := range paths {
+		_, scaledImage, err := openScaled(path, size)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if deduper.Seen(scaledImage) {
+			logf(1, "skipping %s, a near-duplicate of an earlier image", path)
+			continue
+		}
+		kept = append(kept, path)
+	}
+
+	return kept, nil
+}
+
+// sharedPalette creates one palette of k colors for all of the images at paths, resized to size, with the settings,
+// and times it with timer. It stops when ctx is cancelled.
+func sharedPalette(ctx context.Context, paths []string, size *sizeOptions, settings colorpalette.Settings, k int, timer *timing) (color.Palette, error) {
+	done := timer.stage("shared palette", "")
+	defer done()
+
+	imgs := make([]image.Image, len(paths))
+	for i, path := range paths {
+		if ctx.Err() != nil {
+			return nil, errors.New("interrupted while reading the images of the palette")
+		}
+		_, scaledImage, err := openScaled(path, size)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		imgs[i] = scaledImage
+	}
+
+	logf(1, "creating the palette of %d images", len(paths))
+
+	palette, err := settings.CreateFromImagesContext(ctx, imgs, k)
+	if ctx.Err() != nil {
+		return nil, errors.New("interrupted while creating the palette")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return palette, nil
+}
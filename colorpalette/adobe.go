@@ -0,0 +1,367 @@
+package colorpalette
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"unicode/utf16"
+)
+
+// ASE block types
+const (
+	aseGroupStart = 0xC001
+	aseGroupEnd   = 0xC002
+	aseColor      = 0x0001
+)
+
+// ParseASE reads an Adobe Swatch Exchange (.ase) file from r into a ColorPalette.
+// RGB, CMYK, Lab and Gray swatches are converted to RGBA. The name of the first group, if any,
+// becomes the name of the palette.
+func ParseASE(r io.Reader) (ColorPalette, error) {
+	palette := ColorPalette{
+		Colors: []color.RGBA{},
+	}
+
+	var header struct {
+		Signature [4]byte
+		Major     uint16
+		Minor     uint16
+		Blocks    uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return palette, err
+	}
+	if string(header.Signature[:]) != "ASEF" {
+		return palette, errors.New("colorpalette: not an Adobe Swatch Exchange file")
+	}
+
+	for i := uint32(0); i < header.Blocks; i++ {
+		var blockType uint16
+		var blockLength uint32
+		if err := binary.Read(r, binary.BigEndian, &blockType); err != nil {
+			return palette, err
+		}
+		if err := binary.Read(r, binary.BigEndian, &blockLength); err != nil {
+			return palette, err
+		}
+
+		// read what there is, rather than allocating the length that a corrupt block claims
+		block, err := io.ReadAll(io.LimitReader(r, int64(blockLength)))
+		if err != nil {
+			return palette, err
+		}
+		if len(block) < int(blockLength) {
+			return palette, errors.New("colorpalette: truncated Adobe Swatch Exchange file")
+		}
+
+		switch blockType {
+		case aseGroupStart:
+			name, _, err := readASEName(block)
+			if err != nil {
+				return palette, err
+			}
+			if palette.Name == "" {
+				palette.Name = name
+			}
+		case aseColor:
+			clr, err := parseASEColor(block)
+			if err != nil {
+				return palette, err
+			}
+			palette.Colors = append(palette.Colors, clr)
+		}
+	}
+
+	return palette, nil
+}
+
+// readASEName reads the length prefixed UTF-16 name at the start of an ASE block,
+// and returns it together with the rest of the block
+func readASEName(block []byte) (string, []byte, error) {
+	if len(block) < 2 {
+		return "", nil, errors.New("colorpalette: truncated swatch name")
+	}
+
+	length := int(binary.BigEndian.Uint16(block))
+	block = block[2:]
+	if len(block) < 2*length {
+		return "", nil, errors.New("colorpalette: truncated swatch name")
+	}
+
+	return decodeUTF16(block[:2*length]), block[2*length:], nil
+}
+
+// parseASEColor parses the data of an ASE color block
+func parseASEColor(block []byte) (color.RGBA, error) {
+	_, block, err := readASEName(block)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	if len(block) < 4 {
+		return color.RGBA{}, errors.New("colorpalette: truncated swatch")
+	}
+
+	model := string(block[:4])
+	reader := bytes.NewReader(block[4:])
+
+	var amount int
+	switch model {
+	case "RGB ", "LAB ":
+		amount = 3
+	case "CMYK":
+		amount = 4
+	case "Gray":
+		amount = 1
+	default:
+		return color.RGBA{}, fmt.Errorf("colorpalette: unsupported swatch color model %q", model)
+	}
+
+	values := make([]float32, amount)
+	if err := binary.Read(reader, binary.BigEndian, values); err != nil {
+		return color.RGBA{}, err
+	}
+
+	switch model {
+	case "RGB ":
+		return color.RGBA{unitToByte(values[0]), unitToByte(values[1]), unitToByte(values[2]), 255}, nil
+	case "LAB ":
+		// ASE stores the lightness as a fraction
+		return labToRGBA(float64(values[0])*100, float64(values[1]), float64(values[2])), nil
+	case "CMYK":
+		return cmykToRGBA(float64(values[0]), float64(values[1]), float64(values[2]), float64(values[3])), nil
+	default:
+		gray := unitToByte(values[0])
+		return color.RGBA{gray, gray, gray, 255}, nil
+	}
+}
+
+// ACO color spaces
+const (
+	acoRGB  = 0
+	acoHSB  = 1
+	acoCMYK = 2
+	acoLab  = 7
+	acoGray = 8
+)
+
+// ParseACO reads a Photoshop color swatch (.aco) file from r into a ColorPalette.
+// RGB, HSB, CMYK, Lab and Grayscale swatches are converted to RGBA.
+func ParseACO(r io.Reader) (ColorPalette, error) {
+	palette := ColorPalette{
+		Colors: []color.RGBA{},
+	}
+
+	var header [2]uint16
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return palette, err
+	}
+	version, count := header[0], header[1]
+
+	if version != 1 && version != 2 {
+		return palette, errors.New("colorpalette: not a Photoshop color swatch file")
+	}
+
+	// a version 1 section is followed by a version 2 section with the same colors and their names,
+	// the colors of the first section suffice
+	for i := uint16(0); i < count; i++ {
+		var entry [5]uint16
+		if err := binary.Read(r, binary.BigEndian, &entry); err != nil {
+			return palette, err
+		}
+
+		clr, err := acoToRGBA(entry[0], entry[1], entry[2], entry[3], entry[4])
+		if err != nil {
+			return palette, err
+		}
+		palette.Colors = append(palette.Colors, clr)
+
+		if version == 2 {
+			var nameLength uint32
+			if err := binary.Read(r, binary.BigEndian, &nameLength); err != nil {
+				return palette, err
+			}
+			if _, err := io.CopyN(io.Discard, r, 2*int64(nameLength)); err != nil {
+				return palette, err
+			}
+		}
+	}
+
+	return palette, nil
+}
+
+// acoToRGBA converts one ACO color entry to an RGBA color
+func acoToRGBA(space, w, x, y, z uint16) (color.RGBA, error) {
+	switch space {
+	case acoRGB:
+		return color.RGBA{uint8(w >> 8), uint8(x >> 8), uint8(y >> 8), 255}, nil
+	case acoHSB:
+		return hsbToRGBA(float64(w)/65536*360, float64(x)/65535, float64(y)/65535), nil
+	case acoCMYK:
+		// 0 means full ink, 65535 means no ink
+		return cmykToRGBA(1-float64(w)/65535, 1-float64(x)/65535, 1-float64(y)/65535, 1-float64(z)/65535), nil
+	case acoLab:
+		return labToRGBA(float64(w)/100, float64(int16(x))/100, float64(int16(y))/100), nil
+	case acoGray:
+		// 0 is white, 10000 is black
+		gray := unitToByte(float32(1 - float64(w)/10000))
+		return color.RGBA{gray, gray, gray, 255}, nil
+	}
+
+	return color.RGBA{}, fmt.Errorf("colorpalette: unsupported swatch color space %d", space)
+}
+
+// GetPaletteFromASE reads an Adobe Swatch Exchange (.ase) file into a ColorPalette.
+func GetPaletteFromASE(aseFileName string) (ColorPalette, error) {
+	file, err := os.Open(aseFileName)
+	if err != nil {
+		return ColorPalette{}, err
+	}
+	defer file.Close()
+
+	return ParseASE(file)
+}
+
+// GetPaletteFromACO reads a Photoshop color swatch (.aco) file into a ColorPalette.
+func GetPaletteFromACO(acoFileName string) (ColorPalette, error) {
+	file, err := os.Open(acoFileName)
+	if err != nil {
+		return ColorPalette{}, err
+	}
+	defer file.Close()
+
+	return ParseACO(file)
+}
+
+func decodeUTF16(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+
+	// strip the null terminator
+	for len(units) > 0 && units[len(units)-1] == 0 {
+		units = units[:len(units)-1]
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// unitToByte converts a value in [0, 1] to [0, 255]
+func unitToByte(value float32) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, float64(value))) * 255))
+}
+
+// cmykToRGBA converts CMYK ink fractions in [0, 1] to an RGBA color
+func cmykToRGBA(c, m, y, k float64) color.RGBA {
+	r, g, b := color.CMYKToRGB(unitToByte(float32(c)), unitToByte(float32(m)), unitToByte(float32(y)), unitToByte(float32(k)))
+	return color.RGBA{r, g, b, 255}
+}
+
+// labToRGBA converts a CIE L*a*b* color to an RGBA color
+func labToRGBA(l, a, b float64) color.RGBA {
+	rgba := ConvLABAtoRGBA([]float64{l, a, b, 255})
+	return fromChannels(rgba[:3])
+}
+
+// hsbToRGBA converts a hue (degrees), saturation and brightness (both in [0, 1]) to an RGBA color
+func hsbToRGBA(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+
+	return fromChannels([]float64{(r + m) * 255, (g + m) * 255, (b + m) * 255})
+}
+
+// WriteASE writes the palette to w in the Adobe Swatch Exchange (.ase) format, as RGB swatches.
+// If the palette has a name, the swatches are put in a group with that name.
+func (colorpalette *ColorPalette) WriteASE(w io.Writer) error {
+	var body bytes.Buffer
+	blocks := uint32(0)
+
+	writeBlock := func(blockType uint16, data []byte) {
+		binary.Write(&body, binary.BigEndian, blockType)
+		binary.Write(&body, binary.BigEndian, uint32(len(data)))
+		body.Write(data)
+		blocks++
+	}
+
+	if colorpalette.Name != "" {
+		writeBlock(aseGroupStart, encodeASEName(colorpalette.Name))
+	}
+
+	for _, clr := range colorpalette.Colors {
+		var block bytes.Buffer
+		block.Write(encodeASEName(hexString(clr)))
+		block.WriteString("RGB ")
+		binary.Write(&block, binary.BigEndian, []float32{float32(clr.R) / 255, float32(clr.G) / 255, float32(clr.B) / 255})
+		// color type: normal (global and spot are the other options)
+		binary.Write(&block, binary.BigEndian, uint16(2))
+
+		writeBlock(aseColor, block.Bytes())
+	}
+
+	if colorpalette.Name != "" {
+		writeBlock(aseGroupEnd, nil)
+	}
+
+	header := struct {
+		Signature [4]byte
+		Major     uint16
+		Minor     uint16
+		Blocks    uint32
+	}{[4]byte{'A', 'S', 'E', 'F'}, 1, 0, blocks}
+
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return err
+	}
+
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// ToASEFile writes the palette out to the specified path, as an Adobe Swatch Exchange (.ase) file.
+func (colorpalette *ColorPalette) ToASEFile(aseFileName string) error {
+	file, err := os.Create(aseFileName)
+	if err != nil {
+		return err
+	}
+
+	if err := colorpalette.WriteASE(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// encodeASEName encodes a name as a length prefixed, null terminated UTF-16 string
+func encodeASEName(name string) []byte {
+	units := utf16.Encode([]rune(name + "\x00"))
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(len(units)))
+	binary.Write(&buf, binary.BigEndian, units)
+
+	return buf.Bytes()
+}
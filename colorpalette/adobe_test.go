@@ -0,0 +1,120 @@
+package colorpalette
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestASERoundTrip(t *testing.T) {
+	for _, name := range []string{testPalette.Name, ""} {
+		written := testPalette
+		written.Name = name
+
+		var buf bytes.Buffer
+		if err := written.WriteASE(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		palette, err := ParseASE(&buf)
+		if err != nil {
+			t.Fatalf("reading the written palette: %v", err)
+		}
+		if !reflect.DeepEqual(palette, written) {
+			t.Errorf("read %v, wrote %v", palette, written)
+		}
+	}
+}
+
+// acoEntry is a color of an ACO file, see ParseACO
+type acoEntry struct {
+	space, w, x, y, z uint16
+	name              string
+}
+
+// encodeACO encodes the entries as an ACO file of the version (1, or 2 with names)
+func encodeACO(version uint16, entries []acoEntry) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{version, uint16(len(entries))})
+	for _, entry := range entries {
+		binary.Write(&buf, binary.BigEndian, []uint16{entry.space, entry.w, entry.x, entry.y, entry.z})
+		if version == 2 {
+			name := utf16.Encode([]rune(entry.name + "\x00"))
+			binary.Write(&buf, binary.BigEndian, uint32(len(name)))
+			binary.Write(&buf, binary.BigEndian, name)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestParseACO(t *testing.T) {
+	entries := []acoEntry{
+		{acoRGB, 0x1d1d, 0x2b2b, 0x5353, 0, "navy"},
+		{acoRGB, 0xffff, 0x7777, 0xa8a8, 0, "pink"},
+		{acoGray, 0, 0, 0, 0, "white"},
+		{acoGray, 10000, 0, 0, 0, "black"},
+		{acoCMYK, 0xffff, 0xffff, 0xffff, 0xffff, "no ink"},
+		{acoHSB, 0, 0, 0xffff, 0, "white"},
+	}
+	want := []color.RGBA{
+		{0x1d, 0x2b, 0x53, 255},
+		{0xff, 0x77, 0xa8, 255},
+		{255, 255, 255, 255},
+		{0, 0, 0, 255},
+		{255, 255, 255, 255},
+		{255, 255, 255, 255},
+	}
+
+	for _, version := range []uint16{1, 2} {
+		palette, err := ParseACO(bytes.NewReader(encodeACO(version, entries)))
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		if !reflect.DeepEqual(palette.Colors, want) {
+			t.Errorf("version %d: read %v, want %v", version, palette.Colors, want)
+		}
+	}
+}
+
+func TestParseAdobeMalformed(t *testing.T) {
+	var ase bytes.Buffer
+	testPalette.WriteASE(&ase)
+	valid := ase.Bytes()
+
+	// a block that claims to be 4 GiB long
+	huge := append([]byte{}, valid[:12]...)
+	huge = append(huge, 0, 1, 0xff, 0xff, 0xff, 0xff)
+
+	// a color block with a color model that doesn't exist
+	unknown := append([]byte{}, valid...)
+	model := bytes.Index(unknown, []byte("RGB "))
+	copy(unknown[model:], "XYZ ")
+
+	for name, input := range map[string][]byte{
+		"empty":         {},
+		"signature":     []byte("ASEX\x00\x01\x00\x00\x00\x00\x00\x01"),
+		"truncated":     valid[:len(valid)-5],
+		"huge block":    huge,
+		"unknown model": unknown,
+	} {
+		if _, err := ParseASE(bytes.NewReader(input)); err == nil {
+			t.Errorf("ase %s: no error", name)
+		}
+	}
+
+	aco := encodeACO(2, []acoEntry{{acoRGB, 0, 0, 0, 0, "black"}, {acoRGB, 0, 0, 0, 0, "black"}})
+	for name, input := range map[string][]byte{
+		"empty":         {},
+		"version":       encodeACO(3, nil),
+		"truncated":     aco[:len(aco)-3],
+		"unknown space": encodeACO(1, []acoEntry{{42, 0, 0, 0, 0, ""}}),
+	} {
+		if _, err := ParseACO(bytes.NewReader(input)); err == nil {
+			t.Errorf("aco %s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mielpeeters/dither/kmeans"
+)
+
+// Bisecting creates a palette of k colors like Create, but with bisecting k-means (see kmeans.Clustering.Bisect)
+// instead of KMTimes random restarts: the group of colors with the largest spread is split in two until there are
+// k of them. This gives more stable palettes, and suits images with skewed colors (like night photos) better.
+func Bisecting(img image.Image, k int) color.Palette {
+	settings := CurrentSettings()
+	rng := settings.random()
+	pointSet := settings.samplePoints(img, rng)
+	if k < 1 {
+		return color.Palette{}
+	}
+
+	KM, err := kmeans.NewClustering(pointSet, k, settings.Metric.Distance, rng)
+	if err != nil {
+		return color.Palette{}
+	}
+	KM.SetOptions(settings.KMOptions)
+	KM.Pin(settings.pinnedPoints()...)
+	KM.Bisect(settings.KMAccuracy, settings.KMConsecutive)
+
+	palette := color.Palette{}
+	for _, mean := range KM.KMeans.Points {
+		palette = append(palette, pointToRGBA(mean))
+	}
+
+	return palette
+}
@@ -0,0 +1,158 @@
+package colorpalette
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bitDepthName matches the names of the generated palettes, like rgb332, rgb565, gray-4 or gray-16-gamma-2.2
+var bitDepthName = regexp.MustCompile(`^(?:rgb(\d)(\d)(\d)|gr[ae]y-?(\d+)(?:-gamma-(\d+(?:\.\d+)?))?)$`)
+
+// MaxBitDepth is the most bits that the channels of an rgbRGB palette name (see ParseBitDepth) add up to,
+// which makes rgb565 (65536 colors) the largest of them. Larger palettes are too large to dither with.
+const MaxBitDepth = 16
+
+// ErrNotBitDepth is returned by ParseBitDepth for names that aren't bit depth palette names at all
+var ErrNotBitDepth = errors.New("colorpalette: not the name of a bit depth palette")
+
+// RGB returns the uniform palette of a display with the given amount of bits for red, green and blue,
+// like RGB(3, 3, 2) for RGB332. The levels of each channel are spread evenly over 0-255 (and rounded),
+// so the highest level is full intensity. Colors are ordered by red, then green, then blue.
+// Bit depths are clamped to 1-8; note that more than 8 bits in total gives more than 256 colors.
+func RGB(redBits, greenBits, blueBits int) ColorPalette {
+	reds := levels(1 << clampBits(redBits))
+	greens := levels(1 << clampBits(greenBits))
+	blues := levels(1 << clampBits(blueBits))
+
+	palette := ColorPalette{
+		Name:   fmt.Sprintf("rgb%d%d%d", clampBits(redBits), clampBits(greenBits), clampBits(blueBits)),
+		Colors: make([]color.RGBA, 0, len(reds)*len(greens)*len(blues)),
+	}
+
+	for _, r := range reds {
+		for _, g := range greens {
+			for _, b := range blues {
+				palette.Colors = append(palette.Colors, color.RGBA{r, g, b, 255})
+			}
+		}
+	}
+
+	return palette
+}
+
+// RGB332 returns the 256 colors of 8-bit displays: 3 bits for red and green, 2 bits for blue
+func RGB332() ColorPalette {
+	return RGB(3, 3, 2)
+}
+
+// RGB565 returns the 65536 colors of 16-bit displays (like the ST7735): 5 bits for red and blue, 6 bits for green.
+// This is too many colors for a paletted image: dither with process.ApplyErrorDiffusionRGBA.
+func RGB565() ColorPalette {
+	return RGB(5, 6, 5)
+}
+
+// Gray returns a ramp of levels grays from black to white, like Gray(2, 1) for monochrome displays (SSD1306)
+// or Gray(4, 1) for 2-bit grayscale. levels is at least 2 and at most 256.
+//
+// Gray i gets the value 255 * (i / (levels-1))^(1/gamma): a gamma of 1 spaces the values evenly,
+// a gamma of 2.2 spaces the grays evenly in linear light instead (like the physical levels of e-ink displays),
+// which makes the dark end lighter. A gamma of 0 or less counts as 1.
+func Gray(levels int, gamma float64) ColorPalette {
+	if levels < 2 {
+		levels = 2
+	}
+	if levels > 256 {
+		levels = 256
+	}
+	if gamma <= 0 {
+		gamma = 1
+	}
+
+	name := fmt.Sprintf("gray-%d", levels)
+	if gamma != 1 {
+		name += fmt.Sprintf("-gamma-%g", gamma)
+	}
+
+	palette := ColorPalette{
+		Name:   name,
+		Colors: make([]color.RGBA, 0, levels),
+	}
+
+	for i := 0; i < levels; i++ {
+		value := uint8(math.Round(255 * math.Pow(float64(i)/float64(levels-1), 1/gamma)))
+		palette.Colors = append(palette.Colors, color.RGBA{value, value, value, 255})
+	}
+
+	return palette
+}
+
+// levels returns n values spread evenly over 0-255
+func levels(n int) []uint8 {
+	values := make([]uint8, n)
+	for i := range values {
+		values[i] = uint8(math.Round(float64(i) * 255 / float64(n-1)))
+	}
+
+	return values
+}
+
+// clampBits limits the bit depth of one channel to 1-8
+func clampBits(bits int) int {
+	if bits < 1 {
+		return 1
+	}
+	if bits > 8 {
+		return 8
+	}
+
+	return bits
+}
+
+// ParseBitDepth generates the palette with the given name, if it is one of the bit depth palettes:
+// rgbRGB (like rgb332 or rgb565, with 1-8 bits per channel and at most MaxBitDepth in total) or gray-N
+// (with 2-256 levels), optionally followed by -gamma-G (see Gray). Names of another form give ErrNotBitDepth,
+// names of this form with bits or levels out of range another error.
+func ParseBitDepth(name string) (ColorPalette, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	match := bitDepthName.FindStringSubmatch(name)
+	if match == nil {
+		return ColorPalette{}, ErrNotBitDepth
+	}
+
+	if match[4] != "" {
+		n, err := strconv.Atoi(match[4])
+		if err != nil || n < 2 || n > 256 {
+			return ColorPalette{}, fmt.Errorf("colorpalette: %s needs 2 to 256 levels of gray, not %s", name, match[4])
+		}
+
+		gamma := 1.0
+		if match[5] != "" {
+			gamma, err = strconv.ParseFloat(match[5], 64)
+			if err != nil || gamma <= 0 {
+				return ColorPalette{}, fmt.Errorf("colorpalette: %s needs a gamma above 0, not %s", name, match[5])
+			}
+		}
+
+		return Gray(n, gamma), nil
+	}
+
+	bits := [3]int{}
+	total := 0
+	for i := range bits {
+		bits[i], _ = strconv.Atoi(match[1+i])
+		if bits[i] < 1 || bits[i] > 8 {
+			return ColorPalette{}, fmt.Errorf("colorpalette: %s needs 1 to 8 bits per channel", name)
+		}
+		total += bits[i]
+	}
+	if total > MaxBitDepth {
+		return ColorPalette{}, fmt.Errorf("colorpalette: %s has %d bits (%d colors), at most %d bits are supported", name, total, 1<<total, MaxBitDepth)
+	}
+
+	return RGB(bits[0], bits[1], bits[2]), nil
+}
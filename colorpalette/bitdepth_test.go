@@ -0,0 +1,95 @@
+package colorpalette
+
+import (
+	"errors"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestParseBitDepth(t *testing.T) {
+	names := []struct {
+		name   string
+		want   string
+		colors int
+	}{
+		{"rgb111", "rgb111", 8},
+		{"rgb332", "rgb332", 256},
+		{"RGB565", "rgb565", 65536},
+		{"rgb844", "rgb844", 65536},
+		{"gray-2", "gray-2", 2},
+		{"grey16", "gray-16", 16},
+		{" gray-256 ", "gray-256", 256},
+		{"gray-4-gamma-2.2", "gray-4-gamma-2.2", 4},
+	}
+	for _, tt := range names {
+		palette, err := ParseBitDepth(tt.name)
+		if err != nil {
+			t.Errorf("%q: %v", tt.name, err)
+			continue
+		}
+		if palette.Name != tt.want || len(palette.Colors) != tt.colors {
+			t.Errorf("%q is %s with %d colors, want %s with %d", tt.name, palette.Name, len(palette.Colors), tt.want, tt.colors)
+		}
+	}
+
+	rgb332, _ := ParseBitDepth("rgb332")
+	if first, last := rgb332.Colors[0], rgb332.Colors[255]; first != (color.RGBA{0, 0, 0, 255}) || last != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("rgb332 goes from %v to %v, not from black to white", first, last)
+	}
+}
+
+func TestParseBitDepthErrors(t *testing.T) {
+	for _, name := range []string{"rgb888", "rgb666", "rgb755", "rgb039", "rgb190", "gray-1", "gray-257", "gray-99999999999999999999", "gray-4-gamma-0"} {
+		_, err := ParseBitDepth(name)
+		if err == nil || errors.Is(err, ErrNotBitDepth) {
+			t.Errorf("%q: error %v, want one about its bits or levels", name, err)
+		}
+		if _, ok := Named(name); ok {
+			t.Errorf("%q is a named palette", name)
+		}
+	}
+
+	for _, name := range []string{"pico-8", "rgb", "rgb33", "rgb3322", "gray", "gray-4-gamma", "hsv332"} {
+		if _, err := ParseBitDepth(name); !errors.Is(err, ErrNotBitDepth) {
+			t.Errorf("%q: error %v, want ErrNotBitDepth", name, err)
+		}
+	}
+}
+
+func TestGray(t *testing.T) {
+	ramps := []struct {
+		levels int
+		gamma  float64
+		want   []uint8
+	}{
+		{2, 1, []uint8{0, 255}},
+		{4, 1, []uint8{0, 85, 170, 255}},
+		// a gamma above 1 makes the dark end lighter
+		{4, 2.2, []uint8{0, 155, 212, 255}},
+		// a gamma of 0 or less counts as 1
+		{4, 0, []uint8{0, 85, 170, 255}},
+		// fewer than 2 levels give black and white
+		{1, 1, []uint8{0, 255}},
+	}
+	for _, tt := range ramps {
+		palette := Gray(tt.levels, tt.gamma)
+		values := []uint8{}
+		for _, clr := range palette.Colors {
+			if clr.R != clr.G || clr.R != clr.B || clr.A != 255 {
+				t.Errorf("Gray(%d, %v) has the color %v, which is not an opaque gray", tt.levels, tt.gamma, clr)
+			}
+			values = append(values, clr.R)
+		}
+		if !reflect.DeepEqual(values, tt.want) {
+			t.Errorf("Gray(%d, %v) has the values %v, want %v", tt.levels, tt.gamma, values, tt.want)
+		}
+	}
+
+	if palette := Gray(300, 1); len(palette.Colors) != 256 || palette.Name != "gray-256" {
+		t.Errorf("Gray(300, 1) is %s with %d colors, want gray-256 with 256", palette.Name, len(palette.Colors))
+	}
+	if palette := Gray(16, 2.2); palette.Name != "gray-16-gamma-2.2" {
+		t.Errorf("Gray(16, 2.2) is named %s, want gray-16-gamma-2.2", palette.Name)
+	}
+}
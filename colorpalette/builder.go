@@ -0,0 +1,43 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mielpeeters/dither/kmeans"
+)
+
+// PaletteBuilder creates one palette of k colors from any amount of images (like the frames of a long video),
+// added one by one. Unlike CreateFromImages, the sampled pixels aren't kept: they update an online
+// k-means clustering (see kmeans.Online), so the memory use doesn't grow with the amount of images.
+type PaletteBuilder struct {
+	online   *kmeans.Online
+	settings Settings
+}
+
+// NewPaletteBuilder creates a PaletteBuilder for a palette of k colors, using KMOptions and PinnedColors
+func NewPaletteBuilder(k int) *PaletteBuilder {
+	settings := CurrentSettings()
+	online := kmeans.NewOnline(k, settings.Metric.Distance, settings.random())
+	online.Accuracy, online.Consecutive = settings.KMAccuracy, settings.KMConsecutive
+	online.SetOptions(settings.KMOptions)
+	online.Pin(settings.pinnedPoints()...)
+
+	return &PaletteBuilder{online, settings}
+}
+
+// Add samples the pixels of img, according to Sampling, and adds them to the palette
+func (builder *PaletteBuilder) Add(img image.Image) {
+	samples := builder.settings.samplePoints(img, builder.settings.random())
+	builder.online.Add(samples.Points...)
+}
+
+// Palette returns the palette of the images that were added so far
+func (builder *PaletteBuilder) Palette() color.Palette {
+	palette := color.Palette{}
+	for _, mean := range builder.online.Means().Points {
+		palette = append(palette, pointToRGBA(mean))
+	}
+
+	return palette
+}
@@ -0,0 +1,106 @@
+package colorpalette
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+// CacheDir is the directory in which created palettes are cached, so that creating a palette for the same
+// image again (with the same k and settings) reuses the result instead of running the k-means algorithm.
+// Caching is off when it is empty, which is the default. Rand is not part of the key: a cached palette
+// is reused regardless of the seed.
+var CacheDir = ""
+
+// readCache returns the cached palette with the given key, if there is one
+func (settings Settings) readCache(key string) (ColorPalette, bool) {
+	data, err := os.ReadFile(filepath.Join(settings.CacheDir, key+".json"))
+	if err != nil {
+		return ColorPalette{}, false
+	}
+
+	palette := ColorPalette{}
+	if err := json.Unmarshal(data, &palette); err != nil || len(palette.Colors) == 0 {
+		return ColorPalette{}, false
+	}
+
+	return palette, true
+}
+
+// writeCache stores the palette under the given key.
+// Caching is best effort, a failure only means clustering again next time. The palette is renamed into place,
+// so that a palette that is written at the same time is never read half-way.
+func (settings Settings) writeCache(key string, palette ColorPalette) {
+	output, err := json.Marshal(palette)
+	if err != nil {
+		return
+	}
+
+	if os.MkdirAll(settings.CacheDir, 0755) != nil {
+		return
+	}
+	file, err := os.CreateTemp(settings.CacheDir, "*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(output)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(file.Name(), filepath.Join(settings.CacheDir, key+".json")) != nil {
+		os.Remove(file.Name())
+	}
+}
+
+// cacheKey hashes the content of the images together with k and everything else that changes the palette
+func (settings Settings) cacheKey(imgs []image.Image, k int) string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "k=%d metric=%s sampling=%d factor=%d times=%d accuracy=%g consecutive=%d options=%+v minweight=%g pinned=%v\n",
+		k, settings.Metric.Name, settings.Sampling, settings.SampleFactor, settings.KMTimes, settings.KMAccuracy, settings.KMConsecutive, settings.KMOptions, settings.MinWeight, settings.PinnedColors)
+
+	if settings.Sampling == SampleMask || settings.Sampling == SampleSaliency {
+		if settings.SaliencyMask != nil {
+			hashImage(h, settings.SaliencyMask)
+		}
+	}
+
+	for _, img := range imgs {
+		hashImage(h, img)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashImage writes the bounds and the pixels of img to h
+func hashImage(h hash.Hash, img image.Image) {
+	bounds := img.Bounds()
+	fmt.Fprintf(h, "%v\n", bounds)
+
+	if rgba, ok := img.(*image.RGBA); ok {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			start := rgba.PixOffset(bounds.Min.X, y)
+			h.Write(rgba.Pix[start : start+4*bounds.Dx()])
+		}
+		return
+	}
+
+	row := make([]byte, 8*bounds.Dx())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			i := 8 * (x - bounds.Min.X)
+			binary.LittleEndian.PutUint16(row[i:], uint16(r))
+			binary.LittleEndian.PutUint16(row[i+2:], uint16(g))
+			binary.LittleEndian.PutUint16(row[i+4:], uint16(b))
+			binary.LittleEndian.PutUint16(row[i+6:], uint16(a))
+		}
+		h.Write(row)
+	}
+}
@@ -1,21 +1,30 @@
 package colorpalette
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"io/ioutil"
 	"math"
+	"math/rand"
+	"os"
+	"time"
 
 	"github.com/mielpeeters/dither/geom"
 	"github.com/mielpeeters/dither/kmeans"
 )
 
-// ColorPalette contains name and colors of one colorpalette
+// ColorPalette contains name and colors of one colorpalette.
+// In JSON, the colors are stored as [R, G, B, A] arrays (see MarshalJSON).
 type ColorPalette struct {
-	Name   string  `json:"name"`
-	Colors [][]int `json:"colors"`
+	Name   string       `json:"name"`
+	Colors []color.RGBA `json:"colors"`
+	// Transparent adds a fully transparent color after Colors, for the transparent parts of images.
+	// Dithering gives it to pixels that are (mostly) transparent, and GIFs use it as their transparent index.
+	Transparent bool `json:"transparent,omitempty"`
 }
 
 // KMAccuracy is he accuracy needed for convergence of the k-means algorithm used in function Create
@@ -25,51 +34,60 @@ var KMAccuracy = 0.01
 // used in function Create
 var KMConsecutive = 2
 
-// SampleFactor describes the fraction of pixels to be used in creating a palette.
+// SampleFactor describes the fraction of pixels to be used in creating a palette. Values below 1 take every pixel.
 var SampleFactor = 5
 
 // KMTimes descibes how many times the Kmeans algorithm needs to be run with random start
 var KMTimes = 3
 
+// KMOptions are the options of the k-means algorithm used in function Create, see kmeans.Options.
+// Fields that are left at 0 use kmeans.DefaultOptions. A smaller batch size or iteration limit
+// gives faster (but less accurate) palettes, for example when creating one per frame of a video.
+var KMOptions kmeans.Options
+
+// Rand is the source of randomness used for sampling and for the random starts of the k-means algorithm.
+// If it is nil, a source seeded with the current time is used, so every run gives a different palette.
+// Set it to rand.New(rand.NewSource(seed)) to get reproducible palettes. It must not be used concurrently.
+// To create palettes concurrently, give each of them Settings with a Rand of its own.
+var Rand *rand.Rand
+
+// PinnedColors are colors that are guaranteed to be part of palettes made by Create and CreatePLT.
+// They take up the first slots of the palette, the k-means algorithm fills in the remaining ones.
+var PinnedColors []color.Color
+
 // Create creates a new colorpalette using the k-means clustering algorithm
 //
 //   - samplefactor: how many pixles to skip, during sampling for the creatrion of the KMeans problem's cluster points
 //     (higher means faster, because less points to iterate over)
 //   - kmTimes defines the amount of times to start the k-means algorithm with random init, the best output is choosen
+//   - Sampling defines which pixels are taken, see SampleStrategy
+//   - Rand makes the result reproducible, if set
+//   - CacheDir makes it reuse the palette created earlier for the same image, if set
+//
+// An image without pixels gives an empty palette, CreateContext returns kmeans.ErrNoPoints for it instead.
 func Create(img image.Image, k int) color.Palette {
-	pointSet := geom.PointSet{}
-	// sample only 1/samplefactor of the pixels
-	for x := 0; x < img.Bounds().Max.X; x += SampleFactor {
-		for y := 0; y < img.Bounds().Max.Y; y += SampleFactor {
-			newPoint := colorToPoint(img.At(x, y))
-			newPoint.ID = x + y*img.Bounds().Max.X
-
-			pointSet.Points = append(pointSet.Points, newPoint)
-		}
-	}
-
-	var colorPalettes []ColorPalette
-	var errors []float64
-
-	// do the algorithm kmTimes
-	for i := 0; i < KMTimes; i++ {
-		KM := kmeans.CreateKMeansProblem(pointSet, k, geom.RedMeanDistance)
+	colorPalette, _ := CurrentSettings().create(context.Background(), []image.Image{img}, k, nil)
 
-		KM.Cluster(KMAccuracy, KMConsecutive)
-
-		colorPalette := ColorPalette{}
-		for index := range KM.KMeans.Points {
-			colorPalette.Colors = append(colorPalette.Colors, pointToColorSlice(KM.KMeans.Points[index]))
-		}
-
-		colorPalettes = append(colorPalettes, colorPalette)
-		errors = append(errors, KM.TotalDist())
-	}
+	return colorPalette.ToPalette()
+}
 
-	// now select the colorpalette with the lowest error!
-	minIndex := findMinIndex(errors)
+// Iteration describes the progress of palette creation, after one iteration of the k-means algorithm
+type Iteration struct {
+	// Restart is the run of the k-means algorithm, from 0 up to KMTimes
+	Restart int
+	// Iteration is the iteration within that run, starting at 1
+	Iteration int
+	// Error is the total distance from the sampled colors to their closest palette color
+	Error float64
+}
 
-	return colorPalettes[minIndex].ToPalette()
+// CreateContext creates a new colorpalette like Create, and calls onIteration (which may be nil)
+// after every iteration of the k-means algorithm, so that callers can show the progress.
+//
+// When ctx is cancelled, the creation stops after the current iteration, and the error of ctx is returned.
+// An image without pixels gives kmeans.ErrNoPoints.
+func CreateContext(ctx context.Context, img image.Image, k int, onIteration func(Iteration)) (color.Palette, error) {
+	return CurrentSettings().CreateContext(ctx, img, k, onIteration)
 }
 
 // CreatePLT creates a new colorpalette using the k-means clustering algorithm
@@ -77,121 +95,130 @@ func Create(img image.Image, k int) color.Palette {
 //   - samplefactor: how many pixles to skip, during sampling for the creatrion of the KMeans problem's cluster points
 //     (higher means faster, because less points to iterate over)
 //   - kmTimes defines the amount of times to start the k-means algorithm with random init, the best output is choosen
+//   - Sampling defines which pixels are taken, see SampleStrategy
+//   - Rand makes the result reproducible, if set
+//   - CacheDir makes it reuse the palette created earlier for the same image, if set
+//
+// An image without pixels gives an empty palette, like Create.
 func CreatePLT(img image.Image, k int) ColorPalette {
-	pointSet := geom.PointSet{}
-	// sample only 1/samplefactor of the pixels
-	for x := 0; x < img.Bounds().Max.X; x += SampleFactor {
-		for y := 0; y < img.Bounds().Max.Y; y += SampleFactor {
-			newPoint := colorToPoint(img.At(x, y))
-			newPoint.ID = x + y*img.Bounds().Max.X
-
-			pointSet.Points = append(pointSet.Points, newPoint)
-		}
-	}
+	colorPalette, _ := CurrentSettings().create(context.Background(), []image.Image{img}, k, nil)
 
-	var colorPalettes []ColorPalette
-	var errors []float64
+	return colorPalette
+}
 
-	// do the algorithm kmTimes
-	for i := 0; i < KMTimes; i++ {
-		KM := kmeans.CreateKMeansProblem(pointSet, k, geom.RedMeanDistance)
+// CreateFromImages creates one colorpalette for all of the images, like Create does for one image.
+// The pixels of all inputs are sampled before clustering, so that the palette isn't biased toward one of them.
+// Images without pixels give an empty palette, CreateFromImagesContext returns kmeans.ErrNoPoints for them instead.
+func CreateFromImages(imgs []image.Image, k int) color.Palette {
+	colorPalette, _ := CurrentSettings().create(context.Background(), imgs, k, nil)
 
-		KM.Cluster(KMAccuracy, KMConsecutive)
+	return colorPalette.ToPalette()
+}
 
-		colorPalette := ColorPalette{}
-		for index := range KM.KMeans.Points {
-			colorPalette.Colors = append(colorPalette.Colors, pointToColorSlice(KM.KMeans.Points[index]))
-		}
+// CreateFromImagesContext creates one colorpalette for all of the images like CreateFromImages, but returns
+// the error of ctx when it is cancelled, and kmeans.ErrNoPoints when the images have no pixels to sample.
+func CreateFromImagesContext(ctx context.Context, imgs []image.Image, k int) (color.Palette, error) {
+	return CurrentSettings().CreateFromImagesContext(ctx, imgs, k)
+}
 
-		colorPalettes = append(colorPalettes, colorPalette)
-		errors = append(errors, KM.TotalDist())
+// random returns the Rand of the settings, or a new source seeded with the current time if it isn't set
+func (settings Settings) random() *rand.Rand {
+	if settings.Rand != nil {
+		return settings.Rand
 	}
 
-	// now select the colorpalette with the lowest error!
-	minIndex := findMinIndex(errors)
-
-	return colorPalettes[minIndex]
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
 }
 
-// Traverse is used to find colours on one line in the image
-func (colorpalette *ColorPalette) Traverse(img *image.Image, ltr bool, name string) (ColorPalette, int) {
-	var step image.Point
-	var maxX, maxY int
-	var currentPix image.Point
-	palette := ColorPalette{
-		Name:   name,
-		Colors: [][]int{},
-	}
-
-	maxBounds := (*img).Bounds().Max
-	maxX = maxBounds.X
-	maxY = maxBounds.Y
-
-	if ltr {
-		step = image.Point{1, 0}
-		currentPix = image.Point{
-			X: 0,
-			Y: maxBounds.Y / 2,
+// create samples the images and clusters them into a palette of k colors, using the cache if CacheDir is set
+func (settings Settings) create(ctx context.Context, imgs []image.Image, k int, onIteration func(Iteration)) (ColorPalette, error) {
+	key := ""
+	if settings.CacheDir != "" {
+		key = settings.cacheKey(imgs, k)
+		if palette, ok := settings.readCache(key); ok {
+			return palette, nil
 		}
+	}
+
+	rng := settings.random()
+
+	// sample only a fraction of the pixels, according to the Sampling strategy
+	var pointSet geom.PointSet
+	if len(imgs) == 1 {
+		pointSet = settings.samplePoints(imgs[0], rng)
 	} else {
-		step = image.Point{0, 1}
-		currentPix = image.Point{
-			X: maxBounds.X / 2,
-			Y: 0,
+		for _, img := range imgs {
+			samples := settings.samplePoints(img, rng)
+
+			// the IDs need to be unique over all of the images
+			for _, point := range samples.Points {
+				point.ID = len(pointSet.Points)
+				pointSet.Points = append(pointSet.Points, point)
+			}
 		}
 	}
 
-	consecutiveSame := 0
-
-	white := color.RGBA{
-		R: 255,
-		G: 255,
-		B: 255,
-		A: 255,
+	palette, err := settings.cluster(ctx, pointSet, k, rng, onIteration)
+	if err != nil {
+		return palette, err
 	}
 
-	currentColor := color.RGBA{
-		R: 255,
-		G: 255,
-		B: 255,
-		A: 255,
+	if key != "" {
+		settings.writeCache(key, palette)
 	}
 
-	for currentPix.X < maxX && currentPix.Y < maxY {
-		clr := (*img).At(currentPix.X, currentPix.Y)
-		clrRGBA := ToRGBA(clr)
+	return palette, nil
+}
 
-		if clrRGBA == currentColor {
-			consecutiveSame++
-		} else {
-			consecutiveSame = 0
-			currentColor = clrRGBA
-		}
+// cluster runs the k-means algorithm KMTimes (of the settings) on the pointSet, and returns the colorpalette with the lowest error.
+// onIteration may be nil, the error of ctx is returned when it is cancelled.
+func (settings Settings) cluster(ctx context.Context, pointSet geom.PointSet, k int, rng *rand.Rand, onIteration func(Iteration)) (ColorPalette, error) {
+	KM, err := kmeans.NewClustering(pointSet, k, settings.Metric.Distance, rng)
+	if err != nil {
+		return ColorPalette{}, err
+	}
+	KM.SetOptions(settings.KMOptions)
+	KM.Pin(settings.pinnedPoints()...)
 
-		if consecutiveSame == 8 && currentColor != white {
-			clrArray := []int{
-				int(currentColor.R),
-				int(currentColor.G),
-				int(currentColor.B),
-				int(currentColor.A),
-			}
-			palette.Colors = append(palette.Colors, clrArray)
+	var report func(int, kmeans.IterationStats)
+	if onIteration != nil {
+		report = func(run int, stats kmeans.IterationStats) {
+			onIteration(Iteration{Restart: run, Iteration: stats.Iteration, Error: stats.TotalDist})
 		}
+	}
 
-		currentPix = currentPix.Add(step)
+	best, _, err := KM.ClusterBestContext(ctx, settings.KMTimes, settings.KMAccuracy, settings.KMConsecutive, report)
+	if err != nil {
+		return ColorPalette{}, err
+	}
+
+	colorPalette := ColorPalette{}
+	for index := range best.KMeans.Points {
+		colorPalette.Colors = append(colorPalette.Colors, pointToRGBA(best.KMeans.Points[index]))
 	}
 
-	return palette, len(palette.Colors)
+	return colorPalette, nil
 }
 
-func pointToColorSlice(point geom.Point) []int {
-	returnValue := []int{}
+// pinnedPoints converts the PinnedColors of the settings to points, to be pinned in a k-means problem
+func (settings Settings) pinnedPoints() []geom.Point {
+	points := []geom.Point{}
+
+	for _, clr := range settings.PinnedColors {
+		points = append(points, colorToPoint(clr))
+	}
+
+	return points
+}
 
-	for _, value := range point.Coordinates {
-		returnValue = append(returnValue, int(value))
+// pointToRGBA converts a cluster center back to a color
+func pointToRGBA(point geom.Point) color.RGBA {
+	values := make([]float64, len(point.Coordinates))
+	for i, value := range point.Coordinates {
+		values[i] = float64(value)
 	}
 
-	return returnValue
+	return fromChannels(values)
 }
 
 func colorToPoint(clr color.Color) geom.Point {
@@ -205,27 +232,53 @@ func colorToPoint(clr color.Color) geom.Point {
 	return point
 }
 
-func findMinIndex(arr []float64) int {
-	min := math.Inf(1) // Initialize min with the highest possible float64 value
-	minIndex := 0      // Initialize minIndex with 0
-	for i, v := range arr {
-		if v < min {
-			min = v
-			minIndex = i
-		}
+// GetPalettesFromJSON returns a slice of ColorPalettes after reading them from a JSON file.
+// Errors are ignored (giving no palettes), use ReadPalettes to handle them.
+func GetPalettesFromJSON(jsonFileName string) []ColorPalette {
+	file, err := os.Open(jsonFileName)
+	if err != nil {
+		return []ColorPalette{}
+	}
+	defer file.Close()
+
+	data, err := ReadPalettes(file)
+	if err != nil {
+		return []ColorPalette{}
 	}
-	return minIndex
+
+	return data
 }
 
-// GetPalettesFromJSON returns a slice of ColorPalettes after reading them from a JSON file.
-func GetPalettesFromJSON(jsonFileName string) []ColorPalette {
-	file, _ := ioutil.ReadFile(jsonFileName)
+// ReadPalettes reads the palettes from JSON: either a list of palettes, or a single palette (as written by Write)
+func ReadPalettes(r io.Reader) ([]ColorPalette, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
 
 	data := []ColorPalette{}
+	if err := json.Unmarshal(raw, &data); err == nil {
+		return data, nil
+	}
 
-	_ = json.Unmarshal([]byte(file), &data)
+	var single ColorPalette
+	if err := json.Unmarshal(raw, &single); err != nil {
+		return nil, err
+	}
 
-	return data
+	return []ColorPalette{single}, nil
+}
+
+// Write writes the ColorPalette to w, as formatted JSON
+func (colorpalette *ColorPalette) Write(w io.Writer) error {
+	output, err := json.MarshalIndent(colorpalette, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(output)
+
+	return err
 }
 
 // GetPaletteWithName returns a specific from a slice of ColorPalette.
@@ -238,44 +291,39 @@ func GetPaletteWithName(name string, palettes []ColorPalette) *ColorPalette {
 		}
 	}
 
-	black := []int{0, 0, 0, 255}
-	colors := [][]int{black}
+	black := color.RGBA{0, 0, 0, 255}
+	colors := []color.RGBA{black}
 
 	val := ColorPalette{
-		"New",
-		colors,
+		Name:   "New",
+		Colors: colors,
 	}
 	return &val
 }
 
 // ToJSONFile writes the given ColorPalette out to the specified path, as a JSON file (formatted).
-func (colorpalette *ColorPalette) ToJSONFile(jsonFileName string) {
-	output, err := json.MarshalIndent(colorpalette, "", "  ")
+func (colorpalette *ColorPalette) ToJSONFile(jsonFileName string) error {
+	file, err := os.Create(jsonFileName)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
-	err = ioutil.WriteFile(jsonFileName, output, 0644)
-
-	if err != nil {
-		fmt.Println(err)
+	if err := colorpalette.Write(file); err != nil {
+		file.Close()
+		return err
 	}
+
+	return file.Close()
 }
 
-// ToJSONFileNoIndent writes the given ColorPalette out to the specified path, as a JSON file (formatted).
-func (colorpalette *ColorPalette) ToJSONFileNoIndent(jsonFileName string) {
+// ToJSONFileNoIndent writes the given ColorPalette out to the specified path, as a JSON file (not formatted).
+func (colorpalette *ColorPalette) ToJSONFileNoIndent(jsonFileName string) error {
 	output, err := json.Marshal(colorpalette)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
-	err = ioutil.WriteFile(jsonFileName, output, 0644)
-
-	if err != nil {
-		fmt.Println(err)
-	}
+	return ioutil.WriteFile(jsonFileName, output, 0644)
 }
 
 // ConvRGBAtoHSLA converts between RGBA and HSLA color formats
@@ -370,20 +418,101 @@ func ConvHSLAtoRGBA(hsla []float64) []float64 {
 	return output
 }
 
+// ConvRGBAtoLABA converts between RGBA and CIE L*a*b* (plus alpha) color formats, using the D65 white point
+func ConvRGBAtoLABA(rgba []float64) []float64 {
+	// linearize the sRGB components
+	linear := make([]float64, 3)
+	for i := 0; i < 3; i++ {
+		c := rgba[i] / 255.0
+		if c <= 0.04045 {
+			linear[i] = c / 12.92
+		} else {
+			linear[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+
+	// to XYZ, relative to the D65 reference white
+	x := (0.4124*linear[0] + 0.3576*linear[1] + 0.1805*linear[2]) / 0.95047
+	y := (0.2126*linear[0] + 0.7152*linear[1] + 0.0722*linear[2]) / 1.0
+	z := (0.0193*linear[0] + 0.1192*linear[1] + 0.9505*linear[2]) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+
+	fx, fy, fz := f(x), f(y), f(z)
+
+	output := []float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz), rgba[3]}
+
+	return output
+}
+
+// ConvLABAtoRGBA converts between CIE L*a*b* (plus alpha) and RGBA color formats, using the D65 white point.
+// Colors outside of the sRGB gamut are clipped.
+func ConvLABAtoRGBA(laba []float64) []float64 {
+	fy := (laba[0] + 16) / 116
+	fx := fy + laba[1]/500
+	fz := fy - laba[2]/200
+
+	finv := func(t float64) float64 {
+		if t*t*t > 216.0/24389.0 {
+			return t * t * t
+		}
+		return (116*t - 16) * 27.0 / 24389.0
+	}
+
+	x := finv(fx) * 0.95047
+	y := finv(fy) * 1.0
+	z := finv(fz) * 1.08883
+
+	linear := []float64{
+		3.2406*x - 1.5372*y - 0.4986*z,
+		-0.9689*x + 1.8758*y + 0.0415*z,
+		0.0557*x - 0.2040*y + 1.0570*z,
+	}
+
+	output := []float64{0, 0, 0, laba[3]}
+	for i, c := range linear {
+		if c <= 0.0031308 {
+			c = 12.92 * c
+		} else {
+			c = 1.055*math.Pow(c, 1/2.4) - 0.055
+		}
+		output[i] = math.Max(0, math.Min(255, c*255))
+	}
+
+	return output
+}
+
+// DeltaE returns the CIE76 color difference between two RGBA colors.
+// A difference of about 2.3 is considered just noticeable.
+func DeltaE(left, right color.RGBA) float64 {
+	leftLab := ConvRGBAtoLABA(channels(left))
+	rightLab := ConvRGBAtoLABA(channels(right))
+
+	var dist float64
+	for i := 0; i < 3; i++ {
+		dist += (leftLab[i] - rightLab[i]) * (leftLab[i] - rightLab[i])
+	}
+
+	return math.Sqrt(dist)
+}
+
 // ToPalette converts between this custom ColorPalette and the
-// Go standard library color.Palette type struct
+// Go standard library color.Palette type struct.
+// If the palette is Transparent, its last color is color.RGBA{} (fully transparent).
 func (colorpalette *ColorPalette) ToPalette() color.Palette {
 	colors := []color.Color{}
-	var paletteColor color.Color
-
-	for i := 0; i < len(colorpalette.Colors); i++ {
-		paletteColor = color.RGBA{
-			uint8(colorpalette.Colors[i][0]),
-			uint8(colorpalette.Colors[i][1]),
-			uint8(colorpalette.Colors[i][2]),
-			uint8(colorpalette.Colors[i][3]),
-		}
-		colors = append(colors, paletteColor)
+
+	for _, clr := range colorpalette.Colors {
+		colors = append(colors, clr)
+	}
+
+	if colorpalette.Transparent {
+		colors = append(colors, color.RGBA{})
 	}
 
 	return colors
@@ -414,7 +543,7 @@ func BW() color.Palette {
 func ToRGBA(origColor color.Color) color.RGBA {
 	orig, ok := color.RGBAModel.Convert(origColor).(color.RGBA)
 	if !ok {
-		fmt.Println("type conversion (to rgba color) went wrong")
+		fmt.Fprintln(os.Stderr, "type conversion (to rgba color) went wrong")
 	}
 	return orig
 }
@@ -1,16 +1,27 @@
 package colorpalette
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/kmeans"
 	"github.com/mielpeeters/dither/process"
+	"github.com/mielpeeters/dither/testgen"
 )
 
 var img image.Image
@@ -96,3 +107,202 @@ func TestCreateOnce(t *testing.T) {
 
 	fmt.Printf("\n\033[1m\033[32m done: took %.2f seconds\n\n", duration.Seconds())
 }
+
+// TestCreateQuality checks that Create finds the colors of an image with a known optimal palette
+func TestCreateQuality(t *testing.T) {
+	k := 5
+	img, optimal := testgen.GaussianClusters(k, 300, 100, 8, rand.New(rand.NewSource(2)))
+
+	// a seeded Rand, so that the random starts (and the result) are the same on every run
+	settings := CurrentSettings()
+	settings.SampleFactor = 2
+	settings.KMTimes = 10
+	settings.Rand = rand.New(rand.NewSource(1))
+
+	palette, err := settings.CreateContext(context.Background(), img, k, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testgen.MaxCenterError(palette, optimal); err > 6 {
+		t.Errorf("palette is too far from the optimal one: max error %.2f", err)
+	}
+}
+
+// TestSettingsConcurrent checks that palettes created at the same time, each with their own Rand,
+// are the ones that are created one at a time with the same seeds
+func TestSettingsConcurrent(t *testing.T) {
+	img, _ := testgen.GaussianClusters(6, 100, 50, 8, rand.New(rand.NewSource(3)))
+
+	create := func(seed int64) color.Palette {
+		settings := CurrentSettings()
+		settings.Rand = rand.New(rand.NewSource(seed))
+		palette, err := settings.CreateContext(context.Background(), img, 6, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		return palette
+	}
+
+	want := make([]color.Palette, 4)
+	for i := range want {
+		want[i] = create(int64(i))
+	}
+
+	got := make([]color.Palette, len(want))
+	var wg sync.WaitGroup
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = create(int64(i))
+		}(i)
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("concurrent palettes differ from the sequential ones:\n%v\n%v", got, want)
+	}
+}
+
+// TestCreatePinned checks that the pinned colors take the first slots of the palette, as they are
+func TestCreatePinned(t *testing.T) {
+	img, _ := testgen.GaussianClusters(3, 40, 20, 8, rand.New(rand.NewSource(4)))
+
+	settings := CurrentSettings()
+	settings.Rand = rand.New(rand.NewSource(1))
+	settings.PinnedColors = []color.Color{color.RGBA{0, 0, 0, 255}, color.RGBA{255, 0, 0, 255}}
+	palette, err := settings.CreateContext(context.Background(), img, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(palette) != 5 {
+		t.Fatalf("the palette has %d colors, want 5", len(palette))
+	}
+	for i, pinned := range settings.PinnedColors {
+		if palette[i] != pinned {
+			t.Errorf("color %d is %v, want the pinned %v", i, palette[i], pinned)
+		}
+	}
+}
+
+// TestCreateCache checks that a palette is cached on disk and read back for the same image and k,
+// while a different k is created again
+func TestCreateCache(t *testing.T) {
+	img, _ := testgen.GaussianClusters(3, 40, 20, 8, rand.New(rand.NewSource(4)))
+
+	settings := CurrentSettings()
+	settings.Rand = rand.New(rand.NewSource(1))
+	settings.CacheDir = t.TempDir()
+	if _, err := settings.CreateContext(context.Background(), img, 3, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := filepath.Glob(filepath.Join(settings.CacheDir, "*.json"))
+	if err != nil || len(cached) != 1 {
+		t.Fatalf("the cache holds %v, want one palette", cached)
+	}
+
+	// replace the cached palette, which the next call needs to return as is
+	want := color.Palette{color.RGBA{1, 2, 3, 255}, color.RGBA{4, 5, 6, 255}, color.RGBA{7, 8, 9, 255}}
+	data, _ := json.Marshal(FromPalette(want, "cached"))
+	if err := os.WriteFile(cached[0], data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	palette, err := settings.CreateContext(context.Background(), img, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(palette, want) {
+		t.Errorf("the palette is %v, want the cached %v", palette, want)
+	}
+
+	palette, err = settings.CreateContext(context.Background(), img, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(palette) != 4 {
+		t.Errorf("the palette for another k has %d colors, want 4", len(palette))
+	}
+	if cached, _ := filepath.Glob(filepath.Join(settings.CacheDir, "*.json")); len(cached) != 2 {
+		t.Errorf("the cache holds %d palettes, want 2", len(cached))
+	}
+}
+
+// TestCreateNoPixels checks that the error returning variants report an image without pixels
+func TestCreateNoPixels(t *testing.T) {
+	empty := image.NewRGBA(image.Rect(0, 0, 0, 0))
+
+	if _, err := CreateContext(context.Background(), empty, 4, nil); !errors.Is(err, kmeans.ErrNoPoints) {
+		t.Errorf("CreateContext: error %v, want kmeans.ErrNoPoints", err)
+	}
+	if _, err := CreateFromImagesContext(context.Background(), []image.Image{empty, empty}, 4); !errors.Is(err, kmeans.ErrNoPoints) {
+		t.Errorf("CreateFromImagesContext: error %v, want kmeans.ErrNoPoints", err)
+	}
+}
+
+// TestCIEDE2000 checks ciede2000 against test data of Sharma et al. (2005)
+func TestCIEDE2000(t *testing.T) {
+	pairs := []struct {
+		lab1, lab2 []float64
+		diff       float64
+	}{
+		{[]float64{50, 2.6772, -79.7751}, []float64{50, 0, -82.7485}, 2.0425},
+		{[]float64{50, -1.3802, -84.2814}, []float64{50, 0, -82.7485}, 1.0},
+		{[]float64{50, 2.5, 0}, []float64{50, 0, -2.5}, 4.3065},
+		{[]float64{50, 2.49, -0.001}, []float64{50, -2.49, 0.0011}, 7.2195},
+		{[]float64{60.2574, -34.0099, 36.2677}, []float64{60.4626, -34.1751, 39.4387}, 1.2644},
+		{[]float64{90.8027, -2.0831, 1.441}, []float64{91.1528, -1.6435, 0.0447}, 1.4441},
+	}
+
+	for _, pair := range pairs {
+		if diff := ciede2000(pair.lab1, pair.lab2); math.Abs(diff-pair.diff) > 1e-4 {
+			t.Errorf("ciede2000(%v, %v) = %.4f, want %.4f", pair.lab1, pair.lab2, diff, pair.diff)
+		}
+	}
+}
+
+// TestMeasureQuality checks MeasureQuality on identical images, a known offset, and that the blur scores
+// dithering closer than picking the closest color of every pixel
+func TestMeasureQuality(t *testing.T) {
+	defer func(blur int) { QualityBlur = blur }(QualityBlur)
+
+	gray := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	lighter := image.NewRGBA(gray.Rect)
+	gradient := image.NewRGBA(image.Rect(0, 0, 64, 16))
+	for i := range gray.Pix {
+		gray.Pix[i], lighter.Pix[i] = 100, 110
+		if i%4 == 3 {
+			gray.Pix[i], lighter.Pix[i] = 255, 255
+		}
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 64; x++ {
+			gradient.Set(x, y, color.RGBA{uint8(x * 4), uint8(x * 4), uint8(x * 4), 255})
+		}
+	}
+
+	QualityBlur = 0
+	if quality := MeasureQuality(gray, gray); !math.IsInf(quality.PSNR, 1) || quality.SSIM != 1 || quality.MeanDeltaE != 0 {
+		t.Errorf("identical images measure %+v", quality)
+	}
+	if quality := MeasureQuality(gray, lighter); math.Abs(quality.PSNR-28.1308) > 1e-4 {
+		t.Errorf("an offset of 10 has a PSNR of %.4f, want 28.1308", quality.PSNR)
+	}
+
+	// error diffusion changes the image it dithers
+	clone := func() *image.RGBA {
+		copied := *gradient
+		copied.Pix = append([]uint8{}, gradient.Pix...)
+		return &copied
+	}
+
+	QualityBlur = 1
+	bw := BW()
+	dithered := MeasureQuality(gradient, process.ApplyErrorDiffusion(clone(), bw, &process.FloydSteinBerg))
+	nearest := MeasureQuality(gradient, process.ApplyErrorDiffusion(clone(), bw, &process.Nothing))
+	if dithered.MeanDeltaE >= nearest.MeanDeltaE || dithered.PSNR <= nearest.PSNR {
+		t.Errorf("dithering measures %+v, not closer than the closest colors %+v", dithered, nearest)
+	}
+}
@@ -0,0 +1,91 @@
+package colorpalette
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+)
+
+// Quantizer creates a palette of k colors for an image. Images with fewer distinct colors than k can give fewer,
+// see the quantizers themselves.
+type Quantizer func(img image.Image, k int) color.Palette
+
+// NamedQuantizer is a Quantizer with the name it is known by (in the CLI, for example)
+type NamedQuantizer struct {
+	Name     string
+	Quantize Quantizer
+}
+
+// Quantizers are the available quantizers: "kmeans" (Create), "bisecting" (Bisecting), "median-cut" (MedianCut),
+// "octree" (Octree) and "wu" (Wu)
+var Quantizers = []NamedQuantizer{
+	{"kmeans", Create},
+	{"bisecting", Bisecting},
+	{"median-cut", MedianCut},
+	{"octree", Octree},
+	{"wu", Wu},
+}
+
+// QuantizerWithName returns the quantizer with the given name, and whether it exists
+func QuantizerWithName(name string) (Quantizer, bool) {
+	for _, quantizer := range Quantizers {
+		if quantizer.Name == name {
+			return quantizer.Quantize, true
+		}
+	}
+
+	return nil, false
+}
+
+// Comparison is the result of one quantizer in Compare
+type Comparison struct {
+	Quantizer string
+	Palette   color.Palette
+	// Duration is the time it took to create the palette
+	Duration time.Duration
+	// Coverage tells how well the palette suits the image, see Coverage
+	Coverage Coverage
+}
+
+// Compare runs all Quantizers on img, and returns their palettes of k colors together with
+// how long they took and how well they suit the image, so that the best one for the content can be chosen.
+func Compare(img image.Image, k int) []Comparison {
+	comparisons := []Comparison{}
+
+	for _, quantizer := range Quantizers {
+		start := time.Now()
+		palette := quantizer.Quantize(img, k)
+		duration := time.Since(start)
+
+		colorPalette := FromPalette(palette, quantizer.Name)
+		comparisons = append(comparisons, Comparison{
+			Quantizer: quantizer.Name,
+			Palette:   palette,
+			Duration:  duration,
+			Coverage:  colorPalette.Coverage(img),
+		})
+	}
+
+	return comparisons
+}
+
+// FormatComparisons formats the results of Compare as a table
+func FormatComparisons(comparisons []Comparison) string {
+	var table strings.Builder
+
+	fmt.Fprintf(&table, "%-12s %8s %8s %12s %13s %10s\n", "quantizer", "colors", "unused", "mean DeltaE", "worst region", "duration")
+	for _, comparison := range comparisons {
+		worst := 0.0
+		if len(comparison.Coverage.Worst) > 0 {
+			worst = comparison.Coverage.Worst[0].MeanDeltaE
+		}
+
+		fmt.Fprintf(&table, "%-12s %8d %8d %12.2f %13.2f %10s\n",
+			comparison.Quantizer, len(comparison.Palette), comparison.Coverage.Unused,
+			comparison.Coverage.MeanDeltaE, worst, comparison.Duration.Round(time.Millisecond))
+	}
+
+	return table.String()
+}
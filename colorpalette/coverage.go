@@ -0,0 +1,173 @@
+package colorpalette
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strings"
+
+	"github.com/mielpeeters/dither/kdtree"
+)
+
+// CoverageRegionSize is the width and height (in pixels) of the regions that Coverage rates
+var CoverageRegionSize = 32
+
+// CoverageWorstRegions is the amount of worst-served regions that Coverage reports
+var CoverageWorstRegions = 5
+
+// EntryCoverage holds how much one palette color is used for an image
+type EntryCoverage struct {
+	Color color.RGBA
+	// Count is the amount of pixels for which this is the closest palette color, Fraction the share of all pixels
+	Count    int
+	Fraction float64
+	// MeanDeltaE is the mean DeltaE between those pixels and this color (0 if it isn't used)
+	MeanDeltaE float64
+}
+
+// Region is a rectangle of the image, with the mean DeltaE between its pixels and their closest palette colors
+type Region struct {
+	Bounds     image.Rectangle
+	MeanDeltaE float64
+}
+
+// Coverage reports how well a palette suits an image, without dithering: every pixel is matched with its closest
+// palette color. A palette with unused or rarely used colors is larger than needed, a high mean DeltaE
+// (or bad worst regions) means that it lacks colors for (parts of) the image.
+type Coverage struct {
+	// Entries holds the usage of each palette color, in the order of ToPalette
+	Entries []EntryCoverage
+	// Unused is the amount of palette colors that are the closest color of no pixel at all
+	Unused int
+	// MeanDeltaE is the mean DeltaE between the pixels and their closest palette color
+	MeanDeltaE float64
+	// Worst holds the CoverageWorstRegions regions with the highest MeanDeltaE, worst first
+	Worst []Region
+}
+
+// Coverage measures how well the palette covers the colors of img, see Coverage.
+// Pixels that get the transparent color (when the palette is Transparent) don't count towards the DeltaE values.
+func (colorpalette *ColorPalette) Coverage(img image.Image) Coverage {
+	palette := colorpalette.ToPalette()
+	coverage := Coverage{Entries: make([]EntryCoverage, len(palette))}
+	if len(palette) == 0 {
+		return coverage
+	}
+
+	index := kdtree.NewPaletteIndex(palette)
+
+	// the closest palette color and its DeltaE, per distinct pixel color
+	type match struct {
+		index  int
+		deltaE float64
+	}
+	matches := map[color.RGBA]match{}
+
+	entryDist := make([]float64, len(palette))
+	entryMeasured := make([]int, len(palette))
+
+	bounds := img.Bounds()
+	size := CoverageRegionSize
+	if size < 1 {
+		size = 1
+	}
+	cellsX := (bounds.Dx() + size - 1) / size
+	cellsY := (bounds.Dy() + size - 1) / size
+	cellDist := make([]float64, cellsX*cellsY)
+	cellMeasured := make([]int, cellsX*cellsY)
+
+	var totalDist float64
+	var measured int
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := ToRGBA(img.At(x, y))
+
+			m, ok := matches[pixel]
+			if !ok {
+				m.index = index.Index(pixel)
+				m.deltaE = -1
+				if _, _, _, a := palette[m.index].RGBA(); a != 0 {
+					m.deltaE = DeltaE(pixel, colorpalette.Colors[m.index])
+				}
+				matches[pixel] = m
+			}
+
+			coverage.Entries[m.index].Count++
+			if m.deltaE < 0 {
+				continue
+			}
+
+			cell := (x-bounds.Min.X)/size + (y-bounds.Min.Y)/size*cellsX
+			entryDist[m.index] += m.deltaE
+			entryMeasured[m.index]++
+			cellDist[cell] += m.deltaE
+			cellMeasured[cell]++
+			totalDist += m.deltaE
+			measured++
+		}
+	}
+
+	pixels := bounds.Dx() * bounds.Dy()
+	for i, clr := range palette {
+		entry := &coverage.Entries[i]
+		entry.Color = ToRGBA(clr)
+
+		if entry.Count == 0 {
+			coverage.Unused++
+			continue
+		}
+		entry.Fraction = float64(entry.Count) / float64(pixels)
+		if entryMeasured[i] > 0 {
+			entry.MeanDeltaE = entryDist[i] / float64(entryMeasured[i])
+		}
+	}
+
+	if measured > 0 {
+		coverage.MeanDeltaE = totalDist / float64(measured)
+	}
+
+	regions := []Region{}
+	for cell, dist := range cellDist {
+		if cellMeasured[cell] == 0 {
+			continue
+		}
+
+		corner := bounds.Min.Add(image.Pt(cell%cellsX*size, cell/cellsX*size))
+		regions = append(regions, Region{
+			Bounds:     image.Rectangle{corner, corner.Add(image.Pt(size, size))}.Intersect(bounds),
+			MeanDeltaE: dist / float64(cellMeasured[cell]),
+		})
+	}
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].MeanDeltaE > regions[j].MeanDeltaE
+	})
+	if len(regions) > CoverageWorstRegions {
+		regions = regions[:CoverageWorstRegions]
+	}
+	coverage.Worst = regions
+
+	return coverage
+}
+
+// String formats the coverage as a readable report
+func (coverage Coverage) String() string {
+	var report strings.Builder
+
+	fmt.Fprintf(&report, "mean DeltaE %.2f, %d of %d colors unused\n", coverage.MeanDeltaE, coverage.Unused, len(coverage.Entries))
+
+	for i, entry := range coverage.Entries {
+		fmt.Fprintf(&report, "%3d %s %6.2f%% of pixels, mean DeltaE %.2f\n",
+			i, hexString(entry.Color), 100*entry.Fraction, entry.MeanDeltaE)
+	}
+
+	if len(coverage.Worst) > 0 {
+		fmt.Fprintln(&report, "worst regions:")
+	}
+	for _, region := range coverage.Worst {
+		fmt.Fprintf(&report, "    %v mean DeltaE %.2f\n", region.Bounds, region.MeanDeltaE)
+	}
+
+	return report.String()
+}
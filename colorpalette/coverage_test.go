@@ -0,0 +1,50 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	// a red left half and a blue right half, with one green pixel in the top right corner
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			clr := color.RGBA{255, 0, 0, 255}
+			if x >= 4 {
+				clr = color.RGBA{0, 0, 255, 255}
+			}
+			img.SetRGBA(x, y, clr)
+		}
+	}
+	img.SetRGBA(7, 0, color.RGBA{0, 255, 0, 255})
+
+	size, worst := CoverageRegionSize, CoverageWorstRegions
+	t.Cleanup(func() { CoverageRegionSize, CoverageWorstRegions = size, worst })
+	CoverageRegionSize = 4
+	CoverageWorstRegions = 1
+
+	// the palette has no green, and a white that no pixel is closest to
+	palette := ColorPalette{Colors: []color.RGBA{{255, 0, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255}}}
+	coverage := palette.Coverage(img)
+
+	if len(coverage.Entries) != 3 || coverage.Unused != 1 {
+		t.Fatalf("%d entries with %d unused, want 3 with 1 unused", len(coverage.Entries), coverage.Unused)
+	}
+	// the green pixel is closest to red
+	if red := coverage.Entries[0]; red.Count != 17 || red.MeanDeltaE <= 0 {
+		t.Errorf("red covers %+v, want the left half and the green pixel", red)
+	}
+	if blue := coverage.Entries[1]; blue.Count != 15 || blue.Fraction != 15.0/32 || blue.MeanDeltaE != 0 {
+		t.Errorf("blue covers %+v, want the blue pixels of the right half exactly", blue)
+	}
+	if coverage.MeanDeltaE <= 0 {
+		t.Errorf("mean DeltaE %v, want the error of the green pixel", coverage.MeanDeltaE)
+	}
+
+	// the region with the green pixel is the worst
+	if len(coverage.Worst) != 1 || coverage.Worst[0].Bounds != image.Rect(4, 0, 8, 4) {
+		t.Errorf("worst regions %v, want the right half", coverage.Worst)
+	}
+}
@@ -0,0 +1,95 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// dominantBits is the amount of bits per channel of the buckets that Dominant counts the colors in
+const dominantBits = 4
+
+// bucket holds the amount of pixels in one bucket of the color histogram, and the sum of their channels
+type bucket struct {
+	key   int
+	count int
+	sum   [3]int
+}
+
+// Dominant returns the n most representative colors of img, most common first. It is a lot cheaper than
+// Create: the pixels (1/SampleFactor of them in each direction) are counted in a histogram of 4096 buckets,
+// and the average colors of the fullest buckets are returned, skipping colors within MergeThreshold
+// (as DeltaE) of a color that was already picked. Transparent pixels are left out.
+// Fewer than n colors are returned if the image doesn't have that many distinct colors.
+func Dominant(img image.Image, n int) color.Palette {
+	buckets := map[int]*bucket{}
+
+	step := SampleFactor
+	if step < 1 {
+		step = 1
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+
+			// undo the premultiplication by alpha, and use 8 bits per channel
+			r, g, b = (r*0xffff/a)>>8, (g*0xffff/a)>>8, (b*0xffff/a)>>8
+
+			shift := 8 - dominantBits
+			key := int(r>>shift)<<(2*dominantBits) | int(g>>shift)<<dominantBits | int(b>>shift)
+
+			bin, ok := buckets[key]
+			if !ok {
+				bin = &bucket{key: key}
+				buckets[key] = bin
+			}
+			bin.count++
+			bin.sum[0] += int(r)
+			bin.sum[1] += int(g)
+			bin.sum[2] += int(b)
+		}
+	}
+
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, bin := range buckets {
+		sorted = append(sorted, bin)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		// the keys break ties, so that the result doesn't depend on the order of the map
+		return sorted[i].key < sorted[j].key
+	})
+
+	picked := ColorPalette{}
+	for _, bin := range sorted {
+		if len(picked.Colors) >= n {
+			break
+		}
+
+		clr := fromChannels([]float64{
+			float64(bin.sum[0]) / float64(bin.count),
+			float64(bin.sum[1]) / float64(bin.count),
+			float64(bin.sum[2]) / float64(bin.count),
+		})
+
+		duplicate := false
+		for _, existing := range picked.Colors {
+			if DeltaE(existing, clr) < MergeThreshold {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			picked.Colors = append(picked.Colors, clr)
+		}
+	}
+
+	return picked.ToPalette()
+}
@@ -0,0 +1,45 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDominant(t *testing.T) {
+	// mostly red, some blue, a little bit of nearly red and a transparent corner
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			switch {
+			case x < 6:
+				img.SetRGBA(x, y, color.RGBA{200, 20, 20, 255})
+			case x < 9:
+				img.SetRGBA(x, y, color.RGBA{20, 20, 200, 255})
+			default:
+				img.SetRGBA(x, y, color.RGBA{202, 22, 20, 255})
+			}
+		}
+	}
+	img.SetRGBA(0, 0, color.RGBA{})
+
+	factor := SampleFactor
+	t.Cleanup(func() { SampleFactor = factor })
+	SampleFactor = 1
+
+	// the nearly red pixels are within MergeThreshold of the red, so only two colors are found
+	dominant := Dominant(img, 3)
+	if len(dominant) != 2 {
+		t.Fatalf("found %v, want the red and the blue", dominant)
+	}
+
+	for i, want := range []color.RGBA{{200, 20, 20, 255}, {20, 20, 200, 255}} {
+		if got := ToRGBA(dominant[i]); DeltaE(got, want) > 2 {
+			t.Errorf("color %d is %v, want about %v", i, got, want)
+		}
+	}
+
+	if dominant := Dominant(img, 1); len(dominant) != 1 || DeltaE(ToRGBA(dominant[0]), color.RGBA{200, 20, 20, 255}) > 2 {
+		t.Errorf("the most dominant color is %v, want the red", dominant)
+	}
+}
@@ -0,0 +1,117 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/mielpeeters/dither/geom"
+	"github.com/mielpeeters/dither/kmeans"
+)
+
+// Duotone creates a palette of k shades of the given inks, for the duotone (or tritone) poster look.
+// The sampled pixels of img are clustered on their luminance only, and the clusters are mapped onto the ramp
+// through the inks, from the darkest cluster at the first ink to the lightest at the last one
+// (interpolating in CIE L*a*b*). One ink gives a ramp from black through the ink to white,
+// two inks give the classic duotone from shadow color to highlight color, three a tritone, and so on.
+// The palette is ordered from dark to light.
+func Duotone(img image.Image, k int, inks ...color.RGBA) ColorPalette {
+	return CurrentSettings().Duotone(img, k, inks...)
+}
+
+// Duotone creates a palette of k shades of the inks with the settings, see the package function Duotone
+func (settings Settings) Duotone(img image.Image, k int, inks ...color.RGBA) ColorPalette {
+	palette := ColorPalette{Name: "duotone", Colors: []color.RGBA{}}
+	if k < 1 || len(inks) == 0 {
+		return palette
+	}
+
+	ramp := inks
+	if len(inks) == 1 {
+		ramp = []color.RGBA{{0, 0, 0, 255}, inks[0], {255, 255, 255, 255}}
+	}
+
+	levels := settings.luminanceLevels(img, k)
+	if len(levels) == 0 {
+		return palette
+	}
+
+	// the darkest level gets the first ink, the lightest level the last one
+	low, high := levels[0], levels[len(levels)-1]
+	for _, level := range levels {
+		position := 0.5
+		if high > low {
+			position = (level - low) / (high - low)
+		}
+		palette.Colors = append(palette.Colors, rampColor(ramp, position))
+	}
+
+	return palette
+}
+
+// luminanceLevels clusters the luminance of the sampled pixels of img in k clusters (KMTimes, keeping the best run),
+// and returns the sorted luminance of the cluster centers
+func (settings Settings) luminanceLevels(img image.Image, k int) []float64 {
+	rng := settings.random()
+	samples := settings.samplePoints(img, rng)
+	if len(samples.Points) == 0 {
+		return nil
+	}
+
+	pointSet := geom.PointSet{}
+	for i, point := range samples.Points {
+		pointSet.Points = append(pointSet.Points, geom.Point{
+			Coordinates: []float32{float32(luminance(pointToRGBA(point)))},
+			ID:          i,
+			Weight:      point.Weight,
+		})
+	}
+
+	distance := func(pnt1, pnt2 *geom.Point) float64 {
+		return math.Abs(float64(pnt1.Coordinates[0] - pnt2.Coordinates[0]))
+	}
+
+	KM, err := kmeans.NewClustering(pointSet, k, distance, rng)
+	if err != nil {
+		return nil
+	}
+	options := settings.KMOptions
+	// the absolute difference satisfies the triangle inequality
+	options.Triangle = kmeans.TriangleMetric
+	KM.SetOptions(options)
+
+	best, _ := KM.ClusterBest(settings.KMTimes, settings.KMAccuracy, settings.KMConsecutive)
+
+	levels := []float64{}
+	for _, mean := range best.KMeans.Points {
+		levels = append(levels, float64(mean.Coordinates[0]))
+	}
+	sort.Float64s(levels)
+
+	return levels
+}
+
+// rampColor returns the color at position (0 to 1) of the ramp through the given colors, which are spread evenly
+func rampColor(ramp []color.RGBA, position float64) color.RGBA {
+	if len(ramp) == 1 {
+		return ramp[0]
+	}
+
+	scaled := math.Max(0, math.Min(1, position)) * float64(len(ramp)-1)
+	stop := int(scaled)
+	if stop >= len(ramp)-1 {
+		return ramp[len(ramp)-1]
+	}
+	t := scaled - float64(stop)
+
+	from := ConvRGBAtoLABA(channels(ramp[stop]))
+	to := ConvRGBAtoLABA(channels(ramp[stop+1]))
+
+	lab := make([]float64, 4)
+	for c := range lab {
+		lab[c] = from[c] + t*(to[c]-from[c])
+	}
+
+	return fromChannels(ConvLABAtoRGBA(lab))
+}
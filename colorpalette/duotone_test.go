@@ -0,0 +1,75 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// duotoneTestImage returns a gray gradient from dark to light
+func duotoneTestImage() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 64, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{uint8(x * 4)})
+		}
+	}
+
+	return img
+}
+
+func TestDuotone(t *testing.T) {
+	shadow, highlight := color.RGBA{20, 30, 90, 255}, color.RGBA{250, 200, 120, 255}
+
+	settings := CurrentSettings()
+	settings.Rand = rand.New(rand.NewSource(1))
+
+	// two shades of two inks are the inks themselves
+	palette := settings.Duotone(duotoneTestImage(), 2, shadow, highlight)
+	if len(palette.Colors) != 2 {
+		t.Fatalf("got %v, want the two inks", palette.Colors)
+	}
+	for i, ink := range []color.RGBA{shadow, highlight} {
+		if DeltaE(palette.Colors[i], ink) > 1 {
+			t.Errorf("color %d is %v, want the ink %v", i, palette.Colors[i], ink)
+		}
+	}
+
+	// more shades lie between the inks, from dark to light
+	palette = settings.Duotone(duotoneTestImage(), 5, shadow, highlight)
+	if len(palette.Colors) != 5 {
+		t.Fatalf("got %d colors, want 5", len(palette.Colors))
+	}
+	for i := 1; i < len(palette.Colors); i++ {
+		if luminance(palette.Colors[i]) <= luminance(palette.Colors[i-1]) {
+			t.Errorf("the colors %v are not ordered from dark to light", palette.Colors)
+			break
+		}
+	}
+	if DeltaE(palette.Colors[0], shadow) > 1 || DeltaE(palette.Colors[4], highlight) > 1 {
+		t.Errorf("the colors %v don't run from %v to %v", palette.Colors, shadow, highlight)
+	}
+}
+
+func TestDuotoneOneInk(t *testing.T) {
+	settings := CurrentSettings()
+	settings.Rand = rand.New(rand.NewSource(1))
+
+	// one ink ramps from black through the ink to white
+	palette := settings.Duotone(duotoneTestImage(), 3, color.RGBA{200, 0, 0, 255})
+	if len(palette.Colors) != 3 {
+		t.Fatalf("got %v, want 3 colors", palette.Colors)
+	}
+	if DeltaE(palette.Colors[0], color.RGBA{0, 0, 0, 255}) > 1 || DeltaE(palette.Colors[2], color.RGBA{255, 255, 255, 255}) > 1 {
+		t.Errorf("the colors %v don't run from black to white", palette.Colors)
+	}
+	// the middle level is close to the middle of the ramp, which is the ink
+	if middle := palette.Colors[1]; middle.R < 2*middle.G || middle.R < 2*middle.B {
+		t.Errorf("the middle color %v is not a shade of the red ink", middle)
+	}
+
+	if palette := settings.Duotone(duotoneTestImage(), 3); len(palette.Colors) != 0 {
+		t.Errorf("no inks give %v, want no colors", palette.Colors)
+	}
+}
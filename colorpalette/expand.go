@@ -0,0 +1,72 @@
+package colorpalette
+
+// Expand returns a palette of n colors, made by interpolating between neighbouring colors of the palette
+// in the CIE L*a*b* color space, so that the steps look even. The original colors are kept, in their order,
+// and the new colors are spread over the gaps in proportion to their size (as DeltaE).
+// This turns a few colors into smooth ramps: sort the palette first (for example with SortByLuminance)
+// to choose which colors are neighbours. If n is not larger than the palette, a copy of the palette is returned.
+// The transparent color is kept, but not counted in n.
+func (colorpalette *ColorPalette) Expand(n int) ColorPalette {
+	expanded := ColorPalette{Name: colorpalette.Name, Transparent: colorpalette.Transparent}
+
+	if n <= len(colorpalette.Colors) || len(colorpalette.Colors) < 2 {
+		expanded.Colors = append(expanded.Colors, colorpalette.Colors...)
+		return expanded
+	}
+
+	labs := make([][]float64, len(colorpalette.Colors))
+	for i, clr := range colorpalette.Colors {
+		labs[i] = ConvRGBAtoLABA(channels(clr))
+	}
+
+	// the gaps between neighbours get new colors in proportion to their size, using the largest remainders
+	gaps := len(labs) - 1
+	sizes := make([]float64, gaps)
+	total := 0.0
+	for i := range sizes {
+		sizes[i] = DeltaE(colorpalette.Colors[i], colorpalette.Colors[i+1])
+		total += sizes[i]
+	}
+
+	extra := n - len(labs)
+	counts := make([]int, gaps)
+	remainders := make([]float64, gaps)
+	assigned := 0
+	for i, size := range sizes {
+		share := float64(extra) / float64(gaps)
+		if total > 0 {
+			share = float64(extra) * size / total
+		}
+		counts[i] = int(share)
+		remainders[i] = share - float64(counts[i])
+		assigned += counts[i]
+	}
+	for ; assigned < extra; assigned++ {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		counts[largest]++
+		remainders[largest] = -1
+	}
+
+	for i := 0; i < gaps; i++ {
+		expanded.Colors = append(expanded.Colors, colorpalette.Colors[i])
+
+		for step := 1; step <= counts[i]; step++ {
+			t := float64(step) / float64(counts[i]+1)
+
+			lab := make([]float64, 4)
+			for c := range lab {
+				lab[c] = labs[i][c] + t*(labs[i+1][c]-labs[i][c])
+			}
+
+			expanded.Colors = append(expanded.Colors, fromChannels(ConvLABAtoRGBA(lab)))
+		}
+	}
+	expanded.Colors = append(expanded.Colors, colorpalette.Colors[gaps])
+
+	return expanded
+}
@@ -0,0 +1,58 @@
+package colorpalette
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	palette := ColorPalette{
+		Name:        "ramp",
+		Colors:      []color.RGBA{{0, 0, 0, 255}, {128, 0, 0, 255}, {255, 255, 255, 255}},
+		Transparent: true,
+	}
+
+	expanded := palette.Expand(9)
+	if len(expanded.Colors) != 9 || expanded.Name != palette.Name || !expanded.Transparent {
+		t.Fatalf("expanded into %v, want 9 colors and the name and transparency of the palette", expanded)
+	}
+
+	// the original colors are kept, in their order
+	kept := 0
+	for _, clr := range expanded.Colors {
+		if kept < len(palette.Colors) && clr == palette.Colors[kept] {
+			kept++
+		}
+	}
+	if kept != len(palette.Colors) || expanded.Colors[0] != palette.Colors[0] || expanded.Colors[8] != palette.Colors[2] {
+		t.Errorf("expanded into %v, which doesn't keep %v at the ends and in order", expanded.Colors, palette.Colors)
+	}
+
+	// the steps between neighbours are even, so no step is much larger than the others
+	steps := []float64{}
+	for i := 1; i < len(expanded.Colors); i++ {
+		steps = append(steps, DeltaE(expanded.Colors[i-1], expanded.Colors[i]))
+	}
+	for _, step := range steps {
+		if step > 2*steps[0] || step < steps[0]/2 {
+			t.Errorf("uneven steps %v", steps)
+			break
+		}
+	}
+}
+
+func TestExpandSmaller(t *testing.T) {
+	palette := ColorPalette{Colors: []color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}}}
+
+	for _, n := range []int{0, 1, 2} {
+		expanded := palette.Expand(n)
+		if !reflect.DeepEqual(expanded.Colors, palette.Colors) {
+			t.Errorf("expanding to %d gives %v, want a copy of the palette", n, expanded.Colors)
+		}
+		expanded.Colors[0] = color.RGBA{1, 2, 3, 255}
+		if palette.Colors[0] != (color.RGBA{0, 0, 0, 255}) {
+			t.Fatalf("expanding to %d doesn't copy the colors", n)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package colorpalette
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseGPL reads a GIMP palette (.gpl) from r into a ColorPalette.
+// The palette name is taken from the "Name:" header, color names are ignored.
+func ParseGPL(r io.Reader) (ColorPalette, error) {
+	palette := ColorPalette{
+		Colors: []color.RGBA{},
+	}
+
+	scanner := bufio.NewScanner(r)
+
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		if err := scanner.Err(); err != nil {
+			return palette, err
+		}
+		return palette, errors.New("colorpalette: not a GIMP palette, missing header")
+	}
+
+	lineNumber := 1
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "Name:") {
+			palette.Name = strings.TrimSpace(strings.TrimPrefix(line, "Name:"))
+			continue
+		}
+
+		if strings.HasPrefix(line, "Columns:") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return palette, fmt.Errorf("colorpalette: invalid color on line %d of GIMP palette", lineNumber)
+		}
+
+		values := [3]uint8{}
+		for i := range values {
+			value, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return palette, fmt.Errorf("colorpalette: invalid color on line %d of GIMP palette", lineNumber)
+			}
+			values[i] = uint8(value)
+		}
+
+		palette.Colors = append(palette.Colors, color.RGBA{values[0], values[1], values[2], 255})
+	}
+
+	return palette, scanner.Err()
+}
+
+// GetPaletteFromGPL reads a GIMP palette (.gpl) file into a ColorPalette.
+func GetPaletteFromGPL(gplFileName string) (ColorPalette, error) {
+	file, err := os.Open(gplFileName)
+	if err != nil {
+		return ColorPalette{}, err
+	}
+	defer file.Close()
+
+	return ParseGPL(file)
+}
+
+// WriteGPL writes the palette to w in the GIMP palette (.gpl) format
+func (colorpalette *ColorPalette) WriteGPL(w io.Writer) error {
+	name := colorpalette.Name
+	if name == "" {
+		name = "Untitled"
+	}
+
+	if _, err := fmt.Fprintf(w, "GIMP Palette\nName: %s\nColumns: %d\n#\n", name, gplColumns(len(colorpalette.Colors))); err != nil {
+		return err
+	}
+
+	for _, clr := range colorpalette.Colors {
+		if _, err := fmt.Fprintf(w, "%3d %3d %3d\t%s\n", clr.R, clr.G, clr.B, hexString(clr)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ToGPLFile writes the palette out to the specified path, as a GIMP palette (.gpl) file.
+func (colorpalette *ColorPalette) ToGPLFile(gplFileName string) error {
+	file, err := os.Create(gplFileName)
+	if err != nil {
+		return err
+	}
+
+	if err := colorpalette.WriteGPL(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// gplColumns returns the amount of columns GIMP uses to show a palette of n colors
+func gplColumns(n int) int {
+	if n > 16 {
+		return 16
+	}
+	if n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+package colorpalette
+
+import (
+	"bytes"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPalette has colors with every channel at the extremes, and one of each in between
+var testPalette = ColorPalette{
+	Name: "test palette",
+	Colors: []color.RGBA{
+		{0, 0, 0, 255},
+		{255, 255, 255, 255},
+		{29, 43, 83, 255},
+		{255, 119, 168, 255},
+		{1, 128, 254, 255},
+	},
+}
+
+func TestGPLRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testPalette.WriteGPL(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	palette, err := ParseGPL(&buf)
+	if err != nil {
+		t.Fatalf("reading the written palette: %v", err)
+	}
+	if !reflect.DeepEqual(palette, testPalette) {
+		t.Errorf("read %v, wrote %v", palette, testPalette)
+	}
+}
+
+func TestParseGPL(t *testing.T) {
+	input := "GIMP Palette\nName: written by hand\nColumns: 4\n# a comment\n\n  0 10  20 first\n255 255 255\n"
+	palette, err := ParseGPL(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ColorPalette{Name: "written by hand", Colors: []color.RGBA{{0, 10, 20, 255}, {255, 255, 255, 255}}}
+	if !reflect.DeepEqual(palette, want) {
+		t.Errorf("read %v, want %v", palette, want)
+	}
+}
+
+func TestParseGPLMalformed(t *testing.T) {
+	inputs := map[string]string{
+		"empty":            "",
+		"no header":        "0 0 0\n",
+		"other header":     "JASC-PAL\n0100\n",
+		"too few channels": "GIMP Palette\n0 0\n",
+		"not a number":     "GIMP Palette\n0 zero 0\n",
+		"out of range":     "GIMP Palette\n0 256 0\n",
+		"negative":         "GIMP Palette\n0 -1 0\n",
+	}
+
+	for name, input := range inputs {
+		if _, err := ParseGPL(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
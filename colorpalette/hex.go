@@ -0,0 +1,170 @@
+package colorpalette
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseHexColor parses one color in hex notation: RRGGBB or RRGGBBAA, optionally prefixed with # (or 0x).
+// The shorthand RGB and RGBA notations are accepted as well.
+// Like in CSS, the red, green and blue values are not premultiplied by the alpha value.
+func ParseHexColor(hex string) (color.RGBA, error) {
+	digits := strings.TrimSpace(hex)
+	digits = strings.TrimPrefix(digits, "#")
+	digits = strings.TrimPrefix(strings.TrimPrefix(digits, "0x"), "0X")
+
+	// expand the shorthand notations
+	if len(digits) == 3 || len(digits) == 4 {
+		expanded := ""
+		for _, digit := range digits {
+			expanded += string(digit) + string(digit)
+		}
+		digits = expanded
+	}
+
+	if len(digits) != 6 && len(digits) != 8 {
+		return color.RGBA{}, fmt.Errorf("colorpalette: invalid hex color %q", hex)
+	}
+
+	clr := [4]uint8{0, 0, 0, 255}
+	for i := 0; i < len(digits)/2; i++ {
+		value, err := strconv.ParseUint(digits[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return color.RGBA{}, fmt.Errorf("colorpalette: invalid hex color %q", hex)
+		}
+		clr[i] = uint8(value)
+	}
+
+	return premultiply(color.NRGBA{clr[0], clr[1], clr[2], clr[3]}), nil
+}
+
+// FromHex creates a ColorPalette from a slice of colors in hex notation, see ParseHexColor
+func FromHex(hexes []string) (ColorPalette, error) {
+	palette := ColorPalette{
+		Colors: []color.RGBA{},
+	}
+
+	for _, hex := range hexes {
+		clr, err := ParseHexColor(hex)
+		if err != nil {
+			return palette, err
+		}
+		palette.Colors = append(palette.Colors, clr)
+	}
+
+	return palette, nil
+}
+
+// ParseHex reads a list of hex colors from r, one per line, as exported by Lospec.
+// Empty lines and lines starting with ; or // are skipped.
+func ParseHex(r io.Reader) (ColorPalette, error) {
+	hexes := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		hexes = append(hexes, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return ColorPalette{}, err
+	}
+
+	return FromHex(hexes)
+}
+
+// GetPaletteFromHex reads a text file with hex colors (see ParseHex) into a ColorPalette.
+// The palette is named after the file.
+func GetPaletteFromHex(hexFileName string) (ColorPalette, error) {
+	file, err := os.Open(hexFileName)
+	if err != nil {
+		return ColorPalette{}, err
+	}
+	defer file.Close()
+
+	palette, err := ParseHex(file)
+	palette.Name = strings.TrimSuffix(filepath.Base(hexFileName), filepath.Ext(hexFileName))
+
+	return palette, err
+}
+
+// premultiply returns clr with its red, green and blue values multiplied by its alpha value, as color.RGBA holds them.
+// Unlike color.RGBAModel, which truncates, it rounds the values, so that unpremultiply gives back the same color.
+func premultiply(clr color.NRGBA) color.RGBA {
+	scale := func(value uint8) uint8 {
+		return uint8((int(value)*int(clr.A) + 127) / 255)
+	}
+
+	return color.RGBA{scale(clr.R), scale(clr.G), scale(clr.B), clr.A}
+}
+
+// unpremultiply returns clr with its red, green and blue values divided by its alpha value, rounded like premultiply
+func unpremultiply(clr color.RGBA) color.NRGBA {
+	if clr.A == 0 {
+		return color.NRGBA{}
+	}
+
+	scale := func(value uint8) uint8 {
+		return uint8(math.Min(255, float64((int(value)*255+int(clr.A)/2)/int(clr.A))))
+	}
+
+	return color.NRGBA{scale(clr.R), scale(clr.G), scale(clr.B), clr.A}
+}
+
+// hexString returns the color as #RRGGBB, or as #RRGGBBAA (not premultiplied) if it isn't opaque
+func hexString(clr color.RGBA) string {
+	if clr.A != 255 {
+		nrgba := unpremultiply(clr)
+		return fmt.Sprintf("#%02x%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B, nrgba.A)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", clr.R, clr.G, clr.B)
+}
+
+// ToHex returns the colors of the palette in hex notation, see ParseHexColor
+func (colorpalette *ColorPalette) ToHex() []string {
+	hexes := []string{}
+
+	for _, clr := range colorpalette.Colors {
+		hexes = append(hexes, hexString(clr))
+	}
+
+	return hexes
+}
+
+// WriteHex writes the palette to w as a list of hex colors, one per line
+func (colorpalette *ColorPalette) WriteHex(w io.Writer) error {
+	for _, hex := range colorpalette.ToHex() {
+		if _, err := fmt.Fprintln(w, hex); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ToHexFile writes the palette out to the specified path, as a text file with one hex color per line.
+func (colorpalette *ColorPalette) ToHexFile(hexFileName string) error {
+	file, err := os.Create(hexFileName)
+	if err != nil {
+		return err
+	}
+
+	if err := colorpalette.WriteHex(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
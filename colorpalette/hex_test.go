@@ -0,0 +1,86 @@
+package colorpalette
+
+import (
+	"bytes"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHexRoundTrip(t *testing.T) {
+	written := testPalette
+	written.Colors = append(append([]color.RGBA{}, written.Colors...), color.RGBA{10, 20, 30, 40})
+
+	var buf bytes.Buffer
+	if err := written.WriteHex(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	palette, err := ParseHex(&buf)
+	if err != nil {
+		t.Fatalf("reading the written palette: %v", err)
+	}
+	// hex files have no name
+	if !reflect.DeepEqual(palette.Colors, written.Colors) {
+		t.Errorf("read %v, wrote %v", palette.Colors, written.Colors)
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	colors := map[string]color.RGBA{
+		"1d2b53":     {0x1d, 0x2b, 0x53, 255},
+		"#FF77A8":    {0xff, 0x77, 0xa8, 255},
+		"0x00e436":   {0x00, 0xe4, 0x36, 255},
+		" #1d2b5380": {0x0f, 0x16, 0x2a, 0x80},
+		"#f0a":       {0xff, 0x00, 0xaa, 255},
+		"f0a8":       {0x88, 0x00, 0x5b, 0x88},
+	}
+	for hex, want := range colors {
+		clr, err := ParseHexColor(hex)
+		if err != nil {
+			t.Errorf("%q: %v", hex, err)
+		} else if clr != want {
+			t.Errorf("%q is %v, want %v", hex, clr, want)
+		}
+	}
+}
+
+// TestParseHexTranslucent checks that translucent hex colors, which aren't premultiplied, give valid color.RGBA values
+func TestParseHexTranslucent(t *testing.T) {
+	clr, err := ParseHexColor("#ff000080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (color.RGBA{0x80, 0, 0, 0x80}); clr != want {
+		t.Errorf("#ff000080 is %v, want %v", clr, want)
+	}
+
+	if hex := hexString(clr); hex != "#ff000080" {
+		t.Errorf("%v is written as %s, want #ff000080", clr, hex)
+	}
+}
+
+// TestPremultiplyRoundTrip checks that every valid color.RGBA survives unpremultiplying and premultiplying it again
+func TestPremultiplyRoundTrip(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for v := 0; v <= a; v++ {
+			clr := color.RGBA{uint8(v), uint8(v), uint8(v), uint8(a)}
+			if got := premultiply(unpremultiply(clr)); got != clr {
+				t.Fatalf("%v gives %v", clr, got)
+			}
+		}
+	}
+}
+
+func TestParseHexMalformed(t *testing.T) {
+	for _, hex := range []string{"", "#", "12345", "1234567", "#gg0000", "#-10000", "0x", "#1d2b53ff00"} {
+		if _, err := ParseHexColor(hex); err == nil {
+			t.Errorf("%q: no error", hex)
+		}
+	}
+
+	if _, err := ParseHex(strings.NewReader("; a palette\n1d2b53\n\nnot a color\n")); err == nil {
+		t.Errorf("a file with a line that isn't a color gives no error")
+	}
+}
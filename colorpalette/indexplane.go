@@ -0,0 +1,319 @@
+package colorpalette
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FromPalette converts a Go standard library color.Palette into a ColorPalette with the given name.
+// A fully transparent last color makes the ColorPalette Transparent, so that ToPalette gives back the same palette.
+func FromPalette(palette color.Palette, name string) ColorPalette {
+	colorPalette := ColorPalette{
+		Name:   name,
+		Colors: []color.RGBA{},
+	}
+
+	if len(palette) > 0 {
+		if _, _, _, a := palette[len(palette)-1].RGBA(); a == 0 {
+			colorPalette.Transparent = true
+			palette = palette[:len(palette)-1]
+		}
+	}
+
+	for _, clr := range palette {
+		colorPalette.Colors = append(colorPalette.Colors, ToRGBA(clr))
+	}
+
+	return colorPalette
+}
+
+// ExportIndexPlane writes the paletted image out as two files: its palette as a JSON file at palettePath
+// (see ToJSONFile), and its raw color indices at indexPath. The index plane is written as a binary PGM image
+// if indexPath ends in .pgm, or as a NumPy array (of shape height x width) if it ends in .npy.
+//
+// The index plane can be edited with external tools, and turned back into an image with ImportIndexPlane.
+func ExportIndexPlane(paletted *image.Paletted, indexPath, palettePath string) error {
+	var encode func(io.Writer, *image.Paletted) error
+
+	switch strings.ToLower(filepath.Ext(indexPath)) {
+	case ".pgm":
+		encode = writePGM
+	case ".npy":
+		encode = writeNPY
+	default:
+		return fmt.Errorf("colorpalette: unsupported index plane format %q", filepath.Ext(indexPath))
+	}
+
+	file, err := os.Create(indexPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := encode(writer, paletted); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(FromPalette(paletted.Palette, ""), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(palettePath, output, 0644)
+}
+
+// ImportIndexPlane reassembles a paletted image from an index plane and palette file, as written by ExportIndexPlane.
+// Indices that are out of range of the palette result in an error.
+func ImportIndexPlane(indexPath, palettePath string) (*image.Paletted, error) {
+	data, err := os.ReadFile(palettePath)
+	if err != nil {
+		return nil, err
+	}
+
+	colorPalette := ColorPalette{}
+	if err := json.Unmarshal(data, &colorPalette); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var paletted *image.Paletted
+
+	switch strings.ToLower(filepath.Ext(indexPath)) {
+	case ".pgm":
+		paletted, err = readPGM(bufio.NewReader(file))
+	case ".npy":
+		paletted, err = readNPY(bufio.NewReader(file))
+	default:
+		err = fmt.Errorf("colorpalette: unsupported index plane format %q", filepath.Ext(indexPath))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	paletted.Palette = colorPalette.ToPalette()
+
+	for _, index := range paletted.Pix {
+		if int(index) >= len(paletted.Palette) {
+			return nil, fmt.Errorf("colorpalette: index %d is out of range of the palette", index)
+		}
+	}
+
+	return paletted, nil
+}
+
+// indexRows returns the color indices of paletted, row by row, with its top left corner at (0, 0)
+func indexRows(paletted *image.Paletted) [][]byte {
+	bounds := paletted.Bounds()
+	rows := make([][]byte, bounds.Dy())
+
+	for y := range rows {
+		start := paletted.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		rows[y] = paletted.Pix[start : start+bounds.Dx()]
+	}
+
+	return rows
+}
+
+func writePGM(w io.Writer, paletted *image.Paletted) error {
+	bounds := paletted.Bounds()
+
+	if _, err := fmt.Fprintf(w, "P5\n%d %d\n255\n", bounds.Dx(), bounds.Dy()); err != nil {
+		return err
+	}
+
+	for _, row := range indexRows(paletted) {
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readPGM(r *bufio.Reader) (*image.Paletted, error) {
+	// the header consists of four whitespace separated tokens, comments start with #
+	tokens := []int{}
+	magic := ""
+
+	for len(tokens) < 3 {
+		token, err := readPGMToken(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if magic == "" {
+			if token != "P5" {
+				return nil, errors.New("colorpalette: index plane is not a binary PGM image")
+			}
+			magic = token
+			continue
+		}
+
+		value, err := strconv.Atoi(token)
+		if err != nil {
+			return nil, errors.New("colorpalette: invalid PGM header")
+		}
+		tokens = append(tokens, value)
+	}
+
+	width, height, maxValue := tokens[0], tokens[1], tokens[2]
+	if maxValue > 255 {
+		return nil, errors.New("colorpalette: 16 bit PGM index planes are not supported")
+	}
+
+	return readIndices(r, width, height)
+}
+
+// readPGMToken reads one header token, and the single whitespace character following it
+func readPGMToken(r *bufio.Reader) (string, error) {
+	token := []byte{}
+
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case c == '#':
+			if _, err := r.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, c)
+		}
+	}
+}
+
+const npyMagic = "\x93NUMPY"
+
+func writeNPY(w io.Writer, paletted *image.Paletted) error {
+	bounds := paletted.Bounds()
+
+	header := fmt.Sprintf("{'descr': '|u1', 'fortran_order': False, 'shape': (%d, %d), }", bounds.Dy(), bounds.Dx())
+	// the total header length is padded to a multiple of 64 bytes, and ends in a newline
+	total := len(npyMagic) + 4 + len(header) + 1
+	header += strings.Repeat(" ", (64-total%64)%64) + "\n"
+
+	if _, err := io.WriteString(w, npyMagic+"\x01\x00"); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(header))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+
+	for _, row := range indexRows(paletted) {
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var npyShape = regexp.MustCompile(`'shape':\s*\(\s*(\d+)\s*,\s*(\d+)\s*,?\s*\)`)
+
+func readNPY(r *bufio.Reader) (*image.Paletted, error) {
+	preamble := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, preamble); err != nil {
+		return nil, err
+	}
+	if string(preamble[:len(npyMagic)]) != npyMagic {
+		return nil, errors.New("colorpalette: index plane is not a NumPy array")
+	}
+
+	var headerLength int
+	switch preamble[len(npyMagic)] {
+	case 1:
+		var length uint16
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return nil, err
+		}
+		headerLength = int(length)
+	default:
+		var length uint32
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return nil, err
+		}
+		headerLength = int(length)
+	}
+
+	header, err := io.ReadAll(io.LimitReader(r, int64(headerLength)))
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < headerLength {
+		return nil, errors.New("colorpalette: NumPy index plane header is truncated")
+	}
+
+	if !bytes.Contains(header, []byte("'|u1'")) && !bytes.Contains(header, []byte("'u1'")) {
+		return nil, errors.New("colorpalette: NumPy index plane needs to be of type uint8")
+	}
+	if bytes.Contains(header, []byte("'fortran_order': True")) {
+		return nil, errors.New("colorpalette: NumPy index plane needs to be in C order")
+	}
+
+	shape := npyShape.FindSubmatch(header)
+	if shape == nil {
+		return nil, errors.New("colorpalette: NumPy index plane needs to be two dimensional")
+	}
+	height, err := strconv.Atoi(string(shape[1]))
+	if err != nil {
+		return nil, errors.New("colorpalette: invalid NumPy index plane shape")
+	}
+	width, err := strconv.Atoi(string(shape[2]))
+	if err != nil {
+		return nil, errors.New("colorpalette: invalid NumPy index plane shape")
+	}
+
+	return readIndices(r, width, height)
+}
+
+// maxIndexPlane is the most indices that an index plane can hold, a gigapixel
+const maxIndexPlane = 1 << 30
+
+// readIndices reads the width x height indices of an index plane into a paletted image without palette.
+// The indices are read as they come, rather than allocating the size that a corrupt header claims.
+func readIndices(r io.Reader, width, height int) (*image.Paletted, error) {
+	if width < 0 || height < 0 || (height > 0 && width > maxIndexPlane/height) {
+		return nil, fmt.Errorf("colorpalette: invalid index plane size %dx%d", width, height)
+	}
+
+	pix, err := io.ReadAll(io.LimitReader(r, int64(width*height)))
+	if err != nil {
+		return nil, err
+	}
+	if len(pix) < width*height {
+		return nil, errors.New("colorpalette: index plane is truncated")
+	}
+
+	return &image.Paletted{Pix: pix, Stride: width, Rect: image.Rect(0, 0, width, height)}, nil
+}
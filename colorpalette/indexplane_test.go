@@ -0,0 +1,112 @@
+package colorpalette
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testPaletted returns a paletted image of testPalette with random indices, within a larger image
+// so that its bounds don't start at (0, 0)
+func testPaletted() *image.Paletted {
+	rng := rand.New(rand.NewSource(1))
+	full := image.NewPaletted(image.Rect(0, 0, 17, 9), testPalette.ToPalette())
+	for i := range full.Pix {
+		full.Pix[i] = uint8(rng.Intn(len(full.Palette)))
+	}
+
+	return full.SubImage(image.Rect(2, 1, 15, 8)).(*image.Paletted)
+}
+
+func TestIndexPlaneRoundTrip(t *testing.T) {
+	paletted := testPaletted()
+
+	for _, ext := range []string{".pgm", ".npy"} {
+		dir := t.TempDir()
+		indexPath, palettePath := filepath.Join(dir, "indices"+ext), filepath.Join(dir, "palette.json")
+		if err := ExportIndexPlane(paletted, indexPath, palettePath); err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+
+		imported, err := ImportIndexPlane(indexPath, palettePath)
+		if err != nil {
+			t.Fatalf("%s: importing the exported index plane: %v", ext, err)
+		}
+
+		bounds := paletted.Bounds()
+		if imported.Bounds() != bounds.Sub(bounds.Min) {
+			t.Fatalf("%s: imported bounds %v, want %v", ext, imported.Bounds(), bounds.Sub(bounds.Min))
+		}
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				if got, want := imported.ColorIndexAt(x, y), paletted.ColorIndexAt(bounds.Min.X+x, bounds.Min.Y+y); got != want {
+					t.Fatalf("%s: index at %d,%d is %d, want %d", ext, x, y, got, want)
+				}
+			}
+		}
+		if !reflect.DeepEqual(imported.Palette, paletted.Palette) {
+			t.Errorf("%s: imported palette %v, want %v", ext, imported.Palette, paletted.Palette)
+		}
+	}
+}
+
+// npyFile returns a NumPy file of version 1 with the header and data
+func npyFile(header string, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic + "\x01\x00")
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	buf.Write(data)
+
+	return buf.Bytes()
+}
+
+func TestImportIndexPlaneMalformed(t *testing.T) {
+	dir := t.TempDir()
+	palettePath := filepath.Join(dir, "palette.json")
+	if err := testPalette.ToJSONFile(palettePath); err != nil {
+		t.Fatal(err)
+	}
+
+	u1 := "{'descr': '|u1', 'fortran_order': False, 'shape': (2, 2), }\n"
+	inputs := map[string][]byte{
+		"plain pgm.pgm":        []byte("P2\n2 2\n255\n0 1 2 3\n"),
+		"16 bit.pgm":           []byte("P5\n2 2\n65535\n\x00\x00\x00\x00\x00\x00\x00\x00"),
+		"bad header.pgm":       []byte("P5\ntwo 2\n255\n\x00\x00\x00\x00"),
+		"truncated.pgm":        []byte("P5\n2 2\n255\n\x00\x00\x00"),
+		"huge.pgm":             []byte("P5\n100000000 100000000\n255\n\x00"),
+		"negative.pgm":         []byte("P5\n-2 2\n255\n\x00\x00\x00\x00"),
+		"out of range.pgm":     []byte("P5\n2 2\n255\n\x00\x01\x02\x09"),
+		"empty.pgm":            {},
+		"magic.npy":            []byte("\x93NUMPX\x01\x00\x00\x00"),
+		"float.npy":            npyFile("{'descr': '<f8', 'fortran_order': False, 'shape': (2, 2), }\n", make([]byte, 32)),
+		"fortran.npy":          npyFile("{'descr': '|u1', 'fortran_order': True, 'shape': (2, 2), }\n", make([]byte, 4)),
+		"three dims.npy":       npyFile("{'descr': '|u1', 'fortran_order': False, 'shape': (2, 2, 2), }\n", make([]byte, 8)),
+		"truncated.npy":        npyFile(u1, make([]byte, 3)),
+		"huge shape.npy":       npyFile("{'descr': '|u1', 'fortran_order': False, 'shape': (99999999999999999999, 2), }\n", nil),
+		"huge header.npy":      []byte(npyMagic + "\x02\x00\xff\xff\xff\xff{'descr'"),
+		"truncated header.npy": []byte(npyMagic + "\x01\x00\x40\x00{'descr'"),
+	}
+
+	for name, data := range inputs {
+		indexPath := filepath.Join(dir, name)
+		if err := os.WriteFile(indexPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ImportIndexPlane(indexPath, palettePath); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	// a valid file, so that the errors above aren't about the palette
+	indexPath := filepath.Join(dir, "valid.npy")
+	os.WriteFile(indexPath, npyFile(u1, []byte{0, 1, 2, 3}), 0644)
+	if _, err := ImportIndexPlane(indexPath, palettePath); err != nil {
+		t.Errorf("valid npy: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package colorpalette
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mielpeeters/dither/kdtree"
+)
+
+// IndexExtension is the extension of the files that hold the k-d tree index of a palette
+const IndexExtension = ".kdx"
+
+// IndexCacheDir is the directory in which LoadIndex caches the k-d tree indexes that it builds.
+// If it is empty, a "dither/indexes" directory in the user cache directory is used.
+var IndexCacheDir = ""
+
+var unsafeName = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// IndexPath returns the path of the k-d tree index of the palette with the given name,
+// stored next to the palette JSON file jsonFileName: "palettes.json" becomes "palettes.<name>.kdx"
+func IndexPath(jsonFileName, name string) string {
+	base := strings.TrimSuffix(jsonFileName, filepath.Ext(jsonFileName))
+
+	return base + "." + unsafeName.ReplaceAllString(name, "_") + IndexExtension
+}
+
+// ToIndexFile builds the k-d tree index of the palette, and writes it to path
+func (colorpalette *ColorPalette) ToIndexFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := kdtree.NewPaletteIndex(colorpalette.ToPalette()).Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// LoadIndex returns the k-d tree index of the palette: the one saved along with the palette JSON file jsonFileName
+// (see IndexPath and ToIndexFile), or else the one in IndexCacheDir. When neither is there or up to date,
+// a new one is built and cached in IndexCacheDir (if possible), so that the next run can skip building it.
+// Nothing is written next to the palette JSON file.
+func (colorpalette *ColorPalette) LoadIndex(jsonFileName string) *kdtree.PaletteIndex {
+	palette := colorpalette.ToPalette()
+
+	if index, ok := readIndex(IndexPath(jsonFileName, colorpalette.Name), palette); ok {
+		return index
+	}
+
+	dir, err := indexCacheDir()
+	if err != nil {
+		return kdtree.NewPaletteIndex(palette)
+	}
+	path := filepath.Join(dir, colorpalette.indexKey()+IndexExtension)
+
+	if index, ok := readIndex(path, palette); ok {
+		return index
+	}
+
+	index := kdtree.NewPaletteIndex(palette)
+
+	// caching is best effort, a failure only means building the index again next time.
+	// The index is renamed into place, so that an index that is written at the same time is never read half-way.
+	if os.MkdirAll(dir, 0755) == nil {
+		if file, err := os.CreateTemp(dir, "*.tmp"); err == nil {
+			err := index.Encode(file)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil || os.Rename(file.Name(), path) != nil {
+				os.Remove(file.Name())
+			}
+		}
+	}
+
+	return index
+}
+
+// readIndex reads the k-d tree index at path, if it is there and belongs to palette
+func readIndex(path string, palette color.Palette) (*kdtree.PaletteIndex, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	index, err := kdtree.DecodePaletteIndex(file)
+	if err != nil || !index.Matches(palette) {
+		return nil, false
+	}
+
+	return index, true
+}
+
+// indexKey hashes the colors of the palette, which are all that its index depends on
+func (colorpalette *ColorPalette) indexKey() string {
+	h := sha256.New()
+	for _, clr := range colorpalette.Colors {
+		h.Write([]byte{clr.R, clr.G, clr.B, clr.A})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func indexCacheDir() (string, error) {
+	if IndexCacheDir != "" {
+		return IndexCacheDir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "dither", "indexes"), nil
+}
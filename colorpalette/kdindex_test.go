@@ -0,0 +1,54 @@
+package colorpalette
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIndex(t *testing.T) {
+	saved := IndexCacheDir
+	t.Cleanup(func() { IndexCacheDir = saved })
+	IndexCacheDir = t.TempDir()
+
+	libraryDir := t.TempDir()
+	library := filepath.Join(libraryDir, "palettes.json")
+	if err := testPalette.ToJSONFile(library); err != nil {
+		t.Fatal(err)
+	}
+
+	index := testPalette.LoadIndex(library)
+	if !index.Matches(testPalette.ToPalette()) {
+		t.Fatalf("the loaded index doesn't belong to the palette")
+	}
+
+	// reading the palettes doesn't write next to them, the index is cached instead
+	entries, _ := os.ReadDir(libraryDir)
+	if len(entries) != 1 {
+		t.Errorf("the library directory has %d files, want only the library", len(entries))
+	}
+	cached, _ := filepath.Glob(filepath.Join(IndexCacheDir, "*"+IndexExtension))
+	if len(cached) != 1 {
+		t.Fatalf("%d cached indexes, want 1", len(cached))
+	}
+
+	// a palette with other colors doesn't get the cached index of this one
+	other := testPalette
+	other.Colors = other.Colors[1:]
+	if index := other.LoadIndex(library); !index.Matches(other.ToPalette()) {
+		t.Errorf("the index of a palette with other colors doesn't belong to it")
+	}
+
+	// an index that was written next to the library on request is used, and left as it is
+	indexPath := IndexPath(library, testPalette.Name)
+	if err := testPalette.ToIndexFile(indexPath); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(IndexCacheDir)
+	if index := testPalette.LoadIndex(library); !index.Matches(testPalette.ToPalette()) {
+		t.Errorf("the index next to the library doesn't belong to the palette")
+	}
+	if _, err := os.Stat(IndexCacheDir); err == nil {
+		t.Errorf("the index next to the library was cached again")
+	}
+}
@@ -0,0 +1,101 @@
+package colorpalette
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// LospecURL is the address of the Lospec palette API, the slug is filled in at %s
+var LospecURL = "https://lospec.com/palette-list/%s.json"
+
+// LospecCacheDir is the directory in which downloaded Lospec palettes are cached.
+// If it is empty, a "dither/lospec" directory in the user cache directory is used.
+var LospecCacheDir = ""
+
+// maxLospecResponse is the most that is read of a response of the Lospec API, palettes are a lot smaller
+const maxLospecResponse = 1 << 20
+
+var lospecSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// lospecPalette is the response of the Lospec palette API
+type lospecPalette struct {
+	Name   string   `json:"name"`
+	Author string   `json:"author"`
+	Colors []string `json:"colors"`
+}
+
+// FetchLospec downloads the palette with the given slug (like "pico-8") from lospec.com.
+// Palettes are cached on disk (see LospecCacheDir), so each palette is only downloaded once.
+func FetchLospec(slug string) (ColorPalette, error) {
+	if !lospecSlug.MatchString(slug) {
+		return ColorPalette{}, fmt.Errorf("colorpalette: invalid lospec palette slug %q", slug)
+	}
+
+	cachePath := ""
+	if dir, err := lospecCacheDir(); err == nil {
+		cachePath = filepath.Join(dir, slug+".json")
+
+		if data, err := os.ReadFile(cachePath); err == nil {
+			palette := ColorPalette{}
+			if err := json.Unmarshal(data, &palette); err == nil {
+				return palette, nil
+			}
+		}
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Get(fmt.Sprintf(LospecURL, slug))
+	if err != nil {
+		return ColorPalette{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ColorPalette{}, fmt.Errorf("colorpalette: fetching lospec palette %q failed: %s", slug, resp.Status)
+	}
+
+	var response lospecPalette
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxLospecResponse)).Decode(&response); err != nil {
+		return ColorPalette{}, err
+	}
+
+	palette, err := FromHex(response.Colors)
+	if err != nil {
+		return ColorPalette{}, err
+	}
+	palette.Name = response.Name
+	if palette.Name == "" {
+		palette.Name = slug
+	}
+
+	// caching is best effort, a failure only means downloading again next time
+	if cachePath != "" {
+		if output, err := json.Marshal(palette); err == nil {
+			if os.MkdirAll(filepath.Dir(cachePath), 0755) == nil {
+				os.WriteFile(cachePath, output, 0644)
+			}
+		}
+	}
+
+	return palette, nil
+}
+
+func lospecCacheDir() (string, error) {
+	if LospecCacheDir != "" {
+		return LospecCacheDir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "dither", "lospec"), nil
+}
@@ -0,0 +1,87 @@
+package colorpalette
+
+import (
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// useLospecServer points LospecURL at a test server with handler, and LospecCacheDir at a temporary directory
+func useLospecServer(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+
+	url, cacheDir := LospecURL, LospecCacheDir
+	t.Cleanup(func() {
+		server.Close()
+		LospecURL, LospecCacheDir = url, cacheDir
+	})
+	LospecURL = server.URL + "/palette-list/%s.json"
+	LospecCacheDir = t.TempDir()
+}
+
+func TestFetchLospec(t *testing.T) {
+	var requests atomic.Int32
+	useLospecServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/palette-list/pico-8.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"name": "PICO-8", "author": "", "colors": ["000000", "1d2b53", "ff77a8"]}`)
+	})
+
+	want := ColorPalette{
+		Name:   "PICO-8",
+		Colors: []color.RGBA{{0, 0, 0, 255}, {0x1d, 0x2b, 0x53, 255}, {0xff, 0x77, 0xa8, 255}},
+	}
+
+	for i := 0; i < 2; i++ {
+		palette, err := FetchLospec("pico-8")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(palette, want) {
+			t.Errorf("fetch %d: got %v, want %v", i, palette, want)
+		}
+	}
+
+	// the second fetch is read from the cache
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+
+	if _, err := FetchLospec("not-there"); err == nil {
+		t.Errorf("a palette that isn't found gives no error")
+	}
+}
+
+func TestFetchLospecInvalidSlug(t *testing.T) {
+	var requests atomic.Int32
+	useLospecServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	})
+
+	for _, slug := range []string{"", "Pico-8", "../pico-8", "pico--8", "pico-8/", "-pico"} {
+		if _, err := FetchLospec(slug); err == nil {
+			t.Errorf("%q: no error", slug)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("invalid slugs made %d requests", n)
+	}
+}
+
+func TestFetchLospecOversized(t *testing.T) {
+	useLospecServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"name": "%s", "colors": ["000000"]}`, strings.Repeat("a", 2*maxLospecResponse))
+	})
+
+	if _, err := FetchLospec("large"); err == nil {
+		t.Errorf("a response larger than maxLospecResponse gives no error")
+	}
+}
@@ -0,0 +1,152 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// weightedColor is a distinct color of an image, with the amount of pixels that have it
+type weightedColor struct {
+	clr   color.RGBA
+	count int
+}
+
+// histogram returns the distinct opaque colors of img, with their amount of pixels.
+// Pixels with an alpha below 128 are left out, the others are counted as opaque colors.
+func histogram(img image.Image) []weightedColor {
+	counts := map[color.RGBA]int{}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+
+			// undo the premultiplication by alpha
+			clr := color.RGBA{uint8((r * 0xffff / a) >> 8), uint8((g * 0xffff / a) >> 8), uint8((b * 0xffff / a) >> 8), 255}
+			counts[clr]++
+		}
+	}
+
+	colors := make([]weightedColor, 0, len(counts))
+	for clr, count := range counts {
+		colors = append(colors, weightedColor{clr, count})
+	}
+
+	// a fixed order, so that the results don't depend on the order of the map
+	sort.Slice(colors, func(i, j int) bool {
+		left, right := colors[i].clr, colors[j].clr
+		if left.R != right.R {
+			return left.R < right.R
+		}
+		if left.G != right.G {
+			return left.G < right.G
+		}
+		return left.B < right.B
+	})
+
+	return colors
+}
+
+// channel returns channel c (0 for red, 1 for green, 2 for blue) of clr
+func channel(clr color.RGBA, c int) uint8 {
+	switch c {
+	case 0:
+		return clr.R
+	case 1:
+		return clr.G
+	}
+	return clr.B
+}
+
+// channelSpread returns the sum of the squared distances of the pixels of the colors to their mean, along channel c
+func channelSpread(colors []weightedColor, c int) float64 {
+	var count, sum, squares float64
+	for _, wc := range colors {
+		value := float64(channel(wc.clr, c))
+		count += float64(wc.count)
+		sum += value * float64(wc.count)
+		squares += value * value * float64(wc.count)
+	}
+	if count == 0 {
+		return 0
+	}
+
+	return squares - sum*sum/count
+}
+
+// average returns the mean color of the weighted colors
+func average(colors []weightedColor) color.RGBA {
+	var sum [3]float64
+	total := 0
+
+	for _, wc := range colors {
+		sum[0] += float64(wc.clr.R) * float64(wc.count)
+		sum[1] += float64(wc.clr.G) * float64(wc.count)
+		sum[2] += float64(wc.clr.B) * float64(wc.count)
+		total += wc.count
+	}
+
+	return fromChannels([]float64{sum[0] / float64(total), sum[1] / float64(total), sum[2] / float64(total)})
+}
+
+// MedianCut creates a palette of k colors with the median cut algorithm: starting with a box around all colors
+// of img, the box with the largest spread of its pixels along a color channel is split at the median pixel along
+// that channel, until there are k boxes. Each box gives the average color of its pixels.
+// Images with fewer than k distinct colors give a palette of those colors.
+func MedianCut(img image.Image, k int) color.Palette {
+	boxes := [][]weightedColor{histogram(img)}
+	if len(boxes[0]) == 0 || k < 1 {
+		return color.Palette{}
+	}
+
+	for len(boxes) < k {
+		// find the box and channel with the largest spread of the pixels: the sum of their squared distances
+		// to the mean, so that a box with many pixels is split before a wide box of a few outliers
+		bestBox, bestChannel, bestSpread := -1, 0, 0.0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+
+			for c := 0; c < 3; c++ {
+				if spread := channelSpread(box, c); spread > bestSpread {
+					bestBox, bestChannel, bestSpread = i, c, spread
+				}
+			}
+		}
+		if bestBox < 0 {
+			// every box holds a single color
+			break
+		}
+
+		box := boxes[bestBox]
+		sort.SliceStable(box, func(i, j int) bool {
+			return channel(box[i].clr, bestChannel) < channel(box[j].clr, bestChannel)
+		})
+
+		// split at the median pixel, keeping at least one color on both sides
+		total := 0
+		for _, wc := range box {
+			total += wc.count
+		}
+		cut, seen := 1, box[0].count
+		for cut < len(box)-1 && seen < total/2 {
+			seen += box[cut].count
+			cut++
+		}
+
+		boxes[bestBox] = box[:cut]
+		boxes = append(boxes, box[cut:])
+	}
+
+	palette := color.Palette{}
+	for _, box := range boxes {
+		palette = append(palette, average(box))
+	}
+
+	return palette
+}
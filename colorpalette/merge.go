@@ -0,0 +1,93 @@
+package colorpalette
+
+import (
+	"image/color"
+	"math"
+	"strings"
+)
+
+// MergeThreshold is the DeltaE below which two colors are considered duplicates by Merge
+var MergeThreshold = 5.0
+
+// mergeColor is a color that possibly represents multiple collapsed colors
+type mergeColor struct {
+	rgba   [4]float64
+	weight float64
+}
+
+func (mc *mergeColor) color() color.RGBA {
+	return fromChannels(mc.rgba[:])
+}
+
+// absorb collapses other into mc, as a weighted average of both
+func (mc *mergeColor) absorb(other mergeColor) {
+	total := mc.weight + other.weight
+	for i := range mc.rgba {
+		mc.rgba[i] = (mc.rgba[i]*mc.weight + other.rgba[i]*other.weight) / total
+	}
+	mc.weight = total
+}
+
+// Merge combines the colors of multiple ColorPalettes into one.
+// Colors closer to each other than MergeThreshold (as DeltaE) are collapsed into their average.
+// If maxColors is positive and there are still more colors left than that, the closest
+// pairs of colors are collapsed until only maxColors remain.
+// The merged palette is Transparent if any of the palettes is.
+func Merge(maxColors int, palettes ...ColorPalette) ColorPalette {
+	names := []string{}
+	merged := []mergeColor{}
+	transparent := false
+
+	for _, palette := range palettes {
+		if palette.Name != "" {
+			names = append(names, palette.Name)
+		}
+		transparent = transparent || palette.Transparent
+
+	colors:
+		for _, clr := range palette.Colors {
+			candidate := mergeColor{
+				rgba:   [4]float64{float64(clr.R), float64(clr.G), float64(clr.B), float64(clr.A)},
+				weight: 1,
+			}
+
+			for i := range merged {
+				if DeltaE(merged[i].color(), clr) < MergeThreshold {
+					merged[i].absorb(candidate)
+					continue colors
+				}
+			}
+
+			merged = append(merged, candidate)
+		}
+	}
+
+	for maxColors > 0 && len(merged) > maxColors {
+		// find the closest pair, and collapse it
+		best := math.Inf(1)
+		var bestI, bestJ int
+		for i := range merged {
+			for j := i + 1; j < len(merged); j++ {
+				dist := DeltaE(merged[i].color(), merged[j].color())
+				if dist < best {
+					best = dist
+					bestI, bestJ = i, j
+				}
+			}
+		}
+
+		merged[bestI].absorb(merged[bestJ])
+		merged = append(merged[:bestJ], merged[bestJ+1:]...)
+	}
+
+	palette := ColorPalette{
+		Name:        strings.Join(names, "+"),
+		Colors:      []color.RGBA{},
+		Transparent: transparent,
+	}
+	for i := range merged {
+		palette.Colors = append(palette.Colors, merged[i].color())
+	}
+
+	return palette
+}
@@ -0,0 +1,41 @@
+package colorpalette
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	first := ColorPalette{Name: "first", Colors: []color.RGBA{{255, 0, 0, 255}, {0, 0, 255, 255}}}
+	second := ColorPalette{Name: "second", Colors: []color.RGBA{{253, 2, 0, 255}, {0, 255, 0, 255}}, Transparent: true}
+
+	if DeltaE(first.Colors[0], second.Colors[0]) >= MergeThreshold {
+		t.Fatalf("the reds are not within MergeThreshold of each other")
+	}
+
+	// the reds collapse into their average, the other colors are kept
+	merged := Merge(0, first, second)
+	want := ColorPalette{
+		Name:        "first+second",
+		Colors:      []color.RGBA{{254, 1, 0, 255}, {0, 0, 255, 255}, {0, 255, 0, 255}},
+		Transparent: true,
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged into %v, want %v", merged, want)
+	}
+
+	// colors outside of the threshold are only collapsed to reach maxColors
+	merged = Merge(2, first, second)
+	if len(merged.Colors) != 2 {
+		t.Errorf("merged into %d colors, want 2", len(merged.Colors))
+	}
+
+	// a lower threshold keeps the reds apart
+	threshold := MergeThreshold
+	t.Cleanup(func() { MergeThreshold = threshold })
+	MergeThreshold = 0.5
+	if merged := Merge(0, first, second); len(merged.Colors) != 4 {
+		t.Errorf("with threshold %v, merged into %v, want all four colors", MergeThreshold, merged.Colors)
+	}
+}
@@ -0,0 +1,184 @@
+package colorpalette
+
+import (
+	"image/color"
+	"math"
+	"sync"
+
+	"github.com/mielpeeters/dither/geom"
+)
+
+// NamedMetric is a distance of color points with the name it is chosen by
+type NamedMetric struct {
+	Name     string
+	Distance func(pnt1, pnt2 *geom.Point) float64
+}
+
+// Metrics are the available color distances: "redmean" (geom.RedMeanDistance), "euclidean"
+// (geom.SquaredEuclideanDistance), "lab" (LabDistance) and "ciede2000" (CIEDE2000Distance),
+// from fast to perceptually accurate
+var Metrics = []NamedMetric{
+	{"redmean", geom.RedMeanDistance},
+	{"euclidean", geom.SquaredEuclideanDistance},
+	{"lab", LabDistance},
+	{"ciede2000", CIEDE2000Distance},
+}
+
+// Metric is the color distance that the k-means palettes (of Create, Bisecting, Refine, NewPaletteBuilder
+// and NewPaletteTree) are clustered with
+var Metric = Metrics[0]
+
+// MetricWithName returns the color distance with the given name, and whether it exists
+func MetricWithName(name string) (NamedMetric, bool) {
+	for _, metric := range Metrics {
+		if metric.Name == name {
+			return metric, true
+		}
+	}
+
+	return NamedMetric{}, false
+}
+
+// LabDistance returns the squared euclidean distance of two color points in CIE L*a*b* (the CIE76 color difference),
+// which only uses the first 3 dimensions of the points, like geom.RedMeanDistance
+func LabDistance(pnt1, pnt2 *geom.Point) float64 {
+	lab1, lab2 := pointToLab(pnt1), pointToLab(pnt2)
+
+	var dist float64
+	for i := 0; i < 3; i++ {
+		dist += (lab1[i] - lab2[i]) * (lab1[i] - lab2[i])
+	}
+
+	return dist
+}
+
+// CIEDE2000Distance returns the square of the CIEDE2000 color difference of two color points,
+// which only uses the first 3 dimensions of the points, like geom.RedMeanDistance
+func CIEDE2000Distance(pnt1, pnt2 *geom.Point) float64 {
+	diff := ciede2000(pointToLab(pnt1), pointToLab(pnt2))
+
+	return diff * diff
+}
+
+// pointToLab returns the CIE L*a*b* coordinates of a color point
+func pointToLab(pnt *geom.Point) []float64 {
+	rgba := []float64{float64(pnt.Coordinates[0]), float64(pnt.Coordinates[1]), float64(pnt.Coordinates[2]), 0}
+
+	return ConvRGBAtoLABA(rgba)
+}
+
+// ciede2000 returns the CIEDE2000 color difference of two L*a*b* colors, as in Sharma et al. (2005)
+func ciede2000(lab1, lab2 []float64) float64 {
+	const pow25To7 = 6103515625.0
+	degrees := func(rad float64) float64 { return rad * 180 / math.Pi }
+	radians := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	l1, a1, b1 := lab1[0], lab1[1], lab1[2]
+	l2, a2, b2 := lab2[0], lab2[1], lab2[2]
+
+	// the a* axis is stretched for neutral colors
+	cBar := (math.Hypot(a1, b1) + math.Hypot(a2, b2)) / 2
+	g := 0.5 * (1 - math.Sqrt(math.Pow(cBar, 7)/(math.Pow(cBar, 7)+pow25To7)))
+	a1, a2 = (1+g)*a1, (1+g)*a2
+
+	c1, c2 := math.Hypot(a1, b1), math.Hypot(a2, b2)
+	hue := func(a, b float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		return math.Mod(degrees(math.Atan2(b, a))+360, 360)
+	}
+	h1, h2 := hue(a1, b1), hue(a2, b2)
+
+	dL := l2 - l1
+	dC := c2 - c1
+	dh := 0.0
+	if c1*c2 != 0 {
+		dh = h2 - h1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(c1*c2) * math.Sin(radians(dh)/2)
+
+	lBar := (l1 + l2) / 2
+	cBar = (c1 + c2) / 2
+	hBar := h1 + h2
+	if c1*c2 != 0 {
+		switch {
+		case math.Abs(h1-h2) <= 180:
+			hBar /= 2
+		case hBar < 360:
+			hBar = (hBar + 360) / 2
+		default:
+			hBar = (hBar - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos(radians(hBar-30)) + 0.24*math.Cos(radians(2*hBar)) +
+		0.32*math.Cos(radians(3*hBar+6)) - 0.20*math.Cos(radians(4*hBar-63))
+	dTheta := 30 * math.Exp(-((hBar-275)/25)*((hBar-275)/25))
+	rC := 2 * math.Sqrt(math.Pow(cBar, 7)/(math.Pow(cBar, 7)+pow25To7))
+	sL := 1 + 0.015*(lBar-50)*(lBar-50)/math.Sqrt(20+(lBar-50)*(lBar-50))
+	sC := 1 + 0.045*cBar
+	sH := 1 + 0.015*cBar*t
+	rT := -math.Sin(radians(2*dTheta)) * rC
+
+	l, c, h := dL/sL, dC/sC, dH/sH
+
+	return math.Sqrt(l*l + c*c + h*h + rT*c*h)
+}
+
+// MetricIndex finds the palette color closest to a color by a color distance (see Metrics), instead of
+// the distance that color.Palette.Index uses. It remembers the colors it has looked up, as the Lab distances
+// are slow to compute. It is safe for concurrent use.
+type MetricIndex struct {
+	colors   []geom.Point
+	distance func(pnt1, pnt2 *geom.Point) float64
+
+	mu    sync.Mutex
+	found map[color.RGBA]int
+}
+
+// NewMetricIndex creates the index of palette for the given distance
+func NewMetricIndex(palette color.Palette, distance func(pnt1, pnt2 *geom.Point) float64) *MetricIndex {
+	index := MetricIndex{
+		colors:   make([]geom.Point, len(palette)),
+		distance: distance,
+		found:    map[color.RGBA]int{},
+	}
+
+	for i, clr := range palette {
+		index.colors[i] = colorToPoint(clr)
+	}
+
+	return &index
+}
+
+// Index returns the index of the palette color closest to clr
+func (index *MetricIndex) Index(clr color.Color) int {
+	rgba := ToRGBA(clr)
+
+	index.mu.Lock()
+	closest, ok := index.found[rgba]
+	index.mu.Unlock()
+	if ok {
+		return closest
+	}
+
+	point := colorToPoint(rgba)
+	minDist := math.Inf(1)
+	for i := range index.colors {
+		if dist := index.distance(&point, &index.colors[i]); dist < minDist {
+			closest, minDist = i, dist
+		}
+	}
+
+	index.mu.Lock()
+	index.found[rgba] = closest
+	index.mu.Unlock()
+
+	return closest
+}
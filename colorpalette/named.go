@@ -0,0 +1,136 @@
+package colorpalette
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// builtin maps the names of the built-in palettes to their colors, in hex notation
+var builtin = map[string][]string{
+	"1-bit": {"000000", "ffffff"},
+	"gameboy": {
+		"0f380f", "306230", "8bac0f", "9bbc0f",
+	},
+	"pico-8": {
+		"000000", "1d2b53", "7e2553", "008751", "ab5236", "5f574f", "c2c3c7", "fff1e8",
+		"ff004d", "ffa300", "ffec27", "00e436", "29adff", "83769c", "ff77a8", "ffccaa",
+	},
+	"cga": {
+		"000000", "0000aa", "00aa00", "00aaaa", "aa0000", "aa00aa", "aa5500", "aaaaaa",
+		"555555", "5555ff", "55ff55", "55ffff", "ff5555", "ff55ff", "ffff55", "ffffff",
+	},
+	"ega": egaColors(),
+	"c64": {
+		"000000", "ffffff", "68372b", "70a4b2", "6f3d86", "588d43", "352879", "b8c76f",
+		"6f4f25", "433900", "9a6759", "444444", "6c6c6c", "9ad284", "6c5eb5", "959595",
+	},
+	"zx-spectrum": {
+		"000000", "0000d7", "d70000", "d700d7", "00d700", "00d7d7", "d7d700", "d7d7d7",
+		"0000ff", "ff0000", "ff00ff", "00ff00", "00ffff", "ffff00", "ffffff",
+	},
+	"nes": {
+		"747474", "24188c", "0000a8", "44009c", "8c0074", "a80010", "a40000", "7c0800",
+		"402c00", "004400", "005000", "003c14", "183c5c", "000000", "bcbcbc", "0070ec",
+		"2038ec", "8000f0", "bc00bc", "e40058", "d82800", "c84c0c", "887000", "009400",
+		"00a800", "009038", "008088", "fcfcfc", "3cbcfc", "5c94fc", "cc88fc", "f478fc",
+		"fc74b4", "fc7460", "fc9838", "f0bc3c", "80d010", "4cdc48", "58f898", "00e8d8",
+		"787878", "a8e4fc", "c4d4fc", "d4c8fc", "fcc4fc", "fcc4d8", "fcbcb0", "fcd8a8",
+		"fce4a0", "e0fca0", "a8f0bc", "b0fccc", "9cfcf0", "c4c4c4",
+	},
+}
+
+// aliases maps alternative names to the names of built-in palettes
+var aliases = map[string]string{
+	"1bit":         "1-bit",
+	"bw":           "1-bit",
+	"gb":           "gameboy",
+	"game-boy":     "gameboy",
+	"pico8":        "pico-8",
+	"commodore-64": "c64",
+	"commodore64":  "c64",
+	"zx":           "zx-spectrum",
+	"zxspectrum":   "zx-spectrum",
+}
+
+// registered holds the palettes added with Register
+var registered = map[string]ColorPalette{}
+var registryMu sync.RWMutex
+
+// egaColors returns the 64 colors of the EGA: every combination of four levels of red, green and blue
+func egaColors() []string {
+	levels := []int{0x00, 0x55, 0xaa, 0xff}
+	colors := []string{}
+
+	for _, r := range levels {
+		for _, g := range levels {
+			for _, b := range levels {
+				colors = append(colors, fmt.Sprintf("%02x%02x%02x", r, g, b))
+			}
+		}
+	}
+
+	return colors
+}
+
+// Named returns a built-in (or registered) palette by name, like "pico-8", "gameboy", "nes", "cga", "ega",
+// "c64", "zx-spectrum" or "1-bit". Names are case insensitive. The boolean reports whether the palette exists.
+// The bit depth palettes are generated from their names: like "rgb332" and "rgb565" (see RGB) or "gray-4" and
+// "gray-16-gamma-2.2" (see ParseBitDepth).
+func Named(name string) (ColorPalette, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+
+	registryMu.RLock()
+	palette, ok := registered[name]
+	registryMu.RUnlock()
+	if ok {
+		return palette, true
+	}
+
+	hexes, ok := builtin[name]
+	if !ok {
+		palette, err := ParseBitDepth(name)
+		return palette, err == nil
+	}
+
+	palette, err := FromHex(hexes)
+	if err != nil {
+		return ColorPalette{}, false
+	}
+	palette.Name = name
+
+	return palette, true
+}
+
+// Register adds a palette to the registry, so it can be found by Named.
+// A registered palette takes precedence over a built-in palette with the same name.
+func Register(palette ColorPalette) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registered[strings.ToLower(palette.Name)] = palette
+}
+
+// Names returns the names of all built-in and registered palettes, sorted alphabetically
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := []string{}
+	for name := range builtin {
+		names = append(names, name)
+	}
+	for name := range registered {
+		if _, ok := builtin[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
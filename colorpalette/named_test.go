@@ -0,0 +1,55 @@
+package colorpalette
+
+import (
+	"image/color"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	palettes := map[string]int{"pico-8": 16, "PICO8": 16, " gameboy ": 4, "ega": 64, "c64": 16, "commodore-64": 16, "rgb332": 256, "gray-4": 4}
+	for name, colors := range palettes {
+		palette, ok := Named(name)
+		if !ok || len(palette.Colors) != colors {
+			t.Errorf("%q: found %v with %d colors, want %d", name, ok, len(palette.Colors), colors)
+		}
+	}
+
+	if _, ok := Named("not-a-palette"); ok {
+		t.Errorf("an unknown name is found")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registered, "test-register")
+		delete(registered, "gameboy")
+		registryMu.Unlock()
+	})
+
+	custom := ColorPalette{Name: "Test-Register", Colors: []color.RGBA{{1, 2, 3, 255}}}
+	Register(custom)
+	if palette, ok := Named("test-register"); !ok || !reflect.DeepEqual(palette, custom) {
+		t.Errorf("the registered palette is found as %v (%v), want %v", palette, ok, custom)
+	}
+
+	names := Names()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("the names %v are not sorted", names)
+	}
+	if i := sort.SearchStrings(names, "test-register"); i == len(names) || names[i] != "test-register" {
+		t.Errorf("the names %v don't include the registered palette", names)
+	}
+
+	// a registered palette takes precedence over the built-in one, without listing the name twice
+	gameboy := ColorPalette{Name: "gameboy", Colors: []color.RGBA{{0, 0, 0, 255}}}
+	Register(gameboy)
+	if palette, _ := Named("gameboy"); !reflect.DeepEqual(palette, gameboy) {
+		t.Errorf("gameboy is %v, want the registered palette", palette)
+	}
+	if len(Names()) != len(names) {
+		t.Errorf("registering gameboy changes the amount of names from %d to %d", len(names), len(Names()))
+	}
+}
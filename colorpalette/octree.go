@@ -0,0 +1,251 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// octreeDepth is the depth of the leaves of a full octree: one level per bit of the color channels
+const octreeDepth = 8
+
+// octreeNode is a node of the octree that Octree builds. Its children split its part of the color cube in eight.
+type octreeNode struct {
+	children [8]*octreeNode
+	leaf     bool
+	count    int
+	sum      [3]int
+}
+
+// Octree creates a palette of k colors with octree quantization: the colors of img are put in an octree that
+// splits the color cube in eight at every level, after which the nodes whose merge adds the least error are merged
+// (from the deepest level up) until only k leaves are left. When merging all children of a node would leave fewer
+// than k, only the closest children are joined. Each leaf gives the average color of its pixels.
+// Images with fewer than k distinct colors give a palette of those colors.
+func Octree(img image.Image, k int) color.Palette {
+	if k < 1 {
+		return color.Palette{}
+	}
+
+	root := &octreeNode{}
+	// levels holds the nodes that have children, per depth
+	levels := make([][]*octreeNode, octreeDepth)
+	leaves := 0
+
+	for _, wc := range histogram(img) {
+		node := root
+		for depth := 0; depth < octreeDepth; depth++ {
+			shift := 7 - depth
+			child := int(wc.clr.R>>shift&1)<<2 | int(wc.clr.G>>shift&1)<<1 | int(wc.clr.B>>shift&1)
+
+			if node.children[child] == nil {
+				if !node.hasChildren() {
+					levels[depth] = append(levels[depth], node)
+				}
+				node.children[child] = &octreeNode{leaf: depth == octreeDepth-1}
+				if depth == octreeDepth-1 {
+					leaves++
+				}
+			}
+			node = node.children[child]
+		}
+
+		node.count += wc.count
+		node.sum[0] += int(wc.clr.R) * wc.count
+		node.sum[1] += int(wc.clr.G) * wc.count
+		node.sum[2] += int(wc.clr.B) * wc.count
+	}
+
+	// merge the children of the nodes into them, starting at the deepest level and with the nodes
+	// whose merge adds the least error, until k leaves are left
+	for depth := octreeDepth - 1; depth >= 0 && leaves > k; depth-- {
+		nodes := levels[depth]
+		costs := make(map[*octreeNode]float64, len(nodes))
+		for _, node := range nodes {
+			costs[node] = node.mergeCost()
+		}
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return costs[nodes[i]] < costs[nodes[j]]
+		})
+
+		for _, node := range nodes {
+			if leaves <= k {
+				break
+			}
+			// merging all children of these nodes would leave fewer than k leaves
+			if node.leaf || leaves-(node.childCount()-1) < k {
+				continue
+			}
+			leaves -= node.merge() - 1
+		}
+
+		// only join the closest children of the nodes, until there are k leaves
+		for ; leaves > k; leaves-- {
+			var best *octreeNode
+			bestCost := 0.0
+			for _, node := range nodes {
+				if node.leaf || node.childCount() < 2 {
+					continue
+				}
+				if _, _, cost := node.closestChildren(); best == nil || cost < bestCost {
+					best, bestCost = node, cost
+				}
+			}
+			if best == nil {
+				// all of the nodes were merged, continue a level up
+				break
+			}
+			best.mergeClosest()
+		}
+	}
+
+	palette := color.Palette{}
+	root.collect(&palette)
+
+	return palette
+}
+
+// hasChildren reports whether the node has any children
+func (node *octreeNode) hasChildren() bool {
+	for _, child := range node.children {
+		if child != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// childCount returns the amount of children of the node
+func (node *octreeNode) childCount() int {
+	count := 0
+	for _, child := range node.children {
+		if child != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// mean returns the average color of the pixels of a leaf
+func (node *octreeNode) mean() [3]float64 {
+	return [3]float64{
+		float64(node.sum[0]) / float64(node.count),
+		float64(node.sum[1]) / float64(node.count),
+		float64(node.sum[2]) / float64(node.count),
+	}
+}
+
+// joinCost returns how much the squared error of the pixels grows when the leaves are joined into one:
+// the amount of pixels of each leaf times the squared distance of its mean to the mean of them all
+func joinCost(leaves []*octreeNode) float64 {
+	count := 0
+	var sum [3]int
+	for _, leaf := range leaves {
+		count += leaf.count
+		for c := range sum {
+			sum[c] += leaf.sum[c]
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+
+	cost := 0.0
+	for _, leaf := range leaves {
+		if leaf.count == 0 {
+			continue
+		}
+		mean := leaf.mean()
+		for c := range mean {
+			d := mean[c] - float64(sum[c])/float64(count)
+			cost += float64(leaf.count) * d * d
+		}
+	}
+	return cost
+}
+
+// mergeCost returns how much merging the children of the node adds to the squared error, see joinCost.
+// The children need to be leaves.
+func (node *octreeNode) mergeCost() float64 {
+	children := []*octreeNode{}
+	for _, child := range node.children {
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+	return joinCost(children)
+}
+
+// closestChildren returns the two children of the node that add the least error when joined (see joinCost),
+// and that error. The children need to be leaves, at least two of them.
+func (node *octreeNode) closestChildren() (int, int, float64) {
+	bestI, bestJ, bestCost := -1, -1, 0.0
+	for i, left := range node.children {
+		for j := i + 1; j < len(node.children); j++ {
+			right := node.children[j]
+			if left == nil || right == nil {
+				continue
+			}
+			if cost := joinCost([]*octreeNode{left, right}); bestI < 0 || cost < bestCost {
+				bestI, bestJ, bestCost = i, j, cost
+			}
+		}
+	}
+
+	return bestI, bestJ, bestCost
+}
+
+// mergeClosest joins the closest children of the node (see closestChildren) into one leaf
+func (node *octreeNode) mergeClosest() {
+	bestI, bestJ, _ := node.closestChildren()
+	left, right := node.children[bestI], node.children[bestJ]
+	left.count += right.count
+	for c := range left.sum {
+		left.sum[c] += right.sum[c]
+	}
+	node.children[bestJ] = nil
+}
+
+// merge turns the node into a leaf holding all pixels of its children, which need to be leaves.
+// It returns the amount of children that were merged.
+func (node *octreeNode) merge() int {
+	merged := 0
+	node.count = 0
+	node.sum = [3]int{}
+
+	for i, child := range node.children {
+		if child == nil {
+			continue
+		}
+
+		node.count += child.count
+		for c := range node.sum {
+			node.sum[c] += child.sum[c]
+		}
+		node.children[i] = nil
+		merged++
+	}
+	node.leaf = true
+
+	return merged
+}
+
+// collect appends the average colors of the leaves below (or at) the node to palette
+func (node *octreeNode) collect(palette *color.Palette) {
+	if node.leaf {
+		if node.count > 0 {
+			*palette = append(*palette, fromChannels([]float64{
+				float64(node.sum[0]) / float64(node.count),
+				float64(node.sum[1]) / float64(node.count),
+				float64(node.sum[2]) / float64(node.count),
+			}))
+		}
+		return
+	}
+
+	for _, child := range node.children {
+		if child != nil {
+			child.collect(palette)
+		}
+	}
+}
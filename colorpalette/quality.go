@@ -0,0 +1,160 @@
+package colorpalette
+
+import (
+	"image"
+	"math"
+)
+
+// QualityBlur is the radius of the box blur that MeasureQuality applies to both images before measuring them,
+// the way the eye mixes the dots of a dithered image at a distance. Without it, the noise that dithering
+// adds to get the colors right on average is measured as an error. 0 measures the pixels as they are.
+var QualityBlur = 1
+
+// ssimWindow is the size of the square windows that SSIM is measured in, with ssimWindow/2 pixels between them
+const ssimWindow = 8
+
+// Quality tells how closely an image (like a dithered one) reproduces the original, see MeasureQuality
+type Quality struct {
+	// PSNR is the peak signal-to-noise ratio of the red, green and blue values in dB: higher is closer,
+	// +Inf for identical images
+	PSNR float64
+	// SSIM is the mean structural similarity of the luma: 1 for identical images, lower is further apart
+	SSIM float64
+	// MeanDeltaE is the mean DeltaE of the pixels, about 2.3 is just noticeable
+	MeanDeltaE float64
+}
+
+// MeasureQuality measures how closely reproduced reproduces original, after blurring both by QualityBlur.
+// The images need to have the same size.
+func MeasureQuality(original, reproduced image.Image) Quality {
+	width, height := original.Bounds().Dx(), original.Bounds().Dy()
+	left := blurredChannels(original, QualityBlur)
+	right := blurredChannels(reproduced, QualityBlur)
+
+	var squared, deltaE float64
+	leftLuma := make([]float64, width*height)
+	rightLuma := make([]float64, width*height)
+	for i := range leftLuma {
+		l, r := left[i*3:i*3+3], right[i*3:i*3+3]
+		for c := 0; c < 3; c++ {
+			squared += (l[c] - r[c]) * (l[c] - r[c])
+		}
+
+		leftLab := ConvRGBAtoLABA([]float64{l[0], l[1], l[2], 255})
+		rightLab := ConvRGBAtoLABA([]float64{r[0], r[1], r[2], 255})
+		deltaE += math.Sqrt((leftLab[0]-rightLab[0])*(leftLab[0]-rightLab[0]) +
+			(leftLab[1]-rightLab[1])*(leftLab[1]-rightLab[1]) +
+			(leftLab[2]-rightLab[2])*(leftLab[2]-rightLab[2]))
+
+		leftLuma[i] = 0.299*l[0] + 0.587*l[1] + 0.114*l[2]
+		rightLuma[i] = 0.299*r[0] + 0.587*r[1] + 0.114*r[2]
+	}
+
+	quality := Quality{PSNR: math.Inf(1), MeanDeltaE: deltaE / float64(width*height)}
+	if mse := squared / float64(width*height*3); mse > 0 {
+		quality.PSNR = 10 * math.Log10(255*255/mse)
+	}
+	quality.SSIM = ssim(leftLuma, rightLuma, width, height)
+
+	return quality
+}
+
+// blurredChannels returns the red, green and blue values of the pixels of img (row by row),
+// each the mean of the pixels within radius of it
+func blurredChannels(img image.Image, radius int) []float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	values := make([]float64, width*height*3)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			i := (y*width + x) * 3
+			values[i], values[i+1], values[i+2] = float64(r>>8), float64(g>>8), float64(b>>8)
+		}
+	}
+	if radius < 1 {
+		return values
+	}
+
+	// the box blur is separable: first along the rows, then along the columns
+	blurred := make([]float64, len(values))
+	for pass := 0; pass < 2; pass++ {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				var sum [3]float64
+				count := 0.0
+				for d := -radius; d <= radius; d++ {
+					nx, ny := x+d, y
+					if pass == 1 {
+						nx, ny = x, y+d
+					}
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					j := (ny*width + nx) * 3
+					sum[0], sum[1], sum[2] = sum[0]+values[j], sum[1]+values[j+1], sum[2]+values[j+2]
+					count++
+				}
+				i := (y*width + x) * 3
+				blurred[i], blurred[i+1], blurred[i+2] = sum[0]/count, sum[1]/count, sum[2]/count
+			}
+		}
+		values, blurred = blurred, values
+	}
+
+	return values
+}
+
+// ssim returns the mean structural similarity of two images of luma values (row by row),
+// over windows of ssimWindow pixels, or over the whole image if it is smaller
+func ssim(left, right []float64, width, height int) float64 {
+	const c1, c2 = (0.01 * 255) * (0.01 * 255), (0.03 * 255) * (0.03 * 255)
+
+	window := ssimWindow
+	if width < window || height < window {
+		window = int(math.Min(float64(width), float64(height)))
+	}
+	if window < 1 {
+		return 1
+	}
+	step := window / 2
+	if step < 1 {
+		step = 1
+	}
+
+	var total float64
+	windows := 0
+	for y0 := 0; y0+window <= height; y0 += step {
+		for x0 := 0; x0+window <= width; x0 += step {
+			var meanL, meanR float64
+			for y := y0; y < y0+window; y++ {
+				for x := x0; x < x0+window; x++ {
+					meanL += left[y*width+x]
+					meanR += right[y*width+x]
+				}
+			}
+			n := float64(window * window)
+			meanL /= n
+			meanR /= n
+
+			var varL, varR, covariance float64
+			for y := y0; y < y0+window; y++ {
+				for x := x0; x < x0+window; x++ {
+					dl, dr := left[y*width+x]-meanL, right[y*width+x]-meanR
+					varL += dl * dl
+					varR += dr * dr
+					covariance += dl * dr
+				}
+			}
+			varL /= n
+			varR /= n
+			covariance /= n
+
+			total += (2*meanL*meanR + c1) * (2*covariance + c2) / ((meanL*meanL + meanR*meanR + c1) * (varL + varR + c2))
+			windows++
+		}
+	}
+
+	return total / float64(windows)
+}
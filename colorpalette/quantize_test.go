@@ -0,0 +1,124 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/mielpeeters/dither/testgen"
+)
+
+// quantizationError returns the mean squared RGB distance of the pixels of img to their closest palette color
+func quantizationError(img image.Image, palette color.Palette) float64 {
+	bounds := img.Bounds()
+	total := 0.0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := ToRGBA(img.At(x, y))
+			closest := ToRGBA(palette[palette.Index(pixel)])
+
+			for _, d := range []float64{
+				float64(pixel.R) - float64(closest.R),
+				float64(pixel.G) - float64(closest.G),
+				float64(pixel.B) - float64(closest.B),
+			} {
+				total += d * d
+			}
+		}
+	}
+
+	return total / float64(bounds.Dx()*bounds.Dy())
+}
+
+// testQuantizers are the quantizers that don't cluster with k-means, with how much worse than the optimal
+// palette they may be on well separated clusters (the mean squared error, as a multiple of the optimal one)
+var testQuantizers = []struct {
+	name     string
+	quantize Quantizer
+	bound    float64
+}{
+	{"median-cut", MedianCut, 6},
+	{"octree", Octree, 8},
+	{"wu", Wu, 1.2},
+}
+
+func TestQuantizerColorCount(t *testing.T) {
+	img, _ := testgen.GaussianClusters(8, 200, 50, 6, rand.New(rand.NewSource(1)))
+	for _, quantizer := range testQuantizers {
+		for _, k := range []int{1, 2, 7, 8, 16, 300} {
+			if palette := quantizer.quantize(img, k); len(palette) != k {
+				t.Errorf("%s with k=%d gives %d colors", quantizer.name, k, len(palette))
+			}
+		}
+		if palette := quantizer.quantize(img, 0); len(palette) != 0 {
+			t.Errorf("%s with k=0 gives %d colors", quantizer.name, len(palette))
+		}
+	}
+
+	// an image with fewer distinct colors than k gives those colors
+	few := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 128, 0, 255}, {0, 0, 255, 255}}
+	for x := 0; x < 30; x++ {
+		for y := 0; y < 10; y++ {
+			few.SetRGBA(x, y, colors[x/10])
+		}
+	}
+	for _, quantizer := range testQuantizers {
+		palette := quantizer.quantize(few, 8)
+		if len(palette) != len(colors) {
+			t.Errorf("%s gives %d colors for an image of %d colors", quantizer.name, len(palette), len(colors))
+		}
+		if err := quantizationError(few, palette); err != 0 {
+			t.Errorf("%s doesn't reproduce an image of %d colors exactly (error %.1f)", quantizer.name, len(colors), err)
+		}
+	}
+}
+
+func TestQuantizerQuality(t *testing.T) {
+	const sigma = 6
+	for seed := int64(1); seed <= 3; seed++ {
+		img, optimal := testgen.GaussianClusters(8, 200, 50, sigma, rand.New(rand.NewSource(seed)))
+		// the error of the cluster centers, close to 3 sigma² (a bit less, as the channels are clipped)
+		best := quantizationError(img, optimal)
+
+		for _, quantizer := range testQuantizers {
+			palette := quantizer.quantize(img, 8)
+			if err := quantizationError(img, palette); err > quantizer.bound*best {
+				t.Errorf("seed %d: %s has a mean squared error of %.1f, more than %g times the %.1f of the clusters",
+					seed, quantizer.name, err, quantizer.bound, best)
+			}
+		}
+
+		// Wu finds the clusters themselves
+		if err := testgen.MaxCenterError(Wu(img, 8), optimal); err > 5 {
+			t.Errorf("seed %d: wu misses a cluster center by %.1f", seed, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	saved := KMTimes
+	t.Cleanup(func() { KMTimes = saved })
+	KMTimes = 1
+
+	img, _ := testgen.GaussianClusters(4, 80, 20, 6, rand.New(rand.NewSource(1)))
+	comparisons := Compare(img, 4)
+	if len(comparisons) != len(Quantizers) {
+		t.Fatalf("%d comparisons, want one for each of the %d quantizers", len(comparisons), len(Quantizers))
+	}
+
+	table := FormatComparisons(comparisons)
+	for i, comparison := range comparisons {
+		if comparison.Quantizer != Quantizers[i].Name {
+			t.Errorf("comparison %d is of %s, want %s", i, comparison.Quantizer, Quantizers[i].Name)
+		}
+		if len(comparison.Palette) != 4 {
+			t.Errorf("%s gives %d colors, want 4", comparison.Quantizer, len(comparison.Palette))
+		}
+		if !strings.Contains(table, comparison.Quantizer) {
+			t.Errorf("the table has no row for %s:\n%s", comparison.Quantizer, table)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mielpeeters/dither/geom"
+	"github.com/mielpeeters/dither/kmeans"
+)
+
+// Refine creates a palette for img like Create, but starts the k-means algorithm from the colors of previous
+// instead of random ones, and only runs it once. For similar images, like consecutive frames of a video,
+// this converges in a few iterations, and the palette colors stay in the same slots (so the video doesn't flicker).
+// The palette has as many colors as previous (leaving out its transparent color, see process.TransparentIndex).
+// PinnedColors are kept in the first slots, like in Create.
+func Refine(img image.Image, previous color.Palette) color.Palette {
+	start := []geom.Point{}
+	for _, clr := range previous {
+		if _, _, _, a := clr.RGBA(); a == 0 {
+			continue
+		}
+		start = append(start, colorToPoint(clr))
+	}
+
+	settings := CurrentSettings()
+	rng := settings.random()
+	pointSet := settings.samplePoints(img, rng)
+	if len(start) == 0 {
+		return color.Palette{}
+	}
+
+	KM, err := kmeans.NewClustering(pointSet, len(start), settings.Metric.Distance, rng)
+	if err != nil {
+		return color.Palette{}
+	}
+	KM.SetOptions(settings.KMOptions)
+	KM.Pin(settings.pinnedPoints()...)
+	// the pinned colors take the first slots of previous as well
+	KM.Start(start[minInt(len(settings.PinnedColors), len(start)):]...)
+	KM.Cluster(settings.KMAccuracy, settings.KMConsecutive)
+
+	palette := color.Palette{}
+	for _, mean := range KM.KMeans.Points {
+		palette = append(palette, pointToRGBA(mean))
+	}
+
+	return palette
+}
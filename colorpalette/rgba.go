@@ -0,0 +1,80 @@
+package colorpalette
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// jsonPalette is how a ColorPalette is stored as JSON: every color is an array of its red, green, blue and
+// alpha values (0-255), which keeps the palette files written before Colors held color.RGBA values readable.
+// Unlike in color.RGBA, the red, green and blue values are not premultiplied by the alpha value.
+type jsonPalette struct {
+	Name        string  `json:"name"`
+	Colors      [][]int `json:"colors"`
+	Transparent bool    `json:"transparent,omitempty"`
+}
+
+// MarshalJSON writes the palette with its colors as [R, G, B, A] arrays
+func (colorpalette ColorPalette) MarshalJSON() ([]byte, error) {
+	stored := jsonPalette{
+		Name:        colorpalette.Name,
+		Colors:      make([][]int, len(colorpalette.Colors)),
+		Transparent: colorpalette.Transparent,
+	}
+
+	for i, clr := range colorpalette.Colors {
+		nrgba := unpremultiply(clr)
+		stored.Colors[i] = []int{int(nrgba.R), int(nrgba.G), int(nrgba.B), int(nrgba.A)}
+	}
+
+	return json.Marshal(stored)
+}
+
+// UnmarshalJSON reads a palette with its colors as [R, G, B, A] arrays, or [R, G, B] for opaque colors.
+// Values outside of 0-255 give an error, instead of wrapping around.
+func (colorpalette *ColorPalette) UnmarshalJSON(data []byte) error {
+	var stored jsonPalette
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	colors := make([]color.RGBA, len(stored.Colors))
+	for i, values := range stored.Colors {
+		if len(values) != 3 && len(values) != 4 {
+			return fmt.Errorf("colorpalette: color %d of palette %q has %d values instead of 3 or 4", i, stored.Name, len(values))
+		}
+
+		channels := [4]uint8{0, 0, 0, 255}
+		for c, value := range values {
+			if value < 0 || value > 255 {
+				return fmt.Errorf("colorpalette: color %d of palette %q has value %d outside of 0-255", i, stored.Name, value)
+			}
+			channels[c] = uint8(value)
+		}
+		colors[i] = premultiply(color.NRGBA{channels[0], channels[1], channels[2], channels[3]})
+	}
+
+	colorpalette.Name = stored.Name
+	colorpalette.Colors = colors
+	colorpalette.Transparent = stored.Transparent
+
+	return nil
+}
+
+// channels returns the red, green, blue and alpha values of clr, as used by the color space conversions
+func channels(clr color.RGBA) []float64 {
+	return []float64{float64(clr.R), float64(clr.G), float64(clr.B), float64(clr.A)}
+}
+
+// fromChannels returns the color with the given red, green, blue and alpha values,
+// rounded and limited to 0-255. A missing alpha value means the color is opaque.
+func fromChannels(values []float64) color.RGBA {
+	rgba := [4]uint8{0, 0, 0, 255}
+	for c := 0; c < len(values) && c < 4; c++ {
+		rgba[c] = uint8(math.Max(0, math.Min(255, math.Round(values[c]))))
+	}
+
+	return color.RGBA{rgba[0], rgba[1], rgba[2], rgba[3]}
+}
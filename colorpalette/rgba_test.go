@@ -0,0 +1,47 @@
+package colorpalette
+
+import (
+	"encoding/json"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	written := testPalette
+	written.Colors = append(append([]color.RGBA{}, written.Colors...), color.RGBA{10, 20, 30, 40}, color.RGBA{})
+
+	data, err := json.Marshal(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var palette ColorPalette
+	if err := json.Unmarshal(data, &palette); err != nil {
+		t.Fatalf("reading the written palette: %v", err)
+	}
+	if !reflect.DeepEqual(palette, written) {
+		t.Errorf("read %v, wrote %v", palette, written)
+	}
+}
+
+// TestJSONTranslucent checks that the values of translucent colors are stored without premultiplying them
+func TestJSONTranslucent(t *testing.T) {
+	var palette ColorPalette
+	if err := json.Unmarshal([]byte(`{"name": "red", "colors": [[255, 0, 0, 128], [0, 255, 0]]}`), &palette); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []color.RGBA{{128, 0, 0, 128}, {0, 255, 0, 255}}
+	if !reflect.DeepEqual(palette.Colors, want) {
+		t.Errorf("read %v, want %v", palette.Colors, want)
+	}
+
+	data, err := json.Marshal(palette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"name":"red","colors":[[255,0,0,128],[0,255,0,255]]}`; string(data) != want {
+		t.Errorf("wrote %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,147 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// saliencySize is the size of the longest side of the saliency map
+const saliencySize = 64
+
+// saliencyScales are the surround radii (in saliency map pixels) used in the center-surround contrast
+var saliencyScales = []int{2, 4, 8}
+
+// Saliency estimates which regions of img draw the attention, using a center-surround contrast heuristic:
+// a region that differs a lot in colour from its surroundings, at several scales, is considered salient.
+// Regions near the center of the image get a mild boost, as that is where subjects usually are.
+//
+// The returned map is small (at most 64 pixels wide or high), brighter meaning more salient.
+// It can be used as the SaliencyMask for the SampleMask strategy.
+func Saliency(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return image.NewGray(image.Rect(0, 0, 0, 0))
+	}
+
+	// size of the square block of image pixels that is averaged into one map pixel
+	block := int(math.Ceil(float64(maxInt(bounds.Dx(), bounds.Dy())) / saliencySize))
+	width := (bounds.Dx() + block - 1) / block
+	height := (bounds.Dy() + block - 1) / block
+
+	// average colours of each block, as a row-major slice
+	means := make([][3]float64, width*height)
+	for bx := 0; bx < width; bx++ {
+		for by := 0; by < height; by++ {
+			minX := bounds.Min.X + bx*block
+			minY := bounds.Min.Y + by*block
+			rect := image.Rect(minX, minY, minX+block, minY+block).Intersect(bounds)
+
+			var sum [3]float64
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				for y := rect.Min.Y; y < rect.Max.Y; y++ {
+					clr := ToRGBA(img.At(x, y))
+					sum[0] += float64(clr.R)
+					sum[1] += float64(clr.G)
+					sum[2] += float64(clr.B)
+				}
+			}
+
+			n := float64(rect.Dx() * rect.Dy())
+			means[bx+by*width] = [3]float64{sum[0] / n, sum[1] / n, sum[2] / n}
+		}
+	}
+
+	integral := integralImage(means, width, height)
+
+	saliency := make([]float64, width*height)
+	var maxSaliency float64
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			center := means[x+y*width]
+
+			var contrast float64
+			for _, radius := range saliencyScales {
+				surround := boxMean(integral, width, height, x-radius, y-radius, x+radius+1, y+radius+1)
+
+				dist := 0.0
+				for i := range center {
+					dist += (center[i] - surround[i]) * (center[i] - surround[i])
+				}
+				contrast += math.Sqrt(dist)
+			}
+
+			// mild center bias: the edges of the image keep half of their contrast
+			dx := (float64(x)+0.5)/float64(width) - 0.5
+			dy := (float64(y)+0.5)/float64(height) - 0.5
+			contrast *= 0.5 + 0.5*math.Exp(-(dx*dx+dy*dy)/(2*0.3*0.3))
+
+			saliency[x+y*width] = contrast
+			maxSaliency = math.Max(maxSaliency, contrast)
+		}
+	}
+
+	gray := image.NewGray(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			var value uint8
+			if maxSaliency > 0 {
+				value = uint8(255 * saliency[x+y*width] / maxSaliency)
+			}
+			gray.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+
+	return gray
+}
+
+// integralImage returns the summed area table of values, which has one extra row and column of zeros
+func integralImage(values [][3]float64, width, height int) [][3]float64 {
+	integral := make([][3]float64, (width+1)*(height+1))
+
+	for y := 1; y <= height; y++ {
+		for x := 1; x <= width; x++ {
+			for i := 0; i < 3; i++ {
+				integral[x+y*(width+1)][i] = values[(x-1)+(y-1)*width][i] +
+					integral[(x-1)+y*(width+1)][i] +
+					integral[x+(y-1)*(width+1)][i] -
+					integral[(x-1)+(y-1)*(width+1)][i]
+			}
+		}
+	}
+
+	return integral
+}
+
+// boxMean returns the mean value within the rectangle [x0, x1) x [y0, y1), clipped to the map size,
+// using the summed area table integral
+func boxMean(integral [][3]float64, width, height, x0, y0, x1, y1 int) [3]float64 {
+	x0, y0 = maxInt(x0, 0), maxInt(y0, 0)
+	x1, y1 = minInt(x1, width), minInt(y1, height)
+
+	stride := width + 1
+	n := float64((x1 - x0) * (y1 - y0))
+
+	var mean [3]float64
+	for i := 0; i < 3; i++ {
+		sum := integral[x1+y1*stride][i] - integral[x0+y1*stride][i] - integral[x1+y0*stride][i] + integral[x0+y0*stride][i]
+		mean[i] = sum / n
+	}
+
+	return mean
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,261 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/mielpeeters/dither/geom"
+)
+
+// SampleStrategy defines how the pixels that make up the k-means problem of Create are chosen
+type SampleStrategy int
+
+const (
+	// SampleUniform takes one pixel every SampleFactor pixels, in both directions
+	SampleUniform SampleStrategy = iota
+	// SampleVariance takes more pixels from regions with a high local colour variance,
+	// and fewer from flat regions (like a plain background)
+	SampleVariance
+	// SampleMask takes pixels in proportion to the brightness of SaliencyMask
+	SampleMask
+	// SampleSaliency takes pixels in proportion to the estimated Saliency of the image,
+	// so subjects get more colours than the background. A SaliencyMask, if set, takes precedence.
+	SampleSaliency
+	// SampleHistogram takes every distinct color of the image once, weighted by its amount of pixels.
+	// Unlike the other strategies, no pixels are skipped, which is unbiased and (for images with few distinct colors) fast.
+	SampleHistogram
+)
+
+// Sampling is the SampleStrategy used by Create and CreatePLT
+var Sampling = SampleUniform
+
+// SaliencyMask is the importance map used by the SampleMask and SampleSaliency strategies: brighter pixels get sampled more often.
+// The mask is stretched to the bounds of the image that is sampled.
+// If it is nil, SampleMask falls back to SampleUniform.
+var SaliencyMask image.Image
+
+// MinWeight is the minimal relative weight (compared to the mean weight) that a region gets
+// during weighted sampling, so that flat backgrounds are never left out completely.
+var MinWeight = 0.1
+
+// samplePoints converts (a fraction of) the pixels of img into a PointSet, according to Sampling
+func (settings Settings) samplePoints(img image.Image, rng *rand.Rand) geom.PointSet {
+	switch settings.Sampling {
+	case SampleVariance:
+		return settings.sampleWeighted(img, settings.varianceWeights(img), rng)
+	case SampleMask:
+		if settings.SaliencyMask != nil {
+			return settings.sampleWeighted(img, settings.maskWeights(img, settings.SaliencyMask), rng)
+		}
+	case SampleSaliency:
+		mask := settings.SaliencyMask
+		if mask == nil {
+			mask = Saliency(img)
+		}
+		return settings.sampleWeighted(img, settings.maskWeights(img, mask), rng)
+	case SampleHistogram:
+		return sampleHistogram(img)
+	}
+
+	return settings.sampleUniform(img)
+}
+
+// sampleStep returns SampleFactor, or 1 if it is lower, which takes every pixel
+func (settings Settings) sampleStep() int {
+	if settings.SampleFactor < 1 {
+		return 1
+	}
+
+	return settings.SampleFactor
+}
+
+// sampleUniform samples only 1/SampleFactor of the pixels, in each direction
+func (settings Settings) sampleUniform(img image.Image) geom.PointSet {
+	pointSet := geom.PointSet{}
+	step := settings.sampleStep()
+
+	for x := 0; x < img.Bounds().Max.X; x += step {
+		for y := 0; y < img.Bounds().Max.Y; y += step {
+			newPoint := colorToPoint(img.At(x, y))
+			newPoint.ID = x + y*img.Bounds().Max.X
+
+			pointSet.Points = append(pointSet.Points, newPoint)
+		}
+	}
+
+	return pointSet
+}
+
+// sampleHistogram returns a point for every distinct color of img, weighted by its amount of pixels
+func sampleHistogram(img image.Image) geom.PointSet {
+	counts := map[color.RGBA]int{}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			counts[ToRGBA(img.At(x, y))]++
+		}
+	}
+
+	colors := make([]color.RGBA, 0, len(counts))
+	for clr := range counts {
+		colors = append(colors, clr)
+	}
+
+	// a fixed order, so that the results don't depend on the order of the map
+	sort.Slice(colors, func(i, j int) bool {
+		left, right := colors[i], colors[j]
+		return uint32(left.R)<<24|uint32(left.G)<<16|uint32(left.B)<<8|uint32(left.A) <
+			uint32(right.R)<<24|uint32(right.G)<<16|uint32(right.B)<<8|uint32(right.A)
+	})
+
+	pointSet := geom.PointSet{}
+	for i, clr := range colors {
+		point := colorToPoint(clr)
+		point.ID = i
+		point.Weight = float32(counts[clr])
+		pointSet.Points = append(pointSet.Points, point)
+	}
+
+	return pointSet
+}
+
+// cellGrid divides the bounds in square cells of SampleFactor pixels wide,
+// and returns the amount of cells in both directions
+func (settings Settings) cellGrid(bounds image.Rectangle) (int, int) {
+	step := settings.sampleStep()
+	cellsX := (bounds.Dx() + step - 1) / step
+	cellsY := (bounds.Dy() + step - 1) / step
+
+	return cellsX, cellsY
+}
+
+// cellRect returns the pixel rectangle of cell (cx, cy), clipped to bounds
+func (settings Settings) cellRect(bounds image.Rectangle, cx, cy int) image.Rectangle {
+	step := settings.sampleStep()
+	minX := bounds.Min.X + cx*step
+	minY := bounds.Min.Y + cy*step
+
+	return image.Rect(minX, minY, minX+step, minY+step).Intersect(bounds)
+}
+
+// sampleWeighted samples the pixels of img, where each cell of the grid (see cellGrid) gets an amount of samples
+// proportional to its weight. On average, as many points are taken as with sampleUniform.
+func (settings Settings) sampleWeighted(img image.Image, weights []float64, rng *rand.Rand) geom.PointSet {
+	pointSet := geom.PointSet{}
+	bounds := img.Bounds()
+	cellsX, cellsY := settings.cellGrid(bounds)
+
+	mean := 0.0
+	for _, weight := range weights {
+		mean += weight
+	}
+	mean /= float64(len(weights))
+
+	if mean == 0 || math.IsNaN(mean) {
+		return settings.sampleUniform(img)
+	}
+
+	// apply the lower bound to the weights and renormalize
+	floor := settings.MinWeight * mean
+	mean = 0.0
+	for i := range weights {
+		weights[i] = math.Max(weights[i], floor)
+		mean += weights[i]
+	}
+	mean /= float64(len(weights))
+
+	for cy := 0; cy < cellsY; cy++ {
+		for cx := 0; cx < cellsX; cx++ {
+			expected := weights[cx+cy*cellsX] / mean
+
+			// stochastic rounding of the expected amount of samples
+			amount := int(expected)
+			if rng.Float64() < expected-float64(amount) {
+				amount++
+			}
+
+			cell := settings.cellRect(bounds, cx, cy)
+			for i := 0; i < amount; i++ {
+				x := cell.Min.X + rng.Intn(cell.Dx())
+				y := cell.Min.Y + rng.Intn(cell.Dy())
+
+				newPoint := colorToPoint(img.At(x, y))
+				newPoint.ID = x + y*bounds.Max.X
+
+				pointSet.Points = append(pointSet.Points, newPoint)
+			}
+		}
+	}
+
+	return pointSet
+}
+
+// varianceWeights returns the colour variance of each cell of img.
+// Each cell is grown by one pixel on every side, so that single pixel cells have a meaningful variance too.
+func (settings Settings) varianceWeights(img image.Image) []float64 {
+	bounds := img.Bounds()
+	cellsX, cellsY := settings.cellGrid(bounds)
+	weights := make([]float64, cellsX*cellsY)
+
+	for cy := 0; cy < cellsY; cy++ {
+		for cx := 0; cx < cellsX; cx++ {
+			cell := settings.cellRect(bounds, cx, cy).Inset(-1).Intersect(bounds)
+
+			var sum, sumSquares [3]float64
+			for x := cell.Min.X; x < cell.Max.X; x++ {
+				for y := cell.Min.Y; y < cell.Max.Y; y++ {
+					clr := ToRGBA(img.At(x, y))
+					channels := [3]float64{float64(clr.R), float64(clr.G), float64(clr.B)}
+					for i, value := range channels {
+						sum[i] += value
+						sumSquares[i] += value * value
+					}
+				}
+			}
+
+			n := float64(cell.Dx() * cell.Dy())
+			variance := 0.0
+			for i := range sum {
+				variance += sumSquares[i]/n - (sum[i]/n)*(sum[i]/n)
+			}
+
+			weights[cx+cy*cellsX] = math.Max(variance, 0)
+		}
+	}
+
+	return weights
+}
+
+// maskWeights returns the mean brightness of mask over each cell of img.
+// The mask is stretched to fit the bounds of img.
+func (settings Settings) maskWeights(img image.Image, mask image.Image) []float64 {
+	bounds := img.Bounds()
+	maskBounds := mask.Bounds()
+	cellsX, cellsY := settings.cellGrid(bounds)
+	weights := make([]float64, cellsX*cellsY)
+
+	for cy := 0; cy < cellsY; cy++ {
+		for cx := 0; cx < cellsX; cx++ {
+			cell := settings.cellRect(bounds, cx, cy)
+
+			sum := 0.0
+			for x := cell.Min.X; x < cell.Max.X; x++ {
+				for y := cell.Min.Y; y < cell.Max.Y; y++ {
+					mx := maskBounds.Min.X + (x-bounds.Min.X)*maskBounds.Dx()/bounds.Dx()
+					my := maskBounds.Min.Y + (y-bounds.Min.Y)*maskBounds.Dy()/bounds.Dy()
+
+					gray := color.GrayModel.Convert(mask.At(mx, my)).(color.Gray)
+					sum += float64(gray.Y) / 255.0
+				}
+			}
+
+			weights[cx+cy*cellsX] = sum / float64(cell.Dx()*cell.Dy())
+		}
+	}
+
+	return weights
+}
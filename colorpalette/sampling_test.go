@@ -0,0 +1,109 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// TestSampleFactorBelowOne checks that every strategy takes every pixel with a SampleFactor below 1,
+// instead of dividing by it or never advancing
+func TestSampleFactorBelowOne(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(30 * x), uint8(40 * y), 0, 255})
+		}
+	}
+
+	for _, factor := range []int{0, -3} {
+		for _, strategy := range []SampleStrategy{SampleUniform, SampleVariance, SampleMask, SampleSaliency, SampleHistogram} {
+			settings := Settings{SampleFactor: factor, Sampling: strategy, SaliencyMask: img, MinWeight: 0.1}
+			points := settings.samplePoints(img, rand.New(rand.NewSource(1)))
+			if len(points.Points) == 0 {
+				t.Errorf("factor %d, strategy %d: no points", factor, strategy)
+			}
+		}
+
+		if cellsX, cellsY := (Settings{SampleFactor: factor}).cellGrid(img.Bounds()); cellsX != 8 || cellsY != 6 {
+			t.Errorf("factor %d: %dx%d cells, want one per pixel", factor, cellsX, cellsY)
+		}
+	}
+}
+
+// subjectImage returns a gray 128x96 image with a red square of 24x24 pixels in the center
+func subjectImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 128, 96))
+	for y := 0; y < 96; y++ {
+		for x := 0; x < 128; x++ {
+			clr := color.RGBA{120, 120, 120, 255}
+			if x >= 52 && x < 76 && y >= 36 && y < 60 {
+				clr = color.RGBA{220, 20, 20, 255}
+			}
+			img.SetRGBA(x, y, clr)
+		}
+	}
+
+	return img
+}
+
+// TestSaliency checks that the saliency map is small, brightest on the subject and dark on a flat background
+func TestSaliency(t *testing.T) {
+	saliency := Saliency(subjectImage())
+	if size := saliency.Bounds().Size(); size.X > 64 || size.Y > 64 || size.X*96 != size.Y*128 {
+		t.Fatalf("the map is %v, want at most 64 pixels with the aspect ratio of the image", size)
+	}
+
+	// the square in map pixels, the map being half the size of the image
+	square := image.Rect(26, 18, 38, 30)
+	var brightest image.Point
+	for y := 0; y < saliency.Bounds().Dy(); y++ {
+		for x := 0; x < saliency.Bounds().Dx(); x++ {
+			if saliency.GrayAt(x, y).Y > saliency.GrayAt(brightest.X, brightest.Y).Y {
+				brightest = image.Pt(x, y)
+			}
+		}
+	}
+	if !brightest.In(square) {
+		t.Errorf("the most salient pixel is %v, want it within the subject %v", brightest, square)
+	}
+	if corner := saliency.GrayAt(0, 0).Y; corner > 32 {
+		t.Errorf("the corner has a saliency of %d, want at most 32", corner)
+	}
+
+	flat := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for i := range flat.Pix {
+		flat.Pix[i] = 200
+	}
+	for _, value := range Saliency(flat).Pix {
+		if value != 0 {
+			t.Fatalf("a flat image has a saliency of %d, want 0", value)
+		}
+	}
+}
+
+// TestSampleSaliency checks that saliency sampling takes more of the subject than uniform sampling
+func TestSampleSaliency(t *testing.T) {
+	img := subjectImage()
+
+	// the share of the weight of the sampled points that is red
+	redShare := func(strategy SampleStrategy) float64 {
+		settings := Settings{SampleFactor: 4, Sampling: strategy, MinWeight: 0.1}
+		var red, total float64
+		for _, point := range settings.samplePoints(img, rand.New(rand.NewSource(1))).Points {
+			weight := float64(point.Mass())
+			if point.Coordinates[0] > 200 {
+				red += weight
+			}
+			total += weight
+		}
+
+		return red / total
+	}
+
+	uniform, salient := redShare(SampleUniform), redShare(SampleSaliency)
+	if salient < 2*uniform {
+		t.Errorf("saliency sampling takes %.2f of the subject, uniform sampling %.2f, want at least twice as much", salient, uniform)
+	}
+}
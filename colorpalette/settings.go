@@ -0,0 +1,84 @@
+package colorpalette
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+
+	"github.com/mielpeeters/dither/kmeans"
+)
+
+// Settings are the settings with which palettes are created. The package variables of the same names hold the
+// settings of Create and the other package functions; a Settings value gives one call settings of its own,
+// so that palettes can be created concurrently, each with its own Rand.
+type Settings struct {
+	KMAccuracy    float64
+	KMConsecutive int
+	SampleFactor  int
+	KMTimes       int
+	KMOptions     kmeans.Options
+	// Rand must not be used by more than one call at a time, like the package variable
+	Rand         *rand.Rand
+	PinnedColors []color.Color
+	Sampling     SampleStrategy
+	SaliencyMask image.Image
+	MinWeight    float64
+	Metric       NamedMetric
+	CacheDir     string
+}
+
+// CurrentSettings returns the settings of the package variables
+func CurrentSettings() Settings {
+	return Settings{
+		KMAccuracy:    KMAccuracy,
+		KMConsecutive: KMConsecutive,
+		SampleFactor:  SampleFactor,
+		KMTimes:       KMTimes,
+		KMOptions:     KMOptions,
+		Rand:          Rand,
+		PinnedColors:  PinnedColors,
+		Sampling:      Sampling,
+		SaliencyMask:  SaliencyMask,
+		MinWeight:     MinWeight,
+		Metric:        Metric,
+		CacheDir:      CacheDir,
+	}
+}
+
+// UseSettings sets the package variables to the settings, for the functions that create palettes with them
+func UseSettings(settings Settings) {
+	KMAccuracy = settings.KMAccuracy
+	KMConsecutive = settings.KMConsecutive
+	SampleFactor = settings.SampleFactor
+	KMTimes = settings.KMTimes
+	KMOptions = settings.KMOptions
+	Rand = settings.Rand
+	PinnedColors = settings.PinnedColors
+	Sampling = settings.Sampling
+	SaliencyMask = settings.SaliencyMask
+	MinWeight = settings.MinWeight
+	Metric = settings.Metric
+	CacheDir = settings.CacheDir
+}
+
+// CreateContext creates a palette for img with the settings, see the package function CreateContext
+func (settings Settings) CreateContext(ctx context.Context, img image.Image, k int, onIteration func(Iteration)) (color.Palette, error) {
+	colorPalette, err := settings.create(ctx, []image.Image{img}, k, onIteration)
+	if err != nil {
+		return nil, err
+	}
+
+	return colorPalette.ToPalette(), nil
+}
+
+// CreateFromImagesContext creates one palette for all of the images with the settings, like CreateFromImages.
+// When ctx is cancelled, the creation stops after the current iteration, and the error of ctx is returned.
+func (settings Settings) CreateFromImagesContext(ctx context.Context, imgs []image.Image, k int) (color.Palette, error) {
+	colorPalette, err := settings.create(ctx, imgs, k, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return colorPalette.ToPalette(), nil
+}
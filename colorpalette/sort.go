@@ -0,0 +1,87 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// luminance returns the relative luminance (Rec. 709) of an RGBA color
+func luminance(clr color.RGBA) float64 {
+	return 0.2126*float64(clr.R) + 0.7152*float64(clr.G) + 0.0722*float64(clr.B)
+}
+
+// SortByLuminance sorts the colors of the palette from dark to light
+func (colorpalette *ColorPalette) SortByLuminance() {
+	sort.SliceStable(colorpalette.Colors, func(i, j int) bool {
+		return luminance(colorpalette.Colors[i]) < luminance(colorpalette.Colors[j])
+	})
+}
+
+// SortByHue sorts the colors of the palette by hue, starting at red.
+// Grays (no saturation) are put at the end, from dark to light.
+func (colorpalette *ColorPalette) SortByHue() {
+	hsla := func(clr color.RGBA) []float64 {
+		return ConvRGBAtoHSLA(channels(clr))
+	}
+
+	sort.SliceStable(colorpalette.Colors, func(i, j int) bool {
+		left := hsla(colorpalette.Colors[i])
+		right := hsla(colorpalette.Colors[j])
+
+		leftGray := left[1] == 0
+		rightGray := right[1] == 0
+
+		if leftGray != rightGray {
+			return rightGray
+		}
+		if leftGray {
+			return left[2] < right[2]
+		}
+		if left[0] != right[0] {
+			return left[0] < right[0]
+		}
+		return left[2] < right[2]
+	})
+}
+
+// SortByFrequency sorts the colors of the palette from most to least used in img,
+// where each pixel of img counts for the palette color closest to it.
+func (colorpalette *ColorPalette) SortByFrequency(img image.Image) {
+	counts := colorpalette.Frequencies(img)
+
+	indices := make([]int, len(colorpalette.Colors))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	sort.SliceStable(indices, func(i, j int) bool {
+		return counts[indices[i]] > counts[indices[j]]
+	})
+
+	sorted := make([]color.RGBA, len(indices))
+	for i, index := range indices {
+		sorted[i] = colorpalette.Colors[index]
+	}
+
+	colorpalette.Colors = sorted
+}
+
+// Frequencies returns, for each color of the palette, the amount of pixels in img that are closest to that color
+func (colorpalette *ColorPalette) Frequencies(img image.Image) []int {
+	palette := colorpalette.ToPalette()
+	counts := make([]int, len(palette))
+
+	if len(palette) == 0 {
+		return counts
+	}
+
+	bounds := img.Bounds()
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			counts[palette.Index(img.At(x, y))]++
+		}
+	}
+
+	return counts
+}
@@ -0,0 +1,57 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// sortTestColors are colors in no particular order: blue, white, red, a dark gray, green and black
+var sortTestColors = []color.RGBA{
+	{0, 0, 255, 255}, {255, 255, 255, 255}, {255, 0, 0, 255}, {60, 60, 60, 255}, {0, 255, 0, 255}, {0, 0, 0, 255},
+}
+
+func TestSortByLuminance(t *testing.T) {
+	palette := ColorPalette{Colors: append([]color.RGBA{}, sortTestColors...)}
+	palette.SortByLuminance()
+
+	want := []color.RGBA{{0, 0, 0, 255}, {0, 0, 255, 255}, {255, 0, 0, 255}, {60, 60, 60, 255}, {0, 255, 0, 255}, {255, 255, 255, 255}}
+	if !reflect.DeepEqual(palette.Colors, want) {
+		t.Errorf("sorted into %v, want %v", palette.Colors, want)
+	}
+}
+
+func TestSortByHue(t *testing.T) {
+	palette := ColorPalette{Colors: append([]color.RGBA{}, sortTestColors...)}
+	palette.SortByHue()
+
+	// red, green and blue by hue, then the grays from dark to light
+	want := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {0, 0, 0, 255}, {60, 60, 60, 255}, {255, 255, 255, 255}}
+	if !reflect.DeepEqual(palette.Colors, want) {
+		t.Errorf("sorted into %v, want %v", palette.Colors, want)
+	}
+}
+
+func TestSortByFrequency(t *testing.T) {
+	// 3 columns of green, 2 of white, 1 of red; blue, the gray and black are not used
+	img := image.NewRGBA(image.Rect(0, 0, 6, 2))
+	for y := 0; y < 2; y++ {
+		for x, clr := range []color.RGBA{{0, 250, 0, 255}, {0, 255, 10, 255}, {0, 255, 0, 255}, {250, 250, 250, 255}, {255, 255, 255, 255}, {255, 0, 0, 255}} {
+			img.SetRGBA(x, y, clr)
+		}
+	}
+
+	palette := ColorPalette{Colors: append([]color.RGBA{}, sortTestColors...)}
+	if counts, want := palette.Frequencies(img), []int{0, 4, 2, 0, 6, 0}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("frequencies %v, want %v", counts, want)
+	}
+
+	palette.SortByFrequency(img)
+
+	// the unused colors keep their order
+	want := []color.RGBA{{0, 255, 0, 255}, {255, 255, 255, 255}, {255, 0, 0, 255}, {0, 0, 255, 255}, {60, 60, 60, 255}, {0, 0, 0, 255}}
+	if !reflect.DeepEqual(palette.Colors, want) {
+		t.Errorf("sorted into %v, want %v", palette.Colors, want)
+	}
+}
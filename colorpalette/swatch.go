@@ -0,0 +1,79 @@
+package colorpalette
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// ToSwatchImage renders the palette as a grid of square cells of cellSize pixels, with the given amount of columns.
+// If labels is set, each cell shows the hex value of its color (this needs cells of about 50 pixels wide to fit).
+func (colorpalette *ColorPalette) ToSwatchImage(cellSize, columns int, labels bool) image.Image {
+	if columns < 1 {
+		columns = 1
+	}
+	if cellSize < 1 {
+		cellSize = 1
+	}
+
+	amount := len(colorpalette.Colors)
+	if amount < columns {
+		columns = amount
+	}
+
+	rows := 0
+	if columns > 0 {
+		rows = (amount + columns - 1) / columns
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, columns*cellSize, rows*cellSize))
+
+	// the transparent color (if any) is left out
+	palette := colorpalette.ToPalette()[:len(colorpalette.Colors)]
+	for i, clr := range palette {
+		x := (i % columns) * cellSize
+		y := (i / columns) * cellSize
+		cell := image.Rect(x, y, x+cellSize, y+cellSize)
+
+		draw.Draw(img, cell, &image.Uniform{clr}, image.Point{}, draw.Src)
+
+		if labels {
+			rgb := colorpalette.Colors[i]
+			label := fmt.Sprintf("#%02x%02x%02x", rgb.R, rgb.G, rgb.B)
+			drawLabel(img, cell, label, labelColor(rgb))
+		}
+	}
+
+	return img
+}
+
+// labelColor returns black or white, whichever is most readable on top of clr
+func labelColor(clr color.RGBA) color.Color {
+	if luminance(clr) > 128 {
+		return color.Black
+	}
+	return color.White
+}
+
+// drawLabel draws the text centered in the bottom of cell
+func drawLabel(img *image.RGBA, cell image.Rectangle, text string, clr color.Color) {
+	face := basicfont.Face7x13
+
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(clr),
+		Face: face,
+	}
+
+	width := drawer.MeasureString(text).Ceil()
+	x := cell.Min.X + (cell.Dx()-width)/2
+	y := cell.Max.Y - face.Descent - 2
+
+	drawer.Dot = fixed.P(x, y)
+	drawer.DrawString(text)
+}
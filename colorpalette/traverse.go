@@ -0,0 +1,129 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+)
+
+// TraverseTolerance is the DeltaE within which Traverse considers neighbouring pixels to be the same swatch,
+// so that anti-aliased edges and JPEG noise don't break up the swatches. 0 only accepts exactly equal pixels.
+var TraverseTolerance = 5.0
+
+// TraverseRunLength is the amount of consecutive pixels of the same color that Traverse needs to see a swatch
+var TraverseRunLength = 8
+
+// TraverseLines is the amount of lines that Traverse scans, spread evenly over the image.
+// Use more than one for images with multiple rows (or columns) of swatches.
+var TraverseLines = 1
+
+// Traverse is used to find colours on lines through the image: swatch strips, like screenshots of palettes.
+// With ltr, it scans horizontal lines from left to right, otherwise vertical lines from top to bottom
+// (TraverseLines of them, by default one through the middle).
+//
+// Every run of at least TraverseRunLength pixels within TraverseTolerance of each other is a swatch,
+// and gives the average color of its pixels, in the order of the lines. Swatches that repeat are kept.
+// If the four corners of the image have the same color, the swatches lie on a background (a margin around them),
+// and swatches of that color are skipped; a strip that runs to the edges has no background.
+// It returns the palette with the given name, and its amount of colors.
+func (colorpalette *ColorPalette) Traverse(img *image.Image, ltr bool, name string) (ColorPalette, int) {
+	palette := ColorPalette{
+		Name:   name,
+		Colors: []color.RGBA{},
+	}
+
+	bounds := (*img).Bounds()
+	if bounds.Empty() {
+		return palette, 0
+	}
+
+	background, hasBackground := backgroundColor(*img)
+
+	lines := TraverseLines
+	if lines < 1 {
+		lines = 1
+	}
+
+	for line := 1; line <= lines; line++ {
+		var start, step image.Point
+		if ltr {
+			start = image.Pt(bounds.Min.X, bounds.Min.Y+line*bounds.Dy()/(lines+1))
+			step = image.Pt(1, 0)
+		} else {
+			start = image.Pt(bounds.Min.X+line*bounds.Dx()/(lines+1), bounds.Min.Y)
+			step = image.Pt(0, 1)
+		}
+
+		for _, swatch := range traverseLine(*img, start, step) {
+			if hasBackground && DeltaE(swatch, background) <= TraverseTolerance {
+				continue
+			}
+			palette.Colors = append(palette.Colors, swatch)
+		}
+	}
+
+	return palette, len(palette.Colors)
+}
+
+// traverseLine walks through img from start, in steps of step, and returns the average colors
+// of the runs of similar pixels that are long enough to be swatches
+func traverseLine(img image.Image, start, step image.Point) []color.RGBA {
+	swatches := []color.RGBA{}
+
+	var sum [4]int
+	length := 0
+
+	// closeRun adds the current run as a swatch, if it is long enough
+	closeRun := func() {
+		if length >= TraverseRunLength && length > 0 {
+			swatches = append(swatches, runColor(sum, length))
+		}
+		sum = [4]int{}
+		length = 0
+	}
+
+	for pixel := start; pixel.In(img.Bounds()); pixel = pixel.Add(step) {
+		clr := ToRGBA(img.At(pixel.X, pixel.Y))
+
+		if length > 0 && DeltaE(clr, runColor(sum, length)) > TraverseTolerance {
+			closeRun()
+		}
+
+		sum[0] += int(clr.R)
+		sum[1] += int(clr.G)
+		sum[2] += int(clr.B)
+		sum[3] += int(clr.A)
+		length++
+	}
+	closeRun()
+
+	return swatches
+}
+
+// runColor returns the average color of a run of pixels, given the sum of their channels
+func runColor(sum [4]int, length int) color.RGBA {
+	return fromChannels([]float64{
+		float64(sum[0]) / float64(length),
+		float64(sum[1]) / float64(length),
+		float64(sum[2]) / float64(length),
+		float64(sum[3]) / float64(length),
+	})
+}
+
+// backgroundColor returns the color of the four corners of img, if they all have the same color
+// (within TraverseTolerance of the top left one)
+func backgroundColor(img image.Image) (color.RGBA, bool) {
+	bounds := img.Bounds()
+	topLeft := ToRGBA(img.At(bounds.Min.X, bounds.Min.Y))
+
+	for _, corner := range []image.Point{
+		{bounds.Max.X - 1, bounds.Min.Y},
+		{bounds.Min.X, bounds.Max.Y - 1},
+		{bounds.Max.X - 1, bounds.Max.Y - 1},
+	} {
+		if DeltaE(topLeft, ToRGBA(img.At(corner.X, corner.Y))) > TraverseTolerance {
+			return color.RGBA{}, false
+		}
+	}
+
+	return topLeft, true
+}
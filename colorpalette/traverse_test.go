@@ -0,0 +1,99 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"testing"
+)
+
+// swatchStrip returns an image of the colors as swatches of size x size pixels, in one row (or column, without ltr),
+// with a margin of the given width in the color of margin around them
+func swatchStrip(colors []color.RGBA, size, width int, margin color.RGBA, ltr bool) image.Image {
+	length := len(colors) * size
+	rect := image.Rect(0, 0, length+2*width, size+2*width)
+	if !ltr {
+		rect = image.Rect(0, 0, size+2*width, length+2*width)
+	}
+
+	img := image.NewRGBA(rect)
+	draw.Draw(img, rect, image.NewUniform(margin), image.Point{}, draw.Src)
+	for i, clr := range colors {
+		swatch := image.Rect(width+i*size, width, width+(i+1)*size, width+size)
+		if !ltr {
+			swatch = image.Rect(width, width+i*size, width+size, width+(i+1)*size)
+		}
+		draw.Draw(img, swatch, image.NewUniform(clr), image.Point{}, draw.Src)
+	}
+
+	return img
+}
+
+func TestTraverse(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	colors := []color.RGBA{
+		{29, 43, 83, 255},
+		{255, 119, 168, 255},
+		{0, 228, 54, 255},
+		{255, 236, 39, 255},
+	}
+	// a palette with a repeated color, which is kept
+	repeats := []color.RGBA{colors[0], colors[1], colors[0], colors[2]}
+
+	strips := []struct {
+		name   string
+		colors []color.RGBA
+		width  int
+		ltr    bool
+	}{
+		{"full-bleed", colors, 0, true},
+		{"full-bleed vertical", colors, 0, false},
+		{"bordered", colors, 10, true},
+		{"bordered vertical", colors, 10, false},
+		{"thin border", colors, 1, true},
+		{"full-bleed repeats", repeats, 0, true},
+		{"bordered repeats", repeats, 10, true},
+		// the first and last swatches are white like the corners, but there is no margin
+		{"full-bleed white ends", []color.RGBA{white, colors[0], colors[1], white}, 0, true},
+	}
+
+	for _, strip := range strips {
+		img := swatchStrip(strip.colors, 20, strip.width, white, strip.ltr)
+		palette, n := (&ColorPalette{}).Traverse(&img, strip.ltr, strip.name)
+
+		want := strip.colors
+		if strip.width == 0 && want[0] == white && want[len(want)-1] == white {
+			// all four corners are white, which makes it the background
+			want = want[1 : len(want)-1]
+		}
+		if n != len(want) || !reflect.DeepEqual(palette.Colors, want) {
+			t.Errorf("%s: found %d colors %v, want %v", strip.name, n, palette.Colors, want)
+		}
+	}
+}
+
+func TestTraverseLines(t *testing.T) {
+	saved := TraverseLines
+	t.Cleanup(func() { TraverseLines = saved })
+	TraverseLines = 2
+
+	// two rows of swatches on a gray background
+	gray := color.RGBA{128, 128, 128, 255}
+	top := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}}
+	bottom := []color.RGBA{{0, 0, 255, 255}, {255, 0, 0, 255}}
+
+	img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	draw.Draw(img, img.Rect, image.NewUniform(gray), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(10, 10, 30, 30), image.NewUniform(top[0]), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(30, 10, 50, 30), image.NewUniform(top[1]), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(10, 30, 30, 50), image.NewUniform(bottom[0]), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(30, 30, 50, 50), image.NewUniform(bottom[1]), image.Point{}, draw.Src)
+
+	var scanned image.Image = img
+	palette, _ := (&ColorPalette{}).Traverse(&scanned, true, "grid")
+	want := append(append([]color.RGBA{}, top...), bottom...)
+	if !reflect.DeepEqual(palette.Colors, want) {
+		t.Errorf("found %v, want the rows in order %v", palette.Colors, want)
+	}
+}
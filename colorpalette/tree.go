@@ -0,0 +1,52 @@
+package colorpalette
+
+import (
+	"context"
+	"image"
+	"image/color"
+
+	"github.com/mielpeeters/dither/geom"
+	"github.com/mielpeeters/dither/kmeans"
+)
+
+// PaletteTree holds nested palettes of an image, from 1 color up to the k colors it was created with.
+// Every palette is a subset of the palettes with more colors, which suits adaptive detail (like a GIF that
+// uses fewer colors for some frames) and progressive previews.
+type PaletteTree struct {
+	dendrogram *kmeans.Dendrogram
+}
+
+// CreateTree creates a palette of k colors like Create, and arranges its colors in a tree with agglomerative
+// clustering (see kmeans.Agglomerate), weighted by the amount of sampled pixels that each color gets.
+func CreateTree(img image.Image, k int) *PaletteTree {
+	settings := CurrentSettings()
+	colorPalette, _ := settings.create(context.Background(), []image.Image{img}, k, nil)
+	palette := colorPalette.ToPalette()
+
+	counts := make([]int, len(palette))
+	for _, point := range settings.samplePoints(img, settings.random()).Points {
+		counts[palette.Index(pointToRGBA(point))]++
+	}
+
+	points := geom.PointSet{}
+	for i, clr := range palette {
+		point := colorToPoint(clr)
+		point.ID = i
+		// colors that no sampled pixel is closest to still count a little
+		point.Weight = float32(maxInt(counts[i], 1))
+		points.Points = append(points.Points, point)
+	}
+
+	return &PaletteTree{kmeans.Agglomerate(points, settings.Metric.Distance)}
+}
+
+// Palette returns the palette of n colors (at most the k colors the tree was created with).
+// Its colors keep the order they have in the palettes with more colors.
+func (tree *PaletteTree) Palette(n int) color.Palette {
+	palette := color.Palette{}
+	for _, point := range tree.dendrogram.Cut(n).Points {
+		palette = append(palette, pointToRGBA(point))
+	}
+
+	return palette
+}
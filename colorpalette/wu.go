@@ -0,0 +1,233 @@
+package colorpalette
+
+import (
+	"image"
+	"image/color"
+)
+
+// wuSize is the size of the moment tables of Wu in each dimension: 32 levels per channel, plus a zero border
+const wuSize = 33
+
+// wuBox is a box in the color cube of Wu, spanning the levels r0 < r <= r1 (and likewise for g and b)
+type wuBox struct {
+	r0, r1, g0, g1, b0, b1 int
+}
+
+// wuMoments holds the cumulative moments of the colors: the amount of pixels, the sums of their channels
+// and the sum of their squared channels, each summed over all levels up to (and including) a cell
+type wuMoments struct {
+	weight, red, green, blue, squares []float64
+}
+
+// Wu creates a palette of k colors with Xiaolin Wu's quantizer: the color cube (at 5 bits per channel)
+// is split into boxes, each time cutting the box with the largest variance where that reduces the variance most.
+// Each box gives the average color of its pixels. A box of one cell of the cube isn't split, so images with
+// fewer than k distinct colors at 5 bits per channel give fewer colors.
+func Wu(img image.Image, k int) color.Palette {
+	if k < 1 {
+		return color.Palette{}
+	}
+
+	moments := newWuMoments(histogram(img))
+
+	boxes := make([]wuBox, k)
+	variances := make([]float64, k)
+	boxes[0] = wuBox{r1: wuSize - 1, g1: wuSize - 1, b1: wuSize - 1}
+	if moments.volume(boxes[0], moments.weight) == 0 {
+		return color.Palette{}
+	}
+
+	amount := 1
+	next := 0
+	for amount < k {
+		if moments.cut(&boxes[next], &boxes[amount]) {
+			variances[next] = moments.variance(boxes[next])
+			variances[amount] = moments.variance(boxes[amount])
+			amount++
+		} else {
+			// this box can't be split
+			variances[next] = 0
+		}
+
+		next = 0
+		for i := 1; i < amount; i++ {
+			if variances[i] > variances[next] {
+				next = i
+			}
+		}
+		if variances[next] <= 0 {
+			break
+		}
+	}
+
+	palette := color.Palette{}
+	for _, box := range boxes[:amount] {
+		weight := moments.volume(box, moments.weight)
+		if weight == 0 {
+			continue
+		}
+
+		palette = append(palette, fromChannels([]float64{
+			moments.volume(box, moments.red) / weight,
+			moments.volume(box, moments.green) / weight,
+			moments.volume(box, moments.blue) / weight,
+		}))
+	}
+
+	return palette
+}
+
+// wuIndex returns the index of a cell in the moment tables
+func wuIndex(r, g, b int) int {
+	return (r*wuSize+g)*wuSize + b
+}
+
+// newWuMoments builds the cumulative moments of the colors
+func newWuMoments(colors []weightedColor) *wuMoments {
+	cells := wuSize * wuSize * wuSize
+	moments := &wuMoments{
+		weight:  make([]float64, cells),
+		red:     make([]float64, cells),
+		green:   make([]float64, cells),
+		blue:    make([]float64, cells),
+		squares: make([]float64, cells),
+	}
+
+	for _, wc := range colors {
+		r, g, b := float64(wc.clr.R), float64(wc.clr.G), float64(wc.clr.B)
+		count := float64(wc.count)
+		i := wuIndex(int(wc.clr.R>>3)+1, int(wc.clr.G>>3)+1, int(wc.clr.B>>3)+1)
+
+		moments.weight[i] += count
+		moments.red[i] += r * count
+		moments.green[i] += g * count
+		moments.blue[i] += b * count
+		moments.squares[i] += (r*r + g*g + b*b) * count
+	}
+
+	// sum the moments over all lower levels, in each of the three dimensions
+	for _, table := range [][]float64{moments.weight, moments.red, moments.green, moments.blue, moments.squares} {
+		for r := 1; r < wuSize; r++ {
+			for g := 1; g < wuSize; g++ {
+				for b := 1; b < wuSize; b++ {
+					table[wuIndex(r, g, b)] += table[wuIndex(r-1, g, b)] + table[wuIndex(r, g-1, b)] + table[wuIndex(r, g, b-1)] -
+						table[wuIndex(r-1, g-1, b)] - table[wuIndex(r-1, g, b-1)] - table[wuIndex(r, g-1, b-1)] +
+						table[wuIndex(r-1, g-1, b-1)]
+				}
+			}
+		}
+	}
+
+	return moments
+}
+
+// volume returns the sum of a moment over the cells of the box
+func (moments *wuMoments) volume(box wuBox, table []float64) float64 {
+	return table[wuIndex(box.r1, box.g1, box.b1)] - table[wuIndex(box.r1, box.g1, box.b0)] -
+		table[wuIndex(box.r1, box.g0, box.b1)] + table[wuIndex(box.r1, box.g0, box.b0)] -
+		table[wuIndex(box.r0, box.g1, box.b1)] + table[wuIndex(box.r0, box.g1, box.b0)] +
+		table[wuIndex(box.r0, box.g0, box.b1)] - table[wuIndex(box.r0, box.g0, box.b0)]
+}
+
+// lower returns the box with its upper bound in dimension dir (0 for red, 1 for green, 2 for blue) moved to position
+func (box wuBox) lower(dir, position int) wuBox {
+	switch dir {
+	case 0:
+		box.r1 = position
+	case 1:
+		box.g1 = position
+	default:
+		box.b1 = position
+	}
+	return box
+}
+
+// bounds returns the lower and upper bound of the box in dimension dir
+func (box wuBox) bounds(dir int) (int, int) {
+	switch dir {
+	case 0:
+		return box.r0, box.r1
+	case 1:
+		return box.g0, box.g1
+	}
+	return box.b0, box.b1
+}
+
+// variance returns the sum of the squared distances of the pixels in the box to their average color,
+// or 0 if the box is a single cell (and can't be split)
+func (moments *wuMoments) variance(box wuBox) float64 {
+	if box.r1-box.r0 <= 1 && box.g1-box.g0 <= 1 && box.b1-box.b0 <= 1 {
+		return 0
+	}
+
+	weight := moments.volume(box, moments.weight)
+	if weight == 0 {
+		return 0
+	}
+
+	r := moments.volume(box, moments.red)
+	g := moments.volume(box, moments.green)
+	b := moments.volume(box, moments.blue)
+
+	return moments.volume(box, moments.squares) - (r*r+g*g+b*b)/weight
+}
+
+// maximize finds the position in dimension dir at which to cut the box, so that the variance of both halves
+// is minimal. It returns that position (or -1 if the box can't be cut) and how good the cut is (higher is better).
+func (moments *wuMoments) maximize(box wuBox, dir int) (int, float64) {
+	wholeR := moments.volume(box, moments.red)
+	wholeG := moments.volume(box, moments.green)
+	wholeB := moments.volume(box, moments.blue)
+	wholeW := moments.volume(box, moments.weight)
+
+	low, high := box.bounds(dir)
+	cut, best := -1, 0.0
+
+	for position := low + 1; position < high; position++ {
+		half := box.lower(dir, position)
+
+		halfR := moments.volume(half, moments.red)
+		halfG := moments.volume(half, moments.green)
+		halfB := moments.volume(half, moments.blue)
+		halfW := moments.volume(half, moments.weight)
+		if halfW == 0 || halfW == wholeW {
+			continue
+		}
+
+		score := (halfR*halfR + halfG*halfG + halfB*halfB) / halfW
+		restR, restG, restB, restW := wholeR-halfR, wholeG-halfG, wholeB-halfB, wholeW-halfW
+		score += (restR*restR + restG*restG + restB*restB) / restW
+
+		if score > best {
+			cut, best = position, score
+		}
+	}
+
+	return cut, best
+}
+
+// cut splits box in two along the best dimension, putting the upper part in other.
+// It returns false if the box can't be split.
+func (moments *wuMoments) cut(box, other *wuBox) bool {
+	dir, position, best := -1, -1, 0.0
+	for d := 0; d < 3; d++ {
+		if cut, score := moments.maximize(*box, d); cut >= 0 && (dir < 0 || score > best) {
+			dir, position, best = d, cut, score
+		}
+	}
+	if dir < 0 {
+		return false
+	}
+
+	*other = *box
+	switch dir {
+	case 0:
+		box.r1, other.r0 = position, position
+	case 1:
+		box.g1, other.g0 = position, position
+	default:
+		box.b1, other.b0 = position, position
+	}
+
+	return true
+}
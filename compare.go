@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/imgutil"
+)
+
+// sheetCellWidth is the least width of the images on the contact sheet, smaller images are scaled up
+// by a whole factor so that their pixels stay sharp and their labels fit
+const sheetCellWidth = 200
+
+// comparison is the result of dithering the input with one combination of a dithering algorithm and amount of colors
+type comparison struct {
+	dither   string
+	k        int
+	image    *image.Paletted
+	quality  colorpalette.Quality
+	duration time.Duration
+}
+
+// compareCommand dithers an image with every combination of the given dithering algorithms and amounts of colors,
+// and saves them on a labeled contact sheet, printing how close each one is to the image
+func compareCommand(args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	inputPath := flags.String("p", "", "path to the input image, which can be given as the argument as well")
+	outputPath := flags.String("o", "compare.png", "path to save the contact sheet of the dithered images to")
+	dithers := flags.String("dithers", "floyd-steinberg,atkinson,bayer8", "comma separated dithering algorithms to compare: "+strings.Join(ditherNames(), ", ")+" (fs and jjn for short)")
+	ks := flags.String("k", "8,16", "comma separated amounts of colors to compare, a palette is created from the image for each")
+	size := addSizeFlags(flags, "factor by which the image is scaled down before dithering")
+	blur := flags.Int("blur", colorpalette.QualityBlur, "radius of the blur before measuring, the way the eye mixes the dots at a distance (0 measures the pixels as they are)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither compare input.jpg [-dithers fs,atkinson,bayer8] [-k 8,16] [-o compare.png]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *inputPath == "" && flags.NArg() == 1 {
+		*inputPath = flags.Arg(0)
+	} else if flags.NArg() > 0 {
+		problems.add("compare takes one input image, as -p or the argument, not %q", flags.Args())
+	}
+	if *inputPath == "" {
+		problems.add("provide an input image")
+	}
+	size.check(problems)
+	if *blur < 0 {
+		problems.add("the radius of the blur (-blur) can't be negative")
+	}
+
+	algorithms := []ditherer{}
+	for _, name := range strings.Split(*dithers, ",") {
+		algorithm, ok := ditherWithName(strings.TrimSpace(name))
+		if !ok {
+			problems.add("the dithering algorithms (-dithers) need to be some of %s, not %q", strings.Join(ditherNames(), ", "), name)
+			continue
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	amounts := []int{}
+	for _, value := range strings.Split(*ks, ",") {
+		k, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || k < 1 || k > 256 {
+			problems.add("the amounts of colors (-k) need to be between 1 and 256, not %q", value)
+			continue
+		}
+		amounts = append(amounts, k)
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+	colorpalette.QualityBlur = *blur
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	timer := newTiming()
+
+	done := timer.stage("decode", *inputPath)
+	_, scaledImage, err := openScaled(*inputPath, size)
+	done()
+	if err != nil {
+		return err
+	}
+
+	comparisons := []comparison{}
+	for _, k := range amounts {
+		// the progress of creating the palettes isn't printed, the stages are
+		options := paletteOptions{k: k, quantizer: "kmeans", quiet: true}
+		if err := options.setup(); err != nil {
+			return err
+		}
+
+		done = timer.stage("palette", fmt.Sprintf("k %d", k))
+		palette, index, err := options.palette(ctx, scaledImage)
+		done()
+		if err != nil {
+			return err
+		}
+		colorIndex := options.colorIndex(palette, index)
+
+		for _, algorithm := range algorithms {
+			// error diffusion changes the image it dithers
+			input := image.NewRGBA(scaledImage.Rect)
+			copy(input.Pix, scaledImage.Pix)
+
+			done = timer.stage("dither", "")
+			start := time.Now()
+			paletted, err := algorithm.paletted(ctx, input, palette, colorIndex, nil)
+			duration := time.Since(start)
+			done()
+			if err != nil {
+				return err
+			}
+
+			done = timer.stage("measure", "")
+			quality := colorpalette.MeasureQuality(scaledImage, paletted)
+			done()
+
+			comparisons = append(comparisons, comparison{algorithm.name, k, paletted, quality, duration})
+		}
+	}
+
+	printComparisons(os.Stdout, comparisons)
+
+	done = timer.stage("encode", *outputPath)
+	format := imgutil.FormatOf(*outputPath)
+	if format == "" {
+		format = "png"
+	}
+	err = saveImage(contactSheet(scaledImage, comparisons, len(algorithms)), *outputPath, format)
+	done()
+	if err != nil {
+		return err
+	}
+
+	timer.summary()
+
+	return nil
+}
+
+// printComparisons prints the quality and duration of every comparison as a table
+func printComparisons(w io.Writer, comparisons []comparison) {
+	fmt.Fprintf(w, "%-20s %4s %8s %7s %12s %10s\n", "dither", "k", "PSNR", "SSIM", "mean DeltaE", "duration")
+	for _, c := range comparisons {
+		fmt.Fprintf(w, "%-20s %4d %8.2f %7.4f %12.2f %10s\n",
+			c.dither, c.k, c.quality.PSNR, c.quality.SSIM, c.quality.MeanDeltaE, roundDuration(c.duration))
+	}
+}
+
+// contactSheet lays out the original and its dithered comparisons, columns to a row, each labeled with
+// its dithering algorithm and amount of colors. The original has a row of its own.
+func contactSheet(original image.Image, comparisons []comparison, columns int) *image.RGBA {
+	const padding, labelHeight = 8, 18
+
+	bounds := original.Bounds()
+	scale := (sheetCellWidth + bounds.Dx() - 1) / bounds.Dx()
+	cellWidth, cellHeight := bounds.Dx()*scale, bounds.Dy()*scale
+
+	rows := 1 + (len(comparisons)+columns-1)/columns
+	sheet := image.NewRGBA(image.Rect(0, 0,
+		padding+columns*(cellWidth+padding),
+		padding+rows*(cellHeight+labelHeight+padding)))
+	draw.Draw(sheet, sheet.Rect, image.White, image.Point{}, draw.Src)
+
+	drawer := font.Drawer{Dst: sheet, Src: image.Black, Face: basicfont.Face7x13}
+	cell := func(i int, img image.Image, label string) {
+		x := padding + (i%columns)*(cellWidth+padding)
+		y := padding + (i/columns)*(cellHeight+labelHeight+padding)
+
+		// scale up by repeating the pixels, like the dithered images are meant to be seen
+		for dy := 0; dy < cellHeight; dy++ {
+			for dx := 0; dx < cellWidth; dx++ {
+				sheet.Set(x+dx, y+dy, img.At(img.Bounds().Min.X+dx/scale, img.Bounds().Min.Y+dy/scale))
+			}
+		}
+
+		drawer.Dot = fixed.P(x, y+cellHeight+labelHeight-5)
+		drawer.DrawString(label)
+	}
+
+	cell(0, original, "original")
+	for i, c := range comparisons {
+		cell(columns+i, c.image, fmt.Sprintf("%s, k %d", c.dither, c.k))
+	}
+
+	return sheet
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configFile is the name of the config file with the default flags of the subcommands. It is read from the
+// current directory, or else from the dither folder of the user config directory (like ~/.config/dither).
+//
+// It is a small subset of TOML: keys are the names of flags, and the values are strings, numbers or booleans.
+// The keys at the top apply to every subcommand that has the flag, those in a [command] table only to that
+// subcommand, and those in a [preset.name] table when the subcommand is run with -preset name.
+// Flags given on the command line override the presets, which override the command tables, which override the top.
+// The [project] and [task.name] tables hold a project instead, see projectFile; their values can also be arrays of strings.
+//
+//	scale = 4
+//	dither = "stucki"
+//
+//	[image]
+//	o = "output.png"
+//
+//	[preset.poster]
+//	k = 6
+//	duotone = "1d2b53,ff77a8"
+const configFile = "dither.toml"
+
+// config maps the names of the tables of a config file ("" for the top) to their keys and values
+type config map[string]map[string]configValue
+
+// configValue is a value of a config file. The strings, numbers and booleans are kept as text, as flag.FlagSet.Set
+// takes them, and an array is kept as the text of its values.
+type configValue struct {
+	text string
+	// list holds the values of an array, and is nil for the other values
+	list []string
+}
+
+// findConfig returns the path of the config file, or "" if there is none
+func findConfig() string {
+	if _, err := os.Stat(configFile); err == nil {
+		return configFile
+	}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		path := filepath.Join(dir, "dither", configFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// readConfig reads the config file at path
+func readConfig(path string) (config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cfg := config{"": {}}
+	table := ""
+
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		start := number
+		// an array can span several lines
+		if key, value, ok := strings.Cut(line, "="); ok && strings.HasPrefix(strings.TrimSpace(value), "[") && !strings.HasPrefix(key, "[") {
+			for !arrayClosed(line) && scanner.Scan() {
+				number++
+				line += " " + strings.TrimSpace(stripComment(scanner.Text()))
+			}
+		}
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: unterminated table header", path, number)
+			}
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			if table == "" {
+				return nil, fmt.Errorf("%s:%d: empty table name", path, number)
+			}
+			if cfg[table] == nil {
+				cfg[table] = map[string]configValue{}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, start)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		var parsed configValue
+		var err error
+		if strings.HasPrefix(value, "[") {
+			parsed.list, err = parseArray(value)
+		} else {
+			parsed.text, err = parseValue(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, start, key, err)
+		}
+		cfg[table][key] = parsed
+	}
+
+	return cfg, scanner.Err()
+}
+
+// stripComment removes a # comment from line, unless the # is within a string
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			// skip the escaped character, which can't end the string
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// parseValue returns the text of a TOML string, number or boolean
+func parseValue(value string) (string, error) {
+	switch {
+	case value == "":
+		return "", errors.New("missing value")
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", errors.New("unterminated string")
+		}
+		return value[1 : len(value)-1], nil
+	case value == "true" || value == "false":
+		return value, nil
+	}
+
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64); err != nil {
+		return "", fmt.Errorf("%q is not a string, number or boolean", value)
+	}
+
+	return strings.ReplaceAll(value, "_", ""), nil
+}
+
+// arrayClosed returns whether the array that line starts has its closing ], outside of the strings in it
+func arrayClosed(line string) bool {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == ']':
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseArray returns the text of the strings, numbers and booleans of a TOML array on one line, like ["a", 'b', 4]
+func parseArray(value string) ([]string, error) {
+	if !strings.HasSuffix(value, "]") || !arrayClosed(value) {
+		return nil, errors.New("unterminated array")
+	}
+
+	list := []string{}
+	rest := strings.TrimSpace(value[1 : len(value)-1])
+	for rest != "" {
+		// the end of the element: the closing quote of a string, else the next comma
+		end := strings.IndexByte(rest, ',')
+		if rest[0] == '"' || rest[0] == '\'' {
+			end = -1
+			for i := 1; i < len(rest); i++ {
+				if rest[0] == '"' && rest[i] == '\\' {
+					i++
+				} else if rest[i] == rest[0] {
+					end = i + 1
+					break
+				}
+			}
+			if end == -1 {
+				return nil, errors.New("unterminated string")
+			}
+		}
+		if end == -1 {
+			end = len(rest)
+		}
+
+		element, err := parseValue(strings.TrimSpace(rest[:end]))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, element)
+
+		rest = strings.TrimSpace(rest[end:])
+		if rest != "" {
+			if rest[0] != ',' {
+				return nil, fmt.Errorf("expected a comma after %q", element)
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+
+	return list, nil
+}
+
+// parseFlags parses the flags of a subcommand, like flags.Parse, and then sets the flags that aren't given in args
+// to the values of the config file, see configFile. It adds the -config, -preset, -j and -seed flags, and those of the verbosity.
+func parseFlags(flags *flag.FlagSet, args []string) (err error) {
+	path := flags.String("config", "", "path to the config file with the default flags (default ./"+configFile+", or one in the user config directory)")
+	preset := flags.String("preset", "", "name of a [preset.name] table of the config file, with flags to use")
+	setVerbosity := addVerbosityFlags(flags)
+	setJobs := addJobsFlag(flags)
+	addSeedFlag(flags)
+	flags.Parse(flagsFirst(flags, args))
+	// the config file can set the verbosity flags, -j and the seed as well, so they are read when it is applied
+	defer func() {
+		if err == nil {
+			err = setVerbosity()
+		}
+		if err == nil {
+			err = setJobs()
+		}
+		if err == nil {
+			applySeed()
+		}
+	}()
+
+	if *path == "" {
+		*path = findConfig()
+	}
+	if *path == "" {
+		if *preset != "" {
+			return fmt.Errorf("there is no %s config file with the preset %q", configFile, *preset)
+		}
+		return nil
+	}
+
+	cfg, err := readConfig(*path)
+	if err != nil {
+		return err
+	}
+
+	if *preset != "" && cfg["preset."+*preset] == nil {
+		return fmt.Errorf("%s has no preset %q", *path, *preset)
+	}
+
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	// the tables from the most general to the most specific, so that the specific values are set last
+	tables := []string{"", flags.Name()}
+	if *preset != "" {
+		tables = append(tables, "preset."+*preset)
+	}
+	for _, table := range tables {
+		for key, value := range cfg[table] {
+			if key == "config" || key == "preset" || given[key] {
+				continue
+			}
+			if flags.Lookup(key) == nil {
+				// the top and the presets are shared by subcommands with other flags
+				if table == flags.Name() {
+					return fmt.Errorf("%s: [%s] has no flag -%s", *path, table, key)
+				}
+				continue
+			}
+			if value.list != nil {
+				return fmt.Errorf("%s: %s: a flag takes a string, number or boolean, not an array", *path, key)
+			}
+			if err := flags.Set(key, value.text); err != nil {
+				return fmt.Errorf("%s: %s: %w", *path, key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// flagsFirst moves the arguments that aren't flags (or their values) behind the flags, so that flags.Parse,
+// which stops at the first of them, also parses the flags after them (like in `palettes show pico-8 -o swatch.png`)
+func flagsFirst(flags *flag.FlagSet, args []string) []string {
+	options, rest := []string{}, []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			options = append(options, arg)
+			return append(append(options, rest...), args[i+1:]...)
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			rest = append(rest, arg)
+		default:
+			options = append(options, arg)
+			name := strings.TrimLeft(arg, "-")
+			if strings.Contains(name, "=") {
+				continue
+			}
+			// the value of a flag that isn't a boolean is the next argument
+			f := flags.Lookup(name)
+			if f == nil {
+				continue
+			}
+			if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); !(ok && boolFlag.IsBoolFlag()) && i+1 < len(args) {
+				i++
+				options = append(options, args[i])
+			}
+		}
+	}
+
+	return append(options, rest...)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mielpeeters/dither/gameofcolor"
+	"github.com/mielpeeters/dither/gifeo"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/kmeans"
+)
+
+// writeConfig writes contents to a config file in a temporary directory, and returns its path
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), configFile)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// keepSettings restores the package settings that parseFlags changes (the verbosity, the seed and the workers)
+// when the test is done
+func keepSettings(t *testing.T) {
+	t.Helper()
+
+	savedVerbosity, savedSeed, procs := verbosity, seed, runtime.GOMAXPROCS(0)
+	kmeansWorkers, imgutilWorkers, gifeoWorkers, gameWorkers := kmeans.Workers, imgutil.Workers, gifeo.Workers, gameofcolor.Workers
+	gifeoVerbosity := gifeo.Verbosity
+	t.Cleanup(func() {
+		verbosity, seed = savedVerbosity, savedSeed
+		runtime.GOMAXPROCS(procs)
+		kmeans.Workers, imgutil.Workers, gifeo.Workers, gameofcolor.Workers = kmeansWorkers, imgutilWorkers, gifeoWorkers, gameWorkers
+		gifeo.Verbosity = gifeoVerbosity
+	})
+}
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     config
+		// err is a part of the error, if reading the config fails
+		err string
+	}{
+		{
+			name:     "empty",
+			contents: "",
+			want:     config{"": {}},
+		},
+		{
+			name:     "values",
+			contents: "scale = 4\nratio = 0.5\nbig = 1_000\nwatch = true\ndither = \"stucki\"\nname = 'C:\\photos'\n",
+			want: config{"": {
+				"scale":  {text: "4"},
+				"ratio":  {text: "0.5"},
+				"big":    {text: "1000"},
+				"watch":  {text: "true"},
+				"dither": {text: "stucki"},
+				"name":   {text: `C:\photos`},
+			}},
+		},
+		{
+			name:     "tables",
+			contents: "k = 8\n\n[image]\no = \"output.png\"\n\n[ preset.poster ]\nk = 6\n",
+			want: config{
+				"":              {"k": {text: "8"}},
+				"image":         {"o": {text: "output.png"}},
+				"preset.poster": {"k": {text: "6"}},
+			},
+		},
+		{
+			name:     "comments",
+			contents: "# the defaults\nk = 8 # colors\nduotone = \"#1d2b53,#ff77a8\" # inks\nescaped = \"a\\\"#b\"\n",
+			want: config{"": {
+				"k":       {text: "8"},
+				"duotone": {text: "#1d2b53,#ff77a8"},
+				"escaped": {text: `a"#b`},
+			}},
+		},
+		{
+			name:     "arrays",
+			contents: "args = [\"image\", '-p', 4, true]\nempty = []\nlines = [\n  \"a\", # first\n  \"b]\",\n]\n",
+			want: config{"": {
+				"args":  {list: []string{"image", "-p", "4", "true"}},
+				"empty": {list: []string{}},
+				"lines": {list: []string{"a", "b]"}},
+			}},
+		},
+		{name: "no value", contents: "k =\n", err: ":1: k: missing value"},
+		{name: "no key", contents: "k = 8\nscale\n", err: ":2: expected key = value"},
+		{name: "bare word", contents: "dither = stucki\n", err: `"stucki" is not a string, number or boolean`},
+		{name: "unterminated string", contents: "o = 'output.png\n", err: "unterminated string"},
+		{name: "unterminated table", contents: "[image\n", err: "unterminated table header"},
+		{name: "empty table", contents: "[ ]\n", err: "empty table name"},
+		{name: "unterminated array", contents: "args = [\"a\", \"b\"\n", err: "unterminated array"},
+		{name: "no comma", contents: "args = [\"a\" \"b\"]\n", err: `expected a comma after "a"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := readConfig(writeConfig(t, test.contents))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("error %v, want one with %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, test.want) {
+				t.Errorf("read %v, want %v", cfg, test.want)
+			}
+		})
+	}
+}
+
+// TestParseFlags checks that the command line overrides the preset, which overrides the table of the command,
+// which overrides the top of the config file
+func TestParseFlags(t *testing.T) {
+	path := writeConfig(t, `
+k = 2
+scale = 2
+dither = "atkinson"
+o = "top.png"
+width = 10
+
+[image]
+k = 3
+scale = 3
+o = "image.png"
+
+[palette]
+nothing = 1
+
+[preset.poster]
+k = 4
+unknown = "skipped"
+`)
+
+	tests := []struct {
+		name string
+		args []string
+		// want maps flags to their values after parsing
+		want map[string]string
+		err  string
+	}{
+		{
+			name: "config",
+			args: []string{"-config", path},
+			want: map[string]string{"k": "3", "scale": "3", "dither": "atkinson", "o": "image.png", "width": "10"},
+		},
+		{
+			name: "preset",
+			args: []string{"-config", path, "-preset", "poster"},
+			want: map[string]string{"k": "4", "scale": "3", "o": "image.png"},
+		},
+		{
+			name: "command line",
+			args: []string{"-config", path, "-preset", "poster", "-k", "5", "input.jpg", "-o", "given.png"},
+			want: map[string]string{"k": "5", "scale": "3", "o": "given.png"},
+		},
+		{name: "no preset", args: []string{"-config", path, "-preset", "flyer"}, err: `has no preset "flyer"`},
+		{name: "no config", args: []string{"-config", filepath.Join(t.TempDir(), configFile)}, err: "no such file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keepSettings(t)
+
+			flags := flag.NewFlagSet("image", flag.ContinueOnError)
+			values := map[string]*string{}
+			for _, name := range []string{"k", "scale", "dither", "o", "width"} {
+				values[name] = flags.String(name, "", "")
+			}
+
+			err := parseFlags(flags, test.args)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("error %v, want one with %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range test.want {
+				if *values[name] != want {
+					t.Errorf("-%s is %q, want %q", name, *values[name], want)
+				}
+			}
+		})
+	}
+}
+
+// TestParseFlagsInvalid checks that the values of the config file that can't be set on the flags of the command are reported
+func TestParseFlagsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{name: "unknown flag of the command", contents: "[image]\nnothing = 1\n", err: "[image] has no flag -nothing"},
+		{name: "array", contents: "k = [1, 2]\n", err: "k: a flag takes a string, number or boolean, not an array"},
+		{name: "invalid value", contents: "k = \"many\"\n", err: "k: parse error"},
+		{name: "invalid threads", contents: "j = 0\n", err: "the amount of threads (-j) needs to be at least 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keepSettings(t)
+
+			flags := flag.NewFlagSet("image", flag.ContinueOnError)
+			flags.Int("k", 8, "")
+			if err := parseFlags(flags, []string{"-config", writeConfig(t, test.contents)}); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error %v, want one with %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestFlagsFirst(t *testing.T) {
+	flags := flag.NewFlagSet("palettes show", flag.ContinueOnError)
+	flags.String("o", "", "")
+	flags.Bool("v", false, "")
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{args: []string{"pico-8", "-o", "swatch.png"}, want: []string{"-o", "swatch.png", "pico-8"}},
+		{args: []string{"pico-8", "-v", "gameboy"}, want: []string{"-v", "pico-8", "gameboy"}},
+		{args: []string{"-", "-o=swatch.png"}, want: []string{"-o=swatch.png", "-"}},
+		{args: []string{"pico-8", "--", "-o"}, want: []string{"--", "pico-8", "-o"}},
+		{args: []string{"pico-8", "-unknown", "value"}, want: []string{"-unknown", "pico-8", "value"}},
+	}
+
+	for _, test := range tests {
+		if got := flagsFirst(flags, test.args); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q is ordered as %q, want %q", test.args, got, test.want)
+		}
+	}
+}
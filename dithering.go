@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/mielpeeters/dither/process"
+)
+
+// ditherer is the dithering algorithm chosen with -dither: an error diffusion matrix,
+// or the threshold matrix of ordered dithering
+type ditherer struct {
+	name      string
+	kernel    *process.ErrorDiffusionMatrix
+	threshold process.ThresholdMatrix
+}
+
+// ditherAliases are the short names of dithering algorithms
+var ditherAliases = map[string]string{
+	"fs":  "floyd-steinberg",
+	"jjn": "jarvis-judice-ninke",
+}
+
+// addDitherFlag registers the flag that chooses the dithering algorithm on flags
+func addDitherFlag(flags *flag.FlagSet) *string {
+	return flags.String("dither", "floyd-steinberg", "dithering algorithm: "+strings.Join(ditherNames(), ", ")+" (fs and jjn for short)")
+}
+
+// ditherFlag returns the dithering algorithm with the name of the -dither flag,
+// or adds a problem to problems if there is none
+func ditherFlag(problems *flagErrors, name string) ditherer {
+	if algorithm, ok := ditherWithName(name); ok {
+		return algorithm
+	}
+
+	problems.add("the dithering algorithm (-dither) needs to be one of %s, not %q", strings.Join(ditherNames(), ", "), name)
+	return ditherer{name: name, kernel: &process.FloydSteinBerg}
+}
+
+// ditherWithName returns the dithering algorithm with name (or one of ditherAliases), if there is one
+func ditherWithName(name string) (ditherer, bool) {
+	if alias, ok := ditherAliases[name]; ok {
+		name = alias
+	}
+	if kernel, ok := process.KernelWithName(name); ok {
+		return ditherer{name: name, kernel: kernel}, true
+	}
+	if threshold, ok := process.ThresholdWithName(name); ok {
+		return ditherer{name: name, threshold: threshold}, true
+	}
+
+	return ditherer{}, false
+}
+
+// ordered returns whether the algorithm is ordered dithering, which only makes paletted images
+func (d ditherer) ordered() bool {
+	return d.threshold != nil
+}
+
+// paletted dithers img with palette and its index, see process.ApplyErrorDiffusionIndex
+func (d ditherer) paletted(ctx context.Context, img *image.RGBA, palette color.Palette, index process.ColorIndex, progress process.Progress) (*image.Paletted, error) {
+	if d.ordered() {
+		return process.ApplyOrderedIndex(ctx, img, palette, index, d.threshold, progress)
+	}
+
+	return process.ApplyErrorDiffusionIndex(ctx, img, palette, index, d.kernel, progress)
+}
+
+// ditherNames returns the names of process.Kernels and process.Thresholds
+func ditherNames() []string {
+	names := []string{}
+	for _, kernel := range process.Kernels {
+		names = append(names, kernel.Name)
+	}
+	for _, threshold := range process.Thresholds {
+		names = append(names, threshold.Name)
+	}
+
+	return names
+}
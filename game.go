@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strings"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/gameofcolor"
+	"github.com/mielpeeters/dither/kdtree"
+)
+
+// gameRules maps the names of the -rules of `dither game` to their rule maps for a palette of k colors.
+// The rules that are made for black and white images ignore k.
+var gameRules = map[string]func(k int) gameofcolor.RuleMap{
+	"life":                func(int) gameofcolor.RuleMap { return gameofcolor.GameOfLifeRules() },
+	"maze":                func(int) gameofcolor.RuleMap { return gameofcolor.MazeRules() },
+	"rock-paper-scissors": gameofcolor.RockPaperScissors,
+	"crystal":             gameofcolor.Crystalisation,
+	"average":             gameofcolor.AvgRules,
+}
+
+// gameCommand dithers an image, and plays a game of color on it, saving the generations as a gif or video
+func gameCommand(args []string) error {
+	flags := flag.NewFlagSet("game", flag.ExitOnError)
+	inputPath := flags.String("p", "", "path to the input image")
+	outputPath := flags.String("o", "output.gif", "path to the output gif, or mp4 or webm video (using ffmpeg)")
+	size := addSizeFlags(flags, "factor by which the image is scaled down before dithering")
+	rules := flags.String("rules", "life", "rules of the game: "+strings.Join(ruleNames(), ", "))
+	iterations := flags.Int("iterations", 50, "amount of generations to play")
+	delay := flags.Int("delay", 8, "delay between the frames, in 100ths of a second")
+	video := addVideoFlags(flags)
+	ditherName := addDitherFlag(flags)
+	options := addPaletteFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither game -p input.jpg -o output.gif [-rules life] [-iterations 50]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *inputPath == "" {
+		problems.add("provide an input image (-p)")
+	}
+	newRules, ok := gameRules[*rules]
+	if !ok {
+		problems.add("the rules (-rules) need to be one of %s, not %q", strings.Join(ruleNames(), ", "), *rules)
+	}
+	if *iterations < 1 {
+		problems.add("the amount of iterations (-iterations) needs to be at least 1")
+	}
+	size.check(problems)
+	algorithm := ditherFlag(problems, *ditherName)
+	given := givenFlags(flags)
+	checkVideo(problems, video, *outputPath, given)
+	options.check(problems, given)
+
+	var fixed color.Palette
+	if *rules == "life" || *rules == "maze" {
+		fixed = colorpalette.BW()
+		if given["palette"] || given["palette-file"] || given["k"] {
+			problems.add("the %s rules play on black and white images, they don't use -palette, -palette-file or -k", *rules)
+		}
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+	if err := checkFFmpeg(*outputPath); err != nil {
+		return err
+	}
+	if err := options.setup(); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	timer := newTiming()
+
+	done := timer.stage("dither", "")
+	paletted, err := ditherInput(ctx, *inputPath, size, options, fixed, algorithm)
+	done()
+	if err != nil {
+		return err
+	}
+
+	done = timer.stage("play", "")
+	frames := newRules(len(paletted.Palette)).Play(paletted, *iterations)
+	done()
+
+	done = timer.stage("encode", "")
+	err = encodeFrames(frames, *outputPath, *delay, video)
+	done()
+	if err != nil {
+		return err
+	}
+
+	logf(1, "saved %s", *outputPath)
+	timer.summary()
+
+	return nil
+}
+
+// ruleNames returns the names of the gameRules, sorted
+func ruleNames() []string {
+	names := make([]string, 0, len(gameRules))
+	for name := range gameRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ditherInput opens the image at path and resizes it to size, and dithers it with algorithm and the palette: fixed if it isn't nil,
+// or else the palette of the options
+func ditherInput(ctx context.Context, path string, size *sizeOptions, options *paletteOptions, fixed color.Palette, algorithm ditherer) (*image.Paletted, error) {
+	_, scaledImage, err := openScaled(path, size)
+	if err != nil {
+		return nil, err
+	}
+
+	palette := fixed
+	var index *kdtree.PaletteIndex
+	if palette == nil {
+		palette, index, err = options.palette(ctx, scaledImage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return algorithm.paletted(ctx, scaledImage, palette, options.colorIndex(palette, index), nil)
+}
@@ -18,6 +18,10 @@ import (
 	"github.com/mielpeeters/pacebar"
 )
 
+// Workers is the amount of goroutines that ApplyRules splits the columns of the image over.
+// If it is 0 (the default), it is runtime.GOMAXPROCS(0).
+var Workers = 0
+
 // Neighbour defines an offset, to describe a neighbouring pixel
 type Neighbour struct {
 	// X and Y are the offsets relative to the current point
@@ -437,7 +441,11 @@ func (rm RuleMap) ApplyRules(img *image.Paletted) *image.Paletted {
 		Xs[i] = i
 	}
 
-	XSlices := needle.ChunkSlice(Xs, runtime.GOMAXPROCS(0))
+	workers := Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	XSlices := needle.ChunkSlice(Xs, workers)
 
 	wg := sync.WaitGroup{}
 
@@ -461,8 +469,14 @@ func (rm RuleMap) ApplyRules(img *image.Paletted) *image.Paletted {
 	return newImg
 }
 
-// PlayGame goes through an amount of iterations of a game based on the given rulemap
+// PlayGame goes through an amount of iterations of a game based on the given rulemap, and saves them as a gif
 func (rm RuleMap) PlayGame(img *image.Paletted, iterations int, outputFile string, delay int) {
+	gifeo.EncodeGIF(rm.Play(img, iterations), outputFile, delay)
+}
+
+// Play goes through an amount of iterations of a game based on the given rulemap,
+// and returns img followed by every generation
+func (rm RuleMap) Play(img *image.Paletted, iterations int) []*image.Paletted {
 	var lastFrame *image.Paletted
 	frames := make([]*image.Paletted, iterations+1)
 
@@ -480,5 +494,5 @@ func (rm RuleMap) PlayGame(img *image.Paletted, iterations int, outputFile strin
 		pb.Done(1)
 	}
 
-	gifeo.EncodeGIF(frames, outputFile, delay)
+	return frames
 }
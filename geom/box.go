@@ -0,0 +1,79 @@
+package geom
+
+// BoundingBox is an axis-aligned box, from Lower to Upper (inclusive) in each dimension.
+// The zero value is an empty box, that Expand grows around the first coordinates it gets.
+type BoundingBox struct {
+	Lower []float64
+	Upper []float64
+}
+
+// BoxAround returns the square box of 2D positions within radius of center, along both axes
+func BoxAround(center Vec, radius float64) BoundingBox {
+	return BoundingBox{
+		Lower: []float64{center[0] - radius, center[1] - radius},
+		Upper: []float64{center[0] + radius, center[1] + radius},
+	}
+}
+
+// Empty reports whether the box holds nothing, because it has no dimensions yet
+func (box *BoundingBox) Empty() bool {
+	return len(box.Lower) == 0
+}
+
+// Contains reports whether the coordinates lie within the box (or on its edge)
+func (box *BoundingBox) Contains(coordinates []float64) bool {
+	if box.Empty() {
+		return false
+	}
+
+	for dim, value := range coordinates {
+		if value < box.Lower[dim] || value > box.Upper[dim] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Intersects reports whether the boxes overlap (touching edges count)
+func (box *BoundingBox) Intersects(other *BoundingBox) bool {
+	if box.Empty() || other.Empty() {
+		return false
+	}
+
+	for dim := range box.Lower {
+		if other.Upper[dim] < box.Lower[dim] || other.Lower[dim] > box.Upper[dim] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Expand grows the box so that it contains the coordinates
+func (box *BoundingBox) Expand(coordinates []float64) {
+	if box.Empty() {
+		box.Lower = append([]float64{}, coordinates...)
+		box.Upper = append([]float64{}, coordinates...)
+		return
+	}
+
+	for dim, value := range coordinates {
+		if value < box.Lower[dim] {
+			box.Lower[dim] = value
+		}
+		if value > box.Upper[dim] {
+			box.Upper[dim] = value
+		}
+	}
+}
+
+// BoundingBox returns the smallest box that contains all points of the set, like LowerAndUpperBounds
+func (ps *PointSet) BoundingBox() BoundingBox {
+	var box BoundingBox
+	for i := range ps.Points {
+		box.Expand(ps.Points[i].Point64().Coordinates)
+	}
+
+	return box
+}
@@ -0,0 +1,54 @@
+package geom
+
+import "testing"
+
+// TestBoundingBox checks the containment and intersection tests of boxes
+func TestBoundingBox(t *testing.T) {
+	set := PointSet{Points: []Point{
+		{Coordinates: []float32{1, 5}},
+		{Coordinates: []float32{3, 2}},
+		{Coordinates: []float32{2, 4}},
+	}}
+
+	box := set.BoundingBox()
+	if box.Lower[0] != 1 || box.Lower[1] != 2 || box.Upper[0] != 3 || box.Upper[1] != 5 {
+		t.Fatalf("got box %v, want from [1 2] to [3 5]", box)
+	}
+
+	for _, test := range []struct {
+		coordinates []float64
+		want        bool
+	}{
+		{[]float64{2, 3}, true},
+		{[]float64{1, 5}, true},
+		{[]float64{0, 3}, false},
+		{[]float64{2, 6}, false},
+	} {
+		if got := box.Contains(test.coordinates); got != test.want {
+			t.Errorf("Contains(%v): got %v, want %v", test.coordinates, got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		other BoundingBox
+		want  bool
+	}{
+		{BoxAround(Vec{2, 3}, 0.5), true},
+		{BoxAround(Vec{4, 6}, 1), true},
+		{BoxAround(Vec{5, 3}, 1), false},
+		{BoundingBox{}, false},
+	} {
+		if got := box.Intersects(&test.other); got != test.want {
+			t.Errorf("Intersects(%v): got %v, want %v", test.other, got, test.want)
+		}
+	}
+
+	var empty BoundingBox
+	if empty.Contains([]float64{0, 0}) {
+		t.Errorf("an empty box contains a point")
+	}
+	empty.Expand([]float64{1, 1})
+	if !empty.Contains([]float64{1, 1}) || empty.Contains([]float64{1, 2}) {
+		t.Errorf("a box expanded around one point is %v", empty)
+	}
+}
@@ -0,0 +1,37 @@
+package geom
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Dedupe returns the distinct points of the set (by coordinates), each with the summed Mass of its copies as Weight,
+// in the order in which they first occur and with the ID of that first one. Clustering the deduplicated points
+// gives the same result as clustering all of them, but is a lot faster for sets with many copies,
+// like the colors of an image (a weighted color histogram).
+func (ps *PointSet) Dedupe() PointSet {
+	unique := PointSet{Points: []Point{}}
+	indices := map[string]int{}
+
+	key := []byte{}
+	for _, point := range ps.Points {
+		key = key[:0]
+		for _, coordinate := range point.Coordinates {
+			key = binary.LittleEndian.AppendUint32(key, math.Float32bits(coordinate))
+		}
+
+		if index, ok := indices[string(key)]; ok {
+			unique.Points[index].Weight += point.Mass()
+			continue
+		}
+
+		indices[string(key)] = len(unique.Points)
+		unique.Points = append(unique.Points, Point{
+			Coordinates: append([]float32{}, point.Coordinates...),
+			ID:          point.ID,
+			Weight:      point.Mass(),
+		})
+	}
+
+	return unique
+}
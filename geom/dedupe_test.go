@@ -0,0 +1,35 @@
+package geom
+
+import "testing"
+
+// TestDedupe checks that copies are merged into one point that weighs as much as all of them
+func TestDedupe(t *testing.T) {
+	set := PointSet{Points: []Point{
+		{Coordinates: []float32{1, 2}, ID: 0},
+		{Coordinates: []float32{3, 4}, ID: 1},
+		{Coordinates: []float32{1, 2}, ID: 2, Weight: 5},
+		{Coordinates: []float32{1, 2}, ID: 3},
+	}}
+
+	unique := set.Dedupe()
+	want := []Point{
+		{Coordinates: []float32{1, 2}, ID: 0, Weight: 7},
+		{Coordinates: []float32{3, 4}, ID: 1, Weight: 1},
+	}
+	if len(unique.Points) != len(want) {
+		t.Fatalf("got %d points, want %d", len(unique.Points), len(want))
+	}
+	for i := range want {
+		if !unique.Points[i].Equals(want[i]) || unique.Points[i].Weight != want[i].Weight {
+			t.Errorf("got point %v, want %v", unique.Points[i], want[i])
+		}
+	}
+
+	// the mean doesn't change
+	mean, dedupedMean := set.Mean(), unique.Mean()
+	for i := range mean.Coordinates {
+		if mean.Coordinates[i] != dedupedMean.Coordinates[i] {
+			t.Errorf("the mean changed from %v to %v", mean.Coordinates, dedupedMean.Coordinates)
+		}
+	}
+}
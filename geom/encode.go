@@ -0,0 +1,33 @@
+package geom
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+// Encode writes the set to w with gob, to be read again with DecodePointSet
+func (ps *PointSet) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(ps)
+}
+
+// DecodePointSet reads a set that was written with Encode
+func DecodePointSet(r io.Reader) (PointSet, error) {
+	var ps PointSet
+	err := gob.NewDecoder(r).Decode(&ps)
+
+	return ps, err
+}
+
+// EncodeJSON writes the set to w as JSON, which is larger than Encode but easy to inspect (or plot)
+func (ps *PointSet) EncodeJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(ps)
+}
+
+// DecodePointSetJSON reads a set that was written with EncodeJSON
+func DecodePointSetJSON(r io.Reader) (PointSet, error) {
+	var ps PointSet
+	err := json.NewDecoder(r).Decode(&ps)
+
+	return ps, err
+}
@@ -0,0 +1,43 @@
+package geom
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// TestEncode checks that sets keep their points, IDs and weights through both encodings
+func TestEncode(t *testing.T) {
+	set := PointSet{Points: []Point{
+		{Coordinates: []float32{1, 2.5, 3}, ID: 7},
+		{Coordinates: []float32{0, -1, 255}, ID: 42, Weight: 12},
+	}}
+
+	encodings := map[string]struct {
+		encode func(io.Writer) error
+		decode func(io.Reader) (PointSet, error)
+	}{
+		"gob":  {set.Encode, DecodePointSet},
+		"json": {set.EncodeJSON, DecodePointSetJSON},
+	}
+
+	for name, encoding := range encodings {
+		var buf bytes.Buffer
+		if err := encoding.encode(&buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		decoded, err := encoding.decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(decoded.Points) != len(set.Points) {
+			t.Fatalf("%s: got %d points, want %d", name, len(decoded.Points), len(set.Points))
+		}
+		for i := range set.Points {
+			if !decoded.Points[i].Equals(set.Points[i]) || decoded.Points[i].Weight != set.Points[i].Weight {
+				t.Errorf("%s: got point %v, want %v", name, decoded.Points[i], set.Points[i])
+			}
+		}
+	}
+}
@@ -9,13 +9,16 @@ import (
 
 // Point is a collection of coordinates, with an identifier
 type Point struct {
-	Coordinates []float32
-	ID          int
+	Coordinates []float32 `json:"coordinates"`
+	ID          int       `json:"id"`
+	// Weight is the amount of points this point stands for, like the amount of pixels that have a color.
+	// It is optional: a weight of 0 counts as 1, see Point.Mass
+	Weight float32 `json:"weight,omitempty"`
 }
 
 // PointSet implements a slice of points
 type PointSet struct {
-	Points []Point
+	Points []Point `json:"points"`
 }
 
 // Bounds is a struct for lower - upper bounds
@@ -47,6 +50,15 @@ func (p *Point) Dimension() int {
 	return len(p.Coordinates)
 }
 
+// Mass returns the weight of the point, which is 1 if no Weight is set
+func (p *Point) Mass() float32 {
+	if p.Weight == 0 {
+		return 1
+	}
+
+	return p.Weight
+}
+
 // Equals determines whether or not two points are the same, including their IDs
 func (p *Point) Equals(point Point) bool {
 	if p.Dimension() != point.Dimension() { //check equality of Dimension
@@ -107,11 +119,15 @@ func (ps *PointSet) ChunkPoints(n int) [][]Point {
 // "MiniBatch" refers to the fact that not all points will be used in the returned slice.
 // This function shuffles the PointSet!
 func (ps *PointSet) ChunkPointsMiniBatch(n, batchSize int) [][]Point {
+	return ps.ChunkPointsMiniBatchRand(n, batchSize, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// ChunkPointsMiniBatchRand is ChunkPointsMiniBatch, shuffling with the given source of randomness
+func (ps *PointSet) ChunkPointsMiniBatchRand(n, batchSize int, rng *rand.Rand) [][]Point {
 	chunks := make([][]Point, n)
 
 	// randomly shuffle the points
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(ps.Points), func(i, j int) {
+	rng.Shuffle(len(ps.Points), func(i, j int) {
 		ps.Points[i], ps.Points[j] = ps.Points[j], ps.Points[i]
 	})
 
@@ -133,25 +149,29 @@ func (ps *PointSet) Remove(index int) {
 	ps.Points = ps.Points[:len(ps.Points)-1]
 }
 
-// Mean calculates the mean Point of all the Points in PointSet.
+// Mean calculates the mean Point of all the Points in PointSet, weighted by their Mass.
 func (ps *PointSet) Mean() Point {
 	meanCoords := []float32{}
 
 	if len(ps.Points) == 0 {
-		return Point{[]float32{}, 0}
+		return Point{Coordinates: []float32{}}
 	}
 	for dim := 0; dim < ps.Points[0].Dimension(); dim++ {
 		meanCoords = append(meanCoords, 0.0)
 	}
 
+	var total float32
+	for _, point := range ps.Points {
+		total += point.Mass()
+	}
+
 	for _, point := range ps.Points { // for each point
 		for i := 0; i < point.Dimension(); i++ { //for each dimension
-			meanCoords[i] += point.Coordinates[i] / float32(len(ps.Points))
+			meanCoords[i] += point.Coordinates[i] * point.Mass() / total
 		}
 	}
 	meanPoint := Point{
-		meanCoords,
-		0,
+		Coordinates: meanCoords,
 	}
 
 	return meanPoint
@@ -203,11 +223,10 @@ func (ps *PointSet) SortByAxis(axis int) {
 }
 
 // BranchByMedian splits a PointSet in a left and a right PointSet, and also returns the Point that splits the two.
-// This is done by sorting on one axis (see (*PointSet).SortByAxis())
+// This is done by selecting the median on one axis (see (*PointSet).SelectNth()), so the halves aren't sorted.
 func (ps *PointSet) BranchByMedian(axis int) (PointSet, PointSet, Point) {
-	ps.SortByAxis(axis)
-
 	medianIndex := len(ps.Points) / 2
+	ps.SelectNth(medianIndex, axis)
 
 	left := ps.Points[:medianIndex]
 	right := ps.Points[medianIndex+1:]
@@ -232,6 +251,18 @@ func EuclidianDistance(pnt1, pnt2 Point) float64 {
 	return dist
 }
 
+// SquaredEuclideanDistance returns the squared euclidean distance of two points, like EuclidianDistance,
+// with the signature of the distance metrics of kmeans
+func SquaredEuclideanDistance(pnt1, pnt2 *Point) float64 {
+	var dist float64
+	for index := range pnt1.Coordinates {
+		diff := float64(pnt1.Coordinates[index] - pnt2.Coordinates[index])
+		dist += diff * diff
+	}
+
+	return dist
+}
+
 // RedMeanDistance returns the red mean distance of two color points,
 // thus only works with the first 3 dimensions of the points
 func RedMeanDistance(pnt1, pnt2 *Point) float64 {
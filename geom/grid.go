@@ -0,0 +1,69 @@
+package geom
+
+import "math"
+
+// Grid is a spatial hash of items at 2D positions: the plane is divided in square cells, and each item is kept
+// in the cell of its position. Finding the items near a position only looks at the cells around it,
+// and building the grid takes linear time, which makes it cheaper than a k-d tree for items that move
+// (and need a new grid every step), as long as the cells are about as large as the radius of the queries.
+type Grid[T any] struct {
+	cellSize float64
+	cells    map[[2]int][]gridEntry[T]
+	size     int
+}
+
+// gridEntry is an item of a Grid with its position
+type gridEntry[T any] struct {
+	position Vec
+	item     T
+}
+
+// NewGrid returns an empty grid with cells of cellSize by cellSize
+func NewGrid[T any](cellSize float64) *Grid[T] {
+	return &Grid[T]{
+		cellSize: cellSize,
+		cells:    map[[2]int][]gridEntry[T]{},
+	}
+}
+
+// cell returns the cell of position
+func (grid *Grid[T]) cell(position Vec) [2]int {
+	return [2]int{int(math.Floor(position[0] / grid.cellSize)), int(math.Floor(position[1] / grid.cellSize))}
+}
+
+// Insert adds item at position to the grid
+func (grid *Grid[T]) Insert(position Vec, item T) {
+	cell := grid.cell(position)
+	grid.cells[cell] = append(grid.cells[cell], gridEntry[T]{position, item})
+	grid.size++
+}
+
+// Neighbors returns the items within radius of center (including any item at center itself)
+func (grid *Grid[T]) Neighbors(center Vec, radius float64) []T {
+	found := []T{}
+
+	lower := grid.cell(Vec{center[0] - radius, center[1] - radius})
+	upper := grid.cell(Vec{center[0] + radius, center[1] + radius})
+	for x := lower[0]; x <= upper[0]; x++ {
+		for y := lower[1]; y <= upper[1]; y++ {
+			for _, entry := range grid.cells[[2]int{x, y}] {
+				if entry.position.SquaredDistance(&center) <= radius*radius {
+					found = append(found, entry.item)
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+// Len returns the amount of items in the grid
+func (grid *Grid[T]) Len() int {
+	return grid.size
+}
+
+// Clear removes all items from the grid
+func (grid *Grid[T]) Clear() {
+	grid.cells = map[[2]int][]gridEntry[T]{}
+	grid.size = 0
+}
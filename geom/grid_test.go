@@ -0,0 +1,43 @@
+package geom
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestGrid checks that the grid finds the same neighbors as comparing against every position
+func TestGrid(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	positions := []Vec{}
+	grid := NewGrid[int](5)
+	for i := 0; i < 1000; i++ {
+		// negative positions too, whose cells round down
+		position := Vec{rng.Float64()*200 - 100, rng.Float64()*200 - 100}
+		positions = append(positions, position)
+		grid.Insert(position, i)
+	}
+	if grid.Len() != len(positions) {
+		t.Fatalf("got %d items, want %d", grid.Len(), len(positions))
+	}
+
+	for i := 0; i < 100; i++ {
+		center := Vec{rng.Float64()*200 - 100, rng.Float64()*200 - 100}
+		radius := rng.Float64() * 20
+
+		want := 0
+		for _, position := range positions {
+			if position.Distance(&center) <= radius {
+				want++
+			}
+		}
+		if got := grid.Neighbors(center, radius); len(got) != want {
+			t.Fatalf("got %d neighbors within %v of %v, want %d", len(got), radius, center, want)
+		}
+	}
+
+	grid.Clear()
+	if grid.Len() != 0 || len(grid.Neighbors(Vec{}, 200)) != 0 {
+		t.Errorf("the grid isn't empty after clearing")
+	}
+}
@@ -0,0 +1,82 @@
+package geom
+
+import "math"
+
+// The metrics below have the signature of SquaredEuclideanDistance and RedMeanDistance, so they can be used
+// for clustering (kmeans) and nearest neighbor searches alike.
+
+// ManhattanDistance returns the sum of the absolute differences of the coordinates of two points
+func ManhattanDistance(pnt1, pnt2 *Point) float64 {
+	var dist float64
+	for index := range pnt1.Coordinates {
+		dist += math.Abs(float64(pnt1.Coordinates[index] - pnt2.Coordinates[index]))
+	}
+
+	return dist
+}
+
+// ChebyshevDistance returns the largest absolute difference of the coordinates of two points
+func ChebyshevDistance(pnt1, pnt2 *Point) float64 {
+	var dist float64
+	for index := range pnt1.Coordinates {
+		dist = math.Max(dist, math.Abs(float64(pnt1.Coordinates[index]-pnt2.Coordinates[index])))
+	}
+
+	return dist
+}
+
+// CosineDistance returns 1 minus the cosine of the angle between two points (as vectors from the origin):
+// 0 for points in the same direction, 2 for opposite ones. Only their direction counts, not their length.
+// The zero vector has no direction, it is at distance 0 of itself and 1 of any other point.
+func CosineDistance(pnt1, pnt2 *Point) float64 {
+	var dot, length1, length2 float64
+	for index := range pnt1.Coordinates {
+		c1, c2 := float64(pnt1.Coordinates[index]), float64(pnt2.Coordinates[index])
+		dot += c1 * c2
+		length1 += c1 * c1
+		length2 += c2 * c2
+	}
+
+	if length1 == 0 || length2 == 0 {
+		if length1 == length2 {
+			return 0
+		}
+		return 1
+	}
+
+	return 1 - dot/math.Sqrt(length1*length2)
+}
+
+// WeightedEuclideanDistance returns a metric of the squared euclidean distance in which the squared difference
+// along each axis is multiplied by its weight, to make some coordinates count more than others
+func WeightedEuclideanDistance(weights []float64) func(pnt1, pnt2 *Point) float64 {
+	return func(pnt1, pnt2 *Point) float64 {
+		var dist float64
+		for index := range pnt1.Coordinates {
+			diff := float64(pnt1.Coordinates[index] - pnt2.Coordinates[index])
+			dist += weights[index] * diff * diff
+		}
+
+		return dist
+	}
+}
+
+// HueDistance returns a metric for points with a hue (in degrees) at coordinate hueAxis, like HSLA colors
+// (see colorpalette.ConvRGBAtoHSLA). It is the squared euclidean distance, in which the hue difference wraps around
+// (350° and 10° are 20° apart) and is taken as a fraction of a full turn, so that it is on the same scale
+// as a saturation and lightness from 0 to 1.
+func HueDistance(hueAxis int) func(pnt1, pnt2 *Point) float64 {
+	return func(pnt1, pnt2 *Point) float64 {
+		var dist float64
+		for index := range pnt1.Coordinates {
+			diff := float64(pnt1.Coordinates[index] - pnt2.Coordinates[index])
+			if index == hueAxis {
+				diff = math.Mod(math.Abs(diff), 360)
+				diff = math.Min(diff, 360-diff) / 360
+			}
+			dist += diff * diff
+		}
+
+		return dist
+	}
+}
@@ -0,0 +1,41 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+// TestMetrics checks the distance metrics on a few known cases
+func TestMetrics(t *testing.T) {
+	point := func(coordinates ...float32) *Point {
+		return &Point{Coordinates: coordinates}
+	}
+
+	tests := []struct {
+		name       string
+		metric     func(pnt1, pnt2 *Point) float64
+		pnt1, pnt2 *Point
+		want       float64
+	}{
+		{"manhattan", ManhattanDistance, point(1, 2, 3), point(4, 0, 3), 5},
+		{"chebyshev", ChebyshevDistance, point(1, 2, 3), point(4, 0, 3), 3},
+		{"cosine, same direction", CosineDistance, point(1, 2), point(2, 4), 0},
+		{"cosine, perpendicular", CosineDistance, point(1, 0), point(0, 3), 1},
+		{"cosine, opposite", CosineDistance, point(1, 1), point(-2, -2), 2},
+		{"cosine, zero vectors", CosineDistance, point(0, 0), point(0, 0), 0},
+		{"cosine, one zero vector", CosineDistance, point(0, 0), point(1, 0), 1},
+		{"weighted euclidean", WeightedEuclideanDistance([]float64{1, 4}), point(0, 0), point(3, 1), 13},
+		{"hue, wrapping around", HueDistance(0), point(350, 0.5, 0.5), point(10, 0.5, 0.5), 1.0 / 324},
+		{"hue, negative hues", HueDistance(0), point(-30, 0.5, 0.5), point(330, 0.5, 0.5), 0},
+		{"hue, saturation and lightness", HueDistance(0), point(180, 0, 0.5), point(0, 1, 0.5), 1.25},
+	}
+
+	for _, test := range tests {
+		if got := test.metric(test.pnt1, test.pnt2); math.Abs(got-test.want) > 1e-6 {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		if got, reversed := test.metric(test.pnt1, test.pnt2), test.metric(test.pnt2, test.pnt1); got != reversed {
+			t.Errorf("%s: not symmetric, %v and %v", test.name, got, reversed)
+		}
+	}
+}
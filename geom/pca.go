@@ -0,0 +1,86 @@
+package geom
+
+import "math"
+
+// principalIterations is the maximum amount of power iterations of PrincipalAxis
+const principalIterations = 100
+
+// PrincipalAxis returns the principal component of the points: the unit vector along which their (weighted)
+// variance is largest, together with that variance. Splitting a set of colors perpendicular to this axis
+// divides it better than splitting along a fixed coordinate axis. The axis is found with power iteration
+// on the Covariance, and its largest coordinate is positive, so the result doesn't depend on the order of the points.
+// It returns an empty axis for an empty set.
+func (ps *PointSet) PrincipalAxis() ([]float64, float64) {
+	covariance := ps.Covariance()
+	if len(covariance) == 0 {
+		return []float64{}, 0
+	}
+
+	// start from the coordinate axis with the largest variance, which has a part along the principal axis
+	start := 0
+	for i := range covariance {
+		if covariance[i][i] > covariance[start][start] {
+			start = i
+		}
+	}
+
+	axis := make([]float64, len(covariance))
+	axis[start] = 1
+	if covariance[start][start] == 0 {
+		// all points are the same, any axis will do
+		return axis, 0
+	}
+
+	next := make([]float64, len(axis))
+	variance := 0.0
+	for iteration := 0; iteration < principalIterations; iteration++ {
+		for i := range next {
+			next[i] = 0
+			for j := range axis {
+				next[i] += covariance[i][j] * axis[j]
+			}
+		}
+
+		variance = normalize(next)
+		change := 0.0
+		for i := range axis {
+			change = math.Max(change, math.Abs(next[i]-axis[i]))
+		}
+		axis, next = next, axis
+
+		if change < 1e-9 {
+			break
+		}
+	}
+
+	largest := 0
+	for i := range axis {
+		if math.Abs(axis[i]) > math.Abs(axis[largest]) {
+			largest = i
+		}
+	}
+	if axis[largest] < 0 {
+		for i := range axis {
+			axis[i] = -axis[i]
+		}
+	}
+
+	return axis, variance
+}
+
+// normalize scales vector to a length of 1, and returns its length before
+func normalize(vector []float64) float64 {
+	var length float64
+	for _, value := range vector {
+		length += value * value
+	}
+	length = math.Sqrt(length)
+
+	if length > 0 {
+		for i := range vector {
+			vector[i] /= length
+		}
+	}
+
+	return length
+}
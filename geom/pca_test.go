@@ -0,0 +1,41 @@
+package geom
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestPrincipalAxis checks that the principal axis of points spread along a diagonal line is that line
+func TestPrincipalAxis(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// along (1, 2, 2) / 3, with a little noise
+	set := PointSet{}
+	for i := 0; i < 1000; i++ {
+		position := rng.Float64()*200 - 100
+		set.Points = append(set.Points, Point{Coordinates: []float32{
+			float32(position/3 + rng.NormFloat64()),
+			float32(2*position/3 + rng.NormFloat64()),
+			float32(2*position/3 + rng.NormFloat64()),
+		}})
+	}
+
+	axis, variance := set.PrincipalAxis()
+	want := []float64{1.0 / 3, 2.0 / 3, 2.0 / 3}
+	for i := range want {
+		if math.Abs(axis[i]-want[i]) > 0.01 {
+			t.Fatalf("got axis %v, want %v", axis, want)
+		}
+	}
+
+	// the variance of a uniform distribution over 200 is 200²/12, plus the noise
+	if math.Abs(variance-200*200/12.0) > 200 {
+		t.Errorf("got variance %v along the axis, want about %v", variance, 200*200/12.0)
+	}
+
+	same := PointSet{Points: []Point{{Coordinates: []float32{1, 1}}, {Coordinates: []float32{1, 1}}}}
+	if axis, variance := same.PrincipalAxis(); len(axis) != 2 || variance != 0 {
+		t.Errorf("got axis %v with variance %v for equal points", axis, variance)
+	}
+}
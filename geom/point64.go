@@ -0,0 +1,79 @@
+package geom
+
+// Point64 is a Point with float64 coordinates, for computations that need the precision (like the physics
+// of particled), while Point keeps large sets of colors compact
+type Point64 struct {
+	Coordinates []float64
+	ID          int
+	// Weight is the amount of points this point stands for, see Point.Weight
+	Weight float64
+}
+
+// Dimension returns the dimension of the space this point lives in
+func (p *Point64) Dimension() int {
+	return len(p.Coordinates)
+}
+
+// Mass returns the weight of the point, which is 1 if no Weight is set
+func (p *Point64) Mass() float64 {
+	if p.Weight == 0 {
+		return 1
+	}
+
+	return p.Weight
+}
+
+// Point64 returns the point with float64 coordinates
+func (p *Point) Point64() Point64 {
+	coordinates := make([]float64, len(p.Coordinates))
+	for i, coordinate := range p.Coordinates {
+		coordinates[i] = float64(coordinate)
+	}
+
+	return Point64{Coordinates: coordinates, ID: p.ID, Weight: float64(p.Weight)}
+}
+
+// Point returns the point with float32 coordinates, losing the precision that doesn't fit
+func (p *Point64) Point() Point {
+	coordinates := make([]float32, len(p.Coordinates))
+	for i, coordinate := range p.Coordinates {
+		coordinates[i] = float32(coordinate)
+	}
+
+	return Point{Coordinates: coordinates, ID: p.ID, Weight: float32(p.Weight)}
+}
+
+// Vec returns the first two coordinates of the point as a vector
+func (p *Point64) Vec() Vec {
+	var v Vec
+	copy(v[:], p.Coordinates)
+
+	return v
+}
+
+// PointFromVec returns the point at the position of v, with the given ID
+func PointFromVec(v Vec, id int) Point64 {
+	return Point64{Coordinates: []float64{v[0], v[1]}, ID: id}
+}
+
+// Point64s returns the points of the set with float64 coordinates
+func (ps *PointSet) Point64s() []Point64 {
+	points := make([]Point64, len(ps.Points))
+	for i := range ps.Points {
+		points[i] = ps.Points[i].Point64()
+	}
+
+	return points
+}
+
+// SquaredEuclideanDistance64 returns the squared euclidean distance of two float64 points,
+// like SquaredEuclideanDistance
+func SquaredEuclideanDistance64(pnt1, pnt2 *Point64) float64 {
+	var dist float64
+	for index := range pnt1.Coordinates {
+		diff := pnt1.Coordinates[index] - pnt2.Coordinates[index]
+		dist += diff * diff
+	}
+
+	return dist
+}
@@ -0,0 +1,63 @@
+package geom
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestPoint64 checks that converting between float32 and float64 points keeps the coordinates, ID and weight
+func TestPoint64(t *testing.T) {
+	point := Point{Coordinates: []float32{1.5, -2, 255, 0.1}, ID: 7, Weight: 3}
+
+	wide := point.Point64()
+	if wide.Dimension() != 4 || wide.ID != 7 || wide.Weight != 3 || wide.Mass() != 3 {
+		t.Errorf("converted into %+v", wide)
+	}
+	if back := wide.Point(); !reflect.DeepEqual(back, point) {
+		t.Errorf("converted back into %+v, want %+v", back, point)
+	}
+
+	// the precision that doesn't fit in a float32 is lost
+	precise := Point64{Coordinates: []float64{1 + 1e-12}}
+	if narrow := precise.Point(); narrow.Coordinates[0] != 1 {
+		t.Errorf("%v is converted into %v, want 1", precise.Coordinates[0], narrow.Coordinates[0])
+	}
+
+	if mass := (&Point64{}).Mass(); mass != 1 {
+		t.Errorf("a point without weight has mass %v, want 1", mass)
+	}
+
+	set := PointSet{Points: []Point{point, {Coordinates: []float32{0, 0, 0, 0}, ID: 8}}}
+	if points := set.Point64s(); len(points) != 2 || !reflect.DeepEqual(points[0], wide) || points[1].ID != 8 {
+		t.Errorf("the set is converted into %+v", points)
+	}
+
+	if v := (&Point64{Coordinates: []float64{3, 4, 5}}).Vec(); v != (Vec{3, 4}) {
+		t.Errorf("the vector of the point is %v, want its first two coordinates", v)
+	}
+	if p := PointFromVec(Vec{3, 4}, 2); !reflect.DeepEqual(p, Point64{Coordinates: []float64{3, 4}, ID: 2}) {
+		t.Errorf("the point of the vector is %+v", p)
+	}
+}
+
+// TestSquaredEuclideanDistance64 checks that the float64 distance matches the float32 one, with more precision
+func TestSquaredEuclideanDistance64(t *testing.T) {
+	pnt1 := Point{Coordinates: []float32{10, 20, 30, 255}}
+	pnt2 := Point{Coordinates: []float32{13, 16, 30, 255}}
+
+	wide1, wide2 := pnt1.Point64(), pnt2.Point64()
+	if got := SquaredEuclideanDistance64(&wide1, &wide2); got != 25 {
+		t.Errorf("got %v, want 25", got)
+	}
+	if got, want := SquaredEuclideanDistance64(&wide1, &wide2), SquaredEuclideanDistance(&pnt1, &pnt2); got != want {
+		t.Errorf("got %v, the float32 distance is %v", got, want)
+	}
+
+	// differences too small for a float32 are still measured
+	close1 := Point64{Coordinates: []float64{1e8}}
+	close2 := Point64{Coordinates: []float64{1e8 + 1}}
+	if got := SquaredEuclideanDistance64(&close1, &close2); math.Abs(got-1) > 1e-9 {
+		t.Errorf("got %v, want 1", got)
+	}
+}
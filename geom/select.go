@@ -0,0 +1,50 @@
+package geom
+
+// SelectNth reorders the points so that the point at index n is the one that would be there if they were sorted
+// on axis (see SortByAxis), with no larger values before it and no smaller values after it.
+// This is the nth element selection of quickselect, which takes linear time on average instead of sorting.
+func (ps *PointSet) SelectNth(n, axis int) {
+	points := ps.Points
+	lo, hi := 0, len(points)-1
+
+	for lo < hi {
+		// the median of three as pivot, so that sorted points don't give the worst case
+		mid := lo + (hi-lo)/2
+		if points[mid].Coordinates[axis] < points[lo].Coordinates[axis] {
+			points[mid], points[lo] = points[lo], points[mid]
+		}
+		if points[hi].Coordinates[axis] < points[lo].Coordinates[axis] {
+			points[hi], points[lo] = points[lo], points[hi]
+		}
+		if points[hi].Coordinates[axis] < points[mid].Coordinates[axis] {
+			points[hi], points[mid] = points[mid], points[hi]
+		}
+		pivot := points[mid].Coordinates[axis]
+
+		// Hoare partition: afterwards, points[lo:j+1] are at most pivot and points[j+1:hi+1] at least pivot
+		i, j := lo, hi
+		for i <= j {
+			for points[i].Coordinates[axis] < pivot {
+				i++
+			}
+			for points[j].Coordinates[axis] > pivot {
+				j--
+			}
+			if i <= j {
+				points[i], points[j] = points[j], points[i]
+				i++
+				j--
+			}
+		}
+
+		switch {
+		case n <= j:
+			hi = j
+		case n >= i:
+			lo = i
+		default:
+			// between j and i, every point equals the pivot
+			return
+		}
+	}
+}
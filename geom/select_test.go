@@ -0,0 +1,34 @@
+package geom
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestSelectNth checks that SelectNth puts the same value at n as sorting does, with the right values on each side
+func TestSelectNth(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 200; i++ {
+		set := PointSet{}
+		for j := 1 + rng.Intn(100); j > 0; j-- {
+			// few distinct values, so that there are many equal ones
+			set.Points = append(set.Points, Point{Coordinates: []float32{float32(rng.Intn(20)), float32(rng.Intn(256))}})
+		}
+		axis, n := rng.Intn(2), rng.Intn(len(set.Points))
+
+		sorted := PointSet{Points: append([]Point{}, set.Points...)}
+		sorted.SortByAxis(axis)
+
+		set.SelectNth(n, axis)
+		value := set.Points[n].Coordinates[axis]
+		if value != sorted.Points[n].Coordinates[axis] {
+			t.Fatalf("got %v at %d, want %v", value, n, sorted.Points[n].Coordinates[axis])
+		}
+		for j, point := range set.Points {
+			if (j < n && point.Coordinates[axis] > value) || (j > n && point.Coordinates[axis] < value) {
+				t.Fatalf("%v at %d is on the wrong side of %v at %d", point.Coordinates[axis], j, value, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package geom
+
+// TotalMass returns the sum of the masses of the points, see Point.Mass
+func (ps *PointSet) TotalMass() float64 {
+	var total float64
+	for i := range ps.Points {
+		total += float64(ps.Points[i].Mass())
+	}
+
+	return total
+}
+
+// Variance returns the variance of the points along each dimension, weighted by their Mass like Mean.
+// It is the population variance: the weighted mean of the squared differences to the mean.
+func (ps *PointSet) Variance() []float64 {
+	covariance := ps.Covariance()
+
+	variance := make([]float64, len(covariance))
+	for i := range covariance {
+		variance[i] = covariance[i][i]
+	}
+
+	return variance
+}
+
+// Covariance returns the covariance matrix of the points, weighted by their Mass like Mean.
+// Element [i][j] is the weighted mean of the product of the differences to the mean along dimensions i and j,
+// so the diagonal holds the Variance.
+func (ps *PointSet) Covariance() [][]float64 {
+	if len(ps.Points) == 0 {
+		return [][]float64{}
+	}
+
+	dimension := ps.Points[0].Dimension()
+	total := ps.TotalMass()
+
+	// the mean in float64, for precision in the sums below
+	mean := make([]float64, dimension)
+	for i := range ps.Points {
+		mass := float64(ps.Points[i].Mass())
+		for dim := 0; dim < dimension; dim++ {
+			mean[dim] += float64(ps.Points[i].Coordinates[dim]) * mass / total
+		}
+	}
+
+	covariance := make([][]float64, dimension)
+	for i := range covariance {
+		covariance[i] = make([]float64, dimension)
+	}
+
+	diff := make([]float64, dimension)
+	for i := range ps.Points {
+		mass := float64(ps.Points[i].Mass())
+		for dim := 0; dim < dimension; dim++ {
+			diff[dim] = float64(ps.Points[i].Coordinates[dim]) - mean[dim]
+		}
+
+		for row := 0; row < dimension; row++ {
+			for col := row; col < dimension; col++ {
+				covariance[row][col] += mass * diff[row] * diff[col] / total
+			}
+		}
+	}
+
+	for row := 0; row < dimension; row++ {
+		for col := 0; col < row; col++ {
+			covariance[row][col] = covariance[col][row]
+		}
+	}
+
+	return covariance
+}
@@ -0,0 +1,44 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+// TestCovariance checks that a weighted point counts like that many copies of it
+func TestCovariance(t *testing.T) {
+	weighted := PointSet{Points: []Point{
+		{Coordinates: []float32{0, 0}, Weight: 3},
+		{Coordinates: []float32{4, 2}},
+	}}
+	copies := PointSet{Points: []Point{
+		{Coordinates: []float32{0, 0}},
+		{Coordinates: []float32{0, 0}},
+		{Coordinates: []float32{0, 0}},
+		{Coordinates: []float32{4, 2}},
+	}}
+
+	// the mean is (1, 0.5)
+	want := [][]float64{{3, 1.5}, {1.5, 0.75}}
+	for _, set := range []PointSet{weighted, copies} {
+		covariance := set.Covariance()
+		for i := range want {
+			for j := range want[i] {
+				if math.Abs(covariance[i][j]-want[i][j]) > 1e-9 {
+					t.Fatalf("got covariance %v, want %v", covariance, want)
+				}
+			}
+		}
+
+		if variance := set.Variance(); math.Abs(variance[0]-3) > 1e-9 || math.Abs(variance[1]-0.75) > 1e-9 {
+			t.Errorf("got variance %v, want [3 0.75]", variance)
+		}
+	}
+
+	if mass := weighted.TotalMass(); mass != 4 {
+		t.Errorf("got a total mass of %v, want 4", mass)
+	}
+	if empty := (&PointSet{}).Covariance(); len(empty) != 0 {
+		t.Errorf("got covariance %v for an empty set", empty)
+	}
+}
@@ -0,0 +1,52 @@
+package geom
+
+import "math"
+
+// Dot returns the dot product of two vectors
+func (v *Vec) Dot(other *Vec) float64 {
+	return v[0]*other[0] + v[1]*other[1]
+}
+
+// Length returns the length of the vector
+func (v *Vec) Length() float64 {
+	return math.Hypot(v[0], v[1])
+}
+
+// Normalize returns the vector scaled to a length of 1, or the zero vector if it has no length
+func (v *Vec) Normalize() Vec {
+	length := v.Length()
+	if length == 0 {
+		return Vec{}
+	}
+
+	return v.Scale(1 / length)
+}
+
+// Rotate returns the vector rotated counterclockwise by angle (in radians)
+func (v *Vec) Rotate(angle float64) Vec {
+	sin, cos := math.Sincos(angle)
+
+	return Vec{v[0]*cos - v[1]*sin, v[0]*sin + v[1]*cos}
+}
+
+// Lerp returns the vector t of the way from v to other: v for t = 0, other for t = 1
+func (v *Vec) Lerp(other *Vec, t float64) Vec {
+	return Vec{v[0] + t*(other[0]-v[0]), v[1] + t*(other[1]-v[1])}
+}
+
+// Clamp returns the vector with each of its coordinates limited to the range from lower to upper
+func (v *Vec) Clamp(lower, upper *Vec) Vec {
+	return Vec{math.Max(lower[0], math.Min(upper[0], v[0])), math.Max(lower[1], math.Min(upper[1], v[1]))}
+}
+
+// Distance returns the distance between two vectors (as positions)
+func (v *Vec) Distance(other *Vec) float64 {
+	return math.Hypot(v[0]-other[0], v[1]-other[1])
+}
+
+// SquaredDistance returns the squared distance between two vectors (as positions), which is cheaper than Distance
+func (v *Vec) SquaredDistance(other *Vec) float64 {
+	dx, dy := v[0]-other[0], v[1]-other[1]
+
+	return dx*dx + dy*dy
+}
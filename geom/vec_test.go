@@ -0,0 +1,44 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+// closeTo reports whether the vectors are equal, up to rounding errors
+func closeTo(v, other Vec) bool {
+	return math.Abs(v[0]-other[0]) < 1e-9 && math.Abs(v[1]-other[1]) < 1e-9
+}
+
+// TestVec checks the vector operations on a few known cases
+func TestVec(t *testing.T) {
+	v, w := Vec{3, 4}, Vec{-1, 2}
+
+	if dot := v.Dot(&w); dot != 5 {
+		t.Errorf("Dot: got %v, want 5", dot)
+	}
+	if length := v.Length(); length != 5 {
+		t.Errorf("Length: got %v, want 5", length)
+	}
+	if normalized := v.Normalize(); !closeTo(normalized, Vec{0.6, 0.8}) {
+		t.Errorf("Normalize: got %v, want [0.6 0.8]", normalized)
+	}
+	if zero := (&Vec{}).Normalize(); zero != (Vec{}) {
+		t.Errorf("Normalize of the zero vector: got %v", zero)
+	}
+	if rotated := v.Rotate(math.Pi / 2); !closeTo(rotated, Vec{-4, 3}) {
+		t.Errorf("Rotate: got %v, want [-4 3]", rotated)
+	}
+	if lerped := v.Lerp(&w, 0.25); !closeTo(lerped, Vec{2, 3.5}) {
+		t.Errorf("Lerp: got %v, want [2 3.5]", lerped)
+	}
+	if clamped := v.Clamp(&Vec{0, 0}, &Vec{2, 10}); clamped != (Vec{2, 4}) {
+		t.Errorf("Clamp: got %v, want [2 4]", clamped)
+	}
+	if dist := v.Distance(&w); !closeTo(Vec{dist}, Vec{math.Sqrt(20)}) {
+		t.Errorf("Distance: got %v, want %v", dist, math.Sqrt(20))
+	}
+	if dist := v.SquaredDistance(&w); dist != 20 {
+		t.Errorf("SquaredDistance: got %v, want 20", dist)
+	}
+}
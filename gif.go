@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mielpeeters/dither/gifeo"
+	"github.com/mielpeeters/dither/process"
+)
+
+// gifCommand creates a dithered gif video from a directory of frames, an animated gif or a video file
+func gifCommand(args []string) error {
+	flags := flag.NewFlagSet("gif", flag.ExitOnError)
+	framesDir := flags.String("frames", "", "directory with the frames of a video (frame_%05d.jpg), an animated gif or a video file (using ffmpeg)")
+	outputPath := flags.String("o", "output.gif", "path to the output gif, or mp4 or webm video (using ffmpeg)")
+	size := addSizeFlags(flags, "factor by which the frames are scaled down before dithering")
+	skipDuplicates := flags.Bool("skip-duplicates", false, "drop near-identical consecutive frames")
+	corruptFrames := flags.String("corrupt-frames", "skip", "what to do with frames that can't be read: skip, repeat (the previous frame) or abort")
+	video := addVideoFlags(flags)
+	options := addPaletteFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither gif -frames video.mp4 -o output.gif [-scale 4] [-k 8]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *framesDir == "" {
+		problems.add("provide the frames of the video (-frames)")
+	}
+	size.check(problems)
+	if options.creates() && options.k > 256 {
+		problems.add("the frames can hold at most 256 colors, not %d (-k)", options.k)
+	}
+	policies := map[string]gifeo.FramePolicy{"skip": gifeo.SkipFrame, "repeat": gifeo.RepeatFrame, "abort": gifeo.AbortVideo}
+	policy, ok := policies[*corruptFrames]
+	if !ok {
+		problems.add("the corrupt frame policy (-corrupt-frames) needs to be skip, repeat or abort, not %q", *corruptFrames)
+	}
+	given := givenFlags(flags)
+	checkVideo(problems, video, *outputPath, given)
+	options.check(problems, given)
+	if err := problems.err(); err != nil {
+		return err
+	}
+	if err := checkFFmpeg(*outputPath); err != nil {
+		return err
+	}
+	if err := options.setup(); err != nil {
+		return err
+	}
+
+	palette, index, err := options.named()
+	if err != nil {
+		return err
+	}
+
+	gf := gifeo.Giffer{
+		Scale:          size.scale,
+		Width:          size.width,
+		Height:         size.height,
+		K:              options.k,
+		Palette:        palette,
+		Index:          index,
+		SkipDuplicates: *skipDuplicates,
+		OnCorruptFrame: policy,
+		Video:          *video,
+	}
+	if options.metric != "" {
+		gf.NewIndex = func(palette color.Palette) process.ColorIndex {
+			return options.colorIndex(palette, nil)
+		}
+	}
+
+	source, err := openFrames(*framesDir)
+	if err != nil {
+		return err
+	}
+	if closer, ok := source.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	err = gf.CreateVideoFrom(source, *outputPath)
+	if errors.Is(err, gifeo.ErrScaledAway) {
+		// like the check of the size of the images in the other subcommands, see sizeOptions.checkImage
+		return &usageError{command: flags.Name(), problems: []string{
+			fmt.Sprintf("the scale (-scale) %d is larger than the frames, which leaves no pixels", size.scale),
+		}}
+	}
+
+	return err
+}
+
+// openFrames returns the source of the frames at path: a directory of frames, an animated gif,
+// or any other video file, which is decoded with ffmpeg
+func openFrames(path string) (gifeo.FrameSource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return gifeo.NewDirSource(path), nil
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".gif" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return gifeo.NewGIFSource(file)
+	}
+
+	return gifeo.NewFFmpegSource(path)
+}
@@ -0,0 +1,221 @@
+package gifeo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/process"
+	"github.com/mielpeeters/pacebar"
+)
+
+// SceneCutThreshold is the mean pixel difference (as a fraction) between consecutive frames,
+// above which Analyze considers the second frame to start a new scene
+var SceneCutThreshold = 0.15
+
+// StillThreshold is the mean pixel difference (as a fraction) between consecutive frames,
+// below which Analyze considers the frames to be duplicates
+var StillThreshold = 0.005
+
+// TargetWidth is the width that the recommended scale of Analyze aims for
+var TargetWidth = 320
+
+// thumbnailWidth is the width to which frames are shrunk during the analysis
+const thumbnailWidth = 64
+
+// Recommendation holds the settings that the analysis pass recommends for the render pass
+type Recommendation struct {
+	Scale          int  `json:"scale"`
+	K              int  `json:"k"`
+	Delay          int  `json:"delay"`
+	SkipDuplicates bool `json:"skip_duplicates"`
+}
+
+// Analysis is the result of the analysis pass over the frames of a video.
+// It can be stored as a JSON sidecar file, so that the render pass can be run (again) without repeating the analysis.
+type Analysis struct {
+	// Frames is the amount of frames in the video
+	Frames int `json:"frames"`
+	// Width and Height are the dimensions of the frames
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Palette is the global palette, built from frames of all scenes
+	Palette colorpalette.ColorPalette `json:"palette"`
+	// SceneCuts are the frame numbers that start a new scene (the first frame always does)
+	SceneCuts []int `json:"scene_cuts"`
+	// Motion is the mean pixel difference (as a fraction) of each frame with the one before it
+	Motion []float64 `json:"motion"`
+	// MeanMotion is the mean of Motion
+	MeanMotion float64 `json:"mean_motion"`
+	// Recommended holds the recommended render settings
+	Recommended Recommendation `json:"recommended"`
+}
+
+// Analyze performs the analysis pass over the frames in inputDir (see CreateVideo for their format):
+// it detects scene cuts, measures the motion between frames, and creates a global palette of k colors
+// from the first frame of every scene and a regular selection of other frames.
+func Analyze(inputDir string, k int) (*Analysis, error) {
+	paths := framePaths(inputDir)
+	if len(paths) == 0 {
+		return nil, errors.New("gifeo: no frames found")
+	}
+
+	analysis := Analysis{
+		Frames:    len(paths),
+		SceneCuts: []int{},
+		Motion:    make([]float64, len(paths)),
+	}
+
+	var pb pacebar.Pacebar
+	if Verbosity > 0 {
+		pb = pacebar.Pacebar{Work: len(paths), Name: "Analysis"}
+	}
+
+	// sample about 32 frames for the palette, next to the scene cuts
+	sampleEvery := int(math.Max(1, float64(len(paths))/32))
+	samples := []*image.RGBA{}
+
+	var previous *image.RGBA
+	stills := 0
+
+	for frame := 0; frame < len(paths); frame++ {
+		img, err := imgutil.OpenImage(paths[frame])
+		if err != nil {
+			return nil, err
+		}
+
+		if frame == 0 {
+			analysis.Width = img.Bounds().Dx()
+			analysis.Height = img.Bounds().Dy()
+		}
+
+		height := int(math.Max(1, float64(thumbnailWidth*analysis.Height/analysis.Width)))
+		thumbnail := process.Resize(img, thumbnailWidth, height)
+
+		if previous == nil {
+			analysis.SceneCuts = append(analysis.SceneCuts, frame)
+			samples = append(samples, thumbnail)
+		} else {
+			motion := meanDifference(previous, thumbnail)
+			analysis.Motion[frame] = motion
+
+			if motion > SceneCutThreshold {
+				analysis.SceneCuts = append(analysis.SceneCuts, frame)
+				samples = append(samples, thumbnail)
+			} else if frame%sampleEvery == 0 {
+				samples = append(samples, thumbnail)
+			}
+
+			if motion < StillThreshold {
+				stills++
+			}
+		}
+
+		analysis.MeanMotion += analysis.Motion[frame] / float64(len(paths))
+		previous = thumbnail
+
+		if Verbosity > 0 {
+			pb.Done(1)
+		}
+	}
+
+	// stack the sampled thumbnails into one image to create the global palette from
+	mosaic := image.NewRGBA(image.Rect(0, 0, thumbnailWidth, len(samples)*previous.Rect.Dy()))
+	for i, sample := range samples {
+		offset := image.Pt(0, i*previous.Rect.Dy())
+		draw.Draw(mosaic, sample.Rect.Add(offset), sample, image.Point{}, draw.Src)
+	}
+
+	palette, err := colorpalette.CreateContext(context.Background(), mosaic, k, nil)
+	if err != nil {
+		return nil, err
+	}
+	analysis.Palette = colorpalette.FromPalette(palette, "video")
+
+	analysis.Recommended = Recommendation{
+		Scale:          int(math.Max(1, math.Round(float64(analysis.Width)/float64(TargetWidth)))),
+		K:              k,
+		Delay:          4,
+		SkipDuplicates: stills*4 > len(paths),
+	}
+
+	return &analysis, nil
+}
+
+// meanDifference returns the mean absolute difference of the RGB channels of two equally sized images, as a fraction
+func meanDifference(left, right *image.RGBA) float64 {
+	var sum float64
+	var count int
+
+	for i := 0; i < len(left.Pix) && i < len(right.Pix); i++ {
+		if i%4 == 3 {
+			// skip the alpha channel
+			continue
+		}
+		sum += math.Abs(float64(left.Pix[i]) - float64(right.Pix[i]))
+		count++
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return sum / float64(count) / 255
+}
+
+// Save writes the analysis out to the specified path, as a JSON sidecar file
+func (analysis *Analysis) Save(path string) error {
+	output, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, output, 0644)
+}
+
+// LoadAnalysis reads an analysis from a JSON sidecar file, as written by (*Analysis).Save
+func LoadAnalysis(path string) (*Analysis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := Analysis{}
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, err
+	}
+
+	return &analysis, nil
+}
+
+// Render performs the render pass: it creates the gif video like CreateVideo, but takes the palette and
+// the settings that are left at their zero value from the analysis.
+func (gf *Giffer) Render(inputDir, outputFile string, analysis *Analysis) error {
+	if analysis == nil {
+		return errors.New("gifeo: render pass needs an analysis")
+	}
+	if len(framePaths(inputDir)) != analysis.Frames {
+		return errors.New("gifeo: the frames don't match the analysis, analyze them again")
+	}
+
+	if gf.Palette == nil {
+		gf.Palette = analysis.Palette.ToPalette()
+	}
+	if gf.Scale == 0 {
+		gf.Scale = analysis.Recommended.Scale
+	}
+	if gf.K == 0 {
+		gf.K = analysis.Recommended.K
+	}
+	if !gf.SkipDuplicates {
+		gf.SkipDuplicates = analysis.Recommended.SkipDuplicates
+	}
+
+	return gf.CreateVideo(inputDir, outputFile)
+}
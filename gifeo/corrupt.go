@@ -0,0 +1,84 @@
+package gifeo
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// FramePolicy defines what CreateVideo does with frames that can't be read (like truncated JPEG files)
+type FramePolicy int
+
+const (
+	// SkipFrame leaves corrupt frames out of the video
+	SkipFrame FramePolicy = iota
+	// RepeatFrame shows the previous frame again in the place of a corrupt frame
+	RepeatFrame
+	// AbortVideo stops creating the video, and returns a CorruptFramesError
+	AbortVideo
+)
+
+// CorruptFramesError lists the frames that couldn't be read
+type CorruptFramesError struct {
+	// Frames maps the numbers of the corrupt frames to the reason they couldn't be read
+	Frames map[int]error
+}
+
+func (err *CorruptFramesError) Error() string {
+	numbers := err.Numbers()
+
+	return fmt.Sprintf("gifeo: %d corrupt frames (%s), frame %d: %v",
+		len(numbers), joinNumbers(numbers), numbers[0], err.Frames[numbers[0]])
+}
+
+// Numbers returns the numbers of the corrupt frames, in order
+func (err *CorruptFramesError) Numbers() []int {
+	numbers := make([]int, 0, len(err.Frames))
+	for number := range err.Frames {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	return numbers
+}
+
+// applyFramePolicy fills in or removes the missing (nil) frames, according to policy.
+// With RepeatFrame, corrupt frames at the start of the video show the first frame that could be read.
+func applyFramePolicy(frames []*image.Paletted, delays []int, policy FramePolicy) ([]*image.Paletted, []int) {
+	keptFrames := []*image.Paletted{}
+	keptDelays := []int{}
+
+	var previous *image.Paletted
+	for _, frame := range frames {
+		if frame != nil {
+			previous = frame
+			break
+		}
+	}
+
+	for i, frame := range frames {
+		if frame == nil {
+			if policy != RepeatFrame {
+				continue
+			}
+			frame = previous
+		}
+
+		keptFrames = append(keptFrames, frame)
+		keptDelays = append(keptDelays, delays[i])
+		previous = frame
+	}
+
+	return keptFrames, keptDelays
+}
+
+// joinNumbers formats the frame numbers as a comma separated list
+func joinNumbers(numbers []int) string {
+	parts := make([]string, len(numbers))
+	for i, number := range numbers {
+		parts[i] = fmt.Sprint(number)
+	}
+
+	return strings.Join(parts, ", ")
+}
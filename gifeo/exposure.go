@@ -0,0 +1,135 @@
+package gifeo
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"sync"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/needle"
+	"github.com/mielpeeters/dither/process"
+)
+
+// LongExposure averages the frames in inputDir into one image, like a long exposure photograph would.
+// Only the frames with numbers in [start, start+length) are used, a length <= 0 means all frames from start on.
+// The frames need to be of format: frame_ddddd.jpg, see CreateVideo.
+func LongExposure(inputDir string, start, length int) (*image.RGBA, error) {
+	paths := framePaths(inputDir)
+
+	end := len(paths)
+	if length > 0 && start+length < end {
+		end = start + length
+	}
+	if start < 0 || start >= end {
+		return nil, errors.New("gifeo: no frames in the requested window")
+	}
+
+	keys := make([]int, 0, end-start)
+	for key := start; key < end; key++ {
+		keys = append(keys, key)
+	}
+
+	// the first frame defines the size of the exposure
+	first, err := imgutil.OpenImage(paths[start])
+	if err != nil {
+		return nil, err
+	}
+	bounds := first.Bounds()
+
+	// sums holds the sum of the R, G, B and A channels of each pixel
+	sums := make([]uint64, 4*bounds.Dx()*bounds.Dy())
+
+	frameNumbers := needle.ChunkSlice(keys, workers())
+
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	used := 0
+
+	for i := range frameNumbers {
+		wg.Add(1)
+		go func(myFrameNumbers []int) {
+			localSums := make([]uint64, len(sums))
+			localUsed := 0
+
+			for _, j := range myFrameNumbers {
+				img, err := imgutil.OpenImage(paths[j])
+				if err != nil || img.Bounds() != bounds {
+					// skip frames that can't be opened, or don't match the size
+					continue
+				}
+
+				addFrame(localSums, img)
+				localUsed++
+			}
+
+			mu.Lock()
+			for index := range sums {
+				sums[index] += localSums[index]
+			}
+			used += localUsed
+			mu.Unlock()
+
+			wg.Done()
+		}(frameNumbers[i])
+	}
+
+	wg.Wait()
+
+	if used == 0 {
+		return nil, errors.New("gifeo: none of the frames could be used")
+	}
+
+	exposure := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for index := range sums {
+		exposure.Pix[index] = uint8(sums[index] / uint64(used))
+	}
+
+	return exposure, nil
+}
+
+// addFrame adds the channel values of all pixels of img to sums
+func addFrame(sums []uint64, img image.Image) {
+	bounds := img.Bounds()
+	index := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			clr := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			sums[index] += uint64(clr.R)
+			sums[index+1] += uint64(clr.G)
+			sums[index+2] += uint64(clr.B)
+			sums[index+3] += uint64(clr.A)
+			index += 4
+		}
+	}
+}
+
+// CreateLongExposure averages the frames in inputDir (see LongExposure), and dithers the result
+// in the same way as the frames of CreateVideo are. The result is saved as a GIF image at outputFile.
+func (gf *Giffer) CreateLongExposure(inputDir, outputFile string, start, length int) error {
+	exposure, err := LongExposure(inputDir, start, length)
+	if err != nil {
+		return err
+	}
+
+	scaledImage := gf.downscale(exposure)
+
+	if gf.Palette == nil {
+		gf.Palette, err = colorpalette.CreateContext(context.Background(), scaledImage, gf.K, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	paletted, err := process.ApplyErrorDiffusionContext(context.Background(), scaledImage, gf.Palette, &process.JarvisJudiceNinke, nil)
+	if err != nil {
+		return err
+	}
+
+	imgutil.SaveGIF(paletted, outputFile)
+
+	return nil
+}
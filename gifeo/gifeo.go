@@ -1,13 +1,18 @@
 // Package gifeo can be used to create gif videos, from a premade set of images
+// or from the frames of a video.
 //
-// ffmpeg can be used to create that set:
-// `ffmpeg -i <input video> frames/frame_%05d.jpg`
+// Producers of frames (a directory of images, animated GIFs, any video through an ffmpeg pipe,
+// or frames in memory) are used through a FrameSource, see CreateVideoFrom and NewFFmpegSource.
 package gifeo
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/gif"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -16,7 +21,7 @@ import (
 
 	"github.com/mielpeeters/dither/colorpalette"
 	"github.com/mielpeeters/dither/imgutil"
-	"github.com/mielpeeters/dither/needle"
+	"github.com/mielpeeters/dither/kdtree"
 	"github.com/mielpeeters/dither/process"
 	"github.com/mielpeeters/pacebar"
 )
@@ -26,29 +31,278 @@ import (
 // 1 -> progress bar
 var Verbosity = 1
 
+// PaletteFrames is the amount of frames, spread evenly over the video, that the palette is created from
+var PaletteFrames = 16
+
+// Workers is the amount of frames that are dithered (or added up, see LongExposure) at the same time.
+// If it is 0 (the default), it is runtime.GOMAXPROCS(0).
+var Workers = 0
+
+// workers returns Workers, or runtime.GOMAXPROCS(0) if it isn't set
+func workers() int {
+	if Workers > 0 {
+		return Workers
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
+
+// ErrScaledAway is returned when Scale is larger than the frames, which leaves no pixels to dither
+var ErrScaledAway = errors.New("gifeo: the scale is larger than the frames, which leaves no pixels")
+
 // Giffer is a struct that contains setup information and is used
 // to create gif videos
 type Giffer struct {
 	// Scale is the scaledown factor used in creating
 	// the pixelated dither effect, on a per-frame basis
 	Scale int
+	// Width and Height resize the frames instead of Scale, when one of them is set.
+	// If only one is set, the other keeps the aspect ratio of the frames.
+	Width, Height int
 	// K is the amount of colors to be used in the palette
 	K int
 	// Palette can be set by the user, if left at default nil,
-	// gifeo will create the palette from the first frame
+	// gifeo will create the palette from a selection of PaletteFrames frames.
+	// A transparent color in the palette (see process.TransparentIndex) becomes the transparent index of the GIF.
 	Palette color.Palette
+	// Index is the k-d tree index of Palette, used to find the closest palette colors.
+	// If left at nil, it is built once and shared by all of the frames.
+	Index *kdtree.PaletteIndex
+	// NewIndex builds the index that finds the closest colors of the palette instead of Index, for another
+	// distance (like colorpalette.NewMetricIndex). It is used by all of the frames at the same time.
+	NewIndex func(color.Palette) process.ColorIndex
+	// SkipDuplicates drops frames that are near-identical to the frame before them
+	// (compared by perceptual hash), showing the previous frame longer instead
+	SkipDuplicates bool
+	// OnCorruptFrame defines what happens with frames that can't be read, see FramePolicy
+	OnCorruptFrame FramePolicy
+	// Video are the settings of the output, when it is an mp4 or webm video instead of a gif (see IsVideo)
+	Video VideoOptions
 
-	mu     sync.Mutex
-	pb     pacebar.Pacebar
-	frames []*image.Paletted
+	mu      sync.Mutex
+	pb      pacebar.Pacebar
+	frames  []*image.Paletted
+	closest process.ColorIndex
 }
 
 // CreateVideo is used to create the gif video
 // The frames in the inputDir directory need to be of format: frame_ddddd.jpg.
 // This can be achieved with ffmpeg by specifying as an output: frame_%05d.jpg
 // That does mean that the maximum GIF length is 6min40s
-func (gf *Giffer) CreateVideo(inputDir, outputFile string) {
+//
+// Frames that can't be read are handled according to OnCorruptFrame. A summary of them is printed at the end
+// (if Verbosity is set), and with AbortVideo a CorruptFramesError is returned instead of creating the video.
+func (gf *Giffer) CreateVideo(inputDir, outputFile string) error {
+	return gf.CreateVideoFrom(NewDirSource(inputDir), outputFile)
+}
+
+// frameJob is a frame read from a FrameSource, to be dithered by one of the workers
+type frameJob struct {
+	number int
+	img    image.Image
+	err    error
+}
+
+// CreateVideoFrom creates the gif video like CreateVideo, from the frames of any FrameSource.
+// An outputFile with the extension of a video (see IsVideo) is encoded by ffmpeg instead, see Encode.
+//
+// When Palette is nil, it is created from PaletteFrames frames: spread evenly over the video if source
+// is a FrameSeeker, otherwise the first PaletteFrames frames are used.
+func (gf *Giffer) CreateVideoFrom(source FrameSource, outputFile string) error {
+	if gf.K > 256 || len(gf.Palette) > 256 {
+		return errors.New("gifeo: gif frames can hold at most 256 colors")
+	}
+
+	seeker, seekable := source.(FrameSeeker)
+
+	// create the pacebar if verbosity is set, and the amount of frames is known
+	gf.pb = pacebar.Pacebar{}
+	if Verbosity > 0 && seekable {
+		gf.pb = pacebar.Pacebar{Work: seeker.Len()}
+	}
+
+	// the frames that are read before dithering starts, to create the palette from
+	pending := []frameJob{}
+
+	// create one palette from frames across the whole video, so it isn't biased toward the first one
+	if gf.Palette == nil {
+		if seekable {
+			palette, err := gf.createPalette(seeker)
+			if err != nil {
+				return err
+			}
+			gf.Palette = palette
+		} else {
+			imgs := []image.Image{}
+			for len(pending) < PaletteFrames {
+				img, err := source.Next()
+				if err == io.EOF {
+					break
+				}
+				pending = append(pending, frameJob{len(pending), img, err})
+				if err == nil && !gf.scalesAway(img.Bounds()) {
+					imgs = append(imgs, gf.downscale(img))
+				}
+			}
+
+			if len(imgs) > 0 {
+				palette, err := colorpalette.CreateFromImagesContext(context.Background(), imgs, gf.K)
+				if err != nil {
+					return err
+				}
+				gf.Palette = palette
+			}
+		}
+	}
+	if gf.Palette != nil {
+		gf.buildIndex()
+	}
+
+	// frames keeps the processed frames in a slice, it grows as frames are read
+	gf.frames = []*image.Paletted{}
+
+	// corrupt keeps the frames that couldn't be read
+	corrupt := CorruptFramesError{Frames: map[int]error{}}
+	scaledAway := false
+	var paletteErr error
+
+	// start multithreaded processing of frames, the frames are read one by one and handed to the workers
+	jobs := make(chan frameJob)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < workers(); i++ {
+		wg.Add(1)
+		go func() {
+			for job := range jobs {
+				paletted := gf.handleFrame(job.img)
+
+				gf.mu.Lock()
+				gf.frames[job.number] = paletted
+				gf.mu.Unlock()
+			}
+			wg.Done()
+		}()
+	}
+
+	for number := 0; ; number++ {
+		var job frameJob
+		if number < len(pending) {
+			job = pending[number]
+		} else {
+			img, err := source.Next()
+			if err == io.EOF {
+				break
+			}
+			job = frameJob{number, img, err}
+		}
+
+		gf.mu.Lock()
+		gf.frames = append(gf.frames, nil)
+		gf.mu.Unlock()
+
+		if job.err != nil {
+			corrupt.Frames[number] = job.err
+			if Verbosity > 0 && gf.pb.Work > 0 {
+				gf.pb.Done(1)
+			}
+			if gf.OnCorruptFrame == AbortVideo {
+				break
+			}
+			continue
+		}
+		if gf.scalesAway(job.img.Bounds()) {
+			scaledAway = true
+			break
+		}
+
+		// when none of the palette frames could be read, the palette is created from the first frame that can,
+		// before it is handed to a worker: the workers only read the palette and its index
+		if gf.Palette == nil {
+			gf.Palette, paletteErr = colorpalette.CreateContext(context.Background(), gf.downscale(job.img), gf.K, nil)
+			if paletteErr != nil {
+				break
+			}
+			gf.buildIndex()
+		}
+
+		jobs <- job
+	}
+
+	// wait for all child threads to finish
+	close(jobs)
+	wg.Wait()
+
+	if scaledAway {
+		return ErrScaledAway
+	}
+	if paletteErr != nil {
+		return paletteErr
+	}
+
+	if len(corrupt.Frames) > 0 {
+		if gf.OnCorruptFrame == AbortVideo {
+			return &corrupt
+		}
+
+		if Verbosity > 0 {
+			action := "skipped"
+			if gf.OnCorruptFrame == RepeatFrame {
+				action = "repeated the previous frame for"
+			}
+			fmt.Fprintf(os.Stderr, "\n%s %d corrupt frames: %s\n", action, len(corrupt.Frames), joinNumbers(corrupt.Numbers()))
+		}
+	}
 
+	delays := make([]int, len(gf.frames))
+	for i := range delays {
+		delays[i] = 4
+	}
+
+	frames, delays := applyFramePolicy(gf.frames, delays, gf.OnCorruptFrame)
+	if len(frames) == 0 {
+		if numbers := corrupt.Numbers(); len(numbers) > 0 {
+			// like a video that ffmpeg couldn't decode
+			return fmt.Errorf("gifeo: none of the frames could be read: %v", corrupt.Frames[numbers[0]])
+		}
+		return errors.New("gifeo: none of the frames could be read")
+	}
+
+	if gf.SkipDuplicates {
+		frames, delays = dropDuplicates(frames, delays)
+	}
+
+	return Encode(frames, delays, outputFile, gf.Video)
+}
+
+// dropDuplicates removes the frames that are near-identical to the last kept frame,
+// and adds their delay to that kept frame
+func dropDuplicates(frames []*image.Paletted, delays []int) ([]*image.Paletted, []int) {
+	keptFrames := []*image.Paletted{}
+	keptDelays := []int{}
+	var lastHash uint64
+
+	for i, frame := range frames {
+		if frame == nil {
+			continue
+		}
+
+		hash := imgutil.DHash(frame)
+		if len(keptFrames) > 0 && imgutil.HammingDistance(hash, lastHash) <= imgutil.DefaultHashDistance {
+			keptDelays[len(keptDelays)-1] += delays[i]
+			continue
+		}
+
+		keptFrames = append(keptFrames, frame)
+		keptDelays = append(keptDelays, delays[i])
+		lastHash = hash
+	}
+
+	return keptFrames, keptDelays
+}
+
+// framePaths maps the frame numbers to the paths of the frames in inputDir.
+// The frames need to be of format: frame_ddddd.jpg
+func framePaths(inputDir string) map[int]string {
 	pattern := "frame_[0-9]{5}\\.jpg"
 
 	re, err := regexp.Compile(pattern)
@@ -58,7 +312,6 @@ func (gf *Giffer) CreateVideo(inputDir, outputFile string) {
 
 	frame := 0
 
-	// paths maps frame numbers to their paths
 	paths := make(map[int]string, 0)
 
 	// add an entry that maps frame count to the path string per matching path
@@ -73,55 +326,22 @@ func (gf *Giffer) CreateVideo(inputDir, outputFile string) {
 		return nil
 	})
 
-	// create the pacebar if verbosity is set
-	if Verbosity > 0 {
-		gf.pb = pacebar.Pacebar{Work: len(paths)}
-	}
-
-	// frames keeps the processed frames in a slice
-	gf.frames = make([]*image.Paletted, len(paths))
-
-	// make a slice of the keys of the paths map (frame numbers)
-	// will be used to spread the multithreaded load
-	keys := make([]int, len(paths))
-	i := 0
-	for key := range paths {
-		keys[i] = key
-		i++
-	}
-
-	// divide the frameNumbers in chunks, each to be dealth with by one thread
-	frameNumbers := needle.ChunkSlice(keys, runtime.GOMAXPROCS(0))
-
-	// start multithreaded processing of frames
-	wg := sync.WaitGroup{}
-
-	for i := range frameNumbers {
-		wg.Add(1)
-		go func(myFrameNumbers *[]int) {
-			for _, j := range *myFrameNumbers {
-				// here, all of the frames that are my responsibility will be dealth with
-				gf.handleFrame((paths)[j], j)
-			}
-			wg.Done()
-		}(&frameNumbers[i])
-	}
-
-	// wait for all child threads to finish
-	wg.Wait()
-
-	EncodeGIF(gf.frames, outputFile, 4)
+	return paths
 }
 
 // EncodeGIF encodes a slice of image.Paletted images with a given palette and
 // saves it into the outputFile path.
 func EncodeGIF(frames []*image.Paletted, outputFile string, delay int) {
-	// everything from here down is encoding & saving the gif
 	delays := make([]int, len(frames))
 	for i := range delays {
 		delays[i] = delay
 	}
 
+	encodeGIF(frames, delays, outputFile)
+}
+
+// encodeGIF encodes the frames, each with its own delay, and saves it into the outputFile path
+func encodeGIF(frames []*image.Paletted, delays []int, outputFile string) {
 	// frame 0 used for config
 	frame0 := *frames[0]
 
@@ -139,6 +359,15 @@ func EncodeGIF(frames []*image.Paletted, outputFile string, delay int) {
 		},
 	}
 
+	// frames are drawn over the previous ones, which would show through the transparent pixels:
+	// clear every frame before the next one is drawn
+	if process.TransparentIndex(frame0.Palette) >= 0 {
+		g.Disposal = make([]byte, len(frames))
+		for i := range g.Disposal {
+			g.Disposal[i] = gif.DisposalBackground
+		}
+	}
+
 	file, err := os.Create(outputFile)
 	if err != nil {
 		panic(err)
@@ -150,29 +379,73 @@ func EncodeGIF(frames []*image.Paletted, outputFile string, delay int) {
 	}
 }
 
-func (gf *Giffer) handleFrame(path string, frameNo int) {
-	// open the input image
-	img, err := imgutil.OpenImage(path)
-	if err != nil {
-		return
+// createPalette creates the palette from PaletteFrames frames, spread evenly over the frames of seeker.
+// It returns nil if none of those frames could be opened.
+func (gf *Giffer) createPalette(seeker FrameSeeker) (color.Palette, error) {
+	amount := PaletteFrames
+	if amount > seeker.Len() {
+		amount = seeker.Len()
 	}
 
-	// scale the image down with a given scale
-	scaledImage := process.Downscale(img, gf.Scale)
-
-	if gf.Palette == nil {
-		gf.mu.Lock() // only one process gets through when gf.Palette is still nill
-		if gf.Palette == nil {
-			gf.Palette = colorpalette.Create(scaledImage, gf.K)
+	imgs := []image.Image{}
+	for i := 0; i < amount; i++ {
+		img, err := seeker.Frame(i * seeker.Len() / amount)
+		// frames that Scale scales away are reported when they are dithered
+		if err != nil || gf.scalesAway(img.Bounds()) {
+			continue
 		}
-		gf.mu.Unlock()
+
+		imgs = append(imgs, gf.downscale(img))
+	}
+
+	if len(imgs) == 0 {
+		return nil, nil
 	}
 
-	paletted := process.ApplyErrorDiffusion(scaledImage, gf.Palette, &process.JarvisJudiceNinke)
+	return colorpalette.CreateFromImagesContext(context.Background(), imgs, gf.K)
+}
+
+// scalesAway returns whether Scale scales a frame of the given bounds down to nothing
+func (gf *Giffer) scalesAway(bounds image.Rectangle) bool {
+	return gf.Width == 0 && gf.Height == 0 && (bounds.Dx()/gf.Scale == 0 || bounds.Dy()/gf.Scale == 0)
+}
+
+// downscale scales a frame down by Scale, or resizes it to Width and Height if one of them is set
+func (gf *Giffer) downscale(img image.Image) *image.RGBA {
+	if gf.Width == 0 && gf.Height == 0 {
+		return process.Downscale(img, gf.Scale)
+	}
+
+	width, height := process.FitSize(img.Bounds(), gf.Width, gf.Height)
+	return process.Resize(img, width, height)
+}
+
+// buildIndex builds the index of Palette, unless Index already matches it, and the index of NewIndex if it is set
+func (gf *Giffer) buildIndex() {
+	if gf.Index == nil || !gf.Index.Matches(gf.Palette) {
+		gf.Index = kdtree.NewPaletteIndex(gf.Palette)
+	}
+	if gf.NewIndex != nil {
+		gf.closest = gf.NewIndex(gf.Palette)
+	}
+}
+
+// handleFrame dithers one frame, with the palette and index that were set before the frames were handed out
+func (gf *Giffer) handleFrame(img image.Image) *image.Paletted {
+	// scale the image down with a given scale
+	scaledImage := gf.downscale(img)
+
+	var index process.ColorIndex = gf.Index
+	if gf.closest != nil {
+		index = gf.closest
+	}
 
-	gf.frames[frameNo] = paletted
+	// the only error is ErrPaletteTooLarge, which CreateVideoFrom rules out before handing out the frames
+	paletted, _ := process.ApplyErrorDiffusionIndex(context.Background(), scaledImage, gf.Palette, index, &process.JarvisJudiceNinke, nil)
 
-	if Verbosity > 0 {
+	if Verbosity > 0 && gf.pb.Work > 0 {
 		gf.pb.Done(1)
 	}
+
+	return paletted
 }
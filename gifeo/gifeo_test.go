@@ -0,0 +1,497 @@
+package gifeo
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mielpeeters/dither/imgutil"
+)
+
+// streamSource produces frames that can't be seeked, of which the first corrupt ones can't be read
+type streamSource struct {
+	frames  []image.Image
+	corrupt int
+	next    int
+}
+
+func (ss *streamSource) Next() (image.Image, error) {
+	if ss.next >= len(ss.frames) {
+		return nil, io.EOF
+	}
+
+	ss.next++
+	if ss.next <= ss.corrupt {
+		return nil, errors.New("corrupt frame")
+	}
+
+	return ss.frames[ss.next-1], nil
+}
+
+// testFrames returns amount gradients, each shifted a bit further
+func testFrames(amount int) []image.Image {
+	frames := make([]image.Image, amount)
+	for i := range frames {
+		img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+		for y := 0; y < 24; y++ {
+			for x := 0; x < 32; x++ {
+				img.SetRGBA(x, y, color.RGBA{uint8(8 * x), uint8(10*y + 4*i), uint8(20 * i), 255})
+			}
+		}
+		frames[i] = img
+	}
+
+	return frames
+}
+
+// TestCreateVideoPaletteFromFrame checks that the palette is created from the first frame that can be read when
+// none of the palette frames could, while the frames are dithered by many workers (run with -race to check them)
+func TestCreateVideoPaletteFromFrame(t *testing.T) {
+	verbosity, paletteFrames, workers := Verbosity, PaletteFrames, Workers
+	t.Cleanup(func() {
+		Verbosity, PaletteFrames, Workers = verbosity, paletteFrames, workers
+	})
+	Verbosity = 0
+	PaletteFrames = 2
+	Workers = 4
+
+	gf := Giffer{Scale: 1, K: 4, OnCorruptFrame: SkipFrame}
+	output := filepath.Join(t.TempDir(), "video.gif")
+
+	source := &streamSource{frames: testFrames(12), corrupt: PaletteFrames}
+	if err := gf.CreateVideoFrom(source, output); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	video, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(source.frames) - source.corrupt; len(video.Image) != want {
+		t.Errorf("the video has %d frames, want %d", len(video.Image), want)
+	}
+	if len(gf.Palette) != gf.K {
+		t.Errorf("the palette has %d colors, want %d", len(gf.Palette), gf.K)
+	}
+}
+
+// TestCreateVideoScaledAway checks that a scale larger than the frames gives ErrScaledAway
+func TestCreateVideoScaledAway(t *testing.T) {
+	verbosity := Verbosity
+	t.Cleanup(func() { Verbosity = verbosity })
+	Verbosity = 0
+
+	gf := Giffer{Scale: 64, K: 4}
+	err := gf.CreateVideoFrom(NewSliceSource(testFrames(3)), filepath.Join(t.TempDir(), "video.gif"))
+	if !errors.Is(err, ErrScaledAway) {
+		t.Errorf("error %v, want ErrScaledAway", err)
+	}
+}
+
+// brokenSource produces frames that can't be seeked, of which the one with number broken can't be read
+type brokenSource struct {
+	frames []image.Image
+	broken int
+	next   int
+}
+
+func (bs *brokenSource) Next() (image.Image, error) {
+	if bs.next >= len(bs.frames) {
+		return nil, io.EOF
+	}
+
+	bs.next++
+	if bs.next-1 == bs.broken {
+		return nil, errors.New("truncated frame")
+	}
+
+	return bs.frames[bs.next-1], nil
+}
+
+// TestCorruptFramePolicies checks the frames and delays of a video with a corrupt frame, for every FramePolicy
+func TestCorruptFramePolicies(t *testing.T) {
+	verbosity := Verbosity
+	t.Cleanup(func() { Verbosity = verbosity })
+	Verbosity = 0
+
+	tests := []struct {
+		name   string
+		policy FramePolicy
+		// frames is the amount of frames of the video, and repeated the frame that is shown twice (or -1)
+		frames   int
+		repeated int
+	}{
+		{name: "skip", policy: SkipFrame, frames: 5, repeated: -1},
+		{name: "repeat", policy: RepeatFrame, frames: 6, repeated: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gf := Giffer{Scale: 1, K: 4, OnCorruptFrame: test.policy}
+			output := filepath.Join(t.TempDir(), "video.gif")
+			if err := gf.CreateVideoFrom(&brokenSource{frames: testFrames(6), broken: 2}, output); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.Open(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			video, err := gif.DecodeAll(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(video.Image) != test.frames || len(video.Delay) != test.frames {
+				t.Fatalf("the video has %d frames and %d delays, want %d", len(video.Image), len(video.Delay), test.frames)
+			}
+			for i, delay := range video.Delay {
+				if delay != 4 {
+					t.Errorf("frame %d has a delay of %d, want 4", i, delay)
+				}
+			}
+			for i := 1; i < len(video.Image); i++ {
+				same := reflect.DeepEqual(video.Image[i].Pix, video.Image[i-1].Pix)
+				if same != (i == test.repeated+1) {
+					t.Errorf("frame %d is the same as the one before it: %v", i, same)
+				}
+			}
+		})
+	}
+
+	t.Run("abort", func(t *testing.T) {
+		gf := Giffer{Scale: 1, K: 4, OnCorruptFrame: AbortVideo}
+		output := filepath.Join(t.TempDir(), "video.gif")
+		err := gf.CreateVideoFrom(&brokenSource{frames: testFrames(6), broken: 2}, output)
+
+		var corrupt *CorruptFramesError
+		if !errors.As(err, &corrupt) {
+			t.Fatalf("error %v, want a CorruptFramesError", err)
+		}
+		if numbers := corrupt.Numbers(); !reflect.DeepEqual(numbers, []int{2}) {
+			t.Errorf("the corrupt frames are %v, want [2]", numbers)
+		}
+		if _, err := os.Stat(output); !os.IsNotExist(err) {
+			t.Errorf("the video was written after aborting: %v", err)
+		}
+	})
+}
+
+// TestLongExposure checks that the long exposure is the average of the frames in the window,
+// and that a window without frames gives an error
+func TestLongExposure(t *testing.T) {
+	dir := t.TempDir()
+	for i, gray := range []uint8{40, 80, 120, 200} {
+		img := image.NewRGBA(image.Rect(0, 0, 16, 12))
+		for index := range img.Pix {
+			img.Pix[index] = gray
+		}
+		imgutil.SaveJPEG(img, filepath.Join(dir, fmt.Sprintf("frame_%05d.jpg", i)), 100)
+	}
+
+	tests := []struct {
+		name          string
+		start, length int
+		want          int
+	}{
+		{name: "all", start: 0, length: 0, want: 110},
+		{name: "window", start: 1, length: 2, want: 100},
+		{name: "past the end", start: 2, length: 10, want: 160},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exposure, err := LongExposure(dir, test.start, test.length)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := exposure.Bounds().Size(); size != image.Pt(16, 12) {
+				t.Fatalf("the exposure is %v, want 16x12", size)
+			}
+
+			// the frames are JPEG images, which may be off by a bit
+			if got := int(exposure.Pix[0]); got < test.want-2 || got > test.want+2 {
+				t.Errorf("the exposure has a red value of %d, want %d", got, test.want)
+			}
+		})
+	}
+
+	if _, err := LongExposure(dir, 4, 0); err == nil {
+		t.Error("a window past the last frame gives no error")
+	}
+}
+
+// TestMorphPalette checks that the palette morphs from the palette of the frame into the target, while the pixels
+// stay the same, and that palettes of different lengths or too few steps give an error
+func TestMorphPalette(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 2), color.Palette{
+		color.RGBA{0, 0, 0, 255},
+		color.RGBA{200, 100, 0, 255},
+	})
+	frame.Pix = []uint8{0, 1, 0, 1, 1, 0, 1, 0}
+	to := color.Palette{color.RGBA{100, 0, 200, 255}, color.RGBA{0, 100, 200, 255}}
+
+	frames, err := MorphPalette(frame, to, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("the morph has %d frames, want 3", len(frames))
+	}
+
+	want := []color.Palette{
+		frame.Palette,
+		{color.RGBA{50, 0, 100, 255}, color.RGBA{100, 100, 100, 255}},
+		to,
+	}
+	for i := range frames {
+		if !reflect.DeepEqual(frames[i].Pix, frame.Pix) {
+			t.Errorf("frame %d has pixels %v, want %v", i, frames[i].Pix, frame.Pix)
+		}
+		if !reflect.DeepEqual(frames[i].Palette, want[i]) {
+			t.Errorf("frame %d has palette %v, want %v", i, frames[i].Palette, want[i])
+		}
+	}
+
+	if _, err := MorphPalette(frame, to[:1], 3); err == nil {
+		t.Error("palettes of different lengths give no error")
+	}
+	if _, err := MorphPalette(frame, to, 1); err == nil {
+		t.Error("a single step gives no error")
+	}
+}
+
+// TestAnalyze checks that the analysis finds the scene cut and the still frames of a video, that its palette holds
+// the colors of both scenes, and that it survives a round trip through its sidecar file
+func TestAnalyze(t *testing.T) {
+	verbosity := Verbosity
+	t.Cleanup(func() { Verbosity = verbosity })
+	Verbosity = 0
+
+	// three still blue frames, followed by three still red frames
+	dir := t.TempDir()
+	for i := 0; i < 6; i++ {
+		clr := color.RGBA{20, 40, 200, 255}
+		if i >= 3 {
+			clr = color.RGBA{220, 30, 30, 255}
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+		for y := 0; y < 48; y++ {
+			for x := 0; x < 64; x++ {
+				img.SetRGBA(x, y, clr)
+			}
+		}
+		imgutil.SaveJPEG(img, filepath.Join(dir, fmt.Sprintf("frame_%05d.jpg", i)), 100)
+	}
+
+	analysis, err := Analyze(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if analysis.Frames != 6 || analysis.Width != 64 || analysis.Height != 48 {
+		t.Errorf("the analysis has %d frames of %dx%d, want 6 of 64x48", analysis.Frames, analysis.Width, analysis.Height)
+	}
+	if !reflect.DeepEqual(analysis.SceneCuts, []int{0, 3}) {
+		t.Errorf("the scene cuts are %v, want [0 3]", analysis.SceneCuts)
+	}
+	if want := (Recommendation{Scale: 1, K: 2, Delay: 4, SkipDuplicates: true}); analysis.Recommended != want {
+		t.Errorf("the recommendation is %+v, want %+v", analysis.Recommended, want)
+	}
+
+	// the palette has a blue and a red color
+	var blue, red bool
+	for _, clr := range analysis.Palette.ToPalette() {
+		r, _, b, _ := clr.RGBA()
+		blue = blue || b>>8 > 150 && r>>8 < 60
+		red = red || r>>8 > 150 && b>>8 < 60
+	}
+	if !blue || !red {
+		t.Errorf("the palette %v misses the color of a scene", analysis.Palette.ToPalette())
+	}
+
+	sidecar := filepath.Join(t.TempDir(), "analysis.json")
+	if err := analysis.Save(sidecar); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadAnalysis(sidecar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, analysis) {
+		t.Errorf("the loaded analysis is %+v, want %+v", loaded, analysis)
+	}
+}
+
+// needFFmpeg skips the test when ffmpeg isn't in the PATH
+func needFFmpeg(t *testing.T) {
+	t.Helper()
+	if err := CheckFFmpeg(); err != nil {
+		t.Skip(err)
+	}
+}
+
+// palettedFrames returns amount frames of 32x24 pixels, frame i having color i of a gray palette
+func palettedFrames(amount int) []*image.Paletted {
+	palette := make(color.Palette, amount)
+	for i := range palette {
+		gray := uint8(255 * i / amount)
+		palette[i] = color.RGBA{gray, gray, gray, 255}
+	}
+
+	frames := make([]*image.Paletted, amount)
+	for i := range frames {
+		frames[i] = image.NewPaletted(image.Rect(0, 0, 32, 24), palette)
+		for index := range frames[i].Pix {
+			frames[i].Pix[index] = uint8(i)
+		}
+	}
+
+	return frames
+}
+
+// TestEncode checks that Encode writes a gif with the delays of the frames, or a video for the video extensions,
+// which needs ffmpeg
+func TestEncode(t *testing.T) {
+	for name, want := range map[string]bool{"video.mp4": true, "video.WEBM": true, "video.gif": false, "video": false} {
+		if IsVideo(name) != want {
+			t.Errorf("IsVideo(%q) is %v, want %v", name, !want, want)
+		}
+	}
+
+	frames, delays := palettedFrames(3), []int{4, 4, 8}
+	output := filepath.Join(t.TempDir(), "video.gif")
+	if err := Encode(frames, delays, output, VideoOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	video, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(video.Delay, delays) {
+		t.Errorf("the gif has delays %v, want %v", video.Delay, delays)
+	}
+
+	t.Run("without ffmpeg", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+
+		err := Encode(frames, delays, filepath.Join(t.TempDir(), "video.mp4"), VideoOptions{})
+		if !errors.Is(err, ErrNoFFmpeg) {
+			t.Errorf("error %v, want ErrNoFFmpeg", err)
+		}
+	})
+
+	t.Run("mp4", func(t *testing.T) {
+		needFFmpeg(t)
+
+		output := filepath.Join(t.TempDir(), "video.mp4")
+		if err := Encode(frames, delays, output, VideoOptions{CRF: 30}); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(output); err != nil || info.Size() == 0 {
+			t.Errorf("the video is missing or empty: %v", err)
+		}
+	})
+
+	t.Run("unknown codec", func(t *testing.T) {
+		needFFmpeg(t)
+
+		err := Encode(frames, delays, filepath.Join(t.TempDir(), "video.mp4"), VideoOptions{Codec: "no-such-codec"})
+		if err == nil || !strings.Contains(err.Error(), "ffmpeg failed") {
+			t.Errorf("error %v, want ffmpeg to fail", err)
+		}
+	})
+}
+
+// TestFFmpegSource checks that the frames of a video are read back through ffmpeg, and that the reason why
+// ffmpeg couldn't decode a video is reported
+func TestFFmpegSource(t *testing.T) {
+	t.Run("without ffmpeg", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+
+		if _, err := NewFFmpegSource("video.mp4"); !errors.Is(err, ErrNoFFmpeg) {
+			t.Errorf("error %v, want ErrNoFFmpeg", err)
+		}
+	})
+
+	t.Run("frames", func(t *testing.T) {
+		needFFmpeg(t)
+
+		// 25 frames per second, so the last frame is shown twice
+		input := filepath.Join(t.TempDir(), "video.mp4")
+		if err := EncodeVideo(palettedFrames(3), []int{4, 4, 8}, input, VideoOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		source, err := NewFFmpegSource(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer source.Close()
+
+		frames := 0
+		for {
+			img, err := source.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := img.Bounds().Size(); size != image.Pt(32, 24) {
+				t.Errorf("frame %d is %v, want 32x24", frames, size)
+			}
+			frames++
+		}
+		if frames != 4 {
+			t.Errorf("the video has %d frames, want 4", frames)
+		}
+	})
+
+	t.Run("not a video", func(t *testing.T) {
+		needFFmpeg(t)
+
+		verbosity := Verbosity
+		t.Cleanup(func() { Verbosity = verbosity })
+		Verbosity = 0
+
+		input := filepath.Join(t.TempDir(), "video.mp4")
+		if err := os.WriteFile(input, []byte("not a video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		source, err := NewFFmpegSource(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer source.Close()
+
+		gf := Giffer{Scale: 1, K: 4}
+		err = gf.CreateVideoFrom(source, filepath.Join(t.TempDir(), "video.gif"))
+		if err == nil || !strings.Contains(err.Error(), "ffmpeg failed") {
+			t.Errorf("error %v, want the reason ffmpeg failed", err)
+		}
+	})
+}
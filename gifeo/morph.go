@@ -0,0 +1,94 @@
+package gifeo
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/process"
+)
+
+// MorphPalette creates steps frames that all show the pixels of frame, while the palette gradually
+// changes from the palette of frame into to. The frames share their pixel data, only the color tables
+// differ, so nothing needs to be dithered again. Palette entries are interpolated index by index,
+// so from and to need to be of equal length.
+func MorphPalette(frame *image.Paletted, to color.Palette, steps int) ([]*image.Paletted, error) {
+	from := frame.Palette
+	if len(from) != len(to) {
+		return nil, errors.New("gifeo: palettes of different length can not be morphed")
+	}
+	if steps < 2 {
+		return nil, errors.New("gifeo: a morph needs at least two frames")
+	}
+
+	frames := make([]*image.Paletted, steps)
+	for i := range frames {
+		t := float64(i) / float64(steps-1)
+
+		palette := make(color.Palette, len(from))
+		for j := range palette {
+			palette[j] = interpolateColor(from[j], to[j], t)
+		}
+
+		frames[i] = &image.Paletted{
+			Pix:     frame.Pix,
+			Stride:  frame.Stride,
+			Rect:    frame.Rect,
+			Palette: palette,
+		}
+	}
+
+	return frames, nil
+}
+
+// interpolateColor linearly interpolates between two colors, t = 0 returning from and t = 1 returning to
+func interpolateColor(from, to color.Color, t float64) color.Color {
+	left := colorpalette.ToRGBA(from)
+	right := colorpalette.ToRGBA(to)
+
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+	}
+
+	return color.RGBA{
+		R: lerp(left.R, right.R),
+		G: lerp(left.G, right.G),
+		B: lerp(left.B, right.B),
+		A: lerp(left.A, right.A),
+	}
+}
+
+// CreatePaletteMorph dithers the image at inputFile once, with gf.Palette (which is created if nil),
+// and saves a GIF animation at outputFile in which the palette morphs into to over the given amount of frames.
+func (gf *Giffer) CreatePaletteMorph(inputFile, outputFile string, to color.Palette, frames int) error {
+	img, err := imgutil.OpenImage(inputFile)
+	if err != nil {
+		return err
+	}
+
+	scaledImage := gf.downscale(img)
+
+	if gf.Palette == nil {
+		gf.Palette, err = colorpalette.CreateContext(context.Background(), scaledImage, gf.K, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	paletted, err := process.ApplyErrorDiffusionContext(context.Background(), scaledImage, gf.Palette, &process.JarvisJudiceNinke, nil)
+	if err != nil {
+		return err
+	}
+
+	morph, err := MorphPalette(paletted, to, frames)
+	if err != nil {
+		return err
+	}
+
+	EncodeGIF(morph, outputFile, 4)
+
+	return nil
+}
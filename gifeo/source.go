@@ -0,0 +1,222 @@
+package gifeo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/mielpeeters/dither/imgutil"
+)
+
+// FrameSource produces the frames of a video, in order.
+type FrameSource interface {
+	// Next returns the next frame, or io.EOF when there are no frames left.
+	// Any other error means that this frame couldn't be read; the source moves on to the next frame
+	// (a source that can't continue after an error returns io.EOF from then on).
+	Next() (image.Image, error)
+}
+
+// FrameSeeker is a FrameSource that knows its amount of frames, and can return any of them.
+// Giffer uses this to pick the frames for the palette from across the whole video.
+type FrameSeeker interface {
+	FrameSource
+	// Len returns the amount of frames
+	Len() int
+	// Frame returns frame i, without changing the position of Next
+	Frame(i int) (image.Image, error)
+}
+
+// DirSource reads the frames from image files in a directory, of format frame_ddddd.jpg.
+// This can be achieved with ffmpeg by specifying as an output: frame_%05d.jpg,
+// though FFmpegSource reads the frames of a video without storing them.
+type DirSource struct {
+	paths []string
+	next  int
+}
+
+// NewDirSource returns the source of the frames in inputDir, in the order of their names
+func NewDirSource(inputDir string) *DirSource {
+	// framePaths numbers the frames in the order of their names
+	numbered := framePaths(inputDir)
+
+	paths := make([]string, len(numbered))
+	for number, path := range numbered {
+		paths[number] = path
+	}
+
+	return &DirSource{paths: paths}
+}
+
+// Next opens the next frame
+func (ds *DirSource) Next() (image.Image, error) {
+	if ds.next >= len(ds.paths) {
+		return nil, io.EOF
+	}
+
+	ds.next++
+
+	return ds.Frame(ds.next - 1)
+}
+
+// Len returns the amount of frames in the directory
+func (ds *DirSource) Len() int {
+	return len(ds.paths)
+}
+
+// Frame opens frame i
+func (ds *DirSource) Frame(i int) (image.Image, error) {
+	return imgutil.OpenImage(ds.paths[i])
+}
+
+// SliceSource produces frames that are already in memory, like the generations of a cellular automaton
+// or the steps of a particle simulation.
+type SliceSource struct {
+	Frames []image.Image
+	next   int
+}
+
+// NewSliceSource returns the source of the given frames
+func NewSliceSource(frames []image.Image) *SliceSource {
+	return &SliceSource{Frames: frames}
+}
+
+// Next returns the next frame
+func (ss *SliceSource) Next() (image.Image, error) {
+	if ss.next >= len(ss.Frames) {
+		return nil, io.EOF
+	}
+
+	ss.next++
+
+	return ss.Frames[ss.next-1], nil
+}
+
+// Len returns the amount of frames
+func (ss *SliceSource) Len() int {
+	return len(ss.Frames)
+}
+
+// Frame returns frame i
+func (ss *SliceSource) Frame(i int) (image.Image, error) {
+	return ss.Frames[i], nil
+}
+
+// NewGIFSource decodes the animated GIF from r, and returns its frames as a source.
+// Frames of a GIF can cover only part of the image, so each frame is drawn onto the previous ones
+// (taking the disposal methods into account) to get complete frames.
+func NewGIFSource(r io.Reader) (*SliceSource, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	frames := []image.Image{}
+
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		if len(g.Disposal) > i && g.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		complete := image.NewRGBA(bounds)
+		copy(complete.Pix, canvas.Pix)
+		frames = append(frames, complete)
+
+		if len(g.Disposal) > i {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+			case gif.DisposalPrevious:
+				canvas = previous
+			}
+		}
+	}
+
+	return NewSliceSource(frames), nil
+}
+
+// FFmpegSource reads the frames of any video that ffmpeg can decode, through a pipe.
+// ffmpeg needs to be installed and in the PATH.
+type FFmpegSource struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	reader *bufio.Reader
+	done   bool
+}
+
+// NewFFmpegSource starts ffmpeg to decode the video at videoPath.
+// Close needs to be called when not all of the frames are read.
+func NewFFmpegSource(videoPath string) (*FFmpegSource, error) {
+	if err := CheckFFmpeg(); err != nil {
+		return nil, err
+	}
+
+	fs := &FFmpegSource{}
+	fs.cmd = exec.Command("ffmpeg", "-loglevel", "error", "-i", videoPath, "-f", "image2pipe", "-vcodec", "png", "-")
+	fs.cmd.Stderr = &fs.stderr
+
+	stdout, err := fs.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	fs.stdout = stdout
+	fs.reader = bufio.NewReader(stdout)
+
+	return fs, nil
+}
+
+// Next decodes the next frame from the pipe
+func (fs *FFmpegSource) Next() (image.Image, error) {
+	if fs.done {
+		return nil, io.EOF
+	}
+
+	// the end of the stream lies between two frames
+	if _, err := fs.reader.Peek(1); err == io.EOF {
+		fs.done = true
+		if err := fs.cmd.Wait(); err != nil {
+			return nil, fmt.Errorf("gifeo: ffmpeg failed: %v: %s", err, strings.TrimSpace(fs.stderr.String()))
+		}
+		return nil, io.EOF
+	}
+
+	img, err := png.Decode(fs.reader)
+	if err != nil {
+		// the stream can't be resynchronized after a broken frame
+		fs.Close()
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// Close stops ffmpeg
+func (fs *FFmpegSource) Close() error {
+	if fs.done {
+		return nil
+	}
+
+	fs.done = true
+	fs.stdout.Close()
+	fs.cmd.Process.Kill()
+
+	return fs.cmd.Wait()
+}
@@ -0,0 +1,149 @@
+package gifeo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// VideoOptions are the settings of the videos that ffmpeg encodes, see EncodeVideo
+type VideoOptions struct {
+	// Codec is the ffmpeg encoder of the video, by default libx264 for .mp4 and libvpx-vp9 for .webm
+	Codec string
+	// FPS is the frame rate of the video. By default every frame of the shortest delay is shown once,
+	// longer delays repeat their frame.
+	FPS int
+	// CRF is the constant rate factor of the codec: lower is better and larger, 0 uses the default of ffmpeg
+	CRF int
+}
+
+// ErrNoFFmpeg is returned when a video is decoded or encoded, but ffmpeg isn't installed
+var ErrNoFFmpeg = errors.New("gifeo: reading and writing videos (other than gif) needs ffmpeg, which isn't in the PATH")
+
+// videoCodecs are the default codecs of the video formats, by extension
+var videoCodecs = map[string]string{
+	".mp4":  "libx264",
+	".webm": "libvpx-vp9",
+}
+
+// IsVideo returns whether outputFile is a video (.mp4 or .webm) that Encode writes with ffmpeg, instead of a gif
+func IsVideo(outputFile string) bool {
+	_, ok := videoCodecs[strings.ToLower(filepath.Ext(outputFile))]
+	return ok
+}
+
+// CheckFFmpeg returns ErrNoFFmpeg if ffmpeg isn't in the PATH
+func CheckFFmpeg() error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return ErrNoFFmpeg
+	}
+	return nil
+}
+
+// Encode saves the frames at outputFile: as a video with EncodeVideo if IsVideo, as a gif otherwise.
+// The delays of the frames are in 100ths of a second, like those of a gif.
+func Encode(frames []*image.Paletted, delays []int, outputFile string, video VideoOptions) error {
+	if IsVideo(outputFile) {
+		return EncodeVideo(frames, delays, outputFile, video)
+	}
+
+	encodeGIF(frames, delays, outputFile)
+	return nil
+}
+
+// EncodeVideo pipes the frames into ffmpeg, which encodes them into the video at outputFile.
+// The delays of the frames are in 100ths of a second, like those of a gif.
+func EncodeVideo(frames []*image.Paletted, delays []int, outputFile string, options VideoOptions) error {
+	if err := CheckFFmpeg(); err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return errors.New("gifeo: a video needs at least one frame")
+	}
+
+	// the frame rate is rate/per frames per second
+	shortest := delays[0]
+	for _, delay := range delays {
+		if delay < shortest {
+			shortest = delay
+		}
+	}
+	rate, per := 100, shortest
+	if per < 1 {
+		per = 1
+	}
+	if options.FPS > 0 {
+		rate, per = options.FPS, 1
+	}
+
+	codec := options.Codec
+	if codec == "" {
+		codec = videoCodecs[strings.ToLower(filepath.Ext(outputFile))]
+	}
+
+	bounds := frames[0].Rect
+	args := []string{
+		"-loglevel", "error", "-y",
+		"-f", "rawvideo", "-pix_fmt", "rgba", "-s", fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy()),
+		"-framerate", fmt.Sprintf("%d/%d", rate, per), "-i", "-",
+		// yuv420p, which every player can show, needs an even width and height
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2", "-pix_fmt", "yuv420p",
+	}
+	if codec != "" {
+		args = append(args, "-c:v", codec)
+	}
+	if options.CRF > 0 {
+		args = append(args, "-crf", strconv.Itoa(options.CRF))
+		if codec == "libvpx-vp9" {
+			// without a bitrate of 0, vp9 treats the crf as a limit of a bitrate
+			args = append(args, "-b:v", "0")
+		}
+	}
+	args = append(args, outputFile)
+
+	cmd := exec.Command("ffmpeg", args...)
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	// elapsed is the time in 100ths of a second until the end of the current frame
+	elapsed, written := 0, 0
+	var writeErr error
+	for i, frame := range frames {
+		draw.Draw(rgba, rgba.Rect, frame, frame.Rect.Min, draw.Src)
+
+		// repeat the frame until the video catches up with the delays, frames shorter than one frame of the video are dropped
+		elapsed += delays[i]
+		until := (elapsed*rate + 50*per) / (100 * per)
+		if written == 0 && until == 0 {
+			until = 1
+		}
+		for ; written < until && writeErr == nil; written++ {
+			_, writeErr = stdin.Write(rgba.Pix)
+		}
+		if writeErr != nil {
+			break
+		}
+	}
+	stdin.Close()
+
+	// an ffmpeg that stopped early (with an unknown codec for example) explains why the frames couldn't be written
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("gifeo: ffmpeg failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return writeErr
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/process"
+)
+
+// imageCommand dithers an image with a chosen palette, or with one created from the image
+func imageCommand(args []string) error {
+	flags := flag.NewFlagSet("image", flag.ExitOnError)
+	inputPath := flags.String("p", "", "path to the input image, - reads it from stdin")
+	outputPath := flags.String("o", "output.png", "path to the output image, - writes it to stdout")
+	format := flags.String("format", "", "format of the output image: "+strings.Join(imgutil.Formats, ", ")+" (by default from the extension of -o, png if it has none)")
+	size := addSizeFlags(flags, "factor by which the image is scaled down before dithering")
+	swatchPath := flags.String("swatch", "", "path to save a preview image of the used palette to (png)")
+	depth := flags.Int("depth", 8, "bits per channel of the output image: 8, or 16 for a png output with direct colors")
+	report := flags.Bool("report", false, "print how well the palette covers the colors of the (scaled) input image")
+	watchInput := flags.Bool("watch", false, "dither the image again every time it changes, until an interrupt")
+	ditherName := addDitherFlag(flags)
+	options := addPaletteFlags(flags)
+	options.addCreateFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither image -p input.jpg -o output.png [-scale 4] [-k 8]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *inputPath == "" {
+		problems.add("provide an input image (-p)")
+	}
+	if *watchInput && *inputPath == "-" {
+		problems.add("stdin (-p -) can't be watched (-watch)")
+	}
+	settings := imageSettings{size: *size, depth: *depth}
+	settings.setup(problems, *format, *outputPath, *ditherName)
+	settings.checkColors(problems, options)
+	options.check(problems, givenFlags(flags))
+	if err := problems.err(); err != nil {
+		return err
+	}
+
+	dither := func() error {
+		// set up on every run, so that with -watch the same image gets the same palette
+		if err := options.setup(); err != nil {
+			return err
+		}
+
+		timer := newTiming()
+
+		done := timer.stage("decode", "")
+		img, err := openInput(*inputPath)
+		done()
+		if err != nil {
+			return err
+		}
+
+		done = timer.stage("downscale", "")
+		scaledImage, err := settings.size.apply(img)
+		done()
+		if err != nil {
+			return err
+		}
+
+		// on an interrupt, stop creating the palette, or stop dithering but still save the rows that are done
+		ctx, stop := interruptContext()
+		defer stop()
+
+		done = timer.stage("palette", "")
+		palette, index, err := options.palette(ctx, scaledImage)
+		done()
+		if err != nil {
+			return err
+		}
+
+		if *swatchPath != "" {
+			selected := colorpalette.FromPalette(palette, "selected")
+			imgutil.SavePNG(selected.ToSwatchImage(64, 8, true), *swatchPath)
+		}
+
+		if *report {
+			// the report can't share stdout with the image
+			reportOutput := os.Stdout
+			if *outputPath == "-" {
+				reportOutput = os.Stderr
+			}
+			selected := colorpalette.FromPalette(palette, "selected")
+			fmt.Fprint(reportOutput, selected.Coverage(scaledImage))
+		}
+
+		var progress process.Progress
+		if verbosity >= 1 {
+			progress = &process.TextProgress{Writer: os.Stderr, Name: "dithering", Unit: "rows"}
+		}
+
+		done = timer.stage("dither", "")
+		dithered, err := settings.ditherImage(ctx, img, scaledImage, palette, options.colorIndex(palette, index), progress)
+		done()
+		if errors.Is(err, context.Canceled) {
+			logf(0, "\ninterrupted, saving the %d completed rows", dithered.Bounds().Dy())
+		} else if err != nil {
+			return err
+		}
+
+		done = timer.stage("encode", "")
+		err = saveImage(dithered, *outputPath, settings.format)
+		done()
+		timer.summary()
+
+		return err
+	}
+
+	if *watchInput {
+		return watch(*inputPath, dither)
+	}
+
+	return dither()
+}
+
+// imageSettings are the flags of `dither image` that apply to every image it dithers
+type imageSettings struct {
+	size   sizeOptions
+	depth  int
+	format string
+	dither ditherer
+}
+
+// setup sets the format (by default from the extension of outputPath, or png) and the dithering algorithm with the given name,
+// and adds the problems with the settings to problems
+func (settings *imageSettings) setup(problems *flagErrors, format, outputPath, ditherName string) {
+	switch {
+	case format != "":
+		name, ok := imgutil.FormatWithName(format)
+		if !ok {
+			problems.add("the format (-format) needs to be one of %s, not %q", strings.Join(imgutil.Formats, ", "), format)
+		}
+		settings.format = name
+	case imgutil.FormatOf(outputPath) != "":
+		settings.format = imgutil.FormatOf(outputPath)
+	default:
+		settings.format = "png"
+	}
+
+	settings.size.check(problems)
+
+	if settings.format == "webp" {
+		// fail before the work is done, rather than when saving it
+		if _, err := exec.LookPath("cwebp"); err != nil {
+			problems.add("a webp output needs cwebp (of libwebp) in PATH")
+		}
+	}
+
+	if settings.depth != 8 && settings.depth != 16 {
+		problems.add("the bit depth (-depth) needs to be 8 or 16")
+	}
+	if settings.depth == 16 && settings.format != "png" && settings.format != "tiff" {
+		problems.add("a bit depth (-depth) of 16 needs a png or tiff output, not %s", settings.format)
+	}
+
+	settings.dither = ditherFlag(problems, ditherName)
+	if settings.dither.ordered() && settings.depth == 16 {
+		problems.add("ordered dithering (-dither %s) makes paletted images, which have a bit depth (-depth) of 8", ditherName)
+	}
+}
+
+// checkColors adds a problem to problems if the options create a palette that doesn't fit the format
+func (settings *imageSettings) checkColors(problems *flagErrors, options *paletteOptions) {
+	if settings.format == "gif" && options.creates() && options.k > 256 {
+		problems.add("gif images can hold at most 256 colors, use a png output for a palette of %d colors (-k)", options.k)
+	}
+	if options.metric != "" && (settings.depth == 16 || options.creates() && options.k > 256) {
+		problems.add("-metric only applies to paletted images, of at most 256 colors (-k) and a bit depth (-depth) of 8")
+	}
+	if settings.dither.ordered() && options.creates() && options.k > 256 {
+		problems.add("ordered dithering (-dither %s) makes paletted images, which can hold at most 256 colors, not %d (-k)", settings.dither.name, options.k)
+	}
+}
+
+// ditherImage dithers img, which is scaledImage before resizing it, with palette and its index.
+// progress may be nil. When ctx is cancelled, it returns the rows that are done together with the error of ctx.
+func (settings *imageSettings) ditherImage(ctx context.Context, img image.Image, scaledImage *image.RGBA, palette color.Palette, index process.ColorIndex, progress process.Progress) (image.Image, error) {
+	// palettes of more than 256 colors don't fit in a paletted image, dither to direct colors instead
+	if len(palette) > 256 || settings.depth == 16 {
+		if settings.format == "gif" {
+			return nil, errors.New("gif images can hold at most 256 colors, use a png output for larger palettes")
+		}
+		if _, ok := index.(*colorpalette.MetricIndex); ok {
+			return nil, errors.New("-metric only applies to paletted images, of at most 256 colors and a bit depth (-depth) of 8")
+		}
+		if settings.dither.ordered() {
+			return nil, fmt.Errorf("ordered dithering (-dither %s) makes paletted images, which can hold at most 256 colors", settings.dither.name)
+		}
+
+		if settings.depth == 16 {
+			// the palette is created from the 8-bit image, but the dithering keeps the 16 bits of the input
+			return process.ApplyErrorDiffusionRGBA64(settings.size.apply64(img), palette, settings.dither.kernel), nil
+		}
+
+		return process.ApplyErrorDiffusionRGBA(scaledImage, palette, settings.dither.kernel), nil
+	}
+
+	return settings.dither.paletted(ctx, scaledImage, palette, index, progress)
+}
+
+// saveImage writes img to path in format (see imgutil.Encode), or to stdout if path is -
+func saveImage(img image.Image, path, format string) error {
+	if path == "-" {
+		output := bufio.NewWriter(os.Stdout)
+		if err := imgutil.Encode(output, img, format); err != nil {
+			return err
+		}
+		return output.Flush()
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := imgutil.Encode(file, img, format); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	logf(1, "saved %s", path)
+	return nil
+}
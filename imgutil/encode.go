@@ -0,0 +1,127 @@
+package imgutil
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	// webp images can be read, but not written by Go, see encodeWebP
+	_ "golang.org/x/image/webp"
+)
+
+// JPEGQuality is the quality of the JPEG images written by Encode
+var JPEGQuality = 95
+
+// Formats are the names of the formats that Encode can write. WebP needs the cwebp tool of libwebp in PATH.
+var Formats = []string{"png", "gif", "jpeg", "bmp", "tiff", "webp"}
+
+// FormatWithName returns the format of Formats with the given name, which may also be an alias
+// (like "jpg" or "tif") or an extension (like ".png"), and whether there is one
+func FormatWithName(name string) (string, bool) {
+	name = strings.TrimPrefix(strings.ToLower(name), ".")
+	switch name {
+	case "jpg":
+		return "jpeg", true
+	case "tif":
+		return "tiff", true
+	}
+
+	for _, format := range Formats {
+		if format == name {
+			return format, true
+		}
+	}
+
+	return "", false
+}
+
+// FormatOf returns the format of Formats that the extension of path stands for, or "" if there is none
+// (for a path without extension, for example)
+func FormatOf(path string) string {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return ""
+	}
+
+	format, _ := FormatWithName(ext)
+	return format
+}
+
+// Encode writes img to w in format, one of Formats or an alias of it (see FormatWithName)
+func Encode(w io.Writer, img image.Image, format string) error {
+	name, ok := FormatWithName(format)
+	if !ok {
+		return fmt.Errorf("unknown image format %q", format)
+	}
+
+	switch name {
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: JPEGQuality})
+	case "bmp":
+		return bmp.Encode(w, img)
+	case "tiff":
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	}
+
+	return encodeWebP(w, img)
+}
+
+// encodeWebP writes img to w as a lossless WebP image, with the cwebp tool
+func encodeWebP(w io.Writer, img image.Image) error {
+	cwebp, err := exec.LookPath("cwebp")
+	if err != nil {
+		return fmt.Errorf("writing webp images needs cwebp (of libwebp) in PATH: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "dither-webp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.png")
+	output := filepath.Join(dir, "output.webp")
+
+	file, err := os.Create(input)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(cwebp, "-quiet", "-lossless", input, "-o", output)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cwebp: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	result, err := os.Open(output)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	_, err = io.Copy(w, result)
+	return err
+}
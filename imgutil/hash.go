@@ -0,0 +1,159 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// DefaultHashDistance is the Hamming distance between two perceptual hashes,
+// at or below which images are considered near-identical
+const DefaultHashDistance = 5
+
+// grayThumbnail shrinks img to width x height grayscale values (row-major), by averaging
+// all pixels that fall within each thumbnail pixel
+func grayThumbnail(img image.Image, width, height int) []float64 {
+	bounds := img.Bounds()
+	thumb := make([]float64, width*height)
+
+	for ty := 0; ty < height; ty++ {
+		minY := bounds.Min.Y + ty*bounds.Dy()/height
+		maxY := bounds.Min.Y + (ty+1)*bounds.Dy()/height
+		if maxY == minY {
+			maxY++
+		}
+
+		for tx := 0; tx < width; tx++ {
+			minX := bounds.Min.X + tx*bounds.Dx()/width
+			maxX := bounds.Min.X + (tx+1)*bounds.Dx()/width
+			if maxX == minX {
+				maxX++
+			}
+
+			var sum float64
+			for y := minY; y < maxY; y++ {
+				for x := minX; x < maxX; x++ {
+					sum += float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+				}
+			}
+
+			thumb[tx+ty*width] = sum / float64((maxX-minX)*(maxY-minY))
+		}
+	}
+
+	return thumb
+}
+
+// DHash returns the 64 bit difference hash of img: whether each pixel of a 9x8 grayscale thumbnail
+// is brighter than its right neighbour. It is fast, and robust against scaling and small color changes.
+func DHash(img image.Image) uint64 {
+	thumb := grayThumbnail(img, 9, 8)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if thumb[x+y*9] > thumb[x+1+y*9] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// PHash returns the 64 bit perceptual hash of img: whether each of the lowest 8x8 frequencies of the
+// discrete cosine transform of a 32x32 grayscale thumbnail lies above their median.
+// It is slower than DHash, but more robust against changes like dithering noise.
+func PHash(img image.Image) uint64 {
+	const size = 32
+	thumb := grayThumbnail(img, size, size)
+
+	// the 2D DCT is separable: transform the rows, then the columns (only the lowest 8 frequencies are needed)
+	rows := make([]float64, size*8)
+	for y := 0; y < size; y++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += thumb[x+y*size] * math.Cos(float64((2*x+1)*u)*math.Pi/(2*size))
+			}
+			rows[u+y*8] = sum
+		}
+	}
+
+	coefficients := make([]float64, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[u+y*8] * math.Cos(float64((2*y+1)*v)*math.Pi/(2*size))
+			}
+			coefficients[u+v*8] = sum
+		}
+	}
+
+	// the median excludes the DC coefficient, which only represents the average brightness
+	sorted := append([]float64{}, coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for _, coefficient := range coefficients {
+		hash <<= 1
+		if coefficient > median {
+			hash |= 1
+		}
+	}
+
+	return hash
+}
+
+// HammingDistance returns the amount of bits that differ between two hashes
+func HammingDistance(left, right uint64) int {
+	return bits.OnesCount64(left ^ right)
+}
+
+// Deduper remembers the perceptual hashes of images, to recognize near-identical ones.
+// It is safe for concurrent use.
+type Deduper struct {
+	// MaxDistance is the Hamming distance at or below which two hashes are considered duplicates
+	MaxDistance int
+	// Hash is the hash function, PHash if nil
+	Hash func(image.Image) uint64
+
+	mu     sync.Mutex
+	hashes []uint64
+}
+
+// NewDeduper creates a Deduper that uses PHash and DefaultHashDistance
+func NewDeduper() *Deduper {
+	return &Deduper{
+		MaxDistance: DefaultHashDistance,
+		Hash:        PHash,
+	}
+}
+
+// Seen reports whether an image near-identical to img was seen before.
+// If not, img is remembered.
+func (d *Deduper) Seen(img image.Image) bool {
+	hashFunc := d.Hash
+	if hashFunc == nil {
+		hashFunc = PHash
+	}
+	hash := hashFunc(img)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, other := range d.hashes {
+		if HammingDistance(hash, other) <= d.MaxDistance {
+			return true
+		}
+	}
+
+	d.hashes = append(d.hashes, hash)
+	return false
+}
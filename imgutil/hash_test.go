@@ -0,0 +1,102 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// hashTestImage returns an image of a gradient with a few random rectangles on it, which differs for every seed
+func hashTestImage(seed int64, width, height int) *image.RGBA {
+	rng := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(255 * x / width), uint8(255 * y / height), 128, 255})
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		rect := image.Rect(rng.Intn(width), rng.Intn(height), rng.Intn(width), rng.Intn(height)).Canon()
+		fill := color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				img.Set(x, y, fill)
+			}
+		}
+	}
+
+	return img
+}
+
+// noisy returns img with every channel changed by up to amount
+func noisy(img *image.RGBA, amount int) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	changed := image.NewRGBA(img.Bounds())
+	for i, value := range img.Pix {
+		if i%4 == 3 {
+			changed.Pix[i] = value
+			continue
+		}
+		changed.Pix[i] = uint8(math.Max(0, math.Min(255, float64(int(value)+rng.Intn(2*amount+1)-amount))))
+	}
+
+	return changed
+}
+
+// halved returns img at half its size, keeping every other pixel
+func halved(img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	small := image.NewRGBA(image.Rect(0, 0, bounds.Dx()/2, bounds.Dy()/2))
+	for y := 0; y < small.Bounds().Dy(); y++ {
+		for x := 0; x < small.Bounds().Dx(); x++ {
+			small.Set(x, y, img.At(bounds.Min.X+2*x, bounds.Min.Y+2*y))
+		}
+	}
+
+	return small
+}
+
+func TestHashes(t *testing.T) {
+	original := hashTestImage(1, 96, 64)
+	other := hashTestImage(2, 96, 64)
+
+	for name, hash := range map[string]func(image.Image) uint64{"dhash": DHash, "phash": PHash} {
+		base := hash(original)
+		if distance := HammingDistance(base, hash(noisy(original, 8))); distance > DefaultHashDistance {
+			t.Errorf("%s: a noisy copy is at distance %d", name, distance)
+		}
+		if distance := HammingDistance(base, hash(halved(original))); distance > DefaultHashDistance {
+			t.Errorf("%s: a downscaled copy is at distance %d", name, distance)
+		}
+		if distance := HammingDistance(base, hash(other)); distance <= DefaultHashDistance {
+			t.Errorf("%s: a different image is at distance %d", name, distance)
+		}
+	}
+}
+
+func TestDeduper(t *testing.T) {
+	original := hashTestImage(1, 96, 64)
+	other := hashTestImage(2, 96, 64)
+
+	deduper := NewDeduper()
+	images := []struct {
+		name string
+		img  image.Image
+		seen bool
+	}{
+		{"original", original, false},
+		{"noisy copy", noisy(original, 8), true},
+		{"downscaled copy", halved(original), true},
+		{"other", other, false},
+		{"other again", other, true},
+		{"original again", original, true},
+	}
+	for _, image := range images {
+		if seen := deduper.Seen(image.img); seen != image.seen {
+			t.Errorf("%s: seen is %t, want %t", image.name, seen, image.seen)
+		}
+	}
+}
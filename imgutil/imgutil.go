@@ -10,6 +10,7 @@ import (
 	"image/jpeg"
 	"image/png"
 	"os"
+	"runtime"
 	"sync"
 )
 
@@ -43,6 +44,19 @@ func ImageToPixels(img image.Image) *[][]color.Color {
 	return &pixels
 }
 
+// Workers is the amount of goroutines that PixelsToImage and Orient split the pixels over.
+// If it is 0 (the default), it is runtime.GOMAXPROCS(0).
+var Workers = 0
+
+// workers returns Workers, or runtime.GOMAXPROCS(0) if it isn't set
+func workers() int {
+	if Workers > 0 {
+		return Workers
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
+
 // PixelsToImage creates an image.RGBA from the given slice of color.Color slices
 func PixelsToImage(pixels *[][]color.Color) *image.RGBA {
 	rect := image.Rect(0, 0, len(*pixels), len((*pixels)[0]))
@@ -50,25 +64,29 @@ func PixelsToImage(pixels *[][]color.Color) *image.RGBA {
 
 	wg := sync.WaitGroup{}
 
-	for x := 0; x < len(*pixels); x++ {
+	// the columns are split over the workers
+	workers := workers()
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(x int) {
-			for y := 0; y < len((*pixels)[0]); y++ {
+		go func(w int) {
+			for x := w; x < len(*pixels); x += workers {
 				if (*pixels)[x] == nil {
 					continue
 				}
-				p := (*pixels)[x][y]
-				if p == nil {
-					continue
-				}
-				original, ok := color.RGBAModel.Convert(p).(color.RGBA)
-				if ok {
-					nImg.Set(x, y, original)
+				for y := 0; y < len((*pixels)[0]); y++ {
+					p := (*pixels)[x][y]
+					if p == nil {
+						continue
+					}
+					original, ok := color.RGBAModel.Convert(p).(color.RGBA)
+					if ok {
+						nImg.Set(x, y, original)
+					}
 				}
 			}
 
 			wg.Done()
-		}(x)
+		}(w)
 	}
 
 	wg.Wait()
@@ -76,19 +94,19 @@ func PixelsToImage(pixels *[][]color.Color) *image.RGBA {
 	return nImg
 }
 
-// OpenImage opens an image by providing a path.
+// OpenImage opens an image by providing a path, turned by its EXIF orientation (see Decode).
 func OpenImage(path string) (image.Image, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		return nil, err
 	}
 
 	defer f.Close()
 
-	img, _, err := image.Decode(f)
+	img, _, err := Decode(f)
 	if err != nil {
-		fmt.Println("Decoding error:", err.Error())
+		fmt.Fprintln(os.Stderr, "Decoding error:", err.Error())
 		return nil, err
 	}
 	return img, nil
@@ -98,7 +116,7 @@ func OpenImage(path string) (image.Image, error) {
 func SavePNG(img image.Image, name string) {
 	f, err := os.Create(name)
 	if err != nil {
-		fmt.Println("couldn't save")
+		fmt.Fprintln(os.Stderr, "couldn't save")
 	}
 	defer f.Close()
 
@@ -106,7 +124,7 @@ func SavePNG(img image.Image, name string) {
 	// then save to file
 	err = png.Encode(f, img)
 	if err != nil {
-		fmt.Println("couldn't save")
+		fmt.Fprintln(os.Stderr, "couldn't save")
 	}
 }
 
@@ -114,13 +132,13 @@ func SavePNG(img image.Image, name string) {
 func SaveGIF(img image.Image, name string) {
 	f, err := os.Create(name)
 	if err != nil {
-		fmt.Println("couldn't save")
+		fmt.Fprintln(os.Stderr, "couldn't save")
 	}
 	defer f.Close()
 
 	err = gif.Encode(f, img, nil)
 	if err != nil {
-		fmt.Println("Couldn't save")
+		fmt.Fprintln(os.Stderr, "Couldn't save")
 	}
 }
 
@@ -128,7 +146,7 @@ func SaveGIF(img image.Image, name string) {
 func SaveJPEG(img image.Image, name string, quality int) {
 	f, err := os.Create(name)
 	if err != nil {
-		fmt.Println("couldn't save")
+		fmt.Fprintln(os.Stderr, "couldn't save")
 	}
 	defer f.Close()
 
@@ -142,6 +160,6 @@ func SaveJPEG(img image.Image, name string, quality int) {
 	err = jpeg.Encode(f, img, &opt)
 
 	if err != nil {
-		fmt.Println("couldn't save")
+		fmt.Fprintln(os.Stderr, "couldn't save")
 	}
 }
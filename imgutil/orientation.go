@@ -0,0 +1,171 @@
+package imgutil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	"io"
+	"sync"
+)
+
+// the EXIF segment (APP1) of a JPEG is at most 64KiB, and comes right after its start
+const exifPeek = 1 << 17
+
+// Decode is image.Decode, which also turns the image the way its EXIF orientation says it should be shown.
+// Phones store the pixels of a photo the way the sensor read them, and only mark how it was held.
+func Decode(r io.Reader) (image.Image, string, error) {
+	br := bufio.NewReaderSize(r, exifPeek)
+	// a shorter image returns what it holds, with an error that image.Decode runs into as well
+	header, _ := br.Peek(exifPeek)
+	orientation := Orientation(header)
+
+	img, format, err := image.Decode(br)
+	if err != nil {
+		return nil, format, err
+	}
+
+	return Orient(img, orientation), format, nil
+}
+
+// Orientation returns the EXIF orientation (1 to 8) of the JPEG that starts with header,
+// or 1, the orientation that needs no turning, if it has none.
+func Orientation(header []byte) int {
+	if len(header) < 2 || header[0] != 0xff || header[1] != 0xd8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(header); {
+		if header[i] != 0xff {
+			return 1
+		}
+		marker := header[i+1]
+		if marker == 0xff {
+			// fill byte
+			i++
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			// the image data starts, or ends, before any EXIF
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(header[i+2:]))
+		if length < 2 {
+			return 1
+		}
+		end := i + 2 + length
+		if end > len(header) {
+			end = len(header)
+		}
+		segment := header[i+4 : end]
+
+		if marker == 0xe1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+
+		i += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation returns the orientation tag of the first IFD of the TIFF structure of an EXIF segment
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// the orientation is a SHORT (type 3), stored in the first bytes of the value
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+
+	return 1
+}
+
+// Orient turns img the way EXIF orientation says it should be shown: it is mirrored (2), turned half a turn (3),
+// flipped (4), transposed (5), turned a quarter clockwise (6), transversed (7) or a quarter counterclockwise (8).
+// With orientation 1, or an unknown one, img is returned as it is.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// the coordinates in img of pixel x, y of the turned image
+	var source func(x, y int) (int, int)
+	switch orientation {
+	case 2:
+		source = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3:
+		source = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4:
+		source = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5:
+		source = func(x, y int) (int, int) { return y, x }
+	case 6:
+		source = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7:
+		source = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8:
+		source = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	rect := image.Rect(0, 0, w, h)
+	if orientation >= 5 {
+		rect = image.Rect(0, 0, h, w)
+	}
+	// only JPEG images have an orientation here, which have 8 bits per channel
+	turned := image.NewRGBA(rect)
+
+	// the rows are split over the workers
+	wg := sync.WaitGroup{}
+	workers := workers()
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			for y := worker; y < rect.Dy(); y += workers {
+				for x := 0; x < rect.Dx(); x++ {
+					sx, sy := source(x, y)
+					turned.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+				}
+			}
+
+			wg.Done()
+		}(worker)
+	}
+	wg.Wait()
+
+	return turned
+}
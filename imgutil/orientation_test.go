@@ -0,0 +1,199 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// exifSegment returns the APP1 segment of a JPEG with an EXIF structure in the given byte order,
+// of which the first IFD holds a padding entry and the orientation tag with the given type and value
+func exifSegment(order binary.ByteOrder, orientationType uint16, orientation int) []byte {
+	tiff := make([]byte, 8+2+2*12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 2)
+
+	// ImageWidth (a LONG), which is skipped
+	order.PutUint16(tiff[10:], 0x0100)
+	order.PutUint16(tiff[12:], 4)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint32(tiff[18:], 640)
+
+	order.PutUint16(tiff[22:], 0x0112)
+	order.PutUint16(tiff[24:], orientationType)
+	order.PutUint32(tiff[26:], 1)
+	order.PutUint16(tiff[30:], uint16(orientation))
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	header := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(2+len(segment)))
+
+	return append(header, segment...)
+}
+
+// jpegHeader returns the start of a JPEG: the start of image marker, followed by the segments
+func jpegHeader(segments ...[]byte) []byte {
+	header := []byte{0xff, 0xd8}
+	for _, segment := range segments {
+		header = append(header, segment...)
+	}
+
+	return header
+}
+
+func TestOrientation(t *testing.T) {
+	app0 := []byte{0xff, 0xe0, 0, 7, 'J', 'F', 'I', 'F', 0}
+	scan := []byte{0xff, 0xda, 0, 2}
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   int
+	}{
+		{"empty", nil, 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"no exif", jpegHeader(app0, scan), 1},
+		{"exif after app0", jpegHeader(app0, exifSegment(binary.BigEndian, 3, 6)), 6},
+		{"fill bytes", jpegHeader([]byte{0xff, 0xff}, exifSegment(binary.LittleEndian, 3, 3)), 3},
+		{"exif after the scan", jpegHeader(scan, exifSegment(binary.BigEndian, 3, 6)), 1},
+		{"not a SHORT", jpegHeader(exifSegment(binary.LittleEndian, 4, 6)), 1},
+		{"out of range", jpegHeader(exifSegment(binary.LittleEndian, 3, 9)), 1},
+		{"zero", jpegHeader(exifSegment(binary.BigEndian, 3, 0)), 1},
+		{"segment length below 2", jpegHeader([]byte{0xff, 0xe1, 0, 1}, exifSegment(binary.BigEndian, 3, 6)), 1},
+		{"no marker", []byte{0xff, 0xd8, 0x00, 0xe1, 0, 2}, 1},
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for orientation := 1; orientation <= 8; orientation++ {
+			tests = append(tests, struct {
+				name   string
+				header []byte
+				want   int
+			}{order.String(), jpegHeader(exifSegment(order, 3, orientation)), orientation})
+		}
+	}
+
+	// the TIFF structure is broken in a copy of a valid header
+	broken := func(offset int, value ...byte) []byte {
+		header := jpegHeader(exifSegment(binary.BigEndian, 3, 6))
+		copy(header[2+4+6+offset:], value)
+		return header
+	}
+	tests = append(tests, []struct {
+		name   string
+		header []byte
+		want   int
+	}{
+		{"unknown byte order", broken(0, 'X', 'X'), 1},
+		{"mixed byte order", broken(0, 'M', 'I'), 1},
+		{"wrong magic number", broken(2, 0, 43), 1},
+		{"ifd before the header", broken(4, 0, 0, 0, 4), 1},
+		{"ifd beyond the segment", broken(4, 0, 0, 1, 0), 1},
+		{"orientation after the counted entries", broken(8, 0, 1), 1},
+		{"no exif identifier", broken(-6, 'E', 'x', 'i', 'f', 'f'), 1},
+	}...)
+
+	for _, test := range tests {
+		if got := Orientation(test.header); got != test.want {
+			t.Errorf("%s: got orientation %d, want %d", test.name, got, test.want)
+		}
+	}
+
+	// every truncation of a valid header has no orientation (the tag is in the last bytes), and doesn't panic
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		header := jpegHeader(exifSegment(order, 3, 6))
+		for length := 0; length < len(header)-4; length++ {
+			if got := Orientation(header[:length]); got != 1 {
+				t.Errorf("%s truncated to %d bytes: got orientation %d, want 1", order, length, got)
+			}
+		}
+	}
+}
+
+// orientTestImage returns the image of 2x3 pixels with the colors of the letters of
+//
+//	ab
+//	cd
+//	ef
+func orientTestImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	for i, letter := range "abcdef" {
+		img.Set(i%2, i/2, color.RGBA{uint8(letter), 0, 0, 255})
+	}
+
+	return img
+}
+
+// orientedLetters returns the letters of the pixels of an oriented orientTestImage, with a / between the rows
+func orientedLetters(img image.Image) string {
+	rows := []string{}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := ""
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			row += string(rune(r >> 8))
+		}
+		rows = append(rows, row)
+	}
+
+	return strings.Join(rows, "/")
+}
+
+func TestOrient(t *testing.T) {
+	saved := Workers
+	t.Cleanup(func() { Workers = saved })
+
+	want := map[int]string{
+		0: "ab/cd/ef",
+		1: "ab/cd/ef",
+		2: "ba/dc/fe",
+		3: "fe/dc/ba",
+		4: "ef/cd/ab",
+		5: "ace/bdf",
+		6: "eca/fdb",
+		7: "fdb/eca",
+		8: "bdf/ace",
+		9: "ab/cd/ef",
+	}
+
+	// more workers than rows, as well as fewer
+	for _, workers := range []int{1, 2, 5} {
+		Workers = workers
+		for orientation, letters := range want {
+			if got := orientedLetters(Orient(orientTestImage(), orientation)); got != letters {
+				t.Errorf("orientation %d with %d workers: got %s, want %s", orientation, workers, got, letters)
+			}
+		}
+	}
+}
+
+// TestDecode checks that Decode turns a JPEG by the orientation of its EXIF segment
+func TestDecode(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 16, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for orientation, size := range map[int]image.Point{1: {16, 8}, 3: {16, 8}, 6: {8, 16}, 8: {8, 16}} {
+		// the EXIF segment goes right after the start of image marker
+		data := append(jpegHeader(exifSegment(binary.LittleEndian, 3, orientation)), encoded.Bytes()[2:]...)
+
+		img, format, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("orientation %d: %v", orientation, err)
+		}
+		if format != "jpeg" || img.Bounds().Size() != size {
+			t.Errorf("orientation %d: decoded a %s of %v, want a jpeg of %v", orientation, format, img.Bounds().Size(), size)
+		}
+	}
+}
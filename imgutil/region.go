@@ -0,0 +1,442 @@
+package imgutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+)
+
+const pngHeader = "\x89PNG\r\n\x1a\n"
+
+// maxChunkLength is the largest chunk length the PNG specification allows
+const maxChunkLength = 1<<31 - 1
+
+var errChunkLength = errors.New("imgutil: invalid png chunk length")
+
+// PNG color types
+const (
+	ctGray      = 0
+	ctRGB       = 2
+	ctPaletted  = 3
+	ctGrayAlpha = 4
+	ctRGBA      = 6
+)
+
+// OpenImageRegion opens the image at path, but only decodes the pixels within region.
+// See DecodeRegion.
+func OpenImageRegion(path string, region image.Rectangle) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return DecodeRegion(f, region)
+}
+
+// DecodeRegion decodes only the part of the image that lies within region.
+// The returned image has its top left corner translated to (0, 0), as the rest of this module expects.
+//
+// Non-interlaced PNG files are decoded row by row: only the pixels of the region are stored,
+// and reading stops as soon as the last row of the region is decoded. This makes it possible to
+// process a crop of a very large scan without holding (or even reading) the whole image.
+// Other formats are decoded in full and cropped afterwards, after turning them by their EXIF orientation (see Decode).
+func DecodeRegion(r io.Reader, region image.Rectangle) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(len(pngHeader))
+	if err == nil && string(header) == pngHeader {
+		img, err := decodePNGRegion(br, region)
+		if err != errInterlaced {
+			return img, err
+		}
+		// interlaced images can not be streamed, the whole stream was buffered by decodePNGRegion
+		return cropImage(img, region)
+	}
+
+	img, _, err := Decode(br)
+	if err != nil {
+		return nil, err
+	}
+
+	return cropImage(img, region)
+}
+
+// cropImage copies the part of img within region into a new image at origin (0, 0)
+func cropImage(img image.Image, region image.Rectangle) (image.Image, error) {
+	region = region.Intersect(img.Bounds())
+	if region.Empty() {
+		return nil, errors.New("imgutil: region lies outside of the image")
+	}
+
+	cropped := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, region.Min, draw.Src)
+
+	return cropped, nil
+}
+
+var errInterlaced = errors.New("imgutil: interlaced png")
+
+// pngInfo holds the header information of a PNG stream
+type pngInfo struct {
+	width, height int
+	depth         int
+	colorType     int
+	interlaced    bool
+	palette       color.Palette
+	// key is the gray or RGB samples of the tRNS chunk, pixels of that exact color are transparent
+	key    [3]uint16
+	hasKey bool
+}
+
+// channels returns the amount of samples per pixel
+func (info *pngInfo) channels() int {
+	switch info.colorType {
+	case ctRGB:
+		return 3
+	case ctGrayAlpha:
+		return 2
+	case ctRGBA:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// check returns an error if the combination of bit depth and color type isn't supported
+func (info *pngInfo) check() error {
+	switch info.depth {
+	case 8, 16:
+		if info.colorType == ctPaletted && info.depth == 16 {
+			break
+		}
+		return nil
+	case 1, 2, 4:
+		if info.colorType == ctGray || info.colorType == ctPaletted {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("imgutil: unsupported png bit depth %d for color type %d", info.depth, info.colorType)
+}
+
+// chunkReader reads the chunks of a PNG stream, and presents the data of consecutive IDAT chunks as one stream
+type chunkReader struct {
+	r         io.Reader
+	remaining uint32
+	crc       uint32
+	done      bool
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for cr.remaining == 0 {
+		if cr.done {
+			return 0, io.EOF
+		}
+
+		// check the crc of the previous chunk
+		var footer [4]byte
+		if _, err := io.ReadFull(cr.r, footer[:]); err != nil {
+			return 0, err
+		}
+		if binary.BigEndian.Uint32(footer[:]) != cr.crc {
+			return 0, errors.New("imgutil: png chunk checksum mismatch")
+		}
+
+		var header [8]byte
+		if _, err := io.ReadFull(cr.r, header[:]); err != nil {
+			return 0, err
+		}
+		if string(header[4:]) != "IDAT" {
+			cr.done = true
+			return 0, io.EOF
+		}
+
+		cr.remaining = binary.BigEndian.Uint32(header[:4])
+		if cr.remaining > maxChunkLength {
+			return 0, errChunkLength
+		}
+		cr.crc = crc32.ChecksumIEEE(header[4:])
+	}
+
+	if uint32(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+
+	n, err := cr.r.Read(p)
+	cr.remaining -= uint32(n)
+	cr.crc = crc32.Update(cr.crc, crc32.IEEETable, p[:n])
+
+	return n, err
+}
+
+// decodePNGRegion decodes the rows of a non-interlaced PNG stream, up to the last row of region.
+// Interlaced images are decoded in full with the standard decoder, returning errInterlaced along with the image.
+func decodePNGRegion(r *bufio.Reader, region image.Rectangle) (image.Image, error) {
+	if _, err := r.Discard(len(pngHeader)); err != nil {
+		return nil, err
+	}
+
+	var info pngInfo
+	var raw bytes.Buffer
+	raw.WriteString(pngHeader)
+
+	// read the chunks up to the first IDAT chunk
+	var idatLength uint32
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		if length > maxChunkLength {
+			return nil, errChunkLength
+		}
+
+		chunkType := string(header[4:])
+		if chunkType == "IDAT" {
+			idatLength = length
+			raw.Write(header[:])
+			break
+		}
+
+		// read what there is, rather than allocating the length that a corrupt chunk claims
+		chunk, err := io.ReadAll(io.LimitReader(r, int64(length)+4))
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) < int(length)+4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		raw.Write(header[:])
+		raw.Write(chunk)
+
+		// the data of the chunk, without its crc
+		data := chunk[:length]
+
+		switch chunkType {
+		case "IHDR":
+			if len(data) < 13 {
+				return nil, errors.New("imgutil: invalid png header")
+			}
+			info.width = int(binary.BigEndian.Uint32(data[0:4]))
+			info.height = int(binary.BigEndian.Uint32(data[4:8]))
+			info.depth = int(data[8])
+			info.colorType = int(data[9])
+			info.interlaced = data[12] != 0
+		case "PLTE":
+			for i := 0; i+2 < len(data); i += 3 {
+				info.palette = append(info.palette, color.NRGBA{data[i], data[i+1], data[i+2], 255})
+			}
+		case "tRNS":
+			switch {
+			case info.colorType == ctPaletted:
+				for i := 0; i < len(data) && i < len(info.palette); i++ {
+					clr := info.palette[i].(color.NRGBA)
+					clr.A = data[i]
+					info.palette[i] = clr
+				}
+			case info.colorType == ctGray && len(data) >= 2:
+				info.key[0] = binary.BigEndian.Uint16(data[0:2])
+				info.hasKey = true
+			case info.colorType == ctRGB && len(data) >= 6:
+				for c := range info.key {
+					info.key[c] = binary.BigEndian.Uint16(data[2*c : 2*c+2])
+				}
+				info.hasKey = true
+			}
+		}
+	}
+
+	if info.interlaced {
+		img, _, err := image.Decode(io.MultiReader(&raw, r))
+		if err != nil {
+			return nil, err
+		}
+		return img, errInterlaced
+	}
+
+	if err := info.check(); err != nil {
+		return nil, err
+	}
+
+	region = region.Intersect(image.Rect(0, 0, info.width, info.height))
+	if region.Empty() {
+		return nil, errors.New("imgutil: region lies outside of the image")
+	}
+
+	idat := &chunkReader{
+		r:         r,
+		remaining: idatLength,
+		crc:       crc32.ChecksumIEEE([]byte("IDAT")),
+	}
+
+	zr, err := zlib.NewReader(idat)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	bitsPerPixel := info.channels() * info.depth
+	rowSize := (info.width*bitsPerPixel + 7) / 8
+	// bytes per complete pixel, used by the filters
+	bpp := (bitsPerPixel + 7) / 8
+
+	current := make([]byte, rowSize+1)
+	previous := make([]byte, rowSize+1)
+
+	out := image.NewNRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+
+	for y := 0; y < region.Max.Y; y++ {
+		if _, err := io.ReadFull(zr, current); err != nil {
+			return nil, err
+		}
+
+		if err := unfilter(current[0], current[1:], previous[1:], bpp); err != nil {
+			return nil, err
+		}
+
+		if y >= region.Min.Y {
+			for x := region.Min.X; x < region.Max.X; x++ {
+				out.SetNRGBA(x-region.Min.X, y-region.Min.Y, info.pixelAt(current[1:], x))
+			}
+		}
+
+		current, previous = previous, current
+	}
+
+	return out, nil
+}
+
+// unfilter reverses the PNG filter of one row, in place
+func unfilter(filter byte, row, previous []byte, bpp int) error {
+	switch filter {
+	case 0:
+	case 1:
+		for i := bpp; i < len(row); i++ {
+			row[i] += row[i-bpp]
+		}
+	case 2:
+		for i := range row {
+			row[i] += previous[i]
+		}
+	case 3:
+		for i := range row {
+			var left int
+			if i >= bpp {
+				left = int(row[i-bpp])
+			}
+			row[i] += byte((left + int(previous[i])) / 2)
+		}
+	case 4:
+		for i := range row {
+			var left, upLeft int
+			if i >= bpp {
+				left = int(row[i-bpp])
+				upLeft = int(previous[i-bpp])
+			}
+			row[i] += paeth(left, int(previous[i]), upLeft)
+		}
+	default:
+		return errors.New("imgutil: invalid png filter type")
+	}
+
+	return nil
+}
+
+func paeth(a, b, c int) byte {
+	p := a + b - c
+	pa, pb, pc := abs(p-a), abs(p-b), abs(p-c)
+
+	if pa <= pb && pa <= pc {
+		return byte(a)
+	} else if pb <= pc {
+		return byte(b)
+	}
+	return byte(c)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// rawSample returns the sample number i of the row, at the bit depth of the image
+func (info *pngInfo) rawSample(row []byte, i int) uint16 {
+	switch info.depth {
+	case 16:
+		return binary.BigEndian.Uint16(row[2*i:])
+	case 8:
+		return uint16(row[i])
+	default:
+		bit := i * info.depth
+		return uint16(row[bit/8]>>(8-info.depth-bit%8)) & (1<<info.depth - 1)
+	}
+}
+
+// sample returns the sample number i of the row, scaled to 8 bits
+func (info *pngInfo) sample(row []byte, i int) uint8 {
+	value := info.rawSample(row, i)
+
+	switch {
+	case info.depth == 16:
+		return uint8(value >> 8)
+	case info.depth == 8 || info.colorType == ctPaletted:
+		return uint8(value)
+	default:
+		return uint8(value) * (255 / (1<<info.depth - 1))
+	}
+}
+
+// alpha returns the alpha of the gray or RGB pixel that starts at sample number i of the row:
+// transparent if it has the color of the tRNS chunk, opaque otherwise
+func (info *pngInfo) alpha(row []byte, i int) uint8 {
+	if !info.hasKey {
+		return 255
+	}
+
+	for c := 0; c < info.channels(); c++ {
+		if info.rawSample(row, i+c) != info.key[c] {
+			return 255
+		}
+	}
+
+	return 0
+}
+
+// pixelAt returns the color of pixel x in the (unfiltered) row
+func (info *pngInfo) pixelAt(row []byte, x int) color.NRGBA {
+	n := info.channels()
+
+	switch info.colorType {
+	case ctGray:
+		v := info.sample(row, x)
+		return color.NRGBA{v, v, v, info.alpha(row, x)}
+	case ctGrayAlpha:
+		v := info.sample(row, n*x)
+		return color.NRGBA{v, v, v, info.sample(row, n*x+1)}
+	case ctRGB:
+		return color.NRGBA{info.sample(row, n*x), info.sample(row, n*x+1), info.sample(row, n*x+2), info.alpha(row, n*x)}
+	case ctRGBA:
+		return color.NRGBA{info.sample(row, n*x), info.sample(row, n*x+1), info.sample(row, n*x+2), info.sample(row, n*x+3)}
+	default:
+		index := int(info.sample(row, x))
+		if index < len(info.palette) {
+			return info.palette[index].(color.NRGBA)
+		}
+		return color.NRGBA{0, 0, 0, 255}
+	}
+}
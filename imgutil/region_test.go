@@ -0,0 +1,301 @@
+package imgutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// testPNG describes a png to encode with encodeTestPNG: the samples of every pixel (one per channel, at depth bits),
+// and optionally a palette, a tRNS chunk and Adam7 interlacing
+type testPNG struct {
+	width, height int
+	depth         int
+	colorType     int
+	interlaced    bool
+	samples       [][]uint16
+	palette       []byte
+	trns          []byte
+}
+
+// adam7 holds the offsets and strides of the passes of Adam7 interlacing
+var adam7 = []struct{ x, y, dx, dy int }{
+	{0, 0, 8, 8}, {4, 0, 8, 8}, {0, 4, 4, 8}, {2, 0, 4, 4}, {0, 2, 2, 4}, {1, 0, 2, 2}, {0, 1, 1, 2},
+}
+
+// encodeTestPNG encodes p, filtering the rows with each of the five filters in turn,
+// as image/png can't write low bit depths of gray, gray with alpha, tRNS chunks or interlaced images
+func encodeTestPNG(t *testing.T, p testPNG) []byte {
+	t.Helper()
+
+	channels := map[int]int{ctGray: 1, ctRGB: 3, ctPaletted: 1, ctGrayAlpha: 2, ctRGBA: 4}[p.colorType]
+	bpp := (channels*p.depth + 7) / 8
+
+	passes := []struct{ x, y, dx, dy int }{{0, 0, 1, 1}}
+	if p.interlaced {
+		passes = adam7
+	}
+
+	var filtered bytes.Buffer
+	filter := 0
+	for _, pass := range passes {
+		var previous []byte
+		for y := pass.y; y < p.height; y += pass.dy {
+			var bits []uint16
+			for x := pass.x; x < p.width; x += pass.dx {
+				bits = append(bits, p.samples[y*p.width+x]...)
+			}
+			if len(bits) == 0 {
+				continue
+			}
+
+			row := make([]byte, (len(bits)*p.depth+7)/8)
+			for i, sample := range bits {
+				switch p.depth {
+				case 16:
+					binary.BigEndian.PutUint16(row[2*i:], sample)
+				case 8:
+					row[i] = byte(sample)
+				default:
+					bit := i * p.depth
+					row[bit/8] |= byte(sample) << (8 - p.depth - bit%8)
+				}
+			}
+			if previous == nil {
+				previous = make([]byte, len(row))
+			}
+
+			filtered.WriteByte(byte(filter))
+			for i := range row {
+				var left, upLeft int
+				if i >= bpp {
+					left, upLeft = int(row[i-bpp]), int(previous[i-bpp])
+				}
+				up := int(previous[i])
+				predictions := []int{0, left, up, (left + up) / 2, int(paeth(left, up, upLeft))}
+				filtered.WriteByte(row[i] - byte(predictions[filter]))
+			}
+			filter = (filter + 1) % 5
+			previous = row
+		}
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(filtered.Bytes())
+	zw.Close()
+
+	var out bytes.Buffer
+	out.WriteString(pngHeader)
+	chunk := func(name string, data []byte) {
+		binary.Write(&out, binary.BigEndian, uint32(len(data)))
+		out.WriteString(name)
+		out.Write(data)
+		binary.Write(&out, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(name), data...)))
+	}
+
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(p.width))
+	binary.BigEndian.PutUint32(header[4:], uint32(p.height))
+	header[8], header[9] = byte(p.depth), byte(p.colorType)
+	if p.interlaced {
+		header[12] = 1
+	}
+	chunk("IHDR", header)
+	if p.palette != nil {
+		chunk("PLTE", p.palette)
+	}
+	if p.trns != nil {
+		chunk("tRNS", p.trns)
+	}
+	// split the data over two IDAT chunks, which the region decoder reads as one stream
+	data := compressed.Bytes()
+	chunk("IDAT", data[:len(data)/2])
+	chunk("IDAT", data[len(data)/2:])
+	chunk("IEND", nil)
+
+	return out.Bytes()
+}
+
+// randomTestPNG returns a png of the color type and depth with random samples. With a tRNS chunk,
+// the first pixel has the transparent color (or palette index 0), and so do a few others.
+func randomTestPNG(rng *rand.Rand, colorType, depth int, trns, interlaced bool) testPNG {
+	p := testPNG{width: 13, height: 11, depth: depth, colorType: colorType, interlaced: interlaced}
+	channels := map[int]int{ctGray: 1, ctRGB: 3, ctPaletted: 1, ctGrayAlpha: 2, ctRGBA: 4}[colorType]
+	levels := 1 << depth
+
+	colors := levels
+	if colorType == ctPaletted {
+		if colors > 40 {
+			colors = 40
+		}
+		for i := 0; i < colors*3; i++ {
+			p.palette = append(p.palette, byte(rng.Intn(256)))
+		}
+	}
+
+	for i := 0; i < p.width*p.height; i++ {
+		pixel := make([]uint16, channels)
+		for c := range pixel {
+			pixel[c] = uint16(rng.Intn(colors))
+		}
+		p.samples = append(p.samples, pixel)
+	}
+
+	if trns {
+		if colorType == ctPaletted {
+			p.samples[0] = []uint16{0}
+		}
+		for i := 0; i < len(p.samples); i += 7 {
+			p.samples[i] = p.samples[0]
+		}
+		switch colorType {
+		case ctPaletted:
+			for i := 0; i < colors/2; i++ {
+				p.trns = append(p.trns, byte(rng.Intn(256)))
+			}
+			p.trns[0] = 0
+		default:
+			for _, sample := range p.samples[0] {
+				p.trns = binary.BigEndian.AppendUint16(p.trns, sample)
+			}
+		}
+	}
+
+	return p
+}
+
+// premultiplied returns the 8 bit premultiplied channels of the pixel at x, y
+func premultiplied(img image.Image, x, y int) [4]int {
+	r, g, b, a := img.At(x, y).RGBA()
+	return [4]int{int(r >> 8), int(g >> 8), int(b >> 8), int(a >> 8)}
+}
+
+func TestDecodeRegion(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	depths := map[int][]int{
+		ctGray:      {1, 2, 4, 8, 16},
+		ctRGB:       {8, 16},
+		ctPaletted:  {1, 2, 4, 8},
+		ctGrayAlpha: {8, 16},
+		ctRGBA:      {8, 16},
+	}
+	regions := []image.Rectangle{
+		image.Rect(0, 0, 13, 11),
+		image.Rect(3, 2, 10, 7),
+		image.Rect(12, 10, 20, 20),
+	}
+
+	for _, colorType := range []int{ctGray, ctRGB, ctPaletted, ctGrayAlpha, ctRGBA} {
+		for _, depth := range depths[colorType] {
+			for _, trns := range []bool{false, true} {
+				if trns && (colorType == ctGrayAlpha || colorType == ctRGBA) {
+					continue
+				}
+				for _, interlaced := range []bool{false, true} {
+					name := fmt.Sprintf("type %d depth %d trns %v interlaced %v", colorType, depth, trns, interlaced)
+					t.Run(name, func(t *testing.T) {
+						data := encodeTestPNG(t, randomTestPNG(rng, colorType, depth, trns, interlaced))
+						want, err := png.Decode(bytes.NewReader(data))
+						if err != nil {
+							t.Fatalf("image/png can't decode the test image: %v", err)
+						}
+
+						// the region decoder keeps 8 bits of 16 bit samples, where image/png keeps them all,
+						// which can differ by 2 after premultiplying by the alpha
+						tolerance := 0
+						if depth == 16 && !interlaced {
+							tolerance = 2
+						}
+
+						for _, region := range regions {
+							got, err := DecodeRegion(bytes.NewReader(data), region)
+							if err != nil {
+								t.Fatalf("region %v: %v", region, err)
+							}
+
+							crop := region.Intersect(want.Bounds())
+							if got.Bounds() != crop.Sub(crop.Min) {
+								t.Fatalf("region %v: bounds %v, want %v", region, got.Bounds(), crop.Sub(crop.Min))
+							}
+							for y := crop.Min.Y; y < crop.Max.Y; y++ {
+								for x := crop.Min.X; x < crop.Max.X; x++ {
+									g, w := premultiplied(got, x-crop.Min.X, y-crop.Min.Y), premultiplied(want, x, y)
+									for c := range g {
+										if d := g[c] - w[c]; d > tolerance || d < -tolerance {
+											t.Fatalf("region %v: pixel %d,%d is %v, image/png decodes %v", region, x, y, g, w)
+										}
+									}
+								}
+							}
+						}
+
+						if trns {
+							if _, _, _, a := want.At(0, 0).RGBA(); a != 0 {
+								t.Fatalf("the first pixel of the test image isn't transparent")
+							}
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeRegionErrors(t *testing.T) {
+	data := encodeTestPNG(t, randomTestPNG(rand.New(rand.NewSource(1)), ctRGB, 8, false, false))
+
+	if _, err := DecodeRegion(bytes.NewReader(data), image.Rect(20, 20, 30, 30)); err == nil {
+		t.Errorf("a region outside of the image gives no error")
+	}
+
+	// the region decoder stops reading after the last row of the region, so only truncating the rows gives an error
+	for _, size := range []int{len(pngHeader) + 10, 100, 300} {
+		if _, err := DecodeRegion(bytes.NewReader(data[:size]), image.Rect(0, 0, 13, 11)); err == nil {
+			t.Errorf("a png truncated to %d bytes gives no error", size)
+		}
+	}
+
+	corrupt := append([]byte{}, data...)
+	// within the first IDAT chunk, whose checksum is checked before reading the second
+	corrupt[100] ^= 0xff
+	if _, err := DecodeRegion(bytes.NewReader(corrupt), image.Rect(0, 0, 13, 11)); err == nil {
+		t.Errorf("a png with a corrupt IDAT chunk gives no error")
+	}
+}
+
+func TestDecodeRegionChunkLengths(t *testing.T) {
+	data := encodeTestPNG(t, randomTestPNG(rand.New(rand.NewSource(1)), ctPaletted, 8, false, false))
+	// the signature and the IHDR chunk: 8 bytes of length and type, 13 of data and 4 of crc
+	ihdr := data[:len(pngHeader)+25]
+
+	chunk := func(length uint32, chunkType string, data ...byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, length)
+		return append(append(b, chunkType...), data...)
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := map[string][]byte{
+		"IHDR length wraps around":    join([]byte(pngHeader), chunk(0xffffffff, "IHDR", 0, 0, 0)),
+		"PLTE length wraps around":    join(ihdr, chunk(0xfffffffe, "PLTE", 1, 2, 3, 4, 5, 6)),
+		"chunk longer than allowed":   join(ihdr, chunk(1<<31, "tEXt", 'a', 'b')),
+		"IDAT longer than allowed":    join(ihdr, chunk(1<<31, "IDAT", 0x78, 0x9c)),
+		"chunk longer than the data":  join(ihdr, chunk(maxChunkLength, "tEXt", 'a', 'b')),
+		"truncated chunk":             join(ihdr, chunk(6, "PLTE", 1, 2, 3)),
+		"IHDR shorter than its field": join([]byte(pngHeader), chunk(4, "IHDR", 0, 0, 0, 1, 0, 0, 0, 0)),
+	}
+
+	for name, corrupt := range tests {
+		if _, err := DecodeRegion(bytes.NewReader(corrupt), image.Rect(0, 0, 1, 1)); err == nil {
+			t.Errorf("%s: gives no error", name)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+
+	"github.com/mielpeeters/dither/gameofcolor"
+	"github.com/mielpeeters/dither/gifeo"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/kmeans"
+)
+
+// addJobsFlag registers -j on flags, and returns the function that applies it after parsing.
+// -j is the amount of workers of the packages that split their work (like the runs of creating a palette,
+// and the frames of a video), and it limits the threads that run them as well, with runtime.GOMAXPROCS.
+func addJobsFlag(flags *flag.FlagSet) func() error {
+	jobs := flags.Int("j", runtime.GOMAXPROCS(0), "amount of threads that work at the same time, on downscaling, dithering, creating palettes and the frames of videos")
+
+	return func() error {
+		if *jobs < 1 {
+			return &usageError{command: flags.Name(), problems: []string{"the amount of threads (-j) needs to be at least 1"}}
+		}
+		runtime.GOMAXPROCS(*jobs)
+		kmeans.Workers = *jobs
+		imgutil.Workers = *jobs
+		gifeo.Workers = *jobs
+		gameofcolor.Workers = *jobs
+
+		return nil
+	}
+}
@@ -0,0 +1,193 @@
+package kdtree
+
+import (
+	"math/bits"
+)
+
+// leafSize is the amount of points a leaf can hold after inserting, before it is split
+const leafSize = 8
+
+// RebalanceFactor defines when Rebalance rebuilds a tree: when it is more than RebalanceFactor times
+// as deep as a balanced tree with the same points would be
+var RebalanceFactor = 2
+
+// Insert adds point to the tree. Many inserts in the same region make the tree unbalanced, see Rebalance.
+func (kd *KDTree) Insert(point Point) {
+	kd.addLookup(point)
+
+	if kd.Root == nil {
+		kd.Root = &Node{PointValue: []Point{point}}
+		return
+	}
+
+	node, axis := kd.Root, 0
+	for !node.isLeafNode() {
+		child := &node.Left
+		if point.Dimension(axis) >= node.PointValue[0].Dimension(axis) {
+			child = &node.Right
+		}
+
+		if *child == nil {
+			*child = &Node{PointValue: []Point{point}, Parrent: node}
+			return
+		}
+
+		node, axis = *child, (axis+1)%point.Dimensions()
+	}
+
+	node.PointValue = append(node.PointValue, point)
+	if len(node.PointValue) > leafSize {
+		node.rebuild(node.PointValue, axis)
+	}
+}
+
+// Delete removes point from the tree, and reports whether it was found. Points are compared with ==,
+// so they need to be comparable (like the pointers that FromGeom returns).
+func (kd *KDTree) Delete(point Point) bool {
+	if kd.Root == nil {
+		return false
+	}
+
+	node, axis, index := kd.Root.find(point, 0)
+	if node == nil {
+		return false
+	}
+	kd.removeLookup(point)
+
+	switch {
+	case node.isLeafNode() && len(node.PointValue) > 1:
+		node.PointValue = append(node.PointValue[:index], node.PointValue[index+1:]...)
+	case node.isLeafNode() && node.isRootNode():
+		kd.Root = nil
+	case node.isLeafNode():
+		// rebuild the parent without this leaf, so that it doesn't end up with only a right child
+		parent := node.Parrent
+		parentAxis := (axis - 1 + point.Dimensions()) % point.Dimensions()
+		parent.rebuild(parent.pointsWithout(point), parentAxis)
+	default:
+		node.rebuild(node.pointsWithout(point), axis)
+	}
+
+	return true
+}
+
+// Rebalance rebuilds the tree if it has become too unbalanced by inserts and deletes (see RebalanceFactor),
+// and reports whether it did
+func (kd *KDTree) Rebalance() bool {
+	if kd.Root == nil {
+		return false
+	}
+
+	points := kd.Root.points(nil)
+	if kd.Depth() <= RebalanceFactor*bits.Len(uint(len(points))) {
+		return false
+	}
+
+	kd.Root.rebuild(points, 0)
+
+	return true
+}
+
+// Depth returns the amount of levels of the tree
+func (kd *KDTree) Depth() int {
+	if kd.Root == nil {
+		return 0
+	}
+
+	return kd.Root.depth()
+}
+
+// Len returns the amount of points in the tree
+func (kd *KDTree) Len() int {
+	if kd.Root == nil {
+		return 0
+	}
+
+	return len(kd.Root.points(nil))
+}
+
+// depth returns the amount of levels of the subtree at node
+func (node *Node) depth() int {
+	depth := 0
+	for _, child := range []*Node{node.Left, node.Right} {
+		if child != nil {
+			depth = maxInt(depth, child.depth())
+		}
+	}
+
+	return depth + 1
+}
+
+// find returns the node of the subtree that holds point, the axis that node splits on and the index of the point
+// in its PointValue, or a nil node if it isn't found
+func (node *Node) find(point Point, axis int) (*Node, int, int) {
+	for i, candidate := range node.PointValue {
+		if candidate == point {
+			return node, axis, i
+		}
+	}
+
+	if node.isLeafNode() {
+		return nil, 0, 0
+	}
+
+	nextAxis := (axis + 1) % point.Dimensions()
+	value, pivot := point.Dimension(axis), node.PointValue[0].Dimension(axis)
+
+	// points equal to the pivot can end up on both sides
+	if node.Left != nil && value <= pivot {
+		if found, foundAxis, index := node.Left.find(point, nextAxis); found != nil {
+			return found, foundAxis, index
+		}
+	}
+	if node.Right != nil && value >= pivot {
+		return node.Right.find(point, nextAxis)
+	}
+
+	return nil, 0, 0
+}
+
+// points appends the points of the subtree at node to points, and returns the result
+func (node *Node) points(points []Point) []Point {
+	points = append(points, node.PointValue...)
+	for _, child := range []*Node{node.Left, node.Right} {
+		if child != nil {
+			points = child.points(points)
+		}
+	}
+
+	return points
+}
+
+// pointsWithout returns the points of the subtree at node, except for point
+func (node *Node) pointsWithout(point Point) []Point {
+	points := node.points(nil)
+	for i := range points {
+		if points[i] == point {
+			return append(points[:i], points[i+1:]...)
+		}
+	}
+
+	return points
+}
+
+// rebuild replaces the subtree at node with a balanced one of the given points, splitting on axis first.
+// The node keeps its place in the tree.
+func (node *Node) rebuild(points []Point, axis int) {
+	rebuilt := generateKDNodeFromPoints(append([]Point{}, points...),
+		axis, points[0].Dimensions(), bits.Len(uint(len(points))))
+
+	node.PointValue, node.Left, node.Right = rebuilt.PointValue, rebuilt.Left, rebuilt.Right
+	for _, child := range []*Node{node.Left, node.Right} {
+		if child != nil {
+			child.Parrent = node
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
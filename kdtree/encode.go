@@ -0,0 +1,109 @@
+package kdtree
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+func init() {
+	gob.Register(&GeomPoint{})
+}
+
+// treeNode is one node of the flattened tree: the amount of points it holds (which follow those of the nodes
+// before it) and the positions of its children in the nodes slice (-1 if there is none)
+type treeNode struct {
+	Points      int32
+	Left, Right int32
+}
+
+// treeData is the encoded form of a KDTree
+type treeData struct {
+	Points []Point
+	Nodes  []treeNode
+}
+
+// Encode writes the tree to w, to be read again with Decode. The points are written with gob,
+// so their type needs to be registered with gob.Register (GeomPoint already is).
+func (kd *KDTree) Encode(w io.Writer) error {
+	data := treeData{Points: []Point{}, Nodes: []treeNode{}}
+	if kd.Root != nil {
+		kd.Root.flatten(&data)
+	}
+
+	return gob.NewEncoder(w).Encode(data)
+}
+
+// flatten adds the subtree at node to data, and returns its position
+func (node *Node) flatten(data *treeData) int32 {
+	if node == nil {
+		return -1
+	}
+
+	position := int32(len(data.Nodes))
+	data.Nodes = append(data.Nodes, treeNode{Points: int32(len(node.PointValue))})
+	data.Points = append(data.Points, node.PointValue...)
+
+	left := node.Left.flatten(data)
+	right := node.Right.flatten(data)
+	data.Nodes[position].Left = left
+	data.Nodes[position].Right = right
+
+	return position
+}
+
+// Decode reads a tree that was written with Encode
+func Decode(r io.Reader) (*KDTree, error) {
+	var data treeData
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	// children always come after their parent, which also rules out cycles
+	validChild := func(child int32, parent int) bool {
+		return child == -1 || (int(child) > parent && int(child) < len(data.Nodes))
+	}
+
+	total := 0
+	for i, node := range data.Nodes {
+		// the points of a node with children split the space, so it needs at least one
+		hasChildren := node.Left != -1 || node.Right != -1
+		if node.Points < 0 || (hasChildren && node.Points == 0) || !validChild(node.Left, i) || !validChild(node.Right, i) {
+			return nil, errors.New("kdtree: invalid tree")
+		}
+		total += int(node.Points)
+	}
+	if total != len(data.Points) {
+		return nil, errors.New("kdtree: invalid tree")
+	}
+
+	kd := &KDTree{}
+	if len(data.Nodes) > 0 {
+		points := data.Points
+		nodes := make([]Node, len(data.Nodes))
+		for i, node := range data.Nodes {
+			nodes[i].PointValue = points[:node.Points:node.Points]
+			points = points[node.Points:]
+
+			for _, child := range []int32{node.Left, node.Right} {
+				if child >= 0 && nodes[child].Parrent != nil {
+					return nil, errors.New("kdtree: invalid tree")
+				}
+			}
+			if node.Left >= 0 {
+				nodes[i].Left = &nodes[node.Left]
+				nodes[node.Left].Parrent = &nodes[i]
+			}
+			if node.Right >= 0 {
+				nodes[i].Right = &nodes[node.Right]
+				nodes[node.Right].Parrent = &nodes[i]
+			}
+		}
+		kd.Root = &nodes[0]
+	}
+	for _, point := range data.Points {
+		kd.addLookup(point)
+	}
+
+	return kd, nil
+}
@@ -2,22 +2,67 @@ package kdtree
 
 import (
 	"fmt"
-	"math"
-	"time"
+	"math/bits"
 
 	"github.com/mielpeeters/dither/geom"
 )
 
-// KDTree is a kd tree struct
+// Point is a point that can be stored in a KDTree
+type Point interface {
+	// Dimensions returns the amount of dimensions of the point
+	Dimensions() int
+	// Dimension returns the coordinate of the point in dimension i
+	Dimension(i int) float64
+}
+
+// GeomPoint makes a geom.Point usable as a Point, see FromGeom
+type GeomPoint struct {
+	geom.Point
+}
+
+// Dimensions returns the amount of coordinates of the point
+func (point *GeomPoint) Dimensions() int {
+	return len(point.Coordinates)
+}
+
+// Dimension returns coordinate i of the point
+func (point *GeomPoint) Dimension(i int) float64 {
+	return float64(point.Coordinates[i])
+}
+
+// FromGeom converts the points of a PointSet to Points, to be stored in a KDTree
+func FromGeom(points geom.PointSet) []Point {
+	converted := make([]Point, len(points.Points))
+	for i := range points.Points {
+		converted[i] = &GeomPoint{points.Points[i]}
+	}
+
+	return converted
+}
+
+// SquaredDistance returns the squared euclidean distance of two points, the default metric for searches
+func SquaredDistance(pnt1, pnt2 Point) float64 {
+	var dist float64
+	for i := 0; i < pnt1.Dimensions(); i++ {
+		diff := pnt1.Dimension(i) - pnt2.Dimension(i)
+		dist += diff * diff
+	}
+
+	return dist
+}
+
+// KDTree is a kd tree struct.
+// The searches keep their state per query, so any amount of goroutines can search the same tree at once,
+// as long as it isn't changed (by Insert, Delete, Rebalance or Rebuild) at the same time.
 type KDTree struct {
-	Root     *Node
-	Lookup   map[int]geom.Point
-	BestDist float64
+	Root *Node
+	// Lookup holds the points of the tree that are Identified, by their ID
+	Lookup map[int]Point
 }
 
 // Node is a node struct for within a KD tree
 type Node struct {
-	PointValue []geom.Point
+	PointValue []Point
 	Left       *Node
 	Right      *Node
 	Parrent    *Node
@@ -34,14 +79,15 @@ func (node *Node) isRootNode() bool {
 func meanCutAlgorithm(points geom.PointSet) geom.PointSet {
 	var returnSet geom.PointSet
 
-	tree := generateKDTreeFromPoints(points, 5)
+	tree := generateKDTreeFromPoints(FromGeom(points), 5)
 
-	var leafs [][]geom.Point
+	var leafs [][]Point
 	tree.Root.leafs(&leafs)
 
 	for _, leaf := range leafs {
-		set := geom.PointSet{
-			Points: leaf,
+		set := geom.PointSet{}
+		for _, point := range leaf {
+			set.Points = append(set.Points, point.(*GeomPoint).Point)
 		}
 
 		// find the mean of the leaf\
@@ -52,68 +98,160 @@ func meanCutAlgorithm(points geom.PointSet) geom.PointSet {
 	return returnSet
 }
 
-func generateKDTreeFromPoints(points geom.PointSet, depth int) KDTree {
+// New builds a KDTree that holds all of the points, splitting until every leaf holds a single point.
+// The points are copied, so the order of the given slice is kept.
+func New(points []Point) *KDTree {
+	if len(points) == 0 {
+		return &KDTree{}
+	}
+
+	kd := generateKDTreeFromPoints(append([]Point{}, points...), bits.Len(uint(len(points))))
+	for _, point := range points {
+		kd.addLookup(point)
+	}
+
+	return &kd
+}
+
+// Points returns all points of the tree
+func (kd *KDTree) Points() []Point {
+	if kd.Root == nil {
+		return []Point{}
+	}
+
+	return kd.Root.points(nil)
+}
+
+// Rebuild builds the tree again from its points, which is needed after their coordinates have changed
+// (like the positions of moving particles)
+func (kd *KDTree) Rebuild() {
+	if kd.Root != nil {
+		kd.Root.rebuild(kd.Root.points(nil), 0)
+	}
+}
+
+func generateKDTreeFromPoints(points []Point, depth int) KDTree {
 	var kd KDTree
 
-	nmbAxis := len(points.Points[0].Coordinates)
+	nmbAxis := points[0].Dimensions()
 
 	root := generateKDNodeFromPoints(points, 0, nmbAxis, depth)
 
 	kd.Root = root
 
-	kd.BestDist = -1.0
-
 	return kd
 }
 
-// Recursively create a KDTree from a set of points, on the given start axis
-// and with the given number of axis
+// Create a KDTree from a set of points, on the given start axis and with the given number of axis.
+// The points are reordered in place, and the nodes refer to them instead of copying them.
+// Instead of recursing and sorting, every node selects its median with selectNth and hands its halves
+// to an explicit stack, so that sets of millions of points can be handled.
 //
 // # Arguments:
 //   - points: the set of points to generate the node from
 //   - axis: the axis to split the node on
 //   - nmbAxis: the number of axis in total
 //   - depth: the depth to grow the tree to
-func generateKDNodeFromPoints(points geom.PointSet, axis int, nmbAxis int, depth int) *Node {
-	if depth == 0 {
-		return &Node{
-			points.Points,
-			nil,
-			nil,
-			nil,
-		}
+func generateKDNodeFromPoints(points []Point, axis int, nmbAxis int, depth int) *Node {
+	all := points
+	if len(all) == 0 {
+		return &Node{}
 	}
-	// generate a left and a right pointset
-	leftSet, rightSet, pivot := points.BranchByMedian(axis)
-
-	thisNode := Node{
-		[]geom.Point{pivot},
-		nil,
-		nil,
-		nil,
+
+	// every node holds at least one point, so all nodes fit in one allocation
+	nodes := make([]Node, 0, maxInt(len(all), 1))
+
+	type task struct {
+		node        *Node
+		lo, hi      int
+		axis, depth int
 	}
 
-	var leftNode *Node
-	var rightNode *Node
+	nodes = append(nodes, Node{})
+	stack := []task{{&nodes[0], 0, len(all), axis, depth}}
 
-	if len(leftSet.Points) > 0 {
-		// create the current node
-		leftNode = generateKDNodeFromPoints(leftSet, (axis+1)%nmbAxis, nmbAxis, depth-1)
-		leftNode.Parrent = &thisNode
-		thisNode.Left = leftNode
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := current.node
 
-		if len(rightSet.Points) > 0 {
-			rightNode = generateKDNodeFromPoints(rightSet, (axis+1)%nmbAxis, nmbAxis, depth-1)
-			rightNode.Parrent = &thisNode
-			thisNode.Right = rightNode
+		if current.depth == 0 {
+			// the capacity is limited, so appending to a leaf doesn't overwrite the points of its neighbors
+			node.PointValue = all[current.lo:current.hi:current.hi]
+			continue
 		}
 
-	} else {
-		thisNode.Left = nil
-		thisNode.Right = nil
+		// split at the median, the points before it are at most its value on this axis, those after it at least
+		median := current.lo + (current.hi-current.lo)/2
+		selectNth(all[current.lo:current.hi], median-current.lo, current.axis)
+		node.PointValue = all[median : median+1 : median+1]
+
+		if median == current.lo {
+			// a single point, without children
+			continue
+		}
+
+		nextAxis := (current.axis + 1) % nmbAxis
+
+		nodes = append(nodes, Node{Parrent: node})
+		node.Left = &nodes[len(nodes)-1]
+		stack = append(stack, task{node.Left, current.lo, median, nextAxis, current.depth - 1})
+
+		if median+1 < current.hi {
+			nodes = append(nodes, Node{Parrent: node})
+			node.Right = &nodes[len(nodes)-1]
+			stack = append(stack, task{node.Right, median + 1, current.hi, nextAxis, current.depth - 1})
+		}
 	}
 
-	return &thisNode
+	return &nodes[0]
+}
+
+// selectNth reorders points so that the point at index n is the one that would be there if the points were sorted
+// on axis, with no larger values before it and no smaller values after it (the nth element selection of quickselect)
+func selectNth(points []Point, n, axis int) {
+	lo, hi := 0, len(points)-1
+
+	for lo < hi {
+		// the median of three as pivot, so that sorted points don't give the worst case
+		mid := lo + (hi-lo)/2
+		if points[mid].Dimension(axis) < points[lo].Dimension(axis) {
+			points[mid], points[lo] = points[lo], points[mid]
+		}
+		if points[hi].Dimension(axis) < points[lo].Dimension(axis) {
+			points[hi], points[lo] = points[lo], points[hi]
+		}
+		if points[hi].Dimension(axis) < points[mid].Dimension(axis) {
+			points[hi], points[mid] = points[mid], points[hi]
+		}
+		pivot := points[mid].Dimension(axis)
+
+		// Hoare partition: afterwards, points[lo:j+1] are at most pivot and points[j+1:hi+1] at least pivot
+		i, j := lo, hi
+		for i <= j {
+			for points[i].Dimension(axis) < pivot {
+				i++
+			}
+			for points[j].Dimension(axis) > pivot {
+				j--
+			}
+			if i <= j {
+				points[i], points[j] = points[j], points[i]
+				i++
+				j--
+			}
+		}
+
+		switch {
+		case n <= j:
+			hi = j
+		case n >= i:
+			lo = i
+		default:
+			// between j and i, every point equals the pivot
+			return
+		}
+	}
 }
 
 func (kd *KDTree) print() {
@@ -153,36 +291,7 @@ func (node *Node) print(level int) {
 	fmt.Println(space, "* [ENDNODE] *")
 }
 
-// goDownOneLevel returns the node that is one level down from the current node,
-// and closest to the given point
-func (node *Node) goDownOneLevel(point geom.Point, level int) (*Node, bool) {
-	var returnNode *Node
-	var returnCode bool
-	if point.Coordinates[level] < node.PointValue[0].Coordinates[level] {
-		if node.Left != nil {
-			returnNode = node.Left
-			returnCode = true
-		}
-	} else {
-		if node.Right != nil {
-			returnNode = node.Right
-			returnCode = true
-		} else if node.Left != nil {
-			returnNode = node.Left
-			returnCode = true
-		}
-
-	}
-	return returnNode, returnCode
-}
-
-func (node *Node) goUpOneLevel() *Node {
-	returnNode := node.Parrent
-
-	return returnNode
-}
-
-func (node *Node) leafs(leaf_vals *[][]geom.Point) {
+func (node *Node) leafs(leaf_vals *[][]Point) {
 	if len(node.PointValue) > 1 {
 		// this is a leaf node
 		*leaf_vals = append(*leaf_vals, node.PointValue)
@@ -195,100 +304,3 @@ func (node *Node) leafs(leaf_vals *[][]geom.Point) {
 		node.Right.leafs(leaf_vals)
 	}
 }
-
-func (kd *KDTree) findNearestNeighborTo(point geom.Point, distanceMetricFunction func(geom.Point, geom.Point) float64, nmbAxis int) (geom.Point, float64) {
-	var currentLevel int
-	var currentBest geom.Point
-	var currentNode *Node
-	var lastNode *Node
-	var exists bool
-	var currentBestDist float64
-
-	// first, traverse the entire tree until we reach a leafnode
-	currentNode = kd.Root
-
-	for !currentNode.isLeafNode() {
-		lastNode = currentNode
-		currentNode, exists = currentNode.goDownOneLevel(point, currentLevel%nmbAxis)
-
-		if !exists {
-			currentNode = lastNode
-			break
-		} else {
-			currentLevel++
-		}
-	}
-
-	// store the current best distance
-	currentBest = currentNode.PointValue[0]
-	currentBestDist = distanceMetricFunction(currentBest, point)
-
-	if kd.BestDist == -1 || currentBestDist < kd.BestDist {
-		kd.BestDist = currentBestDist
-	}
-
-	// now, go up the tree again, until we reach the rootnode again
-	// each time, check if the other branch might contain a better neighbor
-	//	and if the current node might be closer itself
-
-	for !currentNode.isRootNode() {
-		// go up one level, to the parent node
-		lastNode = currentNode
-		currentNode = (currentNode).goUpOneLevel()
-		currentLevel--
-
-		var hyperplanedist float64
-
-		if currentLevel < 0 {
-			break
-		}
-
-		hyperplanedist = math.Pow(float64(point.Coordinates[currentLevel%nmbAxis]-currentNode.PointValue[0].Coordinates[currentLevel%nmbAxis]), 2)
-
-		if kd.BestDist > hyperplanedist {
-			// the hypersphere intersects with the hyperplane
-			// thus the other branch side could contain a better neighbor!
-
-			// create a new kdtree, being the other branch
-			var newKd KDTree
-			var newRoot Node
-			// use the other branch!
-			if currentNode.Left == lastNode {
-				// came from Left branch
-
-				if currentNode.Right != nil {
-					newRoot = *currentNode.Right
-				} else {
-					// other side is empty!
-
-					goto noIntersect
-				}
-			} else if currentNode.Right == lastNode {
-				// came from Right branch
-				newRoot = *currentNode.Left
-			} else {
-				fmt.Println("!!!!!!!something went wrong in the left/right thing!!!!!!!!!")
-				time.Sleep(time.Second)
-			}
-			newRoot.Parrent = nil // make it a root node...
-			newKd.Root = &newRoot
-
-			otherBest, otherBestDist := newKd.findNearestNeighborTo(point, distanceMetricFunction, nmbAxis)
-			if otherBestDist < currentBestDist {
-				currentBest = otherBest
-				currentBestDist = otherBestDist
-				kd.BestDist = currentBestDist
-			}
-		}
-	noIntersect:
-		// lastly, check whether the currentNode itself (which is the parent of the last one, possibly a root!) is closer
-		otherBestDist := distanceMetricFunction(currentNode.PointValue[0], point)
-		if otherBestDist < currentBestDist {
-			currentBest = currentNode.PointValue[0]
-			currentBestDist = otherBestDist
-			kd.BestDist = currentBestDist
-		}
-	}
-
-	return currentBest, currentBestDist
-}
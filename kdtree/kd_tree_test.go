@@ -0,0 +1,295 @@
+package kdtree
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/mielpeeters/dither/geom"
+)
+
+// randomPoints returns n points with random coordinates from 0 to 255, in the given amount of dimensions
+func randomPoints(n, dimensions int, rng *rand.Rand) []Point {
+	points := geom.PointSet{}
+	for i := 0; i < n; i++ {
+		point := geom.Point{ID: i}
+		for d := 0; d < dimensions; d++ {
+			point.Coordinates = append(point.Coordinates, float32(rng.Intn(256)))
+		}
+		points.Points = append(points.Points, point)
+	}
+
+	return FromGeom(points)
+}
+
+// TestKNN checks that KNN finds the same distances as comparing against every point
+func TestKNN(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(1000, 3, rng)
+	kd := New(points)
+
+	for _, k := range []int{1, 5, 50} {
+		for i := 0; i < 100; i++ {
+			query := randomPoints(1, 3, rng)[0]
+
+			distances := []float64{}
+			for _, point := range points {
+				distances = append(distances, SquaredDistance(point, query))
+			}
+			sort.Float64s(distances)
+
+			neighbors := kd.KNN(query, k, SquaredDistance)
+			if len(neighbors) != k {
+				t.Fatalf("expected %d neighbors, got %d", k, len(neighbors))
+			}
+			for j, neighbor := range neighbors {
+				if neighbor.Distance != distances[j] {
+					t.Fatalf("k=%d, %v: neighbor %d has distance %v, want %v", k, query, j, neighbor.Distance, distances[j])
+				}
+			}
+		}
+	}
+}
+
+// TestRangeSearch checks that the range and radius searches find the same points as checking every point
+func TestRangeSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(1000, 2, rng)
+	kd := New(points)
+
+	for i := 0; i < 100; i++ {
+		corners := randomPoints(2, 2, rng)
+		lower := []float64{corners[0].Dimension(0), corners[0].Dimension(1)}
+		upper := []float64{corners[1].Dimension(0), corners[1].Dimension(1)}
+		for axis := range lower {
+			if lower[axis] > upper[axis] {
+				lower[axis], upper[axis] = upper[axis], lower[axis]
+			}
+		}
+
+		want := 0
+		for _, point := range points {
+			if inBox(point, lower, upper) {
+				want++
+			}
+		}
+		if got := len(kd.RangeSearch(lower, upper)); got != want {
+			t.Fatalf("box %v-%v: got %d points, want %d", lower, upper, got, want)
+		}
+
+		center := corners[0]
+		radius := float64(rng.Intn(2000))
+		want = 0
+		for _, point := range points {
+			if SquaredDistance(point, center) <= radius {
+				want++
+			}
+		}
+		if got := len(kd.RadiusSearch(center, radius, SquaredDistance)); got != want {
+			t.Fatalf("radius %v around %v: got %d points, want %d", radius, center, got, want)
+		}
+	}
+}
+
+// TestInsertDelete checks that the tree keeps finding the right points while points are inserted and deleted
+func TestInsertDelete(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(500, 2, rng)
+	kd := New(points[:100])
+
+	// insert the other points in sorted order, the worst case for the balance of the tree
+	rest := append([]Point{}, points[100:]...)
+	sort.Slice(rest, func(i, j int) bool {
+		return rest[i].Dimension(0) < rest[j].Dimension(0)
+	})
+	for _, point := range rest {
+		kd.Insert(point)
+	}
+	if kd.Len() != 500 {
+		t.Fatalf("expected 500 points after inserting, got %d", kd.Len())
+	}
+
+	// delete every other point, by value or by ID
+	kept := []Point{}
+	for i, point := range points {
+		switch {
+		case i%4 == 0:
+			if !kd.Delete(point) {
+				t.Fatalf("point %v was not found", point)
+			}
+		case i%2 == 0:
+			if !kd.DeleteID(i) {
+				t.Fatalf("point with ID %d was not found", i)
+			}
+		default:
+			kept = append(kept, point)
+		}
+	}
+	for i, point := range points {
+		if found, ok := kd.Get(i); ok != (i%2 == 1) || (ok && found != point) {
+			t.Fatalf("ID %d: got %v, %v", i, found, ok)
+		}
+	}
+	if kd.Delete(points[0]) {
+		t.Fatalf("a deleted point was found again")
+	}
+
+	kd.Rebalance()
+	if depth := kd.Depth(); depth > RebalanceFactor*8 {
+		t.Errorf("the tree is still %d levels deep after rebalancing", depth)
+	}
+
+	for i := 0; i < 100; i++ {
+		query := randomPoints(1, 2, rng)[0]
+
+		best := -1.0
+		for _, point := range kept {
+			if dist := SquaredDistance(point, query); best < 0 || dist < best {
+				best = dist
+			}
+		}
+
+		if neighbors := kd.KNN(query, 1, SquaredDistance); len(neighbors) != 1 || neighbors[0].Distance != best {
+			t.Fatalf("%v: got neighbors %v, want distance %v", query, neighbors, best)
+		}
+	}
+}
+
+// TestConcurrentNearest checks that goroutines searching the same tree at once all find the right points
+func TestConcurrentNearest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(1000, 3, rng)
+	queries := randomPoints(400, 3, rng)
+	kd := New(points)
+
+	want := make([]float64, len(queries))
+	for i, query := range queries {
+		want[i] = -1
+		for _, point := range points {
+			if dist := SquaredDistance(point, query); want[i] < 0 || dist < want[i] {
+				want[i] = dist
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, len(queries))
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(queries); i += 8 {
+				if nearest, ok := kd.Nearest(queries[i], SquaredDistance); !ok || nearest.Distance != want[i] {
+					errs <- fmt.Sprintf("query %d: got %v, want distance %v", i, nearest, want[i])
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestEncodeDecode checks that a decoded tree holds the same points and finds the same neighbors
+func TestEncodeDecode(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	kd := New(randomPoints(500, 3, rng))
+	for _, point := range randomPoints(100, 3, rng) {
+		kd.Insert(point)
+	}
+
+	var buf bytes.Buffer
+	if err := kd.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Len() != kd.Len() || decoded.Depth() != kd.Depth() {
+		t.Fatalf("decoded tree has %d points in %d levels, want %d in %d", decoded.Len(), decoded.Depth(), kd.Len(), kd.Depth())
+	}
+
+	for _, query := range randomPoints(100, 3, rng) {
+		got, want := decoded.KNN(query, 5, SquaredDistance), kd.KNN(query, 5, SquaredDistance)
+		for i := range want {
+			if got[i].Distance != want[i].Distance {
+				t.Fatalf("%v: neighbor %d has distance %v, want %v", query, i, got[i].Distance, want[i].Distance)
+			}
+		}
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte("not a tree"))); err == nil {
+		t.Errorf("expected an error decoding garbage")
+	}
+}
+
+// TestMetrics checks that the searches find the same points as comparing against every point, for metrics
+// with their own Plane, on many random trees
+func TestMetrics(t *testing.T) {
+	geomMetric := func(metric func(pnt1, pnt2 *geom.Point) float64) func(Point, Point) float64 {
+		return func(pnt1, pnt2 Point) float64 {
+			return metric(&pnt1.(*GeomPoint).Point, &pnt2.(*GeomPoint).Point)
+		}
+	}
+
+	metrics := map[string]Metric{
+		"squared": Squared,
+		// the alpha coordinate varies too, which redmean ignores
+		"redmean": {geomMetric(geom.RedMeanDistance), RedMeanPlane},
+		"manhattan": {func(pnt1, pnt2 Point) float64 {
+			var dist float64
+			for i := 0; i < pnt1.Dimensions(); i++ {
+				dist += math.Abs(pnt1.Dimension(i) - pnt2.Dimension(i))
+			}
+			return dist
+		}, func(axis int, diff float64) float64 {
+			return math.Abs(diff)
+		}},
+		// only the distance of the first coordinate, without a plane nothing is skipped
+		"unbounded": {func(pnt1, pnt2 Point) float64 {
+			return math.Abs(pnt1.Dimension(0) - pnt2.Dimension(0))
+		}, nil},
+	}
+
+	for name, metric := range metrics {
+		for seed := int64(1); seed <= 20; seed++ {
+			rng := rand.New(rand.NewSource(seed))
+			points := randomPoints(1+rng.Intn(300), 4, rng)
+			kd := New(points)
+
+			for _, query := range randomPoints(20, 4, rng) {
+				distances := []float64{}
+				for _, point := range points {
+					distances = append(distances, metric.Distance(point, query))
+				}
+				sort.Float64s(distances)
+
+				k := 1 + rng.Intn(len(points))
+				for i, neighbor := range kd.KNNMetric(query, k, metric) {
+					if neighbor.Distance != distances[i] {
+						t.Fatalf("%s, seed %d: neighbor %d of %v has distance %v, want %v", name, seed, i, query, neighbor.Distance, distances[i])
+					}
+				}
+
+				if nearest, ok := kd.NearestMetric(query, metric); !ok || nearest.Distance != distances[0] {
+					t.Fatalf("%s, seed %d: the nearest point of %v is at %v, want %v", name, seed, query, nearest.Distance, distances[0])
+				}
+
+				radius := distances[rng.Intn(len(distances))]
+				want := sort.SearchFloat64s(distances, math.Nextafter(radius, math.Inf(1)))
+				if got := len(kd.RadiusSearchMetric(query, radius, metric)); got != want {
+					t.Fatalf("%s, seed %d: got %d points within %v of %v, want %d", name, seed, got, radius, query, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package kdtree
+
+import "math"
+
+// Neighbor is a point found by a search of a KDTree, with its distance to the point that was searched for
+type Neighbor struct {
+	Point    Point
+	Distance float64
+}
+
+// KNN returns the k points of the tree that are closest to point according to metric, from closest to farthest.
+// The tree skips branches based on the squared difference along the splitting axis, so metric needs to be at least
+// that large (like SquaredDistance is). For other metrics, use KNNMetric.
+func (kd *KDTree) KNN(point Point, k int, metric func(Point, Point) float64) []Neighbor {
+	return kd.KNNMetric(point, k, Metric{metric, SquaredPlane})
+}
+
+// KNNMetric is KNN, skipping branches based on the Plane of metric
+func (kd *KDTree) KNNMetric(point Point, k int, metric Metric) []Neighbor {
+	neighbors := []Neighbor{}
+	if kd.Root == nil || k < 1 {
+		return neighbors
+	}
+
+	kd.Root.knn(point, 0, k, metric, &neighbors)
+
+	return neighbors
+}
+
+// Nearest returns the point of the tree that is closest to point according to metric (see KNN),
+// or false if the tree is empty
+func (kd *KDTree) Nearest(point Point, metric func(Point, Point) float64) (Neighbor, bool) {
+	return kd.NearestMetric(point, Metric{metric, SquaredPlane})
+}
+
+// NearestMetric is Nearest, skipping branches based on the Plane of metric
+func (kd *KDTree) NearestMetric(point Point, metric Metric) (Neighbor, bool) {
+	if kd.Root == nil {
+		return Neighbor{}, false
+	}
+
+	// like KNN with k = 1, without keeping a list of neighbors
+	nearest := Neighbor{Distance: math.Inf(1)}
+	kd.Root.nearest(point, 0, metric, &nearest)
+
+	return nearest, nearest.Point != nil
+}
+
+// nearest replaces nearest with the closest point of the subtree at node, if it is closer.
+// axis is the axis the node splits on.
+func (node *Node) nearest(point Point, axis int, metric Metric, nearest *Neighbor) {
+	for _, candidate := range node.PointValue {
+		if dist := metric.Distance(candidate, point); dist < nearest.Distance || nearest.Point == nil {
+			*nearest = Neighbor{candidate, dist}
+		}
+	}
+
+	if node.isLeafNode() {
+		return
+	}
+
+	nextAxis := (axis + 1) % point.Dimensions()
+	diff := point.Dimension(axis) - node.PointValue[0].Dimension(axis)
+
+	near, far := node.Left, node.Right
+	if diff >= 0 {
+		near, far = far, near
+	}
+
+	if near != nil {
+		near.nearest(point, nextAxis, metric, nearest)
+	}
+	if far != nil && metric.bound(axis, diff) <= nearest.Distance {
+		far.nearest(point, nextAxis, metric, nearest)
+	}
+}
+
+// knn adds the points of the subtree at node that are closer than the current neighbors, keeping the k closest.
+// axis is the axis the node splits on.
+func (node *Node) knn(point Point, axis, k int, metric Metric, neighbors *[]Neighbor) {
+	for _, candidate := range node.PointValue {
+		addNeighbor(neighbors, Neighbor{candidate, metric.Distance(candidate, point)}, k)
+	}
+
+	if node.isLeafNode() {
+		return
+	}
+
+	nextAxis := (axis + 1) % point.Dimensions()
+	diff := point.Dimension(axis) - node.PointValue[0].Dimension(axis)
+
+	near, far := node.Left, node.Right
+	if diff >= 0 {
+		near, far = far, near
+	}
+
+	if near != nil {
+		near.knn(point, nextAxis, k, metric, neighbors)
+	}
+
+	// the other side can only hold a closer point if the splitting plane is close enough
+	if far != nil && (len(*neighbors) < k || metric.bound(axis, diff) <= (*neighbors)[len(*neighbors)-1].Distance) {
+		far.knn(point, nextAxis, k, metric, neighbors)
+	}
+}
+
+// addNeighbor inserts neighbor in the sorted neighbors, if it is one of the k closest
+func addNeighbor(neighbors *[]Neighbor, neighbor Neighbor, k int) {
+	list := *neighbors
+	if len(list) == k && neighbor.Distance >= list[k-1].Distance {
+		return
+	}
+
+	position := len(list)
+	for position > 0 && list[position-1].Distance > neighbor.Distance {
+		position--
+	}
+
+	if len(list) < k {
+		list = append(list, Neighbor{})
+	}
+	copy(list[position+1:], list[position:])
+	list[position] = neighbor
+
+	*neighbors = list
+}
@@ -0,0 +1,50 @@
+package kdtree
+
+// Identified is a Point with an ID, by which it can be found in the Lookup of a KDTree
+type Identified interface {
+	Point
+	// PointID returns the ID of the point, which is unique within the tree
+	PointID() int
+}
+
+// PointID returns the ID of the geom.Point
+func (point *GeomPoint) PointID() int {
+	return point.ID
+}
+
+// Get returns the point of the tree with the given ID (see Identified), and whether it is in the tree
+func (kd *KDTree) Get(id int) (Point, bool) {
+	point, ok := kd.Lookup[id]
+	return point, ok
+}
+
+// DeleteID removes the point with the given ID from the tree, and reports whether it was found.
+// Like Delete, it needs the coordinates of the point to be the same as when the tree was last built.
+func (kd *KDTree) DeleteID(id int) bool {
+	point, ok := kd.Lookup[id]
+	if !ok {
+		return false
+	}
+
+	return kd.Delete(point)
+}
+
+// addLookup adds point to the Lookup, if it has an ID
+func (kd *KDTree) addLookup(point Point) {
+	identified, ok := point.(Identified)
+	if !ok {
+		return
+	}
+
+	if kd.Lookup == nil {
+		kd.Lookup = map[int]Point{}
+	}
+	kd.Lookup[identified.PointID()] = point
+}
+
+// removeLookup removes point from the Lookup, if it has an ID
+func (kd *KDTree) removeLookup(point Point) {
+	if identified, ok := point.(Identified); ok && kd.Lookup[identified.PointID()] == point {
+		delete(kd.Lookup, identified.PointID())
+	}
+}
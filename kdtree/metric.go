@@ -0,0 +1,44 @@
+package kdtree
+
+// Metric is a distance function for the searches of a KDTree, together with how far apart two points
+// need to be at least, given how much they differ along one axis. The searches use that to skip the branches
+// on the other side of a splitting plane, so it needs to be a lower bound of the distance,
+// or the searches can miss the closest points.
+type Metric struct {
+	// Distance returns the distance of two points
+	Distance func(Point, Point) float64
+	// Plane returns (a lower bound on) the distance of two points that differ by diff along axis.
+	// If it is nil, no branch is skipped, which is correct for any distance function, but slow.
+	Plane func(axis int, diff float64) float64
+}
+
+// bound returns the lower bound on the distance of two points that differ by diff along axis
+func (metric Metric) bound(axis int, diff float64) float64 {
+	if metric.Plane == nil {
+		return 0
+	}
+
+	return metric.Plane(axis, diff)
+}
+
+// Squared is the Metric of SquaredDistance
+var Squared = Metric{SquaredDistance, SquaredPlane}
+
+// SquaredPlane is the Plane of squared euclidean distances, and of all distances that are at least as large
+func SquaredPlane(axis int, diff float64) float64 {
+	return diff * diff
+}
+
+// RedMeanPlane is the Plane of geom.RedMeanDistance, for color points: it weighs the squared difference
+// in red and blue at least 2 times, and in green 4 times. It ignores the other coordinates (like alpha),
+// so they give no bound.
+func RedMeanPlane(axis int, diff float64) float64 {
+	switch axis {
+	case 0, 2:
+		return 2 * diff * diff
+	case 1:
+		return 4 * diff * diff
+	}
+
+	return 0
+}
@@ -0,0 +1,329 @@
+package kdtree
+
+import (
+	"encoding/gob"
+	"errors"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// PaletteIndex is a k-d tree over the colors of a palette, which finds the closest palette color
+// without comparing against every color of the palette.
+// The tree is stored as flat slices, so it can be saved with Encode and loaded again with DecodePaletteIndex,
+// instead of being rebuilt on every run. It is never changed after creation, so it can be queried concurrently.
+type PaletteIndex struct {
+	colors [][4]uint32
+	nodes  []paletteNode
+	// epsilon and maxVisits make the search approximate, see Approximate
+	epsilon   float64
+	maxVisits int
+}
+
+// paletteNode is one node of the flattened tree: a palette color, the axis it splits on
+// and the positions of its children in the nodes slice (-1 if there is none)
+type paletteNode struct {
+	Color       int32
+	Axis        uint8
+	Left, Right int32
+}
+
+// paletteIndexData is the encoded form of a PaletteIndex
+type paletteIndexData struct {
+	Colors [][4]uint32
+	Nodes  []paletteNode
+}
+
+// NewPaletteIndex builds the index of palette
+func NewPaletteIndex(palette color.Palette) *PaletteIndex {
+	index := PaletteIndex{
+		colors: make([][4]uint32, len(palette)),
+	}
+
+	order := make([]int, len(palette))
+	for i, clr := range palette {
+		index.colors[i] = rgba(clr)
+		order[i] = i
+	}
+
+	index.build(order)
+
+	return &index
+}
+
+// build adds the subtree of the given palette colors to the nodes, splitting on the axis with the largest spread,
+// and returns its position
+func (index *PaletteIndex) build(order []int) int32 {
+	if len(order) == 0 {
+		return -1
+	}
+
+	var axis uint8
+	var spread uint32
+	for a := 0; a < 4; a++ {
+		low, high := index.colors[order[0]][a], index.colors[order[0]][a]
+		for _, i := range order {
+			if index.colors[i][a] < low {
+				low = index.colors[i][a]
+			}
+			if index.colors[i][a] > high {
+				high = index.colors[i][a]
+			}
+		}
+		if high-low > spread {
+			axis = uint8(a)
+			spread = high - low
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		return index.colors[order[i]][axis] < index.colors[order[j]][axis]
+	})
+	median := len(order) / 2
+
+	position := int32(len(index.nodes))
+	index.nodes = append(index.nodes, paletteNode{Color: int32(order[median]), Axis: axis})
+
+	left := index.build(order[:median])
+	right := index.build(order[median+1:])
+	index.nodes[position].Left = left
+	index.nodes[position].Right = right
+
+	return position
+}
+
+// Len returns the amount of palette colors in the index
+func (index *PaletteIndex) Len() int {
+	return len(index.colors)
+}
+
+// Matches reports whether the index was built for (the colors of) palette
+func (index *PaletteIndex) Matches(palette color.Palette) bool {
+	if len(palette) != len(index.colors) {
+		return false
+	}
+
+	for i, clr := range palette {
+		if rgba(clr) != index.colors[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Index returns the index of the palette color closest to clr, in the same way color.Palette.Index does
+func (index *PaletteIndex) Index(clr color.Color) int {
+	if len(index.nodes) == 0 {
+		return 0
+	}
+
+	target := rgba(clr)
+	if index.maxVisits > 0 {
+		return index.approximateSearch(target)
+	}
+
+	best, bestDist := -1, uint32(1<<32-1)
+	limit := bestDist
+
+	index.search(0, target, &best, &bestDist, &limit)
+
+	return best
+}
+
+// Approximate returns an index with the same tree, of which Index may return a palette color that is up to
+// a factor 1 + epsilon farther away than the closest one, and which compares against at most maxVisits
+// palette colors (0 for no limit). It searches the most promising branches first (best bin first),
+// so even a small maxVisits mostly finds the closest color. For dithering, a color that is 1 or 2% farther
+// away is invisible, while the lookups in large palettes get a lot faster (mostly from limiting the visits,
+// the exact search already skips most of the tree).
+func (index *PaletteIndex) Approximate(epsilon float64, maxVisits int) *PaletteIndex {
+	approximate := *index
+	approximate.epsilon = epsilon
+	approximate.maxVisits = maxVisits
+
+	return &approximate
+}
+
+// branch is a subtree that approximateSearch still has to visit, with the (squared, shifted) distance
+// from the target to its splitting plane
+type branch struct {
+	position int32
+	bound    uint32
+}
+
+// limit returns the distance to the splitting plane beyond which a branch can't hold a palette color that is
+// more than a factor 1 + epsilon closer than bestDist
+func (index *PaletteIndex) limit(bestDist uint32) uint32 {
+	if index.epsilon <= 0 {
+		return bestDist
+	}
+
+	return uint32(float64(bestDist) / ((1 + index.epsilon) * (1 + index.epsilon)))
+}
+
+// approximateSearch looks for the closest palette color within maxVisits colors, visiting the branches
+// closest to target first
+func (index *PaletteIndex) approximateSearch(target [4]uint32) int {
+	best, bestDist := -1, uint32(1<<32-1)
+	limit := bestDist
+	visits := 0
+
+	// a queue on the stack, it only grows on the heap for deep trees
+	var storage [64]branch
+	queue := append(storage[:0], branch{0, 0})
+	for len(queue) > 0 {
+		var next branch
+		next, queue = popBranch(queue)
+		if next.bound > limit {
+			// the other branches are even farther away
+			break
+		}
+
+		for position := next.position; position >= 0; {
+			if index.maxVisits > 0 && visits >= index.maxVisits {
+				return best
+			}
+			visits++
+
+			node := index.nodes[position]
+			clr := index.colors[node.Color]
+
+			dist := uint32(0)
+			for a := range clr {
+				dist += sqDiff(clr[a], target[a])
+			}
+			if dist < bestDist || (dist == bestDist && int(node.Color) < best) {
+				best = int(node.Color)
+				bestDist = dist
+				limit = index.limit(bestDist)
+			}
+
+			near, far := node.Left, node.Right
+			if target[node.Axis] >= clr[node.Axis] {
+				near, far = far, near
+			}
+			// only keep the other side if it can hold a closer color, bestDist only gets smaller
+			if bound := sqDiff(clr[node.Axis], target[node.Axis]); far >= 0 && bound <= limit {
+				queue = pushBranch(queue, branch{far, bound})
+			}
+			position = near
+		}
+	}
+
+	return best
+}
+
+// pushBranch adds b to the binary min-heap of branches, and returns the heap
+func pushBranch(queue []branch, b branch) []branch {
+	heap := append(queue, b)
+	for i := len(heap) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if heap[parent].bound <= heap[i].bound {
+			break
+		}
+		heap[parent], heap[i] = heap[i], heap[parent]
+		i = parent
+	}
+
+	return heap
+}
+
+// popBranch removes the branch with the smallest bound from the binary min-heap of branches,
+// and returns it together with the heap
+func popBranch(heap []branch) (branch, []branch) {
+	top := heap[0]
+	last := len(heap) - 1
+	heap[0] = heap[last]
+	heap = heap[:last]
+
+	for i := 0; ; {
+		smallest := i
+		if left := 2*i + 1; left < len(heap) && heap[left].bound < heap[smallest].bound {
+			smallest = left
+		}
+		if right := 2*i + 2; right < len(heap) && heap[right].bound < heap[smallest].bound {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+		heap[i], heap[smallest] = heap[smallest], heap[i]
+		i = smallest
+	}
+
+	return top, heap
+}
+
+// search looks for a closer palette color than best in the subtree at position, skipping the branches
+// that are farther away than limit
+func (index *PaletteIndex) search(position int32, target [4]uint32, best *int, bestDist, limit *uint32) {
+	if position < 0 {
+		return
+	}
+
+	node := index.nodes[position]
+	clr := index.colors[node.Color]
+
+	dist := uint32(0)
+	for a := range clr {
+		dist += sqDiff(clr[a], target[a])
+	}
+	// on a tie, the lowest palette index wins, like in color.Palette.Index
+	if dist < *bestDist || (dist == *bestDist && int(node.Color) < *best) {
+		*best = int(node.Color)
+		*bestDist = dist
+		*limit = index.limit(dist)
+	}
+
+	near, far := node.Left, node.Right
+	if target[node.Axis] >= clr[node.Axis] {
+		near, far = far, near
+	}
+
+	index.search(near, target, best, bestDist, limit)
+
+	// the other side can only hold a closer color if the splitting plane is close enough
+	if sqDiff(clr[node.Axis], target[node.Axis]) <= *limit {
+		index.search(far, target, best, bestDist, limit)
+	}
+}
+
+// Encode writes the index to w, to be read again with DecodePaletteIndex
+func (index *PaletteIndex) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(paletteIndexData{index.colors, index.nodes})
+}
+
+// DecodePaletteIndex reads an index that was written with Encode
+func DecodePaletteIndex(r io.Reader) (*PaletteIndex, error) {
+	var data paletteIndexData
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	// children always come after their parent, which also rules out cycles
+	validChild := func(child int32, parent int) bool {
+		return child == -1 || (int(child) > parent && int(child) < len(data.Nodes))
+	}
+
+	for i, node := range data.Nodes {
+		if node.Color < 0 || int(node.Color) >= len(data.Colors) || node.Axis > 3 ||
+			!validChild(node.Left, i) || !validChild(node.Right, i) {
+			return nil, errors.New("kdtree: invalid palette index")
+		}
+	}
+
+	return &PaletteIndex{colors: data.Colors, nodes: data.Nodes}, nil
+}
+
+func rgba(clr color.Color) [4]uint32 {
+	r, g, b, a := clr.RGBA()
+	return [4]uint32{r, g, b, a}
+}
+
+// sqDiff returns the squared difference of x and y, shifted right by 2 like in the image/color package,
+// so that the sum of four of them fits in a uint32
+func sqDiff(x, y uint32) uint32 {
+	d := x - y
+	return (d * d) >> 2
+}
@@ -0,0 +1,112 @@
+package kdtree
+
+import (
+	"bytes"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// TestPaletteIndex checks that an encoded and decoded index finds the same colors as color.Palette.Index
+func TestPaletteIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomColor := func() color.Color {
+		return color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+	}
+
+	for _, size := range []int{1, 2, 16, 256} {
+		palette := color.Palette{}
+		for i := 0; i < size; i++ {
+			palette = append(palette, randomColor())
+		}
+
+		var buf bytes.Buffer
+		if err := NewPaletteIndex(palette).Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		index, err := DecodePaletteIndex(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !index.Matches(palette) {
+			t.Fatalf("decoded index doesn't match its palette of %d colors", size)
+		}
+
+		for i := 0; i < 1000; i++ {
+			clr := randomColor()
+			if got, want := index.Index(clr), palette.Index(clr); got != want {
+				t.Fatalf("palette of %d colors, %v: got index %d, want %d", size, clr, got, want)
+			}
+		}
+	}
+}
+
+// TestApproximatePaletteIndex checks that the approximate index finds colors within the error bound,
+// and valid colors when it is limited in visits
+func TestApproximatePaletteIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomColor := func() color.Color {
+		return color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+	}
+	distance := func(clr1, clr2 color.Color) float64 {
+		c1, c2 := rgba(clr1), rgba(clr2)
+		dist := 0.0
+		for a := range c1 {
+			dist += float64(sqDiff(c1[a], c2[a]))
+		}
+		return dist
+	}
+
+	palette := color.Palette{}
+	for i := 0; i < 256; i++ {
+		palette = append(palette, randomColor())
+	}
+	exact := NewPaletteIndex(palette)
+
+	for _, epsilon := range []float64{0.01, 0.1, 1} {
+		index := exact.Approximate(epsilon, 0)
+		for i := 0; i < 1000; i++ {
+			clr := randomColor()
+			got, want := distance(palette[index.Index(clr)], clr), distance(palette[exact.Index(clr)], clr)
+			if got > want*(1+epsilon)*(1+epsilon) {
+				t.Fatalf("epsilon %v, %v: got a color at distance %v, the closest is at %v", epsilon, clr, got, want)
+			}
+		}
+	}
+
+	index := exact.Approximate(0, 8)
+	for i := 0; i < 1000; i++ {
+		if got := index.Index(randomColor()); got < 0 || got >= len(palette) {
+			t.Fatalf("got index %d for a palette of %d colors", got, len(palette))
+		}
+	}
+}
+
+func BenchmarkPaletteIndex(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	palette := color.Palette{}
+	for i := 0; i < 256; i++ {
+		palette = append(palette, color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255})
+	}
+	colors := make([]color.Color, 1024)
+	for i := range colors {
+		colors[i] = color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+	}
+
+	exact := NewPaletteIndex(palette)
+	indexes := map[string]*PaletteIndex{
+		"exact":        exact,
+		"epsilon=0.02": exact.Approximate(0.02, 0),
+		"visits=16":    exact.Approximate(0, 16),
+		"epsilon=0.2":  exact.Approximate(0.2, 0),
+		"visits=6":     exact.Approximate(0, 6),
+	}
+	for name, index := range indexes {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				index.Index(colors[i%len(colors)])
+			}
+		})
+	}
+}
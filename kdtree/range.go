@@ -0,0 +1,113 @@
+package kdtree
+
+import (
+	"sort"
+
+	"github.com/mielpeeters/dither/geom"
+)
+
+// RangeSearch returns the points of the tree within the axis-aligned box from lower to upper (inclusive),
+// which hold one bound for each dimension
+func (kd *KDTree) RangeSearch(lower, upper []float64) []Point {
+	found := []Point{}
+	if kd.Root != nil {
+		kd.Root.rangeSearch(lower, upper, 0, &found)
+	}
+
+	return found
+}
+
+// RangeSearchBox returns the points of the tree within box, like RangeSearch
+func (kd *KDTree) RangeSearchBox(box geom.BoundingBox) []Point {
+	if box.Empty() {
+		return []Point{}
+	}
+
+	return kd.RangeSearch(box.Lower, box.Upper)
+}
+
+// rangeSearch adds the points of the subtree at node that are within the box to found.
+// axis is the axis the node splits on.
+func (node *Node) rangeSearch(lower, upper []float64, axis int, found *[]Point) {
+	for _, candidate := range node.PointValue {
+		if inBox(candidate, lower, upper) {
+			*found = append(*found, candidate)
+		}
+	}
+
+	if node.isLeafNode() {
+		return
+	}
+
+	nextAxis := (axis + 1) % len(lower)
+	pivot := node.PointValue[0].Dimension(axis)
+
+	// points equal to the pivot can end up on both sides
+	if node.Left != nil && lower[axis] <= pivot {
+		node.Left.rangeSearch(lower, upper, nextAxis, found)
+	}
+	if node.Right != nil && upper[axis] >= pivot {
+		node.Right.rangeSearch(lower, upper, nextAxis, found)
+	}
+}
+
+// inBox reports whether the point lies within the box from lower to upper
+func inBox(point Point, lower, upper []float64) bool {
+	for axis := 0; axis < point.Dimensions(); axis++ {
+		if value := point.Dimension(axis); value < lower[axis] || value > upper[axis] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RadiusSearch returns the points of the tree within radius of point according to metric, from closest to farthest.
+// Like in KNN, metric needs to be at least the squared difference along any axis, and radius is in the units
+// of metric (so it is a squared radius for squared distances). For other metrics, use RadiusSearchMetric.
+func (kd *KDTree) RadiusSearch(point Point, radius float64, metric func(Point, Point) float64) []Neighbor {
+	return kd.RadiusSearchMetric(point, radius, Metric{metric, SquaredPlane})
+}
+
+// RadiusSearchMetric is RadiusSearch, skipping branches based on the Plane of metric
+func (kd *KDTree) RadiusSearchMetric(point Point, radius float64, metric Metric) []Neighbor {
+	found := []Neighbor{}
+	if kd.Root != nil {
+		kd.Root.radiusSearch(point, radius, 0, metric, &found)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Distance < found[j].Distance
+	})
+
+	return found
+}
+
+// radiusSearch adds the points of the subtree at node that are within radius of point to found.
+// axis is the axis the node splits on.
+func (node *Node) radiusSearch(point Point, radius float64, axis int, metric Metric, found *[]Neighbor) {
+	for _, candidate := range node.PointValue {
+		if dist := metric.Distance(candidate, point); dist <= radius {
+			*found = append(*found, Neighbor{candidate, dist})
+		}
+	}
+
+	if node.isLeafNode() {
+		return
+	}
+
+	nextAxis := (axis + 1) % point.Dimensions()
+	diff := point.Dimension(axis) - node.PointValue[0].Dimension(axis)
+
+	near, far := node.Left, node.Right
+	if diff >= 0 {
+		near, far = far, near
+	}
+
+	if near != nil {
+		near.radiusSearch(point, radius, nextAxis, metric, found)
+	}
+	if far != nil && metric.bound(axis, diff) <= radius {
+		far.radiusSearch(point, radius, nextAxis, metric, found)
+	}
+}
@@ -0,0 +1,96 @@
+package kmeans
+
+import (
+	"github.com/mielpeeters/dither/geom"
+)
+
+// Bisect performs bisecting k-means instead of starting from random means: starting with one cluster of all points,
+// the cluster with the highest inertia is split in two (with 2-means, started from its two points that are farthest
+// apart), until there are k clusters. Their means are then refined with Cluster, whose result is returned.
+// The splits don't depend on random starts, which makes the result more stable, and it suits skewed distributions
+// (like the colors of a night photo) better than random restarts. Pinned means are kept, the others are replaced.
+func (KM *Clustering) Bisect(accuracy float64, consecutiveTimes int) Result {
+	clusters := []geom.PointSet{{Points: append([]geom.Point{}, KM.points.Points...)}}
+	means := []geom.Point{clusters[0].Mean()}
+	// clusters that can't be split, as all of their points are the same
+	unsplittable := map[int]bool{}
+
+	for len(clusters) < KM.k-KM.pinned {
+		worst, worstInertia := -1, 0.0
+		for i := range clusters {
+			if unsplittable[i] || len(clusters[i].Points) < 2 {
+				continue
+			}
+
+			if inertia := KM.setInertia(&clusters[i], &means[i]); inertia > worstInertia {
+				worst, worstInertia = i, inertia
+			}
+		}
+		if worst < 0 {
+			break
+		}
+
+		halves, halfMeans, ok := KM.split(clusters[worst], means[worst], accuracy, consecutiveTimes)
+		if !ok {
+			unsplittable[worst] = true
+			continue
+		}
+
+		clusters[worst], means[worst] = halves[0], halfMeans[0]
+		clusters = append(clusters, halves[1])
+		means = append(means, halfMeans[1])
+	}
+
+	KM.Start(means...)
+
+	return KM.Cluster(accuracy, consecutiveTimes)
+}
+
+// setInertia returns the summed distance of the points of the set to mean, weighted by their Mass
+func (KM *Clustering) setInertia(set *geom.PointSet, mean *geom.Point) float64 {
+	var inertia float64
+	for i := range set.Points {
+		inertia += KM.distanceMetric(mean, &set.Points[i]) * float64(set.Points[i].Mass())
+	}
+
+	return inertia
+}
+
+// split divides the set in two with 2-means, and returns both halves with their means.
+// It returns false if the set can't be divided.
+func (KM *Clustering) split(set geom.PointSet, mean geom.Point, accuracy float64, consecutiveTimes int) ([2]geom.PointSet, [2]geom.Point, bool) {
+	var halves [2]geom.PointSet
+	var means [2]geom.Point
+
+	// start from the point farthest from the mean, and the point farthest from that one
+	first, _ := KM.farthestPoint(&set, &mean)
+	second, dist := KM.farthestPoint(&set, &first)
+	if dist <= 0 {
+		return halves, means, false
+	}
+
+	sub, err := NewClustering(set, 2, KM.distanceMetric, KM.rng)
+	if err != nil {
+		return halves, means, false
+	}
+	sub.options = KM.options
+	// dropping one of the two means would leave nothing to split
+	if sub.options.Empty == EmptyDrop {
+		sub.options.Empty = EmptyFarthest
+	}
+	sub.Start(first, second)
+	sub.Cluster(accuracy, consecutiveTimes)
+
+	// the clusters of sub only hold its last mini batch, divide all points over the two means
+	for _, point := range set.Points {
+		closest := sub.closestMean(&point, nil)
+		halves[closest].Points = append(halves[closest].Points, point)
+	}
+	if len(halves[0].Points) == 0 || len(halves[1].Points) == 0 {
+		return halves, means, false
+	}
+
+	means[0], means[1] = halves[0].Mean(), halves[1].Mean()
+
+	return halves, means, true
+}
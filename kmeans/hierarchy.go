@@ -0,0 +1,117 @@
+package kmeans
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mielpeeters/dither/geom"
+)
+
+// Dendrogram is the tree of clusters made by Agglomerate. Cutting it at any amount of clusters gives
+// nested clusterings: every cluster of a cut is a union of clusters of the cuts with more clusters.
+type Dendrogram struct {
+	points geom.PointSet
+	// merges holds the two clusters that were merged in each step. Clusters 0 up to len(points) are the points
+	// themselves, cluster len(points)+i is the result of merge i.
+	merges [][2]int
+	// representatives holds the index of the point that represents each cluster
+	representatives []int
+}
+
+// Agglomerate performs agglomerative (bottom up) clustering of the points: starting with every point as a cluster,
+// the two clusters whose merge increases the summed distance to their means the least (Ward's method) are merged,
+// until one cluster is left. The points are weighted by their Mass.
+//
+// Every cluster is represented by one of its points: the representative of the heavier of the two merged clusters.
+// So a cut with fewer clusters gives a subset of the representatives of a cut with more, see Dendrogram.Cut.
+// The computation is cubic in the amount of points, so it suits up to a few hundred of them (like the colors of a palette).
+func Agglomerate(points geom.PointSet, distanceMetric func(pnt1, pnt2 *geom.Point) float64) *Dendrogram {
+	n := len(points.Points)
+	dendrogram := &Dendrogram{points: points}
+
+	// the means and weights of the clusters, and whether they are still active (not merged into another one)
+	means := make([]geom.Point, 0, 2*n)
+	masses := make([]float64, 0, 2*n)
+	active := make([]bool, 0, 2*n)
+	for i := range points.Points {
+		means = append(means, points.Points[i])
+		masses = append(masses, float64(points.Points[i].Mass()))
+		active = append(active, true)
+		dendrogram.representatives = append(dendrogram.representatives, i)
+	}
+
+	ward := func(a, b int) float64 {
+		return masses[a] * masses[b] / (masses[a] + masses[b]) * distanceMetric(&means[a], &means[b])
+	}
+
+	for merge := 0; merge < n-1; merge++ {
+		bestA, bestB, bestCost := -1, -1, math.Inf(1)
+		for a := range means {
+			if !active[a] {
+				continue
+			}
+			for b := a + 1; b < len(means); b++ {
+				if !active[b] {
+					continue
+				}
+				if cost := ward(a, b); cost < bestCost {
+					bestA, bestB, bestCost = a, b, cost
+				}
+			}
+		}
+
+		merged := geom.PointSet{Points: []geom.Point{means[bestA], means[bestB]}}
+		merged.Points[0].Weight, merged.Points[1].Weight = float32(masses[bestA]), float32(masses[bestB])
+
+		representative := dendrogram.representatives[bestA]
+		if masses[bestB] > masses[bestA] {
+			representative = dendrogram.representatives[bestB]
+		}
+
+		means = append(means, merged.Mean())
+		masses = append(masses, masses[bestA]+masses[bestB])
+		active = append(active, true)
+		active[bestA], active[bestB] = false, false
+		dendrogram.merges = append(dendrogram.merges, [2]int{bestA, bestB})
+		dendrogram.representatives = append(dendrogram.representatives, representative)
+	}
+
+	return dendrogram
+}
+
+// Cut returns the representatives of the clusters when the dendrogram is cut at n clusters (or at all points,
+// if there are fewer). They are in the order of the points, so the representatives of a cut with fewer
+// clusters keep their relative order.
+func (dendrogram *Dendrogram) Cut(n int) geom.PointSet {
+	points := len(dendrogram.points.Points)
+	if n > points {
+		n = points
+	}
+	if n < 1 {
+		return geom.PointSet{}
+	}
+
+	active := map[int]bool{}
+	for i := 0; i < points; i++ {
+		active[i] = true
+	}
+	for i := 0; i < points-n; i++ {
+		merge := dendrogram.merges[i]
+		delete(active, merge[0])
+		delete(active, merge[1])
+		active[points+i] = true
+	}
+
+	indices := []int{}
+	for cluster := range active {
+		indices = append(indices, dendrogram.representatives[cluster])
+	}
+	sort.Ints(indices)
+
+	cut := geom.PointSet{}
+	for _, index := range indices {
+		cut.Points = append(cut.Points, dendrogram.points.Points[index])
+	}
+
+	return cut
+}
@@ -1,6 +1,8 @@
 package kmeans
 
 import (
+	"context"
+	"errors"
 	"math"
 	"math/rand"
 	"runtime"
@@ -8,9 +10,28 @@ import (
 	"time"
 
 	"github.com/mielpeeters/dither/geom"
+	"github.com/mielpeeters/dither/needle"
 )
 
-// Clustering is a K Means clustering struct
+// ErrNoPoints is returned by NewClustering when there are no points, which have no clusters to find
+var ErrNoPoints = errors.New("kmeans: no points to cluster")
+
+// Workers is the amount of goroutines that the steps of the clustering, and the runs of ClusterBest, are split over.
+// If it is 0 (the default), it is runtime.GOMAXPROCS(0).
+var Workers = 0
+
+// workers returns Workers, or runtime.GOMAXPROCS(0) if it isn't set
+func workers() int {
+	if Workers > 0 {
+		return Workers
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
+
+// Clustering is a K Means clustering struct.
+// Weighted points (see geom.Point.Weight) count as that many points, so a histogram of distinct values
+// can be clustered instead of all of the duplicates.
 type Clustering struct {
 	KMeans         geom.PointSet //The estimated cluster centers (at this step)
 	points         geom.PointSet //The set of Points with kardinality n to subset into k clusters
@@ -18,11 +39,84 @@ type Clustering struct {
 	Clusters       []geom.PointSet
 	maxDist        float64 //Maximum distance within the hyperbox containing all points
 	distanceMetric func(pnt1, pnt2 *geom.Point) float64
+	pinned         int //The first pinned means are fixed, they are never updated
+	rng            *rand.Rand
+	options        Options
 	// batch          []*geom.Point
 }
 
-var maxBatchSize = 30000
-var iterationLimit = 100
+// Options are the settings of a clustering run that trade accuracy for speed, see Clustering.SetOptions
+type Options struct {
+	// MaxBatchSize is the amount of points above which each iteration only assigns a random mini batch of this size
+	MaxBatchSize int
+	// IterationLimit is the maximum amount of iterations, even if the accuracy isn't met
+	IterationLimit int
+	// Triangle tells whether the distance metric satisfies the triangle inequality. If it does, the assignment
+	// step skips the means that can't be closer than the best one so far (Elkan's rule), which is much faster for large k.
+	Triangle Triangle
+	// Medoids makes the cluster representatives actual points: each mean is replaced by the point of its cluster
+	// that is closest to it (a fast approximation of the medoid), so a palette only holds colors of the image
+	Medoids bool
+	// Empty is what happens with the mean of a cluster that has no points left, see EmptyStrategy
+	Empty EmptyStrategy
+	// Tree makes the assignment step search a k-d tree of the means, instead of comparing every point to every mean,
+	// which pays off for large k (about 100 means and up, see BenchmarkAssign). The tree skips branches based on
+	// the squared difference along an axis, so it only finds the closest mean for metrics that are at least that large,
+	// like geom.SquaredEuclideanDistance, or geom.RedMeanDistance for points that only differ in red, green and blue.
+	// For metrics that satisfy the triangle inequality, Triangle is usually faster still; Tree is for those that
+	// don't, like RedMean. It takes precedence over Triangle.
+	Tree bool
+}
+
+// EmptyStrategy defines what happens with the mean of a cluster that has no points left after an assignment step
+type EmptyStrategy int
+
+const (
+	// EmptyRandom moves the mean to a random position within the bounds of the points (or to a random point, with Medoids)
+	EmptyRandom EmptyStrategy = iota
+	// EmptySplit moves the mean to the point of the largest cluster that is farthest from its mean, splitting that cluster
+	EmptySplit
+	// EmptyFarthest moves the mean to the point that is farthest from the mean of its cluster, over all clusters
+	EmptyFarthest
+	// EmptyDrop removes the mean, so the clustering ends with fewer than k means
+	EmptyDrop
+)
+
+// Triangle describes whether a distance metric satisfies the triangle inequality
+type Triangle int
+
+const (
+	// NoTriangle is for metrics that don't satisfy the triangle inequality, like geom.RedMeanDistance:
+	// every point is compared to every mean
+	NoTriangle Triangle = iota
+	// TriangleMetric is for metrics that satisfy the triangle inequality, like the Euclidean distance
+	TriangleMetric
+	// SquaredTriangleMetric is for the squares of such metrics, like geom.SquaredEuclideanDistance
+	SquaredTriangleMetric
+)
+
+// DefaultOptions are the options of new clustering problems
+var DefaultOptions = Options{
+	MaxBatchSize:   30000,
+	IterationLimit: 100,
+}
+
+// SetOptions changes the options of the clustering. Fields that are left at 0 keep their DefaultOptions value.
+func (KM *Clustering) SetOptions(options Options) {
+	KM.options = options.withDefaults()
+}
+
+// withDefaults returns the options with the fields that are left at 0 set to their DefaultOptions value
+func (options Options) withDefaults() Options {
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = DefaultOptions.MaxBatchSize
+	}
+	if options.IterationLimit <= 0 {
+		options.IterationLimit = DefaultOptions.IterationLimit
+	}
+
+	return options
+}
 
 // ClosestMeanIndex returns the index within the KM.kMeans slice
 // of that mean which is closest to the given point, by index pointIndex (stored in KM.points)
@@ -45,25 +139,71 @@ func ClosestMeanIndex(KM *Clustering, pointIndex int) int {
 	return bestIndex
 }
 
+// closestMean returns the index of the mean closest to point, like ClosestMeanIndex.
+// If halfDists is not nil, it holds (a bound on) half of the distance between each pair of means (see meanHalfDists),
+// and the means that are at least twice as far from the best mean as the point is are skipped: by the triangle
+// inequality, they can't be closer to the point.
+func (KM *Clustering) closestMean(point *geom.Point, halfDists [][]float64) int {
+	bestIndex := 0
+	minDist := KM.distanceMetric(point, &KM.KMeans.Points[0])
+
+	for meanIndex := 1; meanIndex < len(KM.KMeans.Points); meanIndex++ {
+		if halfDists != nil && halfDists[bestIndex][meanIndex] >= minDist {
+			continue
+		}
+
+		if dist := KM.distanceMetric(point, &KM.KMeans.Points[meanIndex]); dist < minDist {
+			minDist = dist
+			bestIndex = meanIndex
+		}
+	}
+
+	return bestIndex
+}
+
+// meanHalfDists returns half of the distance between each pair of means, for closestMean,
+// or nil if the distance metric doesn't satisfy the triangle inequality
+func (KM *Clustering) meanHalfDists() [][]float64 {
+	if KM.options.Triangle == NoTriangle {
+		return nil
+	}
+
+	halfDists := make([][]float64, len(KM.KMeans.Points))
+	for i := range halfDists {
+		halfDists[i] = make([]float64, len(KM.KMeans.Points))
+	}
+
+	for i := range KM.KMeans.Points {
+		for j := i + 1; j < len(KM.KMeans.Points); j++ {
+			dist := KM.distanceMetric(&KM.KMeans.Points[i], &KM.KMeans.Points[j])
+			if KM.options.Triangle == SquaredTriangleMetric {
+				// half of the distance, squared
+				dist /= 4
+			} else {
+				dist /= 2
+			}
+
+			halfDists[i][j], halfDists[j][i] = dist, dist
+		}
+	}
+
+	return halfDists
+}
+
 // assign performs the assignment step of the KMeans algorithm: assigning points to clusters.
 func (KM *Clustering) assign() {
 	wg := sync.WaitGroup{}
-	lock := sync.Mutex{}
-
-	workers := runtime.GOMAXPROCS(0)
-
-	var pointChunks [][]geom.Point
-	// try to divide amongst the amount of workers
-	dividedAmount := int(math.Ceil(float64(len(KM.points.Points))) / float64(workers))
 
-	var batchSize int
-	if len(KM.points.Points) > maxBatchSize {
-		batchSize = maxBatchSize / workers
-	} else {
-		batchSize = dividedAmount
+	// the batch doesn't depend on the amount of workers, so a seed creates the same clusters with any amount of them
+	batchSize := len(KM.points.Points)
+	if batchSize > KM.options.MaxBatchSize {
+		batchSize = KM.options.MaxBatchSize
 	}
 
-	pointChunks = KM.points.ChunkPointsMiniBatch(workers, batchSize)
+	KM.rng.Shuffle(len(KM.points.Points), func(i, j int) {
+		KM.points.Points[i], KM.points.Points[j] = KM.points.Points[j], KM.points.Points[i]
+	})
+	pointChunks := needle.ChunkSlice(KM.points.Points[:batchSize], workers())
 
 	// KM.batch = make([]*geom.Point, 0)
 	// for i := range pointChunks {
@@ -71,63 +211,104 @@ func (KM *Clustering) assign() {
 	// }
 
 	startIndex := 0
+	tree := KM.meanTree()
+	var halfDists [][]float64
+	if tree == nil {
+		halfDists = KM.meanHalfDists()
+	}
 
-	// reset clusters
-	KM.Clusters = make([]geom.PointSet, KM.k)
+	// the clusters found by each chunk, merged in chunk order so the result doesn't depend on scheduling
+	chunkClusters := make([][]geom.PointSet, len(pointChunks))
 
 	// handle each chunk in parallel
-	for _, points := range pointChunks {
+	for chunk, points := range pointChunks {
 		wg.Add(1)
 
-		go func(points []geom.Point, startIndex int) {
+		go func(points []geom.Point, startIndex, chunk int) {
 			newClusters := make([]geom.PointSet, KM.k)
+			query := &treePoint{}
 
 			for i, point := range points {
-				bestIndex := ClosestMeanIndex(KM, startIndex+i)
+				var bestIndex int
+				if tree != nil {
+					query.point = &KM.points.Points[startIndex+i]
+					bestIndex = tree.closestMean(query)
+				} else {
+					bestIndex = KM.closestMean(&KM.points.Points[startIndex+i], halfDists)
+				}
 				newClusters[bestIndex].Points = append(newClusters[bestIndex].Points, point)
 			}
 
-			lock.Lock()
-			for cluster := range KM.Clusters {
-				KM.Clusters[cluster].Points = append(KM.Clusters[cluster].Points, newClusters[cluster].Points...)
-			}
-			lock.Unlock()
+			chunkClusters[chunk] = newClusters
 
 			wg.Done()
-		}(points, startIndex)
+		}(points, startIndex, chunk)
 
 		startIndex += len(points)
 	}
 	wg.Wait()
+
+	// reset clusters
+	KM.Clusters = make([]geom.PointSet, KM.k)
+
+	for _, newClusters := range chunkClusters {
+		for cluster := range KM.Clusters {
+			KM.Clusters[cluster].Points = append(KM.Clusters[cluster].Points, newClusters[cluster].Points...)
+		}
+	}
 }
 
 // update performs the update step in the KMeans algorithm: update the means to be the mean of their clusters
 func (KM *Clustering) update() float64 {
 	// calculating the means
 	wg := sync.WaitGroup{}
-	lock := sync.Mutex{}
 
-	changes := []float64{}
+	changes := make([]float64, len(KM.Clusters))
+	old := make([]geom.Point, len(KM.Clusters))
 
-	for clusterID := range KM.Clusters {
+	// pinned means don't move
+	clusterIDs := []int{}
+	for clusterID := KM.pinned; clusterID < len(KM.Clusters); clusterID++ {
+		clusterIDs = append(clusterIDs, clusterID)
+	}
+
+	for _, chunk := range needle.ChunkSlice(clusterIDs, workers()) {
 		wg.Add(1)
-		go func(clusterID int) {
-			old := KM.KMeans.Points[clusterID]
-			mean := (&KM.Clusters[clusterID]).Mean()
-			if len(mean.Coordinates) == 0 {
-				KM.KMeans.Points[clusterID] = createRandomStart(KM.points, 1).Points[0] //bad choice, try another one
-			} else {
-				KM.KMeans.Points[clusterID] = mean
+		go func(clusterIDs []int) {
+			for _, clusterID := range clusterIDs {
+				old[clusterID] = KM.KMeans.Points[clusterID]
+				KM.KMeans.Points[clusterID] = (&KM.Clusters[clusterID]).Mean()
+				if KM.options.Medoids {
+					KM.KMeans.Points[clusterID] = KM.nearestPoint(&KM.Clusters[clusterID], &KM.KMeans.Points[clusterID])
+				}
 			}
-			change := KM.distanceMetric(&old, &KM.KMeans.Points[clusterID])
-			lock.Lock()
-			changes = append(changes, change)
-			lock.Unlock()
 			wg.Done()
-		}(clusterID)
+		}(chunk)
 	}
 	wg.Wait()
 
+	empty := []int{}
+	for clusterID := KM.pinned; clusterID < len(KM.Clusters); clusterID++ {
+		if len(KM.KMeans.Points[clusterID].Coordinates) == 0 {
+			empty = append(empty, clusterID)
+		}
+	}
+
+	if len(empty) > 0 {
+		if KM.options.Empty == EmptyDrop {
+			KM.drop(empty)
+			changes = changes[:len(KM.KMeans.Points)]
+			old = removeIndices(old, empty)
+		} else {
+			// bad choice, try another one (in order, as KM.rng can't be shared between goroutines)
+			KM.reseed(empty)
+		}
+	}
+
+	for clusterID := KM.pinned; clusterID < len(KM.KMeans.Points); clusterID++ {
+		changes[clusterID] = KM.distanceMetric(&old[clusterID], &KM.KMeans.Points[clusterID])
+	}
+
 	var max float64
 	for i := range changes {
 		max = math.Max(max, changes[i])
@@ -136,47 +317,150 @@ func (KM *Clustering) update() float64 {
 	return max
 }
 
-// TotalDist returns the total distance from points to their assigned cluster mean
+// reseed gives the means of the empty clusters a new position, according to the Empty option
+func (KM *Clustering) reseed(empty []int) {
+	// the clusters that were split already, they don't give a second point
+	used := map[int]bool{}
+
+	for _, clusterID := range empty {
+		best, bestScore := -1, 0.0
+		var bestPoint geom.Point
+
+		if KM.options.Empty == EmptySplit || KM.options.Empty == EmptyFarthest {
+			for i := range KM.Clusters {
+				if used[i] || len(KM.Clusters[i].Points) < 2 {
+					continue
+				}
+
+				point, dist := KM.farthestPoint(&KM.Clusters[i], &KM.KMeans.Points[i])
+				score := dist
+				if KM.options.Empty == EmptySplit {
+					score = float64(len(KM.Clusters[i].Points))
+				}
+
+				if best < 0 || score > bestScore {
+					best, bestScore, bestPoint = i, score, point
+				}
+			}
+		}
+
+		switch {
+		case best >= 0:
+			used[best] = true
+			KM.KMeans.Points[clusterID] = bestPoint
+		case KM.options.Medoids:
+			KM.KMeans.Points[clusterID] = KM.points.Points[KM.rng.Intn(len(KM.points.Points))]
+		default:
+			KM.KMeans.Points[clusterID] = createRandomStart(KM.points, 1, KM.rng).Points[0]
+		}
+	}
+}
+
+// drop removes the means (and clusters) with the given indices, which are in increasing order
+func (KM *Clustering) drop(indices []int) {
+	KM.KMeans.Points = removeIndices(KM.KMeans.Points, indices)
+	KM.Clusters = removeIndices(KM.Clusters, indices)
+	KM.k -= len(indices)
+}
+
+// removeIndices returns the elements of slice without the ones at the given indices, which are in increasing order
+func removeIndices[T any](slice []T, indices []int) []T {
+	kept := slice[:0]
+	next := 0
+	for i, element := range slice {
+		if next < len(indices) && indices[next] == i {
+			next++
+			continue
+		}
+		kept = append(kept, element)
+	}
+
+	return kept
+}
+
+// farthestPoint returns the point of the set that is farthest from target, and its distance
+func (KM *Clustering) farthestPoint(set *geom.PointSet, target *geom.Point) (geom.Point, float64) {
+	farthest, maxDist := 0, -1.0
+	for i := range set.Points {
+		if dist := KM.distanceMetric(target, &set.Points[i]); dist > maxDist {
+			maxDist = dist
+			farthest = i
+		}
+	}
+
+	point := set.Points[farthest]
+
+	return geom.Point{Coordinates: point.Coordinates, ID: point.ID}, maxDist
+}
+
+// nearestPoint returns the point of the set that is closest to target, or target if the set is empty
+func (KM *Clustering) nearestPoint(set *geom.PointSet, target *geom.Point) geom.Point {
+	if len(set.Points) == 0 {
+		return *target
+	}
+
+	bestIndex := 0
+	minDist := math.Inf(1)
+	for i := range set.Points {
+		if dist := KM.distanceMetric(target, &set.Points[i]); dist < minDist {
+			minDist = dist
+			bestIndex = i
+		}
+	}
+
+	nearest := set.Points[bestIndex]
+
+	return geom.Point{Coordinates: nearest.Coordinates, ID: nearest.ID}
+}
+
+// TotalDist returns the total distance from points to their assigned cluster mean, weighted by the Mass of the points
 func (KM *Clustering) TotalDist() float64 {
 
 	var sum float64
 
 	wg := sync.WaitGroup{}
-	mutex := sync.Mutex{}
+	localSums := make([]float64, len(KM.KMeans.Points))
 
-	for meanIndex := range KM.KMeans.Points { // iterate over all means
+	meanIndices := make([]int, len(KM.KMeans.Points))
+	for i := range meanIndices {
+		meanIndices[i] = i
+	}
+
+	for _, chunk := range needle.ChunkSlice(meanIndices, workers()) {
 		wg.Add(1)
-		go func(points []geom.Point, meanIndex int) {
-			localSum := 0.0
-			for pointIndex := range points {
-				localSum += KM.distanceMetric(&KM.KMeans.Points[meanIndex], &KM.Clusters[meanIndex].Points[pointIndex])
+		go func(meanIndices []int) {
+			for _, meanIndex := range meanIndices {
+				for pointIndex := range KM.Clusters[meanIndex].Points {
+					point := &KM.Clusters[meanIndex].Points[pointIndex]
+					localSums[meanIndex] += KM.distanceMetric(&KM.KMeans.Points[meanIndex], point) * float64(point.Mass())
+				}
 			}
 
-			mutex.Lock()
-			sum += localSum
-			mutex.Unlock()
-
 			wg.Done()
-		}(KM.Clusters[meanIndex].Points, meanIndex)
+		}(chunk)
 	}
 
 	wg.Wait()
+
+	// add up in a fixed order, so that the result is reproducible
+	for _, localSum := range localSums {
+		sum += localSum
+	}
+
 	return sum
 }
 
 // iterate performs one iteration of the KMeans algorithm
 //
-// Returns:
-//   - whether accuracy was met, as a bool
-//   - the achieved change, maxChange / KM.maxDist, as a percentage (float)
-func (KM *Clustering) iterate(accuracy float64) bool {
+// Returns the achieved change, maxChange / KM.maxDist, as a percentage
+func (KM *Clustering) iterate() float64 {
 	KM.assign()
 	maxChange := KM.update()
 
-	return (maxChange * 100 / KM.maxDist) < accuracy
+	return maxChange * 100 / KM.maxDist
 }
 
-func createRandomStart(points geom.PointSet, k int) geom.PointSet {
+func createRandomStart(points geom.PointSet, k int, rng *rand.Rand) geom.PointSet {
 	//Get bounds so that the random starting points will at least lie in a reasonable region
 	bounds := (&points).LowerAndUpperBounds()
 
@@ -188,9 +472,6 @@ func createRandomStart(points geom.PointSet, k int) geom.PointSet {
 		return returnValue
 	}
 
-	//Re-seed for actual randomness
-	rand.Seed(time.Now().UnixNano())
-
 	//set the dimension
 	dim := points.Points[0].Dimension()
 
@@ -199,9 +480,9 @@ func createRandomStart(points geom.PointSet, k int) geom.PointSet {
 	for i := 0; i < k; i++ {
 		var currentPoint geom.Point
 		for dimNum := 0; dimNum < dim; dimNum++ {
-			low = bounds[dimNum].Lower                                                                //lower bound for this coordinate number
-			upp = bounds[dimNum].Upper                                                                //upper bound for this coordinate number
-			currentPoint.Coordinates = append(currentPoint.Coordinates, rand.Float32()*(upp-low)+low) //random value between corr. bounds
+			low = bounds[dimNum].Lower                                                               //lower bound for this coordinate number
+			upp = bounds[dimNum].Upper                                                               //upper bound for this coordinate number
+			currentPoint.Coordinates = append(currentPoint.Coordinates, rng.Float32()*(upp-low)+low) //random value between corr. bounds
 		}
 		returnValue.Points = append(returnValue.Points, currentPoint) // add the fully random point to the geom.PointSet
 	}
@@ -212,9 +493,23 @@ func createRandomStart(points geom.PointSet, k int) geom.PointSet {
 // CreateKMeansProblem generates a new k-means clustering problem.
 //
 // points is the PointSet that contains the clusters that are to be found. k is the estimated amount of clusters.
-// distanceMetric is the function to be used for determining "closeness"
-func CreateKMeansProblem(points geom.PointSet, k int, distanceMetric func(pnt1, pnt2 *geom.Point) float64) Clustering {
-	kMeans := createRandomStart(points, k)
+// distanceMetric is the function to be used for determining "closeness".
+// points must not be empty, NewClustering checks that and returns ErrNoPoints instead.
+// rng optionally is the source of the random choices, which makes the clustering reproducible (see CreateKMeansProblemRand).
+// Without it, the problem gets a source of its own, seeded with the current time.
+func CreateKMeansProblem(points geom.PointSet, k int, distanceMetric func(pnt1, pnt2 *geom.Point) float64, rng ...*rand.Rand) Clustering {
+	if len(rng) > 0 && rng[0] != nil {
+		return CreateKMeansProblemRand(points, k, distanceMetric, rng[0])
+	}
+
+	return CreateKMeansProblemRand(points, k, distanceMetric, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// CreateKMeansProblemRand generates a new k-means clustering problem, like CreateKMeansProblem,
+// that takes all of its random choices from rng. Clustering with the same seed gives the same result.
+// The problem owns rng while clustering: it must not be used concurrently.
+func CreateKMeansProblemRand(points geom.PointSet, k int, distanceMetric func(pnt1, pnt2 *geom.Point) float64, rng *rand.Rand) Clustering {
+	kMeans := createRandomStart(points, k, rng)
 
 	//Craete the initial clusters, consisting of just the random means in k different geom.PointSets
 	initClusters := make([]geom.PointSet, k)
@@ -240,28 +535,222 @@ func CreateKMeansProblem(points geom.PointSet, k int, distanceMetric func(pnt1,
 		initClusters,
 		maxDist,
 		distanceMetric,
+		0,
+		rng,
+		DefaultOptions,
 	}
 
 	return returnValue
 }
 
+// NewClustering generates a new k-means clustering problem like CreateKMeansProblemRand,
+// but returns ErrNoPoints if points is empty, instead of a problem that fails once it is clustered.
+func NewClustering(points geom.PointSet, k int, distanceMetric func(pnt1, pnt2 *geom.Point) float64, rng *rand.Rand) (Clustering, error) {
+	if len(points.Points) == 0 {
+		return Clustering{}, ErrNoPoints
+	}
+
+	return CreateKMeansProblemRand(points, k, distanceMetric, rng), nil
+}
+
+// Pin fixes the given points as cluster means: they replace the first means of the problem,
+// and will not be moved by the algorithm. Only the remaining means are free to be clustered.
+// If more than k points are given, only the first k are used.
+func (KM *Clustering) Pin(points ...geom.Point) {
+	if len(points) > KM.k {
+		points = points[:KM.k]
+	}
+
+	for i, point := range points {
+		KM.KMeans.Points[i] = point
+	}
+
+	KM.pinned = len(points)
+}
+
+// Start sets the initial means of the problem to the given points, instead of random ones: for example the means
+// of a previous, similar problem (like the previous frame of a video), which converges in far fewer iterations.
+// The points replace the first means, if fewer than k points are given the remaining means stay random.
+// Pinned means (see Pin) are not replaced, the points are used for the means after them.
+func (KM *Clustering) Start(points ...geom.Point) {
+	for i, point := range points {
+		if KM.pinned+i >= KM.k {
+			break
+		}
+
+		KM.KMeans.Points[KM.pinned+i] = point
+	}
+}
+
+// Result describes how a clustering run ended
+type Result struct {
+	// Iterations is the amount of iterations that were performed
+	Iterations int
+	// Change is the largest move of a mean in the last iteration, as a percentage of the size of the point set
+	Change float64
+	// TotalDist is the TotalDist of the final clusters
+	TotalDist float64
+	// Converged tells whether the accuracy was met the required amount of consecutive times
+	Converged bool
+	// LimitReached tells whether the clustering stopped at the IterationLimit (see Options) without converging
+	LimitReached bool
+}
+
 // Cluster performs the clustering algorithm, with specified parameters for accuracy
 //
 //   - accuracy: the amount of relative change below which the algorithm is considered to have converged
 //   - consecutiveTimes: the amount of times the accuracy has to be met consecutively for convergence
-func (KM *Clustering) Cluster(accuracy float64, consecutiveTimes int) {
-	var done bool
+//
+// It returns whether (and how) the clustering converged.
+func (KM *Clustering) Cluster(accuracy float64, consecutiveTimes int) Result {
+	result, _ := KM.ClusterContext(context.Background(), accuracy, consecutiveTimes, nil)
+
+	return result
+}
+
+// ClusterBest runs the clustering n times in parallel, like Cluster, each time from another random start,
+// and returns the run with the lowest TotalDist together with the results of all runs.
+// The first run starts from the current means (so it keeps those set by Start), pinned means are kept in all of them.
+// The random starts are taken from the source of the problem, so a seeded problem gives the same result every time.
+func (KM *Clustering) ClusterBest(n int, accuracy float64, consecutiveTimes int) (*Clustering, []Result) {
+	best, results, _ := KM.ClusterBestContext(context.Background(), n, accuracy, consecutiveTimes, nil)
+
+	return best, results
+}
+
+// ClusterBestContext runs the clustering n times like ClusterBest, and calls onIteration (which may be nil) after every
+// iteration of every run, with the number of the run (starting at 0). The calls are never concurrent.
+//
+// When ctx is cancelled, all runs stop before their next iteration, and the error of ctx is returned.
+func (KM *Clustering) ClusterBestContext(ctx context.Context, n int, accuracy float64, consecutiveTimes int, onIteration func(run int, stats IterationStats)) (*Clustering, []Result, error) {
+	if n < 1 {
+		n = 1
+	}
+
+	// draw the seeds up front, as KM.rng can't be shared between goroutines
+	runs := make([]*Clustering, n)
+	for i := range runs {
+		runs[i] = KM.restart(KM.rng.Int63(), i == 0)
+	}
+
+	results := make([]Result, n)
+	errs := make([]error, n)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	// at most Workers runs at the same time
+	running := make(chan struct{}, workers())
+
+	for i, run := range runs {
+		wg.Add(1)
+		go func(i int, run *Clustering) {
+			defer wg.Done()
+			running <- struct{}{}
+			defer func() { <-running }()
+
+			var report func(IterationStats)
+			if onIteration != nil {
+				report = func(stats IterationStats) {
+					mu.Lock()
+					onIteration(i, stats)
+					mu.Unlock()
+				}
+			}
+
+			results[i], errs[i] = run.ClusterContext(ctx, accuracy, consecutiveTimes, report)
+		}(i, run)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, results, err
+		}
+	}
+
+	bestIndex := 0
+	for i, result := range results {
+		if result.TotalDist < results[bestIndex].TotalDist {
+			bestIndex = i
+		}
+	}
+
+	return runs[bestIndex], results, nil
+}
+
+// restart returns a copy of the problem with its own source of randomness and its own points, for a parallel run.
+// The means that aren't pinned get a new random start, unless keep is set.
+func (KM *Clustering) restart(seed int64, keep bool) *Clustering {
+	run := *KM
+	run.rng = rand.New(rand.NewSource(seed))
+	// the points are shuffled while clustering, the coordinates themselves are never changed
+	run.points = geom.PointSet{Points: append([]geom.Point{}, KM.points.Points...)}
+	run.KMeans = geom.PointSet{Points: append([]geom.Point{}, KM.KMeans.Points...)}
+	run.Clusters = make([]geom.PointSet, KM.k)
+
+	if !keep {
+		copy(run.KMeans.Points[run.pinned:], createRandomStart(run.points, run.k-run.pinned, run.rng).Points)
+	}
+
+	return &run
+}
+
+// IterationStats describes one iteration of the clustering, see ClusterContext
+type IterationStats struct {
+	// Iteration is the number of the iteration, starting at 1
+	Iteration int
+	// TotalDist is the TotalDist after the iteration
+	TotalDist float64
+	// Change is the largest move of a mean in this iteration, as a percentage of the size of the point set
+	Change float64
+	// Consecutive is the amount of consecutive iterations (up to and including this one) with a Change below the accuracy
+	Consecutive int
+	// Elapsed is the time since the clustering started
+	Elapsed time.Duration
+}
+
+// ClusterContext performs the clustering algorithm like Cluster, and calls onIteration (which may be nil)
+// after every iteration, with the statistics of that iteration.
+//
+// When ctx is cancelled, the clustering stops before the next iteration, and the error of ctx is returned
+// together with the result so far.
+func (KM *Clustering) ClusterContext(ctx context.Context, accuracy float64, consecutiveTimes int, onIteration func(stats IterationStats)) (Result, error) {
 	var consecutiveDone int
+	var change float64
 
+	start := time.Now()
 	count := 0
 
-	for consecutiveDone < consecutiveTimes && count < iterationLimit {
+	for consecutiveDone < consecutiveTimes && count < KM.options.IterationLimit {
+		if err := ctx.Err(); err != nil {
+			return Result{Iterations: count, Change: change, TotalDist: KM.TotalDist()}, err
+		}
+
 		count++
-		done = KM.iterate(accuracy)
-		if done {
+		change = KM.iterate()
+		if change < accuracy {
 			consecutiveDone++
 		} else {
 			consecutiveDone = 0
 		}
+
+		if onIteration != nil {
+			onIteration(IterationStats{
+				Iteration:   count,
+				TotalDist:   KM.TotalDist(),
+				Change:      change,
+				Consecutive: consecutiveDone,
+				Elapsed:     time.Since(start),
+			})
+		}
 	}
+
+	converged := consecutiveDone >= consecutiveTimes
+
+	return Result{
+		Iterations:   count,
+		Change:       change,
+		TotalDist:    KM.TotalDist(),
+		Converged:    converged,
+		LimitReached: !converged,
+	}, nil
 }
@@ -0,0 +1,504 @@
+package kmeans
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mielpeeters/dither/geom"
+	"github.com/mielpeeters/dither/testgen"
+)
+
+// TestClusterQuality checks that the clustering finds the centers of well separated Gaussian clusters
+func TestClusterQuality(t *testing.T) {
+	k := 5
+	points, optimal := clusterPoints(k, 200)
+
+	// random starts can end up in a local minimum, keep the best of a few runs,
+	// with a seeded source so that the runs are the same every time
+	rng := rand.New(rand.NewSource(1))
+	var best Clustering
+	bestDist := math.Inf(1)
+	for i := 0; i < 5; i++ {
+		KM := CreateKMeansProblemRand(points, k, geom.RedMeanDistance, rng)
+		KM.Cluster(0.01, 2)
+
+		if dist := KM.TotalDist(); dist < bestDist {
+			bestDist = dist
+			best = KM
+		}
+	}
+
+	found := color.Palette{}
+	for _, mean := range best.KMeans.Points {
+		found = append(found, color.RGBA{uint8(mean.Coordinates[0]), uint8(mean.Coordinates[1]), uint8(mean.Coordinates[2]), 255})
+	}
+
+	if err := testgen.MaxCenterError(found, optimal); err > 5 {
+		t.Errorf("cluster centers are too far from the optimal ones: max error %.2f\nfound:   %v\noptimal: %v", err, found, optimal)
+	}
+}
+
+// TestClusterReproducible checks that clustering with the same seed gives the same means
+func TestClusterReproducible(t *testing.T) {
+	cluster := func(seed int64) geom.PointSet {
+		// clustering shuffles the points, so every run gets its own copy
+		points, _ := clusterPoints(4, 100)
+		KM := CreateKMeansProblemRand(points, 4, geom.RedMeanDistance, rand.New(rand.NewSource(seed)))
+		KM.Cluster(0.01, 2)
+
+		return KM.KMeans
+	}
+
+	first, second := cluster(42), cluster(42)
+	for i := range first.Points {
+		for dim := range first.Points[i].Coordinates {
+			if first.Points[i].Coordinates[dim] != second.Points[i].Coordinates[dim] {
+				t.Fatalf("same seed gave different means:\n%v\n%v", first.Points, second.Points)
+			}
+		}
+	}
+}
+
+// TestCreateKMeansProblemSeed checks that a problem created with a seeded source gives the same clustering twice,
+// the one of CreateKMeansProblemRand with that seed
+func TestCreateKMeansProblemSeed(t *testing.T) {
+	cluster := func(create func(points geom.PointSet) Clustering) Clustering {
+		points, _ := clusterPoints(4, 100)
+		KM := create(points)
+		KM.Cluster(0.01, 2)
+
+		return KM
+	}
+
+	seeded := func(points geom.PointSet) Clustering {
+		return CreateKMeansProblem(points, 4, geom.RedMeanDistance, rand.New(rand.NewSource(7)))
+	}
+	first, second := cluster(seeded), cluster(seeded)
+	withRand := cluster(func(points geom.PointSet) Clustering {
+		return CreateKMeansProblemRand(points, 4, geom.RedMeanDistance, rand.New(rand.NewSource(7)))
+	})
+
+	for _, other := range []Clustering{second, withRand} {
+		if !reflect.DeepEqual(first.KMeans, other.KMeans) {
+			t.Errorf("the same seed gave different means:\n%v\n%v", first.KMeans.Points, other.KMeans.Points)
+		}
+		for i := range first.Clusters {
+			if len(first.Clusters[i].Points) != len(other.Clusters[i].Points) {
+				t.Errorf("the same seed gave clusters of %d and %d points", len(first.Clusters[i].Points), len(other.Clusters[i].Points))
+			}
+		}
+	}
+}
+
+// TestClusterWorkers checks that the amount of workers doesn't change the means
+func TestClusterWorkers(t *testing.T) {
+	saved := Workers
+	t.Cleanup(func() { Workers = saved })
+
+	cluster := func(workers int) geom.PointSet {
+		Workers = workers
+		points, _ := clusterPoints(6, 100)
+		KM := CreateKMeansProblemRand(points, 6, geom.RedMeanDistance, rand.New(rand.NewSource(5)))
+		best, _ := KM.ClusterBest(3, 0.01, 2)
+
+		return best.KMeans
+	}
+
+	one, many := cluster(1), cluster(7)
+	if !reflect.DeepEqual(one, many) {
+		t.Errorf("1 and 7 workers gave different means:\n%v\n%v", one.Points, many.Points)
+	}
+}
+
+// TestClusterContextCancel checks that the clustering reports its iterations and stops when the context is cancelled
+func TestClusterContextCancel(t *testing.T) {
+	points, _ := clusterPoints(4, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var iterations []IterationStats
+	KM := CreateKMeansProblemRand(points, 4, geom.RedMeanDistance, rand.New(rand.NewSource(1)))
+	// an accuracy of 0 is never met, so only the cancellation stops the clustering
+	_, err := KM.ClusterContext(ctx, 0, 2, func(stats IterationStats) {
+		iterations = append(iterations, stats)
+		if stats.Iteration == 2 {
+			cancel()
+		}
+	})
+
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(iterations) != 2 {
+		t.Fatalf("expected 2 iterations before the cancellation, got %d", len(iterations))
+	}
+	for i, stats := range iterations {
+		if stats.Iteration != i+1 || stats.TotalDist <= 0 {
+			t.Errorf("unexpected stats for iteration %d: %+v", i+1, stats)
+		}
+	}
+}
+
+// TestClusterContextCancelled checks that clustering with a context that is already cancelled returns its error
+// right away, without iterating
+func TestClusterContextCancelled(t *testing.T) {
+	points, _ := clusterPoints(6, 200)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	KM := CreateKMeansProblemRand(points, 6, geom.RedMeanDistance, rand.New(rand.NewSource(1)))
+	start := time.Now()
+	result, err := KM.ClusterContext(ctx, 0, 2, func(stats IterationStats) {
+		t.Errorf("iterated after the cancellation: %+v", stats)
+	})
+	if err != ctx.Err() || result.Iterations != 0 {
+		t.Errorf("got error %v after %d iterations, want %v after none", err, result.Iterations, ctx.Err())
+	}
+
+	if _, _, err := KM.ClusterBestContext(ctx, 4, 0, 2, nil); err != ctx.Err() {
+		t.Errorf("the best of several runs gave error %v, want %v", err, ctx.Err())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returning the error took %v", elapsed)
+	}
+}
+
+// TestClusterWeighted checks that weighted points count as that many points
+func TestClusterWeighted(t *testing.T) {
+	points := geom.PointSet{Points: []geom.Point{
+		{Coordinates: []float32{0, 0, 0, 255}, ID: 0, Weight: 3},
+		{Coordinates: []float32{100, 100, 100, 255}, ID: 1},
+	}}
+
+	KM := CreateKMeansProblemRand(points, 1, geom.RedMeanDistance, rand.New(rand.NewSource(1)))
+	if result := KM.Cluster(0.01, 2); !result.Converged || result.LimitReached {
+		t.Errorf("expected the clustering to converge, got %+v", result)
+	}
+
+	if mean := KM.KMeans.Points[0].Coordinates[0]; math.Abs(float64(mean)-25) > 0.01 {
+		t.Errorf("expected the weighted mean at 25, got %v", mean)
+	}
+}
+
+// TestClusterPinned checks that pinned means are kept as they are, while the other means move to the clusters
+func TestClusterPinned(t *testing.T) {
+	pinned := []geom.Point{
+		{Coordinates: []float32{0, 0, 0, 255}},
+		{Coordinates: []float32{255, 255, 255, 255}},
+	}
+
+	for _, restarts := range []int{1, 5} {
+		points, optimal := clusterPoints(4, 100)
+		KM := CreateKMeansProblemRand(points, 4+len(pinned), geom.SquaredEuclideanDistance, rand.New(rand.NewSource(1)))
+		KM.Pin(pinned...)
+		best, _ := KM.ClusterBest(restarts, 0.01, 2)
+
+		for i, point := range pinned {
+			if !reflect.DeepEqual(best.KMeans.Points[i].Coordinates, point.Coordinates) {
+				t.Errorf("%d restarts: pinned mean %d moved from %v to %v", restarts, i, point.Coordinates, best.KMeans.Points[i].Coordinates)
+			}
+		}
+
+		// a single random start can end up in a local minimum, the best of a few runs finds the clusters
+		if restarts == 1 {
+			continue
+		}
+		free := color.Palette{}
+		for _, point := range best.KMeans.Points[len(pinned):] {
+			free = append(free, color.RGBA{uint8(point.Coordinates[0]), uint8(point.Coordinates[1]), uint8(point.Coordinates[2]), 255})
+		}
+		if err := testgen.MaxCenterError(free, optimal); err > 5 {
+			t.Errorf("%d restarts: the other means are too far from the clusters: max error %.2f", restarts, err)
+		}
+	}
+}
+
+// TestClusterNoPoints checks that a problem without points is refused, instead of failing once it is clustered
+func TestClusterNoPoints(t *testing.T) {
+	if _, err := NewClustering(geom.PointSet{}, 4, geom.RedMeanDistance, rand.New(rand.NewSource(1))); err != ErrNoPoints {
+		t.Errorf("expected ErrNoPoints, got %v", err)
+	}
+}
+
+// TestClusterTriangle checks that skipping means with the triangle inequality gives the same clusters
+func TestClusterTriangle(t *testing.T) {
+	cluster := func(triangle Triangle) geom.PointSet {
+		points, _ := clusterPoints(16, 100)
+		KM := CreateKMeansProblemRand(points, 16, geom.SquaredEuclideanDistance, rand.New(rand.NewSource(7)))
+		KM.SetOptions(Options{Triangle: triangle})
+		KM.Cluster(0.01, 2)
+
+		return KM.KMeans
+	}
+
+	naive, pruned := cluster(NoTriangle), cluster(SquaredTriangleMetric)
+	for i := range naive.Points {
+		for dim := range naive.Points[i].Coordinates {
+			if naive.Points[i].Coordinates[dim] != pruned.Points[i].Coordinates[dim] {
+				t.Fatalf("skipping means changed the result:\n%v\n%v", naive.Points, pruned.Points)
+			}
+		}
+	}
+}
+
+// TestClusterTree checks that assigning the points with a k-d tree of the means gives the same clusters
+func TestClusterTree(t *testing.T) {
+	cluster := func(tree bool) geom.PointSet {
+		points, _ := clusterPoints(48, 100)
+		KM := CreateKMeansProblemRand(points, 48, geom.SquaredEuclideanDistance, rand.New(rand.NewSource(7)))
+		KM.SetOptions(Options{Tree: tree})
+		KM.Cluster(0.01, 2)
+
+		return KM.KMeans
+	}
+
+	naive, searched := cluster(false), cluster(true)
+	for i := range naive.Points {
+		for dim := range naive.Points[i].Coordinates {
+			if naive.Points[i].Coordinates[dim] != searched.Points[i].Coordinates[dim] {
+				t.Fatalf("the k-d tree changed the result:\n%v\n%v", naive.Points, searched.Points)
+			}
+		}
+	}
+}
+
+// TestClusterMedoids checks that the means are points of the set when clustering with medoids
+func TestClusterMedoids(t *testing.T) {
+	points, _ := clusterPoints(4, 100)
+	existing := map[[4]float32]bool{}
+	for _, point := range points.Points {
+		c := point.Coordinates
+		existing[[4]float32{c[0], c[1], c[2], c[3]}] = true
+	}
+
+	KM := CreateKMeansProblemRand(points, 4, geom.RedMeanDistance, rand.New(rand.NewSource(1)))
+	KM.SetOptions(Options{Medoids: true})
+	KM.Cluster(0.01, 2)
+
+	for _, mean := range KM.KMeans.Points {
+		c := mean.Coordinates
+		if !existing[[4]float32{c[0], c[1], c[2], c[3]}] {
+			t.Errorf("mean %v is not one of the points", c)
+		}
+	}
+}
+
+// TestClusterBest checks that the parallel restarts are reproducible and that the best run is returned
+func TestClusterBest(t *testing.T) {
+	points, _ := clusterPoints(6, 100)
+
+	cluster := func() (*Clustering, []Result) {
+		KM := CreateKMeansProblemRand(points, 6, geom.RedMeanDistance, rand.New(rand.NewSource(3)))
+		return KM.ClusterBest(4, 0.01, 2)
+	}
+
+	first, results := cluster()
+	second, _ := cluster()
+
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.TotalDist < first.TotalDist() {
+			t.Errorf("a run with a lower TotalDist (%v) than the best one (%v) exists", result.TotalDist, first.TotalDist())
+		}
+	}
+
+	for i := range first.KMeans.Points {
+		for dim := range first.KMeans.Points[i].Coordinates {
+			if first.KMeans.Points[i].Coordinates[dim] != second.KMeans.Points[i].Coordinates[dim] {
+				t.Fatalf("same seed gave different means:\n%v\n%v", first.KMeans.Points, second.KMeans.Points)
+			}
+		}
+	}
+}
+
+// TestClusterEmpty checks the strategies for clusters that lose all of their points
+func TestClusterEmpty(t *testing.T) {
+	points := geom.PointSet{}
+	for i := 0; i < 100; i++ {
+		value := float32(i % 10)
+		if i%2 == 0 {
+			value += 200
+		}
+		points.Points = append(points.Points, geom.Point{Coordinates: []float32{value, value, value, 255}, ID: i})
+	}
+
+	// two means start far away from all points, so their clusters are empty after the first assignment
+	start := []geom.Point{
+		{Coordinates: []float32{0, 0, 0, 255}},
+		{Coordinates: []float32{200, 200, 200, 255}},
+		{Coordinates: []float32{-1000, -1000, -1000, 255}},
+		{Coordinates: []float32{-2000, -2000, -2000, 255}},
+	}
+
+	for _, strategy := range []EmptyStrategy{EmptyRandom, EmptySplit, EmptyFarthest, EmptyDrop} {
+		KM := CreateKMeansProblemRand(points, 4, geom.RedMeanDistance, rand.New(rand.NewSource(1)))
+		KM.SetOptions(Options{Empty: strategy})
+		KM.Start(start...)
+		KM.Cluster(0.01, 2)
+
+		expected := 4
+		if strategy == EmptyDrop {
+			expected = 2
+		}
+		if len(KM.KMeans.Points) != expected {
+			t.Errorf("strategy %d: expected %d means, got %d", strategy, expected, len(KM.KMeans.Points))
+		}
+
+		for _, mean := range KM.KMeans.Points {
+			if mean.Coordinates[0] < -100 {
+				t.Errorf("strategy %d: mean %v was not moved", strategy, mean.Coordinates)
+			}
+		}
+	}
+}
+
+// TestSilhouette checks that well separated clusters score higher with the right k than with a wrong one
+func TestSilhouette(t *testing.T) {
+	score := func(k int) (float64, float64) {
+		points, _ := clusterPoints(4, 100)
+		KM := CreateKMeansProblemRand(points, k, geom.SquaredEuclideanDistance, rand.New(rand.NewSource(1)))
+		best, _ := KM.ClusterBest(4, 0.01, 2)
+
+		return best.Silhouette(500), best.Inertia()
+	}
+
+	right, rightInertia := score(4)
+	wrong, wrongInertia := score(2)
+
+	if right <= wrong {
+		t.Errorf("expected a higher silhouette for k=4 (%.3f) than for k=2 (%.3f)", right, wrong)
+	}
+	if rightInertia >= wrongInertia {
+		t.Errorf("expected a lower inertia for k=4 (%.0f) than for k=2 (%.0f)", rightInertia, wrongInertia)
+	}
+}
+
+// TestBisect checks that bisecting k-means finds the centers of well separated Gaussian clusters in one run
+func TestBisect(t *testing.T) {
+	k := 5
+	points, optimal := clusterPoints(k, 200)
+
+	KM := CreateKMeansProblemRand(points, k, geom.RedMeanDistance, rand.New(rand.NewSource(1)))
+	KM.Bisect(0.01, 2)
+
+	found := color.Palette{}
+	for _, mean := range KM.KMeans.Points {
+		found = append(found, color.RGBA{uint8(mean.Coordinates[0]), uint8(mean.Coordinates[1]), uint8(mean.Coordinates[2]), 255})
+	}
+
+	if err := testgen.MaxCenterError(found, optimal); err > 5 {
+		t.Errorf("cluster centers are too far from the optimal ones: max error %.2f\nfound:   %v\noptimal: %v", err, found, optimal)
+	}
+}
+
+// TestOnline checks that the online clustering finds the centers of well separated Gaussian clusters
+// when the points are added in small parts
+func TestOnline(t *testing.T) {
+	k := 5
+	points, optimal := clusterPoints(k, 200)
+
+	online := NewOnline(k, geom.RedMeanDistance, rand.New(rand.NewSource(1)))
+	online.SetOptions(Options{MaxBatchSize: 2000})
+
+	// the points are added row by row (the image is 200 wide), which would leave the first rows biased
+	// if the means didn't keep moving
+	rows := make([][]geom.Point, 50)
+	for _, point := range points.Points {
+		rows[point.ID/200] = append(rows[point.ID/200], point)
+	}
+	for _, row := range rows {
+		online.Add(row...)
+	}
+
+	found := color.Palette{}
+	for _, mean := range online.Means().Points {
+		found = append(found, color.RGBA{uint8(mean.Coordinates[0]), uint8(mean.Coordinates[1]), uint8(mean.Coordinates[2]), 255})
+	}
+
+	if err := testgen.MaxCenterError(found, optimal); err > 5 {
+		t.Errorf("cluster centers are too far from the optimal ones: max error %.2f\nfound:   %v\noptimal: %v", err, found, optimal)
+	}
+}
+
+// TestAgglomerate checks that the cuts of a dendrogram are nested subsets of each other
+func TestAgglomerate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := geom.PointSet{}
+	for i := 0; i < 32; i++ {
+		points.Points = append(points.Points, geom.Point{
+			Coordinates: []float32{rng.Float32() * 255, rng.Float32() * 255, rng.Float32() * 255, 255},
+			ID:          i,
+			Weight:      float32(1 + rng.Intn(10)),
+		})
+	}
+
+	dendrogram := Agglomerate(points, geom.RedMeanDistance)
+
+	for n := 1; n < 32; n *= 2 {
+		smaller, larger := dendrogram.Cut(n), dendrogram.Cut(2*n)
+		if len(smaller.Points) != n || len(larger.Points) != 2*n {
+			t.Fatalf("expected cuts of %d and %d points, got %d and %d", n, 2*n, len(smaller.Points), len(larger.Points))
+		}
+
+		// the smaller cut is a subsequence of the larger one
+		next := 0
+		for _, point := range larger.Points {
+			if next < len(smaller.Points) && smaller.Points[next].ID == point.ID {
+				next++
+			}
+		}
+		if next != len(smaller.Points) {
+			t.Errorf("the cut at %d is not a subset of the cut at %d", n, 2*n)
+		}
+	}
+}
+
+// clusterPoints returns the colors of a width x 50 image of k Gaussian clusters as points (column by column,
+// with the offset of the pixel as ID), and the centers of the clusters
+func clusterPoints(k, width int) (geom.PointSet, color.Palette) {
+	img, optimal := testgen.GaussianClusters(k, width, 50, 6, rand.New(rand.NewSource(1)))
+
+	points := geom.PointSet{}
+	for x := 0; x < img.Bounds().Dx(); x++ {
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			clr := img.RGBAAt(x, y)
+			points.Points = append(points.Points, geom.Point{
+				Coordinates: []float32{float32(clr.R), float32(clr.G), float32(clr.B), float32(clr.A)},
+				ID:          x + y*img.Bounds().Dx(),
+			})
+		}
+	}
+
+	return points, optimal
+}
+
+// BenchmarkAssign compares the assignment step with every kind of closest mean search
+func BenchmarkAssign(b *testing.B) {
+	for _, k := range []int{8, 32, 128} {
+		points, _ := clusterPoints(k, 100)
+		for name, options := range map[string]Options{
+			"naive":    {},
+			"triangle": {Triangle: SquaredTriangleMetric},
+			"tree":     {Tree: true},
+		} {
+			KM := CreateKMeansProblemRand(points, k, geom.SquaredEuclideanDistance, rand.New(rand.NewSource(7)))
+			KM.SetOptions(options)
+
+			b.Run(fmt.Sprintf("k=%d/%s", k, name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					KM.assign()
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package kmeans
+
+import (
+	"math/rand"
+
+	"github.com/mielpeeters/dither/geom"
+)
+
+// Online is a k-means clustering that takes its points incrementally (row by row, or frame by frame),
+// without keeping them: its memory use doesn't grow with the amount of points.
+//
+// The first points (up to MaxBatchSize of its Options) are kept to start from: they are clustered like a Clustering.
+// After that, every point moves its closest mean toward it, by a step that gets smaller as the mean gets more points
+// (the online update of Sculley's mini-batch k-means).
+type Online struct {
+	// Accuracy and Consecutive are the convergence parameters of clustering the first points, see Clustering.Cluster
+	Accuracy    float64
+	Consecutive int
+
+	k              int
+	distanceMetric func(pnt1, pnt2 *geom.Point) float64
+	rng            *rand.Rand
+	options        Options
+	pinned         []geom.Point
+
+	// warmup holds the first points, until the means are started
+	warmup []geom.Point
+	means  geom.PointSet
+	// masses is the summed Mass of the points that each mean got
+	masses []float64
+}
+
+// NewOnline creates an online k-means clustering with k means, see Online.
+// All random choices are taken from rng, which must not be used concurrently.
+func NewOnline(k int, distanceMetric func(pnt1, pnt2 *geom.Point) float64, rng *rand.Rand) *Online {
+	return &Online{
+		Accuracy:       0.01,
+		Consecutive:    2,
+		k:              k,
+		distanceMetric: distanceMetric,
+		rng:            rng,
+		options:        DefaultOptions,
+	}
+}
+
+// SetOptions changes the options of the clustering, like Clustering.SetOptions.
+// MaxBatchSize is the amount of points that are kept to start the means from.
+func (online *Online) SetOptions(options Options) {
+	online.options = options.withDefaults()
+}
+
+// Pin fixes the given points as means, like Clustering.Pin. It needs to be called before adding points.
+func (online *Online) Pin(points ...geom.Point) {
+	online.pinned = points
+}
+
+// Add adds points to the clustering
+func (online *Online) Add(points ...geom.Point) {
+	for _, point := range points {
+		if online.means.Points == nil {
+			online.warmup = append(online.warmup, point)
+			if len(online.warmup) >= online.options.MaxBatchSize {
+				online.start()
+			}
+			continue
+		}
+
+		online.update(point)
+	}
+}
+
+// Means returns the current means. Until enough points were added to start the means from,
+// they are clustered from the points so far.
+func (online *Online) Means() geom.PointSet {
+	if online.means.Points == nil && len(online.warmup) > 0 {
+		online.start()
+	}
+
+	return geom.PointSet{Points: append([]geom.Point{}, online.means.Points...)}
+}
+
+// start clusters the kept points to get the first means, and forgets the points
+func (online *Online) start() {
+	KM, err := NewClustering(geom.PointSet{Points: online.warmup}, online.k, online.distanceMetric, online.rng)
+	if err != nil {
+		// there are no points to start from yet
+		return
+	}
+	KM.SetOptions(online.options)
+	KM.Pin(online.pinned...)
+	KM.Cluster(online.Accuracy, online.Consecutive)
+
+	online.means = KM.KMeans
+	// only the first k points are pinned
+	online.pinned = online.pinned[:KM.pinned]
+	online.masses = make([]float64, len(online.means.Points))
+	for i := range online.warmup {
+		point := &online.warmup[i]
+		online.masses[KM.closestMean(point, nil)] += float64(point.Mass())
+	}
+
+	online.warmup = nil
+}
+
+// update moves the mean closest to point toward it
+func (online *Online) update(point geom.Point) {
+	closest, minDist := 0, 0.0
+	for i := range online.means.Points {
+		if dist := online.distanceMetric(&point, &online.means.Points[i]); i == 0 || dist < minDist {
+			closest, minDist = i, dist
+		}
+	}
+
+	mass := float64(point.Mass())
+	online.masses[closest] += mass
+	if closest < len(online.pinned) {
+		// pinned means don't move
+		return
+	}
+
+	step := float32(mass / online.masses[closest])
+	mean := online.means.Points[closest].Coordinates
+	moved := make([]float32, len(mean))
+	for dim := range mean {
+		moved[dim] = mean[dim] + step*(point.Coordinates[dim]-mean[dim])
+	}
+	online.means.Points[closest].Coordinates = moved
+}
@@ -0,0 +1,89 @@
+package kmeans
+
+import (
+	"math"
+
+	"github.com/mielpeeters/dither/geom"
+)
+
+// Inertia returns the sum of the distances from all points to their closest mean, weighted by the Mass of the points.
+// Unlike TotalDist, which only covers the points of the last (mini batch) assignment step, it uses all points,
+// so it can be used to compare clusterings with a different k (lower is better, but it always drops as k grows).
+func (KM *Clustering) Inertia() float64 {
+	var inertia float64
+
+	for i := range KM.points.Points {
+		point := &KM.points.Points[i]
+		closest := KM.closestMean(point, nil)
+		inertia += KM.distanceMetric(point, &KM.KMeans.Points[closest]) * float64(point.Mass())
+	}
+
+	return inertia
+}
+
+// Silhouette returns the mean silhouette coefficient of the clustering, from -1 to 1: how much closer the points
+// are to the other points of their cluster than to those of the nearest other cluster. Higher is better, and unlike
+// Inertia it can be compared between clusterings with a different k. Distances are measured with the distance metric
+// of the problem, and points are weighted by their Mass.
+//
+// The computation is quadratic in the amount of points, so only sampleSize points (spread evenly over all points)
+// are used. A sampleSize of 0 uses all of them.
+func (KM *Clustering) Silhouette(sampleSize int) float64 {
+	samples := KM.points.Points
+	if sampleSize > 0 && len(samples) > sampleSize {
+		samples = make([]geom.Point, sampleSize)
+		for i := range samples {
+			samples[i] = KM.points.Points[i*len(KM.points.Points)/sampleSize]
+		}
+	}
+
+	labels := make([]int, len(samples))
+	for i := range samples {
+		labels[i] = KM.closestMean(&samples[i], nil)
+	}
+
+	var total, weights float64
+	for i := range samples {
+		// the summed distance to the points of each cluster, and the summed weight of those points
+		dists := make([]float64, len(KM.KMeans.Points))
+		masses := make([]float64, len(KM.KMeans.Points))
+
+		for j := range samples {
+			if i == j {
+				continue
+			}
+			mass := float64(samples[j].Mass())
+			dists[labels[j]] += KM.distanceMetric(&samples[i], &samples[j]) * mass
+			masses[labels[j]] += mass
+		}
+
+		own := labels[i]
+		mass := float64(samples[i].Mass())
+		// a point also stands for the other points it is weighted with, at a distance of 0
+		masses[own] += mass - 1
+
+		score := 0.0
+		if masses[own] > 0 {
+			a := dists[own] / masses[own]
+			b := math.Inf(1)
+			for cluster := range dists {
+				if cluster != own && masses[cluster] > 0 {
+					b = math.Min(b, dists[cluster]/masses[cluster])
+				}
+			}
+
+			if !math.IsInf(b, 1) && math.Max(a, b) > 0 {
+				score = (b - a) / math.Max(a, b)
+			}
+		}
+
+		total += score * mass
+		weights += mass
+	}
+
+	if weights == 0 {
+		return 0
+	}
+
+	return total / weights
+}
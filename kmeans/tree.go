@@ -0,0 +1,55 @@
+package kmeans
+
+import (
+	"github.com/mielpeeters/dither/geom"
+	"github.com/mielpeeters/dither/kdtree"
+)
+
+// treePoint is a point (or a mean, with its index) as stored in and searched for in the k-d tree of the means
+type treePoint struct {
+	point *geom.Point
+	index int
+}
+
+// Dimensions returns the amount of coordinates of the point
+func (tp *treePoint) Dimensions() int {
+	return len(tp.point.Coordinates)
+}
+
+// Dimension returns coordinate i of the point
+func (tp *treePoint) Dimension(i int) float64 {
+	return float64(tp.point.Coordinates[i])
+}
+
+// meanTree is a k-d tree of the means, to find the closest one without comparing against all of them
+type meanTree struct {
+	tree   *kdtree.KDTree
+	metric func(pnt1, pnt2 kdtree.Point) float64
+}
+
+// meanTree returns a k-d tree of the means, or nil if the options don't ask for one
+func (KM *Clustering) meanTree() *meanTree {
+	if !KM.options.Tree || len(KM.KMeans.Points) == 0 {
+		return nil
+	}
+
+	means := make([]kdtree.Point, len(KM.KMeans.Points))
+	for i := range KM.KMeans.Points {
+		means[i] = &treePoint{&KM.KMeans.Points[i], i}
+	}
+
+	return &meanTree{
+		tree: kdtree.New(means),
+		metric: func(pnt1, pnt2 kdtree.Point) float64 {
+			return KM.distanceMetric(pnt1.(*treePoint).point, pnt2.(*treePoint).point)
+		},
+	}
+}
+
+// closestMean returns the index of the mean closest to the point of query, like Clustering.closestMean.
+// The query is reused for every point, so that searching doesn't allocate.
+func (tree *meanTree) closestMean(query *treePoint) int {
+	nearest, _ := tree.tree.Nearest(query, tree.metric)
+
+	return nearest.Point.(*treePoint).index
+}
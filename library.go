@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mielpeeters/dither/colorpalette"
+)
+
+// libraryFile is the name of the palette library, with the palettes that -palette chooses from besides the
+// built-in ones. It is read from the current directory, else from the dither folder of the user config directory
+// (like ~/.config/dither, following XDG_CONFIG_HOME), else from the directory of the executable.
+const libraryFile = "colorpalette.json"
+
+// libraryDirs returns the directories that are searched for the palette library, in order
+func libraryDirs() []string {
+	dirs := []string{"."}
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, "dither"))
+	}
+	if executable, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(executable))
+	}
+
+	return dirs
+}
+
+// findLibrary returns the path of the palette library, or "" if there is none
+func findLibrary() string {
+	for _, dir := range libraryDirs() {
+		path := filepath.Join(dir, libraryFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// readPaletteFile reads the palettes of the file at path: a palette library or single palette in JSON,
+// a GIMP palette (.gpl) or a list of hex colors (.hex or .txt)
+func readPaletteFile(path string) ([]colorpalette.ColorPalette, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		palettes, err := colorpalette.ReadPalettes(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		return palettes, nil
+	case ".gpl":
+		palette, err := colorpalette.GetPaletteFromGPL(path)
+		return []colorpalette.ColorPalette{palette}, err
+	case ".hex", ".txt":
+		palette, err := colorpalette.GetPaletteFromHex(path)
+		return []colorpalette.ColorPalette{palette}, err
+	}
+
+	return nil, fmt.Errorf("%s is not a palette file: it needs the extension .json, .gpl, .hex or .txt", path)
+}
+
+// selectPalette returns the palette with the given name of palettes, which were read from path.
+// Without a name, it returns the only palette there is.
+func selectPalette(palettes []colorpalette.ColorPalette, name, path string) (*colorpalette.ColorPalette, error) {
+	if name == "" {
+		if len(palettes) != 1 {
+			return nil, fmt.Errorf("%s holds %d palettes, choose one with -palette", path, len(palettes))
+		}
+		return &palettes[0], nil
+	}
+
+	for i := range palettes {
+		if palettes[i].Name == name {
+			return &palettes[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s has no palette %q", path, name)
+}
+
+// paletteFileFormats are the extensions of the files that writePaletteFile writes
+var paletteFileFormats = []string{".json", ".gpl", ".hex", ".txt", ".png"}
+
+// writePaletteFile writes palette to the file at path, in the format of its extension: JSON, a GIMP palette (.gpl),
+// a list of hex colors (.hex or .txt), or a swatch image (.png)
+func writePaletteFile(palette colorpalette.ColorPalette, path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".png" {
+		return saveImage(palette.ToSwatchImage(64, 8, true), path, "png")
+	}
+
+	write := map[string]func(io.Writer) error{
+		".json": palette.Write,
+		".gpl":  palette.WriteGPL,
+		".hex":  palette.WriteHex,
+		".txt":  palette.WriteHex,
+	}[ext]
+	if write == nil {
+		return fmt.Errorf("%s is not a palette file: it needs one of the extensions %s", path, strings.Join(paletteFileFormats, ", "))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	logf(1, "saved %s", path)
+	return nil
+}
@@ -0,0 +1,125 @@
+// Command dither applies error diffusion dithering to an image, or to the frames of a video.
+//
+// Usage:
+//
+//	dither image -p input.jpg -o output.png -scale 4 -k 8
+//	dither palette -p input.jpg -k 8 -swatch palette.png
+//	dither palettes list
+//	dither palettes show pico-8
+//	dither compare input.jpg -dithers fs,atkinson,bayer8 -k 8,16
+//	dither batch -p 'photos/*.jpg' -o output/ -shared -k 8
+//	dither gif -frames video.mp4 -o output.gif -scale 4 -k 8
+//	dither game -p input.jpg -o output.gif -rules life
+//	dither particle -p input.jpg -o output.gif -simulation sort
+//	dither qr -frames video.mp4 -content https://example.com -o output.gif
+//	dither serve -addr localhost:8080
+//	dither version
+//	dither update
+//	dither init myproject
+//	dither run [task]
+//
+// All of the problems with the flags of a command are reported at once, and exit with code 2.
+// Other errors exit with code 1.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// command is a subcommand of dither
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+// commands lists the subcommands, in the order of the usage
+var commands = []command{
+	{"image", "dither an image", imageCommand},
+	{"palette", "create the palette of an image, or compare the quantizers", paletteCommand},
+	{"palettes", "list the named palettes, or show the colors of one", palettesCommand},
+	{"compare", "compare dithering algorithms and amounts of colors on an image, with quality metrics", compareCommand},
+	{"batch", "dither every image of a directory or glob pattern", batchCommand},
+	{"gif", "create a dithered gif video from frames, an animated gif or a video", gifCommand},
+	{"game", "play a game of color on a dithered image", gameCommand},
+	{"particle", "let the pixels of a dithered image move as particles", particleCommand},
+	{"qr", "embed a video in a qr code", qrCommand},
+	{"serve", "serve the playground, a web page to dither images in", serveCommand},
+	{"version", "show the version, build information and optional features", func([]string) error { printVersion(); return nil }},
+	{"update", "update the binary to the latest release", update},
+	{"init", "create a project directory", initProject},
+	{"run", "run a task of the project in the current directory", runProject},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	if len(name) > 0 && name[0] == '-' {
+		// the flags without a subcommand, from before there were subcommands
+		name, args = legacyCommand(os.Args[1:]), os.Args[1:]
+		fmt.Fprintf(os.Stderr, "dither without a subcommand is deprecated, use `dither %s`\n", name)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			err := cmd.run(args)
+			var usage *usageError
+			if errors.As(err, &usage) {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	if name != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+// usage prints the subcommands
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dither <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "run `dither <command> -h` for the flags of a command")
+}
+
+// legacyCommand returns the subcommand that the flags of an invocation without one belong to
+func legacyCommand(args []string) string {
+	for _, arg := range args {
+		flag := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)[0]
+		switch flag {
+		case "frames":
+			return "gif"
+		case "compare":
+			return "palette"
+		}
+	}
+
+	return "image"
+}
+
+// interruptContext returns a context that is cancelled on an interrupt, so that long running work
+// can stop and still save what it has done
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
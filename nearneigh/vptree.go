@@ -0,0 +1,138 @@
+package nearneigh
+
+import (
+	"sort"
+)
+
+// VPTree is a vantage point tree: a metric tree that finds the item closest to a target for any distance function
+// that is a metric (it satisfies the triangle inequality), unlike a k-d tree, whose pruning is only sound for
+// metrics that are axis-separable. Squared distances are not metrics, so use their square root
+// (sqrt of geom.RedMeanDistance, for example, which is close enough to one for palette lookups).
+// The tree is never changed after creation, so it can be queried concurrently.
+type VPTree[T any] struct {
+	items  []T
+	metric func(T, T) float64
+	nodes  []vpNode
+}
+
+// vpNode is one node of the flattened tree: an item (the vantage point), the median distance of the items below it
+// to that vantage point, and the positions of the subtrees of the items within and beyond that radius
+// in the nodes slice (-1 if there is none)
+type vpNode struct {
+	item            int
+	radius          float64
+	inside, outside int
+}
+
+// NewVPTree builds the tree of items, with the given distance metric
+func NewVPTree[T any](items []T, metric func(T, T) float64) *VPTree[T] {
+	tree := VPTree[T]{
+		items:  items,
+		metric: metric,
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	tree.build(order, make([]float64, len(items)))
+
+	return &tree
+}
+
+// build adds the subtree of the given items to the nodes, using the first one as the vantage point,
+// and returns its position. distances is scratch space with the same length as order.
+func (tree *VPTree[T]) build(order []int, distances []float64) int {
+	if len(order) == 0 {
+		return -1
+	}
+
+	position := len(tree.nodes)
+	tree.nodes = append(tree.nodes, vpNode{item: order[0], inside: -1, outside: -1})
+
+	rest, restDistances := order[1:], distances[1:]
+	if len(rest) == 0 {
+		return position
+	}
+
+	vantage := tree.items[order[0]]
+	for i, item := range rest {
+		restDistances[i] = tree.metric(vantage, tree.items[item])
+	}
+	sort.Sort(byDistance{rest, restDistances})
+
+	// the items up to the median are inside the radius, the others beyond it
+	median := (len(rest) - 1) / 2
+	tree.nodes[position].radius = restDistances[median]
+
+	inside := tree.build(rest[:median+1], restDistances[:median+1])
+	outside := tree.build(rest[median+1:], restDistances[median+1:])
+	tree.nodes[position].inside = inside
+	tree.nodes[position].outside = outside
+
+	return position
+}
+
+// Len returns the amount of items in the tree
+func (tree *VPTree[T]) Len() int {
+	return len(tree.items)
+}
+
+// Nearest returns the index (in the items the tree was built with) of the item closest to target,
+// and its distance. On a tie, the lowest index wins, like in color.Palette.Index.
+// It returns -1 if the tree is empty.
+func (tree *VPTree[T]) Nearest(target T) (int, float64) {
+	best, bestDist := -1, 0.0
+	if len(tree.nodes) > 0 {
+		tree.search(0, target, &best, &bestDist)
+	}
+
+	return best, bestDist
+}
+
+// search looks for a closer item than best in the subtree at position
+func (tree *VPTree[T]) search(position int, target T, best *int, bestDist *float64) {
+	if position < 0 {
+		return
+	}
+
+	node := tree.nodes[position]
+	dist := tree.metric(target, tree.items[node.item])
+	if *best < 0 || dist < *bestDist || (dist == *bestDist && node.item < *best) {
+		*best = node.item
+		*bestDist = dist
+	}
+
+	// by the triangle inequality, the items inside the radius are at least dist - radius away from target,
+	// and those beyond it at least radius - dist
+	if dist <= node.radius {
+		tree.search(node.inside, target, best, bestDist)
+		if dist+*bestDist >= node.radius {
+			tree.search(node.outside, target, best, bestDist)
+		}
+	} else {
+		tree.search(node.outside, target, best, bestDist)
+		if dist-*bestDist <= node.radius {
+			tree.search(node.inside, target, best, bestDist)
+		}
+	}
+}
+
+// byDistance sorts items together with their distances
+type byDistance struct {
+	items     []int
+	distances []float64
+}
+
+func (b byDistance) Len() int {
+	return len(b.items)
+}
+
+func (b byDistance) Less(i, j int) bool {
+	return b.distances[i] < b.distances[j]
+}
+
+func (b byDistance) Swap(i, j int) {
+	b.items[i], b.items[j] = b.items[j], b.items[i]
+	b.distances[i], b.distances[j] = b.distances[j], b.distances[i]
+}
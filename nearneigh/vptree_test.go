@@ -0,0 +1,57 @@
+package nearneigh
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mielpeeters/dither/geom"
+)
+
+// TestVPTree checks that the tree finds points as close as the ones found by comparing against every point,
+// for both the euclidean and the manhattan metric
+func TestVPTree(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomPoint := func() geom.Point {
+		return geom.Point{Coordinates: []float32{float32(rng.Intn(256)), float32(rng.Intn(256)), float32(rng.Intn(256))}}
+	}
+
+	metrics := map[string]func(geom.Point, geom.Point) float64{
+		"euclidean": func(pnt1, pnt2 geom.Point) float64 {
+			return math.Sqrt(geom.EuclidianDistance(pnt1, pnt2))
+		},
+		"manhattan": func(pnt1, pnt2 geom.Point) float64 {
+			var dist float64
+			for i := range pnt1.Coordinates {
+				dist += math.Abs(float64(pnt1.Coordinates[i] - pnt2.Coordinates[i]))
+			}
+			return dist
+		},
+	}
+
+	for name, metric := range metrics {
+		for _, size := range []int{0, 1, 2, 16, 256} {
+			points := []geom.Point{}
+			for i := 0; i < size; i++ {
+				points = append(points, randomPoint())
+			}
+			tree := NewVPTree(points, metric)
+
+			for i := 0; i < 500; i++ {
+				target := randomPoint()
+				index, dist := tree.Nearest(target)
+				if size == 0 {
+					if index != -1 {
+						t.Fatalf("empty tree returned index %d", index)
+					}
+					continue
+				}
+
+				want := metric(findNearestNeighbor(points, target, metric), target)
+				if dist != want || metric(points[index], target) != dist {
+					t.Fatalf("%s, %d points, %v: got index %d at distance %v, want distance %v", name, size, target.Coordinates, index, dist, want)
+				}
+			}
+		}
+	}
+}
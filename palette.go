@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/kdtree"
+	"github.com/mielpeeters/dither/process"
+)
+
+// paletteOptions are the flags that choose or create the palette, shared by the subcommands
+type paletteOptions struct {
+	k       int
+	name    string
+	file    string
+	expand  int
+	medoids bool
+	smart   bool
+	cache   bool
+	metric  string
+	// duotone and quantizer are only registered by the subcommands that create a palette from a single image
+	duotone   string
+	quantizer string
+	// quiet leaves out the progress of creating the palette, for subcommands that create several at once
+	quiet bool
+	// settings are the colorpalette settings of the options, set by setup
+	settings colorpalette.Settings
+}
+
+// addPaletteFlags registers the flags that choose the palette on flags
+func addPaletteFlags(flags *flag.FlagSet) *paletteOptions {
+	options := paletteOptions{quantizer: "kmeans"}
+
+	flags.IntVar(&options.k, "k", 10, "amount of colors in the palette, when it is created from the image")
+	flags.StringVar(&options.name, "palette", "", "name of a built-in palette (like pico-8), of a palette in the palette library ("+libraryFile+"), or of one in -palette-file, to use instead of creating one")
+	flags.StringVar(&options.file, "palette-file", "", "path to a palette file (.json, .gpl, or .hex with a hex color per line) to use instead of creating one, -palette chooses one if it holds several")
+	flags.IntVar(&options.expand, "expand", 0, "expand the palette of -palette to this amount of colors, by interpolating between its colors")
+	flags.BoolVar(&options.medoids, "medoids", false, "snap the colors of a kmeans palette to colors that occur in the image (for pixel art)")
+	flags.BoolVar(&options.smart, "smart-palette", false, "give the subjects of the image (the regions that stand out from their surroundings) more of the colors of a kmeans palette than the background")
+	flags.StringVar(&options.metric, "metric", "", "color distance of creating the palette and dithering: "+strings.Join(metricNames(), ", ")+" (by default redmean for the palette and euclidean for dithering)")
+	flags.BoolVar(&options.cache, "cache", false, "reuse the palette created earlier for the same image and settings, from the user cache directory")
+
+	return &options
+}
+
+// addCreateFlags registers the flags that only apply when creating the palette of a single image
+func (options *paletteOptions) addCreateFlags(flags *flag.FlagSet) {
+	flags.StringVar(&options.duotone, "duotone", "", "comma separated hex colors (like 1d2b53,ff77a8): create a palette of -k shades of these inks, from shadows to highlights")
+	flags.StringVar(&options.quantizer, "quantizer", "kmeans", "algorithm that creates the palette of an image: kmeans, bisecting, median-cut, octree or wu")
+}
+
+// check adds the problems with the options to problems. given holds the flags that were given.
+func (options *paletteOptions) check(problems *flagErrors, given map[string]bool) {
+	if options.k < 1 {
+		problems.add("the amount of colors (-k) needs to be at least 1")
+	}
+	if options.expand < 0 {
+		problems.add("the amount of colors to expand to (-expand) can't be negative")
+	}
+	if given["expand"] && options.creates() {
+		problems.add("-expand expands the palette of -palette or -palette-file, which isn't given")
+	}
+
+	// a palette file has its own names, which don't need to be bit depths
+	if _, err := colorpalette.ParseBitDepth(options.name); options.file == "" && err != nil && !errors.Is(err, colorpalette.ErrNotBitDepth) {
+		problems.add("the palette (-palette) can't be generated: %v", err)
+	}
+	if _, ok := colorpalette.MetricWithName(options.metric); options.metric != "" && !ok {
+		problems.add("the color distance (-metric) needs to be one of %s, not %q", strings.Join(metricNames(), ", "), options.metric)
+	}
+	if _, ok := colorpalette.QuantizerWithName(options.quantizer); !ok {
+		problems.add("the quantizer (-quantizer) needs to be kmeans, bisecting, median-cut, octree or wu, not %q", options.quantizer)
+	}
+	if options.duotone != "" {
+		for _, hex := range strings.Split(options.duotone, ",") {
+			if _, err := colorpalette.ParseHexColor(hex); err != nil {
+				problems.add("the duotone inks (-duotone) need to be hex colors: %v", err)
+			}
+		}
+	}
+
+	// the ways to get a palette exclude each other
+	ways := []string{}
+	for _, flag := range []string{"palette", "palette-file", "duotone", "quantizer"} {
+		// with -palette-file, -palette chooses one of its palettes
+		if given[flag] && !(flag == "palette" && given["palette-file"]) {
+			ways = append(ways, "-"+flag)
+		}
+	}
+	if len(ways) > 1 {
+		problems.add("%s each choose how the palette is made, give only one of them", strings.Join(ways, " and "))
+	}
+	if options.medoids && (!options.creates() || options.duotone != "" || options.quantizer != "kmeans") {
+		problems.add("-medoids only applies to palettes created with the kmeans quantizer")
+	}
+	if options.smart && (!options.creates() || options.duotone != "" || options.quantizer != "kmeans") {
+		problems.add("-smart-palette only applies to palettes created with the kmeans quantizer")
+	}
+}
+
+// setup keeps the colorpalette settings of the options as their settings, and applies them to the package settings
+// of colorpalette as well, for the packages that create palettes with those (like gifeo).
+// The options need to be checked first.
+func (options *paletteOptions) setup() error {
+	if verbosity < 1 {
+		options.quiet = true
+	}
+
+	settings, err := options.newSettings()
+	if err != nil {
+		return err
+	}
+	options.settings = settings
+	colorpalette.UseSettings(settings)
+
+	return nil
+}
+
+// newSettings returns the package settings of colorpalette, changed by the options, without changing the package settings
+func (options *paletteOptions) newSettings() (colorpalette.Settings, error) {
+	settings := colorpalette.CurrentSettings()
+	settings.Rand = seededRand(0)
+
+	if options.medoids {
+		settings.KMOptions.Medoids = true
+	}
+	if options.smart {
+		settings.Sampling = colorpalette.SampleSaliency
+	}
+
+	if metric, ok := colorpalette.MetricWithName(options.metric); ok {
+		settings.Metric = metric
+	}
+
+	if options.cache {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return settings, err
+		}
+		settings.CacheDir = filepath.Join(dir, "dither", "palettes")
+	}
+
+	return settings, nil
+}
+
+// creates returns whether the options create a palette, instead of choosing one with -palette or -palette-file
+func (options *paletteOptions) creates() bool {
+	return options.name == "" && options.file == ""
+}
+
+// named returns the palette chosen with -palette or -palette-file (and expanded with -expand), with its k-d tree
+// index if the palette library has one, or a nil palette if none was chosen
+func (options *paletteOptions) named() (color.Palette, *kdtree.PaletteIndex, error) {
+	if options.creates() {
+		return nil, nil, nil
+	}
+
+	path := options.file
+	if path == "" {
+		if named, ok := colorpalette.Named(options.name); ok {
+			palette, index := options.expanded(named.ToPalette(), nil)
+			return palette, index, nil
+		}
+
+		path = findLibrary()
+		if path == "" {
+			return nil, nil, fmt.Errorf("%q is not a built-in palette, and there is no palette library (%s) in %s", options.name, libraryFile, strings.Join(libraryDirs(), ", "))
+		}
+	}
+
+	palettes, err := readPaletteFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	selected, err := selectPalette(palettes, options.name, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var index *kdtree.PaletteIndex
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		// palettes from a library keep their k-d tree index next to it, so it isn't rebuilt every run
+		index = selected.LoadIndex(path)
+	}
+
+	palette, index := options.expanded(selected.ToPalette(), index)
+	return palette, index, nil
+}
+
+// expanded returns palette expanded to -expand colors with its index, which belongs to the palette before expanding,
+// so it is left out if the palette is expanded
+func (options *paletteOptions) expanded(palette color.Palette, index *kdtree.PaletteIndex) (color.Palette, *kdtree.PaletteIndex) {
+	if options.expand <= len(palette) {
+		return palette, index
+	}
+
+	selected := colorpalette.FromPalette(palette, options.name)
+	expanded := selected.Expand(options.expand)
+	return expanded.ToPalette(), nil
+}
+
+// create creates a palette of -k colors for img, with the duotone inks or the quantizer of the options.
+// rng is the source of randomness of the palette, which is only used by this call.
+func (options *paletteOptions) create(ctx context.Context, img *image.RGBA, rng *rand.Rand) (color.Palette, error) {
+	settings := options.settings
+	settings.Rand = rng
+
+	if options.duotone != "" {
+		inks := []color.RGBA{}
+		for _, hex := range strings.Split(options.duotone, ",") {
+			ink, err := colorpalette.ParseHexColor(hex)
+			if err != nil {
+				return nil, err
+			}
+			inks = append(inks, ink)
+		}
+
+		shades := settings.Duotone(img, options.k, inks...)
+		return shades.ToPalette(), nil
+	}
+
+	if options.quantizer != "kmeans" {
+		quantize, _ := colorpalette.QuantizerWithName(options.quantizer)
+		return quantize(img, options.k), nil
+	}
+
+	var onIteration func(colorpalette.Iteration)
+	if !options.quiet {
+		onIteration = func(it colorpalette.Iteration) {
+			fmt.Fprintf(os.Stderr, "\rcreating palette: run %d/%d, iteration %d, error %.0f   ", it.Restart+1, settings.KMTimes, it.Iteration, it.Error)
+		}
+	}
+
+	palette, err := settings.CreateContext(ctx, img, options.k, onIteration)
+	if !options.quiet {
+		fmt.Fprintln(os.Stderr)
+	}
+	if ctx.Err() != nil {
+		return nil, errors.New("interrupted while creating the palette")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return palette, nil
+}
+
+// palette returns the palette chosen with -palette, or else creates one for img
+func (options *paletteOptions) palette(ctx context.Context, img *image.RGBA) (color.Palette, *kdtree.PaletteIndex, error) {
+	palette, index, err := options.named()
+	if err != nil || palette != nil {
+		return palette, index, err
+	}
+
+	palette, err = options.create(ctx, img, options.settings.Rand)
+	return palette, nil, err
+}
+
+// colorIndex returns the index that finds the closest colors of palette by the distance of -metric,
+// which is index if no -metric is given and index isn't nil
+func (options *paletteOptions) colorIndex(palette color.Palette, index *kdtree.PaletteIndex) process.ColorIndex {
+	if metric, ok := colorpalette.MetricWithName(options.metric); ok {
+		return colorpalette.NewMetricIndex(palette, metric.Distance)
+	}
+	if index == nil {
+		return kdtree.NewPaletteIndex(palette)
+	}
+
+	return index
+}
+
+// metricNames returns the names of colorpalette.Metrics
+func metricNames() []string {
+	names := make([]string, len(colorpalette.Metrics))
+	for i, metric := range colorpalette.Metrics {
+		names[i] = metric.Name
+	}
+
+	return names
+}
+
+// openInput opens the image at path, or reads it from stdin if path is -, turned by its EXIF orientation
+func openInput(path string) (image.Image, error) {
+	if path == "-" {
+		img, _, err := imgutil.Decode(os.Stdin)
+		return img, err
+	}
+
+	// like imgutil.OpenImage, without printing the error, which the subcommands return
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := imgutil.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	return img, nil
+}
+
+// openScaled opens the image at path (see openInput), and resizes it to size
+func openScaled(path string, size *sizeOptions) (image.Image, *image.RGBA, error) {
+	img, err := openInput(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scaledImage, err := size.apply(img)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return img, scaledImage, nil
+}
+
+// paletteCommand creates the palette of an image, and prints its colors or compares the quantizers,
+// without dithering the image
+func paletteCommand(args []string) error {
+	if len(args) > 0 && args[0] == "extract" {
+		return extractPalette(args[1:])
+	}
+
+	flags := flag.NewFlagSet("palette", flag.ExitOnError)
+	inputPath := flags.String("p", "", "path to the input image, - reads it from stdin")
+	size := addSizeFlags(flags, "factor by which the image is scaled down before creating the palette")
+	swatchPath := flags.String("swatch", "", "path to save a preview image of the palette to (png)")
+	report := flags.Bool("report", false, "print how well the palette covers the colors of the (scaled) input image")
+	compare := flags.Bool("compare", false, "compare the palettes of all quantizers for the (scaled) input image")
+	options := addPaletteFlags(flags)
+	options.addCreateFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither palette -p input.jpg [-k 8] [-swatch palette.png] [-report] [-compare]")
+		fmt.Fprintln(flags.Output(), "       dither palette extract [-k 8] input.jpg -o palette.json (see dither palette extract -h)")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *inputPath == "" {
+		problems.add("provide an input image (-p)")
+	}
+	size.check(problems)
+	given := givenFlags(flags)
+	if *compare {
+		// compare creates a palette with every quantizer, without saving or reporting one
+		for _, flag := range []string{"palette", "palette-file", "duotone", "quantizer", "swatch", "report"} {
+			if given[flag] {
+				problems.add("-compare compares the quantizers, it can't be combined with -%s", flag)
+			}
+		}
+	}
+	options.check(problems, given)
+	if err := problems.err(); err != nil {
+		return err
+	}
+	if err := options.setup(); err != nil {
+		return err
+	}
+
+	_, scaledImage, err := openScaled(*inputPath, size)
+	if err != nil {
+		return err
+	}
+
+	if *compare {
+		fmt.Print(colorpalette.FormatComparisons(colorpalette.Compare(scaledImage, options.k)))
+		return nil
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	palette, _, err := options.palette(ctx, scaledImage)
+	if err != nil {
+		return err
+	}
+
+	selected := colorpalette.FromPalette(palette, "selected")
+	for _, c := range palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Printf("%02x%02x%02x\n", r>>8, g>>8, b>>8)
+	}
+
+	if *swatchPath != "" {
+		imgutil.SavePNG(selected.ToSwatchImage(64, 8, true), *swatchPath)
+	}
+
+	if *report {
+		fmt.Print(selected.Coverage(scaledImage))
+	}
+
+	return nil
+}
+
+// extractPalette creates the palette of an image, and only writes it to a palette file
+func extractPalette(args []string) error {
+	flags := flag.NewFlagSet("palette extract", flag.ExitOnError)
+	outputPath := flags.String("o", "palette.json", "path to the palette file, its extension chooses the format: "+strings.Join(paletteFileFormats, ", ")+" (a swatch image)")
+	size := addSizeFlags(flags, "factor by which the image is scaled down before creating the palette")
+	options := addPaletteFlags(flags)
+	options.addCreateFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither palette extract [-k 8] input.jpg -o palette.json")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if flags.NArg() != 1 {
+		problems.add("provide one input image, - reads it from stdin")
+	}
+	size.check(problems)
+	known := false
+	ext := strings.ToLower(filepath.Ext(*outputPath))
+	for _, format := range paletteFileFormats {
+		known = known || ext == format
+	}
+	if !known {
+		problems.add("the palette file (-o) needs one of the extensions %s, not %q", strings.Join(paletteFileFormats, ", "), ext)
+	}
+	options.check(problems, givenFlags(flags))
+	if err := problems.err(); err != nil {
+		return err
+	}
+	if err := options.setup(); err != nil {
+		return err
+	}
+
+	_, scaledImage, err := openScaled(flags.Arg(0), size)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	palette, _, err := options.palette(ctx, scaledImage)
+	if err != nil {
+		return err
+	}
+
+	// named after the file, which is the name that -palette chooses it by
+	name := strings.TrimSuffix(filepath.Base(*outputPath), filepath.Ext(*outputPath))
+	return writePaletteFile(colorpalette.FromPalette(palette, name), *outputPath)
+}
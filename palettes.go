@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mielpeeters/dither/colorpalette"
+)
+
+// palettesCommand lists the palettes that -palette can choose, or shows the colors of one of them
+func palettesCommand(args []string) error {
+	action := ""
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "list":
+		return listPalettes(args[1:])
+	case "show":
+		return showPalette(args[1:])
+	case "index":
+		return indexPalettes(args[1:])
+	}
+
+	fmt.Fprintln(os.Stderr, "usage: dither palettes list [-preview] [-palette-file palettes.json]")
+	fmt.Fprintln(os.Stderr, "       dither palettes show [-o swatch.png] [-palette-file palettes.json] <name>")
+	fmt.Fprintln(os.Stderr, "       dither palettes index [-palette-file palettes.json]")
+	if action == "-h" || action == "-help" || action == "help" {
+		return nil
+	}
+	return &usageError{command: "palettes", problems: []string{"choose list, show or index"}}
+}
+
+// listPalettes prints the names of the built-in palettes, and of those in the palette library or -palette-file
+func listPalettes(args []string) error {
+	flags := flag.NewFlagSet("palettes list", flag.ExitOnError)
+	file := flags.String("palette-file", "", "palette file (.json, .gpl or .hex) to list the palettes of, instead of the palette library")
+	preview := flags.Bool("preview", false, "show the colors of every palette, with the colors of the terminal")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither palettes list [-preview] [-palette-file palettes.json]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return &usageError{command: flags.Name(), problems: []string{fmt.Sprintf("list takes no arguments, not %q", flags.Args())}}
+	}
+
+	palettes := []colorpalette.ColorPalette{}
+	for _, name := range colorpalette.Names() {
+		palette, _ := colorpalette.Named(name)
+		palettes = append(palettes, palette)
+	}
+	fmt.Println("built-in:")
+	printPalettes(os.Stdout, palettes, *preview)
+	fmt.Println("  rgb332, rgb565 and gray-N (like gray-4 or gray-16-gamma-2.2) are made from their name")
+
+	path := *file
+	if path == "" {
+		path = findLibrary()
+	}
+	if path == "" {
+		fmt.Printf("\nno palette library (%s) in %s\n", libraryFile, strings.Join(libraryDirs(), ", "))
+		return nil
+	}
+
+	palettes, err := readPaletteFile(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%s:\n", path)
+	printPalettes(os.Stdout, palettes, *preview)
+
+	return nil
+}
+
+// indexPalettes writes the k-d tree index of every palette of the palette library or -palette-file next to it,
+// where -palette finds them. Without them, the indexes are built when they are needed, and cached in the user cache directory.
+func indexPalettes(args []string) error {
+	flags := flag.NewFlagSet("palettes index", flag.ExitOnError)
+	file := flags.String("palette-file", "", "palette JSON file to index the palettes of, instead of the palette library")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither palettes index [-palette-file palettes.json]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if flags.NArg() > 0 {
+		problems.add("index takes no arguments, not %q", flags.Args())
+	}
+	if *file != "" && strings.ToLower(filepath.Ext(*file)) != ".json" {
+		problems.add("only the palettes of a JSON file (-palette-file) are read with their index")
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+
+	path := *file
+	if path == "" {
+		path = findLibrary()
+	}
+	if path == "" {
+		return fmt.Errorf("there is no palette library (%s) in %s", libraryFile, strings.Join(libraryDirs(), ", "))
+	}
+
+	palettes, err := readPaletteFile(path)
+	if err != nil {
+		return err
+	}
+	for _, palette := range palettes {
+		indexPath := colorpalette.IndexPath(path, palette.Name)
+		if err := palette.ToIndexFile(indexPath); err != nil {
+			return err
+		}
+		logf(1, "wrote %s", indexPath)
+	}
+
+	return nil
+}
+
+// printPalettes prints the names of palettes with their amount of colors, and their colors if preview is set
+func printPalettes(w io.Writer, palettes []colorpalette.ColorPalette, preview bool) {
+	width := 0
+	for _, palette := range palettes {
+		if len(palette.Name) > width {
+			width = len(palette.Name)
+		}
+	}
+
+	for _, palette := range palettes {
+		fmt.Fprintf(w, "  %-*s %4d colors", width, palette.Name, len(palette.Colors))
+		if preview {
+			fmt.Fprint(w, "  ", ansiSwatch(palette.ToPalette(), 1, 32))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// showPalette shows the colors of a palette, in the terminal or as a swatch image
+func showPalette(args []string) error {
+	flags := flag.NewFlagSet("palettes show", flag.ExitOnError)
+	outputPath := flags.String("o", "", "path to save a swatch image of the palette to (png), instead of showing it in the terminal")
+	file := flags.String("palette-file", "", "palette file (.json, .gpl or .hex) to find the palette in, instead of the built-in palettes and the palette library")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither palettes show [-o swatch.png] [-palette-file palettes.json] <name>")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if flags.NArg() == 0 && *file == "" {
+		problems.add("provide the name of the palette")
+	}
+	if flags.NArg() > 1 {
+		problems.add("show takes one palette name, not %q", flags.Args())
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+
+	options := paletteOptions{name: flags.Arg(0), file: *file}
+	palette, _, err := options.named()
+	if err != nil {
+		return err
+	}
+
+	if *outputPath != "" {
+		selected := colorpalette.FromPalette(palette, options.name)
+		return saveImage(selected.ToSwatchImage(64, 8, true), *outputPath, "png")
+	}
+
+	for _, clr := range palette {
+		r, g, b, _ := clr.RGBA()
+		fmt.Printf("%s #%02x%02x%02x\n", ansiSwatch(color.Palette{clr}, 3, 1), r>>8, g>>8, b>>8)
+	}
+
+	return nil
+}
+
+// ansiSwatch returns the first max colors of palette as blocks of width characters, in the 24-bit colors of the terminal.
+// With NO_COLOR set, it returns no blocks.
+func ansiSwatch(palette color.Palette, width, max int) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return ""
+	}
+
+	var swatch strings.Builder
+	for i, clr := range palette {
+		if i == max {
+			swatch.WriteString(" …")
+			break
+		}
+		r, g, b, _ := clr.RGBA()
+		fmt.Fprintf(&swatch, "\033[48;2;%d;%d;%dm%s\033[0m", r>>8, g>>8, b>>8, strings.Repeat(" ", width))
+	}
+
+	return swatch.String()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mielpeeters/dither/particled"
+)
+
+// particleCommand dithers an image, and lets its pixels move as particles, saving the simulation as a gif or video
+func particleCommand(args []string) error {
+	flags := flag.NewFlagSet("particle", flag.ExitOnError)
+	inputPath := flags.String("p", "", "path to the input image")
+	outputPath := flags.String("o", "output.gif", "path to the output gif, or mp4 or webm video (using ffmpeg)")
+	size := addSizeFlags(flags, "factor by which the image is scaled down before dithering")
+	simulation := flags.String("simulation", "gravity", "how the pixels move: gravity (pixels of the same color attract, others repel) or sort (by color, from left to right)")
+	length := flags.Int("length", 50, "amount of frames to simulate")
+	timestep := flags.Float64("timestep", 0.1, "time between two frames of the simulation")
+	delay := flags.Int("delay", 8, "delay between the frames, in 100ths of a second")
+	video := addVideoFlags(flags)
+	ditherName := addDitherFlag(flags)
+	options := addPaletteFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither particle -p input.jpg -o output.gif [-simulation gravity] [-length 50]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *inputPath == "" {
+		problems.add("provide an input image (-p)")
+	}
+	if *length < 1 {
+		problems.add("the amount of frames (-length) needs to be at least 1")
+	}
+	size.check(problems)
+	if *simulation != "gravity" && *simulation != "sort" {
+		problems.add("the simulation (-simulation) needs to be gravity or sort, not %q", *simulation)
+	}
+	algorithm := ditherFlag(problems, *ditherName)
+	given := givenFlags(flags)
+	checkVideo(problems, video, *outputPath, given)
+	options.check(problems, given)
+	if err := problems.err(); err != nil {
+		return err
+	}
+	if err := checkFFmpeg(*outputPath); err != nil {
+		return err
+	}
+	if err := options.setup(); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	timer := newTiming()
+
+	done := timer.stage("dither", "")
+	paletted, err := ditherInput(ctx, *inputPath, size, options, nil, algorithm)
+	done()
+	if err != nil {
+		return err
+	}
+
+	var calculation particled.Calculation
+	settings := map[string]any{}
+	switch *simulation {
+	case "gravity":
+		calculation = particled.GravityCalculation
+		settings["likeness"] = func(i, j int) float64 {
+			if i == j {
+				return 1
+			}
+			return -1
+		}
+	case "sort":
+		calculation = particled.SortCalculation
+		settings["width"] = paletted.Rect.Dx()
+		settings["k"] = len(paletted.Palette)
+	}
+
+	done = timer.stage("simulate", "")
+	frames := particled.FromPaletted(paletted, calculation, *timestep, settings).Simulate(*length)
+	done()
+
+	done = timer.stage("encode", "")
+	err = encodeFrames(frames, *outputPath, *delay, video)
+	done()
+	if err != nil {
+		return err
+	}
+
+	logf(1, "saved %s", *outputPath)
+	timer.summary()
+
+	return nil
+}
@@ -7,10 +7,7 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"math"
 
-	"github.com/kyroy/kdtree"
-	"github.com/kyroy/kdtree/kdrange"
 	"github.com/mielpeeters/dither/geom"
 	"github.com/mielpeeters/pacebar"
 )
@@ -42,15 +39,20 @@ type colourClub struct {
 	pixicles []color.Color
 }
 
+// neighborRadius is the distance up to which pixicles affect each other, and the cell size of the grid of pixicles
+const neighborRadius = 5
+
 // Calculation is a type alias for a function which calculates the new
-// position and velocity of pix, based on pixs, timestep and options
-type Calculation func(pix kdtree.Point, pixs *kdtree.KDTree, timestep float64, options map[string]any)
+// position and velocity of pix, based on pixs (by position, see geom.Grid.Neighbors), timestep and options
+type Calculation func(pix *Pixicle, pixs *geom.Grid[*Pixicle], timestep float64, options map[string]any)
 
 // Particled is a type of image that stores pixels at variable locations.
 // Each pixel is represented as a Pixicle.
 type Particled struct {
-	// Particles is a pointer to a slice of all pixicles
-	Pixicles *kdtree.KDTree
+	// Pixicles holds all pixicles
+	Pixicles []*Pixicle
+	// grid holds the pixicles by their position, it is built again after every timestep
+	grid *geom.Grid[*Pixicle]
 	// Palette holds the used colourpalette for this image
 	Palette color.Palette
 	// Calc is a function that calculates the new position and
@@ -72,6 +74,11 @@ func (pix *Pixicle) Dimension(i int) float64 {
 	return pix.Position[i]
 }
 
+// PointID returns the ID of the pixicle, so that it can be found by ID in a kdtree.KDTree
+func (pix *Pixicle) PointID() int {
+	return pix.id
+}
+
 func (pix *Pixicle) toCoordinate() coordinate {
 	return coordinate{
 		x: int(pix.Position[0]),
@@ -167,15 +174,19 @@ func (p *Particled) ToPaletted() *image.Paletted {
 
 	colourClubs := make(map[coordinate]*colourClub)
 
-	fullRange := kdrange.New(0, float64(p.width), 0, float64(p.height))
-	for _, pixicle := range p.Pixicles.RangeSearch(fullRange) {
-		coor := pixicle.(*Pixicle).toCoordinate()
+	box := p.box()
+	for _, pixicle := range p.Pixicles {
+		if !box.Contains(pixicle.Position[:]) {
+			continue
+		}
+
+		coor := pixicle.toCoordinate()
 		if colourClubs[coor] == nil {
 			colourClubs[coor] = &colourClub{
 				pixicles: []color.Color{},
 			}
 		}
-		(colourClubs[coor]).add(p.Palette[pixicle.(*Pixicle).Colour])
+		(colourClubs[coor]).add(p.Palette[pixicle.Colour])
 	}
 
 	for x := 0; x <= p.width; x++ {
@@ -193,19 +204,20 @@ func (p *Particled) ToPaletted() *image.Paletted {
 
 // calculate calls the calculation function on all pixicles
 func (p *Particled) calculate() {
-	fullRange := kdrange.New(-100000, 100000, -1000000, 100000)
-	for _, pixicle := range p.Pixicles.RangeSearch(fullRange) {
-		p.Calc(pixicle, p.Pixicles, p.Timestep, p.Options)
+	for _, pixicle := range p.Pixicles {
+		p.Calc(pixicle, p.grid, p.Timestep, p.Options)
 		p.pb.Done(1)
 	}
 }
 
 // update updates all pixicles to the new positions and velocities
 func (p *Particled) update() {
-	fullRange := kdrange.New(0, float64(p.width), 0, float64(p.height))
-	for _, pixicle := range p.Pixicles.RangeSearch(fullRange) {
-		pixicle.(*Pixicle).Position = pixicle.(*Pixicle).newPosition
-		pixicle.(*Pixicle).Velocity = pixicle.(*Pixicle).newVelocity
+	box := p.box()
+	for _, pixicle := range p.Pixicles {
+		if box.Contains(pixicle.Position[:]) {
+			pixicle.Position = pixicle.newPosition
+			pixicle.Velocity = pixicle.newVelocity
+		}
 	}
 }
 
@@ -213,14 +225,21 @@ func (p *Particled) update() {
 func (p *Particled) Iterate() {
 	p.calculate()
 	p.update()
-	p.Pixicles.Balance()
+	// the positions changed, so the grid needs to be built again
+	p.buildGrid()
 }
 
-func squareDist(p1, p2 *Pixicle) float64 {
-	tmp := math.Pow((p1.Position[0] - p2.Position[0]), 2)
-	tmp += math.Pow((p1.Position[1] - p2.Position[1]), 2)
+// buildGrid puts all pixicles in the grid, at their current position
+func (p *Particled) buildGrid() {
+	p.grid = geom.NewGrid[*Pixicle](neighborRadius)
+	for _, pixicle := range p.Pixicles {
+		p.grid.Insert(pixicle.Position, pixicle)
+	}
+}
 
-	return tmp
+// box returns the bounding box of the image
+func (p *Particled) box() geom.BoundingBox {
+	return geom.BoundingBox{Lower: []float64{0, 0}, Upper: []float64{float64(p.width), float64(p.height)}}
 }
 
 // force along axis between two points, positive if attraction
@@ -228,7 +247,7 @@ func squareDist(p1, p2 *Pixicle) float64 {
 func gravityForce(p1, p2 *Pixicle, likeness float64) geom.Vec {
 	direction := p2.Position.Sub(&p1.Position)
 	var force float64
-	dist := squareDist(p1, p2)
+	dist := p1.Position.SquaredDistance(&p2.Position)
 
 	if dist > 0 {
 		if dist < 0.05 {
@@ -243,15 +262,15 @@ func gravityForce(p1, p2 *Pixicle, likeness float64) geom.Vec {
 	return direction.Scale(force)
 }
 
-func totalGravityForce(pix kdtree.Point, pixs *kdtree.KDTree, options map[string]any) geom.Vec {
+func totalGravityForce(pix *Pixicle, pixs *geom.Grid[*Pixicle], options map[string]any) geom.Vec {
 	var force geom.Vec
 	var likeness float64
 	var currentForce geom.Vec
 	// current implementation is very naive Euler...
 
-	for _, other := range pixs.RangeSearch(kdrange.Range{{pix.Dimension(0) - 5, pix.Dimension(0) + 5}, {pix.Dimension(1) - 5, pix.Dimension(1) + 5}}) {
-		likeness = options["likeness"].(func(int, int) float64)(pix.(*Pixicle).Colour, other.(*Pixicle).Colour)
-		currentForce = gravityForce(pix.(*Pixicle), other.(*Pixicle), likeness)
+	for _, other := range pixs.Neighbors(pix.Position, neighborRadius) {
+		likeness = options["likeness"].(func(int, int) float64)(pix.Colour, other.Colour)
+		currentForce = gravityForce(pix, other, likeness)
 		force = force.Add(&currentForce)
 	}
 
@@ -259,9 +278,7 @@ func totalGravityForce(pix kdtree.Point, pixs *kdtree.KDTree, options map[string
 }
 
 // eulerMethod uses velocity and force to set new position and velocity
-func eulerMethod(pix kdtree.Point, force geom.Vec, timestep, damping float64) {
-	px := pix.(*Pixicle)
-
+func eulerMethod(px *Pixicle, force geom.Vec, timestep, damping float64) {
 	deltaPosition := px.Velocity.Scale(timestep)
 	px.newPosition = px.Position.Add(&deltaPosition)
 
@@ -280,7 +297,7 @@ func eulerMethod(pix kdtree.Point, force geom.Vec, timestep, damping float64) {
 // The options parameter contains the keys ..., which map to values ...:
 //   - "likeness" : func(i,j int) float64 : returns likeness between two colourIndexes.
 //   - "..."
-func GravityCalculation(pix kdtree.Point, pixs *kdtree.KDTree, timestep float64, options map[string]any) {
+func GravityCalculation(pix *Pixicle, pixs *geom.Grid[*Pixicle], timestep float64, options map[string]any) {
 	// TODO: the RK4 implementation!
 
 	force := totalGravityForce(pix, pixs, options)
@@ -301,7 +318,7 @@ func sortForce(pix *Pixicle, width int, k int) geom.Vec {
 // SortCalculation tempts to sort the pixels horizontally
 // options["width"] -> width of the particled image
 // options["k"] -> amount of colours
-func SortCalculation(pix *Pixicle, pixs []*Pixicle, timestep float64, options map[string]any) {
+func SortCalculation(pix *Pixicle, pixs *geom.Grid[*Pixicle], timestep float64, options map[string]any) {
 	force := sortForce(pix, options["width"].(int), options["k"].(int))
 
 	eulerMethod(pix, force, timestep, 0.2)
@@ -311,7 +328,7 @@ func SortCalculation(pix *Pixicle, pixs []*Pixicle, timestep float64, options ma
 func FromPaletted(paletted *image.Paletted, Calc Calculation, timestep float64, options map[string]any) *Particled {
 	X := paletted.Rect.Dx()
 	Y := paletted.Rect.Dy()
-	pixs := make([]kdtree.Point, X*Y)
+	pixs := make([]*Pixicle, X*Y)
 
 	for x := 0; x < X; x++ {
 		for y := 0; y < Y; y++ {
@@ -327,11 +344,8 @@ func FromPaletted(paletted *image.Paletted, Calc Calculation, timestep float64,
 		}
 	}
 
-	// creating the KD tree
-	kd := kdtree.New(pixs)
-
-	return &Particled{
-		Pixicles: kd,
+	p := &Particled{
+		Pixicles: pixs,
 		Palette:  paletted.Palette,
 		Calc:     Calc,
 		width:    X,
@@ -339,6 +353,9 @@ func FromPaletted(paletted *image.Paletted, Calc Calculation, timestep float64,
 		Options:  options,
 		Timestep: timestep,
 	}
+	p.buildGrid()
+
+	return p
 }
 
 // Simulate creates a slice of paletted frames using the particled starting point
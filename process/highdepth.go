@@ -0,0 +1,98 @@
+package process
+
+import (
+	"errors"
+	"image"
+	"image/color"
+
+	"github.com/mielpeeters/dither/kdtree"
+	"golang.org/x/image/draw"
+)
+
+// ErrPaletteTooLarge is returned when dithering to a paletted image with more than 256 colors,
+// which don't fit in its 8-bit color indices. Use ApplyErrorDiffusionRGBA or ApplyErrorDiffusionRGBA64 instead.
+var ErrPaletteTooLarge = errors.New("process: a paletted image can hold at most 256 colors")
+
+// DownscaleRGBA64 scales the image down with a given integer factor, like Downscale,
+// but keeps 16 bits per channel (of 16-bit PNG images, for example)
+func DownscaleRGBA64(img image.Image, factor int) *image.RGBA64 {
+	dst := image.NewRGBA64(image.Rect(0, 0, img.Bounds().Max.X/factor, img.Bounds().Max.Y/factor))
+	draw.NearestNeighbor.Scale(dst, dst.Rect, img, img.Bounds(), draw.Over, nil)
+
+	return dst
+}
+
+// ResizeRGBA64 resizes the image to x, y like Resize, but keeps 16 bits per channel
+func ResizeRGBA64(img image.Image, x, y int) *image.RGBA64 {
+	dst := image.NewRGBA64(image.Rect(0, 0, x, y))
+	draw.BiLinear.Scale(dst, dst.Rect, img, img.Bounds(), draw.Over, nil)
+
+	return dst
+}
+
+// ApplyErrorDiffusionRGBA64 applies the error diffusion dithering like ApplyErrorDiffusionRGBA, but with 16 bits
+// per channel: the input, the palette colors and the diffused errors keep their full precision, and the result
+// can be saved as a 16-bit PNG. Any amount of palette colors can be used. img is not changed.
+func ApplyErrorDiffusionRGBA64(img image.Image, palette color.Palette, diffusers *ErrorDiffusionMatrix) *image.RGBA64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	newImage := image.NewRGBA64(bounds)
+	if len(palette) == 0 {
+		return newImage
+	}
+
+	index := kdtree.NewPaletteIndex(palette)
+
+	// the working copy of the image, which receives the errors: 4 channels per pixel, in row-major order
+	work := make([]float64, 4*width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			copy(work[4*(x+y*width):], []float64{float64(r), float64(g), float64(b), float64(a)})
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pixel := work[4*(x+y*width) : 4*(x+y*width)+4]
+
+			old := color.RGBA64{clamp16(pixel[0]), clamp16(pixel[1]), clamp16(pixel[2]), clamp16(pixel[3])}
+			r, g, b, a := palette[index.Index(old)].RGBA()
+			newImage.SetRGBA64(bounds.Min.X+x, bounds.Min.Y+y, color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)})
+
+			err := [4]float64{
+				float64(old.R) - float64(r),
+				float64(old.G) - float64(g),
+				float64(old.B) - float64(b),
+				float64(old.A) - float64(a),
+			}
+
+			for _, dif := range *diffusers {
+				nx, ny := x+dif.x, y+dif.y
+				if nx < 0 || nx >= width || ny >= height {
+					continue
+				}
+
+				neighbor := work[4*(nx+ny*width) : 4*(nx+ny*width)+4]
+				for i := range err {
+					neighbor[i] += err[i] * dif.fraction
+				}
+			}
+		}
+	}
+
+	return newImage
+}
+
+// clamp16 rounds value to the range of a 16-bit channel
+func clamp16(value float64) uint16 {
+	if value < 0 {
+		return 0
+	}
+	if value > 65535 {
+		return 65535
+	}
+
+	return uint16(value + 0.5)
+}
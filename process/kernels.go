@@ -0,0 +1,30 @@
+package process
+
+// NamedKernel is an error diffusion matrix with the name it is chosen by
+type NamedKernel struct {
+	Name   string
+	Matrix *ErrorDiffusionMatrix
+}
+
+// Kernels are the available error diffusion matrices: "floyd-steinberg" (FloydSteinBerg),
+// "jarvis-judice-ninke" (JarvisJudiceNinke), "stucki" (Stucki), "atkinson" (Atkinson), "simple" (Simple) and "none" (Nothing,
+// which only picks the closest palette color of every pixel)
+var Kernels = []NamedKernel{
+	{"floyd-steinberg", &FloydSteinBerg},
+	{"jarvis-judice-ninke", &JarvisJudiceNinke},
+	{"stucki", &Stucki},
+	{"atkinson", &Atkinson},
+	{"simple", &Simple},
+	{"none", &Nothing},
+}
+
+// KernelWithName returns the error diffusion matrix with the given name, and whether it exists
+func KernelWithName(name string) (*ErrorDiffusionMatrix, bool) {
+	for _, kernel := range Kernels {
+		if kernel.Name == name {
+			return kernel.Matrix, true
+		}
+	}
+
+	return nil, false
+}
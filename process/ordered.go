@@ -0,0 +1,146 @@
+package process
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ThresholdMatrix is the matrix of ordered dithering: it is tiled over the image, and its thresholds
+// (between 0 and 1) shift the colors of the pixels before the closest palette color is chosen
+type ThresholdMatrix [][]float64
+
+// Bayer2, Bayer4 and Bayer8 are the Bayer matrices of 2x2, 4x4 and 8x8 thresholds
+var (
+	Bayer2 = Bayer(2)
+	Bayer4 = Bayer(4)
+	Bayer8 = Bayer(8)
+)
+
+// OrderedSpread is the range of the channel values (0-255) by which ordered dithering shifts the colors.
+// If it is 0, it follows from the amount of colors in the palette: the more colors, the closer together they are.
+var OrderedSpread float64 = 0
+
+// Bayer returns the Bayer matrix of size x size thresholds, size needs to be a power of 2
+func Bayer(size int) ThresholdMatrix {
+	// every step doubles the matrix M into [4M, 4M+2; 4M+3, 4M+1]
+	order := [][]int{{0}}
+	for n := 1; n < size; n *= 2 {
+		next := make([][]int, 2*n)
+		for y := range next {
+			next[y] = make([]int, 2*n)
+			for x := range next[y] {
+				quadrant := [2][2]int{{0, 2}, {3, 1}}[y/n][x/n]
+				next[y][x] = 4*order[y%n][x%n] + quadrant
+			}
+		}
+		order = next
+	}
+
+	matrix := make(ThresholdMatrix, len(order))
+	cells := float64(len(order) * len(order))
+	for y, row := range order {
+		matrix[y] = make([]float64, len(row))
+		for x, value := range row {
+			matrix[y][x] = (float64(value) + 0.5) / cells
+		}
+	}
+
+	return matrix
+}
+
+// NamedThreshold is a threshold matrix with the name it is chosen by
+type NamedThreshold struct {
+	Name   string
+	Matrix ThresholdMatrix
+}
+
+// Thresholds are the available threshold matrices of ordered dithering: "bayer2", "bayer4" and "bayer8"
+var Thresholds = []NamedThreshold{
+	{"bayer2", Bayer2},
+	{"bayer4", Bayer4},
+	{"bayer8", Bayer8},
+}
+
+// ThresholdWithName returns the threshold matrix with the given name, and whether it exists
+func ThresholdWithName(name string) (ThresholdMatrix, bool) {
+	for _, threshold := range Thresholds {
+		if threshold.Name == name {
+			return threshold.Matrix, true
+		}
+	}
+
+	return nil, false
+}
+
+// ApplyOrdered applies ordered dithering with the thresholds of matrix. Unlike error diffusion, every pixel is
+// dithered on its own, so the result has a regular pattern and img is left as it is.
+// Transparency is handled like in ApplyErrorDiffusion.
+// Palettes of more than 256 colors give ErrPaletteTooLarge.
+func ApplyOrdered(img AdjustableImage, palette color.Palette, matrix ThresholdMatrix) (*image.Paletted, error) {
+	return applyOrdered(context.Background(), img, palette, palette.Index, matrix, nil)
+}
+
+// ApplyOrderedIndex applies ordered dithering like ApplyOrdered, finding the closest palette colors with index,
+// and reporting progress like ApplyErrorDiffusionContext
+func ApplyOrderedIndex(ctx context.Context, img AdjustableImage, palette color.Palette, index ColorIndex, matrix ThresholdMatrix, progress Progress) (*image.Paletted, error) {
+	return applyOrdered(ctx, img, palette, index.Index, matrix, progress)
+}
+
+// applyOrdered performs the ordered dithering, using closest to find the palette index of a color
+func applyOrdered(ctx context.Context, img AdjustableImage, palette color.Palette, closest func(color.Color) int, matrix ThresholdMatrix, progress Progress) (*image.Paletted, error) {
+	if len(palette) > 256 {
+		return nil, ErrPaletteTooLarge
+	}
+
+	rect := img.Bounds()
+	newImage := image.NewPaletted(rect, palette)
+	transparent := TransparentIndex(palette)
+
+	spread := OrderedSpread
+	if spread == 0 {
+		spread = 255 / math.Cbrt(float64(len(palette)))
+	}
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		if err := ctx.Err(); err != nil {
+			completed := image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, y)
+			return newImage.SubImage(completed).(*image.Paletted), err
+		}
+
+		if progress != nil {
+			progress.Update(y-rect.Min.Y, rect.Dy())
+		}
+
+		row := matrix[y%len(matrix)]
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			pixel := img.RGBAAt(x, y)
+
+			if transparent >= 0 {
+				if pixel.A < AlphaThreshold {
+					newImage.SetColorIndex(x, y, uint8(transparent))
+					continue
+				}
+				pixel = opaque(pixel)
+			}
+
+			shift := int16(spread * (row[x%len(row)] - 0.5))
+			shifted := color.RGBA{
+				addColorComponents(int16(pixel.R), shift),
+				addColorComponents(int16(pixel.G), shift),
+				addColorComponents(int16(pixel.B), shift),
+				pixel.A,
+			}
+
+			colorIndex := closest(shifted)
+			if colorIndex == transparent {
+				colorIndex = closestOpaque(palette, shifted, transparent)
+			}
+
+			newImage.SetColorIndex(x, y, uint8(colorIndex))
+		}
+	}
+
+	return newImage, nil
+}
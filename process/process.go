@@ -1,12 +1,15 @@
 package process
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"math"
+	"os"
 
 	"github.com/mielpeeters/dither/geom"
+	"github.com/mielpeeters/dither/kdtree"
 	"golang.org/x/image/draw"
 )
 
@@ -33,6 +36,12 @@ type AdjustableImage interface {
 	Set(x, y int, c color.Color)
 }
 
+// ColorIndex finds the palette color closest to a color, like color.Palette.Index does.
+// kdtree.PaletteIndex is a fast one for the same distance, colorpalette.MetricIndex one for other distances.
+type ColorIndex interface {
+	Index(clr color.Color) int
+}
+
 // ErrorDiffusionMatrix is the matrix that is used to spread the errors
 type ErrorDiffusionMatrix []ErrorDiffuser
 
@@ -63,6 +72,17 @@ var JarvisJudiceNinke = ErrorDiffusionMatrix{
 	{2, 2, 1.0 / 48.0},
 }
 
+// Atkinson is the EDM used for Atkinson dithering, which spreads only 3/4 of the error,
+// for more contrast in the highlights and shadows
+var Atkinson = ErrorDiffusionMatrix{
+	{1, 0, 1.0 / 8.0},
+	{2, 0, 1.0 / 8.0},
+	{-1, 1, 1.0 / 8.0},
+	{0, 1, 1.0 / 8.0},
+	{1, 1, 1.0 / 8.0},
+	{0, 2, 1.0 / 8.0},
+}
+
 func roundDown(number float64) int {
 	return int(math.Floor(number))
 }
@@ -99,6 +119,28 @@ func Resize(img image.Image, x, y int) *image.RGBA {
 	return dst
 }
 
+// FitSize returns the size of an image of the given bounds, resized to width and height.
+// If one of them is 0, it follows from the other, keeping the aspect ratio. The size is at least 1x1.
+func FitSize(bounds image.Rectangle, width, height int) (int, int) {
+	switch {
+	case width == 0 && height == 0:
+		width, height = bounds.Dx(), bounds.Dy()
+	case width == 0:
+		width = int(math.Round(float64(bounds.Dx()*height) / float64(bounds.Dy())))
+	case height == 0:
+		height = int(math.Round(float64(bounds.Dy()*width) / float64(bounds.Dx())))
+	}
+
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	return width, height
+}
+
 func addColorComponents(left int16, right int16) uint8 {
 	result := left + right
 
@@ -116,7 +158,7 @@ func addColorComponents(left int16, right int16) uint8 {
 func addErrorToColor(errorColor errorColor, origColor color.Color, factor float64) color.Color {
 	orig, ok := color.RGBAModel.Convert(origColor).(color.RGBA)
 	if !ok {
-		fmt.Println("type conversion (to rgba color) went wrong")
+		fmt.Fprintln(os.Stderr, "type conversion (to rgba color) went wrong")
 	}
 
 	col := color.RGBA{
@@ -169,26 +211,99 @@ func pointToColor(point geom.Point) color.Color {
 
 // ApplyErrorDiffusion will apply the error diffusion dithering, with the provided slice of
 // error spreading ErrorDiffuser elements.
+// If the palette has a transparent color (see TransparentIndex), pixels with an alpha below AlphaThreshold
+// get that color, and the other pixels are dithered as opaque colors.
+//
+// The palette must have at most 256 colors, the most an image.Paletted can hold, which the caller checks first:
+// a larger palette is a programming error, on which it panics with ErrPaletteTooLarge. Palettes that come from
+// input (like flags or files) go to ApplyErrorDiffusionContext instead, which returns ErrPaletteTooLarge,
+// or to ApplyErrorDiffusionRGBA, which takes palettes of any size.
 func ApplyErrorDiffusion(img AdjustableImage, palette color.Palette, diffusers *ErrorDiffusionMatrix) *image.Paletted {
-	X := img.Bounds().Max.X
-	Y := img.Bounds().Max.Y
+	newImage, err := ApplyErrorDiffusionContext(context.Background(), img, palette, diffusers, nil)
+	if err != nil {
+		panic(err)
+	}
 
-	rect := img.Bounds()
+	return newImage
+}
+
+// ApplyErrorDiffusionContext applies the error diffusion dithering like ApplyErrorDiffusion, reporting
+// the amount of completed rows to progress (which may be nil).
+//
+// When ctx is cancelled, the dithering stops after the current row. The rows that were completed are
+// returned (as a SubImage of the full result), together with the error of ctx.
+// Palettes of more than 256 colors give ErrPaletteTooLarge.
+func ApplyErrorDiffusionContext(ctx context.Context, img AdjustableImage, palette color.Palette, diffusers *ErrorDiffusionMatrix, progress Progress) (*image.Paletted, error) {
+	return applyErrorDiffusion(ctx, img, palette, palette.Index, diffusers, progress)
+}
 
+// ApplyErrorDiffusionIndex applies the error diffusion dithering like ApplyErrorDiffusionContext, but finds
+// the closest palette colors with index, like a prebuilt k-d tree index instead of comparing against every palette color.
+// The index needs to be built for palette, see kdtree.NewPaletteIndex.
+func ApplyErrorDiffusionIndex(ctx context.Context, img AdjustableImage, palette color.Palette, index ColorIndex, diffusers *ErrorDiffusionMatrix, progress Progress) (*image.Paletted, error) {
+	return applyErrorDiffusion(ctx, img, palette, index.Index, diffusers, progress)
+}
+
+// applyErrorDiffusion performs the error diffusion dithering into a paletted image, using closest to find
+// the palette index of a color
+func applyErrorDiffusion(ctx context.Context, img AdjustableImage, palette color.Palette, closest func(color.Color) int, diffusers *ErrorDiffusionMatrix, progress Progress) (*image.Paletted, error) {
+	if len(palette) > 256 {
+		return nil, ErrPaletteTooLarge
+	}
+
+	rect := img.Bounds()
 	newImage := image.NewPaletted(rect, palette)
 
+	done, err := diffuse(ctx, img, palette, closest, diffusers, progress, func(x, y, colorIndex int) {
+		newImage.SetColorIndex(x, y, uint8(colorIndex))
+	})
+	if err != nil {
+		completed := image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, done)
+		return newImage.SubImage(completed).(*image.Paletted), err
+	}
+
+	return newImage, nil
+}
+
+// diffuse performs the error diffusion dithering of img, using closest to find the palette index of a color,
+// and passes the palette index of every pixel to set. When ctx is cancelled, it stops after the current row,
+// and returns the amount of completed rows with the error of ctx.
+func diffuse(ctx context.Context, img AdjustableImage, palette color.Palette, closest func(color.Color) int, diffusers *ErrorDiffusionMatrix, progress Progress, set func(x, y, colorIndex int)) (int, error) {
+	X := img.Bounds().Max.X
+	Y := img.Bounds().Max.Y
+
+	transparent := TransparentIndex(palette)
+
 	for y := 0; y <= Y; y++ {
+		if err := ctx.Err(); err != nil {
+			return y, err
+		}
+
+		if progress != nil {
+			progress.Update(y, Y)
+		}
+
 		for x := 0; x <= X; x++ {
 			oldPixel := img.RGBAAt(x, y)
 
-			colorIndex := uint8(palette.Index(oldPixel))
+			if transparent >= 0 {
+				if oldPixel.A < AlphaThreshold {
+					set(x, y, transparent)
+					continue
+				}
+				oldPixel = opaque(oldPixel)
+			}
+
+			colorIndex := closest(oldPixel)
+			if colorIndex == transparent {
+				colorIndex = closestOpaque(palette, oldPixel, transparent)
+			}
 
 			img.Set(x, y, palette[colorIndex])
 
 			err := getColorDifference(oldPixel, img.RGBAAt(x, y))
 
-			// automatically assigns that index that corresponds with oldPixel the best!
-			newImage.Set(x, y, oldPixel)
+			set(x, y, colorIndex)
 
 			for _, dif := range *diffusers {
 				if dif.checkRange(x, y, X, Y) {
@@ -198,6 +313,38 @@ func ApplyErrorDiffusion(img AdjustableImage, palette color.Palette, diffusers *
 		}
 	}
 
+	return Y + 1, nil
+}
+
+// ApplyErrorDiffusionRGBA applies the error diffusion dithering like ApplyErrorDiffusion, but returns
+// the result as a direct color image. This allows palettes of more than 256 colors, which don't fit
+// in an image.Paletted, for outputs that are not limited to indexed colors (like PNG).
+func ApplyErrorDiffusionRGBA(img AdjustableImage, palette color.Palette, diffusers *ErrorDiffusionMatrix) *image.RGBA {
+	newImage := image.NewRGBA(img.Bounds())
+
+	// looking up the closest color is expensive for large palettes, use an index and remember the results
+	index := kdtree.NewPaletteIndex(palette)
+	found := make(map[color.RGBA]int)
+	closest := func(clr color.Color) int {
+		pixel := color.RGBAModel.Convert(clr).(color.RGBA)
+		colorIndex, ok := found[pixel]
+		if !ok {
+			colorIndex = index.Index(pixel)
+			found[pixel] = colorIndex
+		}
+		return colorIndex
+	}
+
+	colors := make([]color.RGBA, len(palette))
+	for i, clr := range palette {
+		colors[i] = color.RGBAModel.Convert(clr).(color.RGBA)
+	}
+
+	// the background context is never cancelled
+	diffuse(context.Background(), img, palette, closest, diffusers, nil, func(x, y, colorIndex int) {
+		newImage.SetRGBA(x, y, colors[colorIndex])
+	})
+
 	return newImage
 }
 
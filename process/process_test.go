@@ -0,0 +1,179 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testGradient returns a gradient over all of the red and green values, with a transparent corner
+func testGradient() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 4), uint8(y * 5), 100, 255})
+		}
+	}
+	img.SetRGBA(0, 0, color.RGBA{})
+
+	return img
+}
+
+// testPalette returns a palette of size grays
+func testPalette(size int) color.Palette {
+	palette := make(color.Palette, size)
+	for i := range palette {
+		palette[i] = color.RGBA{uint8(i * 255 / (size - 1)), uint8(i * 255 / (size - 1)), uint8(i * 255 / (size - 1)), 255}
+	}
+
+	return palette
+}
+
+func TestPaletteTooLarge(t *testing.T) {
+	palette := append(testPalette(256), color.RGBA{1, 2, 3, 255})
+
+	if _, err := ApplyErrorDiffusionContext(context.Background(), testGradient(), palette, &FloydSteinBerg, nil); !errors.Is(err, ErrPaletteTooLarge) {
+		t.Errorf("error diffusion with %d colors: error %v, want ErrPaletteTooLarge", len(palette), err)
+	}
+	func() {
+		defer func() {
+			if err, _ := recover().(error); !errors.Is(err, ErrPaletteTooLarge) {
+				t.Errorf("error diffusion with %d colors: panic %v, want ErrPaletteTooLarge", len(palette), err)
+			}
+		}()
+		ApplyErrorDiffusion(testGradient(), palette, &FloydSteinBerg)
+	}()
+	if _, err := ApplyOrdered(testGradient(), palette, Bayer(4)); !errors.Is(err, ErrPaletteTooLarge) {
+		t.Errorf("ordered dithering with %d colors: error %v, want ErrPaletteTooLarge", len(palette), err)
+	}
+
+	// the direct color result takes any amount of colors
+	rgba := ApplyErrorDiffusionRGBA(testGradient(), palette, &FloydSteinBerg)
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		pixel := color.RGBA{rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2], rgba.Pix[i+3]}
+		if palette[palette.Index(pixel)] != pixel {
+			t.Fatalf("pixel %v of the direct color result is not in the palette", pixel)
+		}
+	}
+}
+
+func TestErrorDiffusionRGBA(t *testing.T) {
+	// with a transparent color, so that the transparent pixels are compared as well
+	palette := append(testPalette(16), color.RGBA{})
+
+	paletted := ApplyErrorDiffusion(testGradient(), palette, &FloydSteinBerg)
+	rgba := ApplyErrorDiffusionRGBA(testGradient(), palette, &FloydSteinBerg)
+
+	// both are dithered in the same way, so they have the same colors
+	bounds := paletted.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if want, got := color.RGBAModel.Convert(paletted.At(x, y)), rgba.RGBAAt(x, y); got != want {
+				t.Fatalf("pixel %d,%d is %v, the paletted result has %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// TestBayer checks the sizes and thresholds of the Bayer matrices
+func TestBayer(t *testing.T) {
+	want := map[int][][]int{
+		2: {{0, 2}, {3, 1}},
+		4: {{0, 8, 2, 10}, {12, 4, 14, 6}, {3, 11, 1, 9}, {15, 7, 13, 5}},
+	}
+	for size, order := range want {
+		matrix := Bayer(size)
+		cells := float64(size * size)
+		for y, row := range order {
+			for x, value := range row {
+				if threshold := (float64(value) + 0.5) / cells; matrix[y][x] != threshold {
+					t.Errorf("Bayer(%d)[%d][%d] is %v, want %v", size, y, x, matrix[y][x], threshold)
+				}
+			}
+		}
+	}
+
+	// every threshold of the 8x8 matrix is used once
+	if len(Bayer8) != 8 {
+		t.Fatalf("Bayer8 has %d rows, want 8", len(Bayer8))
+	}
+	seen := map[float64]bool{}
+	for _, row := range Bayer8 {
+		if len(row) != 8 {
+			t.Fatalf("Bayer8 has a row of %d thresholds, want 8", len(row))
+		}
+		for _, threshold := range row {
+			if threshold <= 0 || threshold >= 1 || seen[threshold] {
+				t.Errorf("Bayer8 has the threshold %v twice or out of (0, 1)", threshold)
+			}
+			seen[threshold] = true
+		}
+	}
+}
+
+// flatGray returns a width x height image of one gray value
+func flatGray(width, height int, value uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{value, value, value, 255})
+		}
+	}
+
+	return img
+}
+
+// TestOrderedMidGray checks that ordered dithering of a flat mid-gray to black and white turns on the pixels of
+// the upper half of the thresholds, tiled over the image
+func TestOrderedMidGray(t *testing.T) {
+	paletted, err := ApplyOrdered(flatGray(8, 8, 128), testPalette(2), Bayer4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := uint8(0)
+			if Bayer4[y%4][x%4] > 0.5 {
+				want = 1
+			}
+			if got := paletted.ColorIndexAt(x, y); got != want {
+				t.Errorf("pixel (%d, %d) is %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+// TestAtkinson checks that Atkinson dithering spreads 3/4 of the error, so that black and white stay as they are
+// and a mid-gray becomes about half white
+func TestAtkinson(t *testing.T) {
+	total := 0.0
+	for _, dif := range Atkinson {
+		total += dif.fraction
+	}
+	if total != 0.75 {
+		t.Errorf("the fractions add up to %v, want 0.75", total)
+	}
+
+	for _, test := range []struct {
+		value uint8
+		// min and max are the bounds of the fraction of white pixels
+		min, max float64
+	}{
+		{0, 0, 0},
+		{255, 1, 1},
+		{128, 0.4, 0.6},
+	} {
+		paletted := ApplyErrorDiffusion(flatGray(32, 32, test.value), testPalette(2), &Atkinson)
+
+		white := 0
+		for _, index := range paletted.Pix {
+			white += int(index)
+		}
+		if fraction := float64(white) / float64(len(paletted.Pix)); fraction < test.min || fraction > test.max {
+			t.Errorf("gray %d: %.2f of the pixels are white, want between %.2f and %.2f", test.value, fraction, test.min, test.max)
+		}
+	}
+}
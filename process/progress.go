@@ -0,0 +1,60 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Progress receives progress updates from long running operations
+type Progress interface {
+	// Update reports that done out of total units of work (like rows) are completed
+	Update(done, total int)
+}
+
+// TextProgress is a Progress that writes the completed fraction, and an estimate of the remaining time,
+// as a single updating line to Writer
+type TextProgress struct {
+	// Writer is where the progress is written to, typically os.Stderr
+	Writer io.Writer
+	// Name describes the work that is being done
+	Name string
+	// Unit describes one unit of work, like "rows"
+	Unit string
+
+	start time.Time
+	last  time.Time
+}
+
+// progressInterval is the minimal time between two updates of a TextProgress
+const progressInterval = 200 * time.Millisecond
+
+// Update writes the progress line, at most once every 200 milliseconds (and always when the work is done)
+func (tp *TextProgress) Update(done, total int) {
+	now := time.Now()
+	if tp.start.IsZero() {
+		tp.start = now
+	}
+
+	if done < total && now.Sub(tp.last) < progressInterval {
+		return
+	}
+	tp.last = now
+
+	eta := "?"
+	if done > 0 {
+		remaining := time.Duration(float64(now.Sub(tp.start)) / float64(done) * float64(total-done))
+		eta = remaining.Round(time.Second).String()
+	}
+
+	percentage := 0.0
+	if total > 0 {
+		percentage = 100 * float64(done) / float64(total)
+	}
+
+	fmt.Fprintf(tp.Writer, "\r%s: %d/%d %s (%.0f%%), ETA %s   ", tp.Name, done, total, tp.Unit, percentage, eta)
+
+	if done >= total {
+		fmt.Fprintln(tp.Writer)
+	}
+}
@@ -0,0 +1,36 @@
+package process
+
+import (
+	"context"
+	"image"
+	"image/color"
+)
+
+// Redither dithers an already paletted image (like a GIF frame) to another palette, with the given diffusers.
+// The pixels that have the transparent color of src (see TransparentIndex) get the transparent color of palette,
+// so that the transparency survives the conversion; if palette has no transparent color, a fully transparent
+// one is appended for them. The other pixels are dithered as opaque colors.
+// Palettes of more than 256 colors (including that extra transparent color) give ErrPaletteTooLarge.
+func Redither(src *image.Paletted, palette color.Palette, diffusers *ErrorDiffusionMatrix) (*image.Paletted, error) {
+	srcTransparent := TransparentIndex(src.Palette)
+
+	img := image.NewRGBA(src.Bounds())
+	hasTransparent := false
+	for i, colorIndex := range src.Pix {
+		if int(colorIndex) == srcTransparent {
+			// leave the pixel fully transparent
+			hasTransparent = true
+			continue
+		}
+
+		clr := color.RGBAModel.Convert(src.Palette[colorIndex]).(color.RGBA)
+		x, y := i%src.Stride, i/src.Stride
+		img.SetRGBA(src.Rect.Min.X+x, src.Rect.Min.Y+y, opaque(clr))
+	}
+
+	if hasTransparent && TransparentIndex(palette) < 0 {
+		palette = append(palette[:len(palette):len(palette)], color.RGBA{})
+	}
+
+	return applyErrorDiffusion(context.Background(), img, palette, palette.Index, diffusers, nil)
+}
@@ -0,0 +1,53 @@
+package process
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// testPaletted returns the gradient dithered to 16 grays and a transparent color
+func testPaletted() *image.Paletted {
+	return ApplyErrorDiffusion(testGradient(), append(testPalette(16), color.RGBA{}), &FloydSteinBerg)
+}
+
+func TestRedither(t *testing.T) {
+	src := testPaletted()
+	palette := testPalette(4)
+
+	first, err := Redither(src, palette, &FloydSteinBerg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Redither(testPaletted(), palette, &FloydSteinBerg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("redithering the same image twice gives different results")
+	}
+
+	// palette has no transparent color, so one is appended for the transparent pixels of src
+	if len(first.Palette) != len(palette)+1 || TransparentIndex(first.Palette) != len(palette) {
+		t.Fatalf("the palette of the result is %v, want the palette with a transparent color", first.Palette)
+	}
+	if len(palette) != 4 {
+		t.Errorf("the palette that was passed in is changed to %v", palette)
+	}
+
+	srcTransparent := TransparentIndex(src.Palette)
+	for i, colorIndex := range src.Pix {
+		if wasTransparent, isTransparent := int(colorIndex) == srcTransparent, int(first.Pix[i]) == len(palette); wasTransparent != isTransparent {
+			t.Fatalf("pixel %d: transparent %v, was %v", i, isTransparent, wasTransparent)
+		}
+	}
+}
+
+func TestReditherTooLarge(t *testing.T) {
+	// with the transparent color that is appended, 256 colors become too many
+	if _, err := Redither(testPaletted(), testPalette(256), &FloydSteinBerg); !errors.Is(err, ErrPaletteTooLarge) {
+		t.Errorf("error %v, want ErrPaletteTooLarge", err)
+	}
+}
@@ -0,0 +1,62 @@
+package process
+
+import (
+	"image/color"
+)
+
+// AlphaThreshold is the alpha value below which pixels get the transparent color of the palette, when it has one.
+// Pixels at or above it are dithered as opaque colors.
+var AlphaThreshold uint8 = 128
+
+// TransparentIndex returns the index of the transparent color of the palette (its first color with an alpha of 0),
+// or -1 if it has none. This is also the color that image/gif uses as the transparent index.
+func TransparentIndex(palette color.Palette) int {
+	for i, clr := range palette {
+		if _, _, _, a := clr.RGBA(); a == 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// opaque returns the color that a pixel is dithered as when the palette has a transparent color:
+// transparency is handled separately, so the alpha is left out (the color is no longer premultiplied).
+func opaque(pixel color.RGBA) color.RGBA {
+	if pixel.A == 0 || pixel.A == 255 {
+		return color.RGBA{pixel.R, pixel.G, pixel.B, 255}
+	}
+
+	unpremultiply := func(c uint8) uint8 {
+		return uint8((int(c)*255 + int(pixel.A)/2) / int(pixel.A))
+	}
+
+	return color.RGBA{unpremultiply(pixel.R), unpremultiply(pixel.G), unpremultiply(pixel.B), 255}
+}
+
+// closestOpaque returns the index of the palette color closest to clr, leaving out the transparent color.
+// It uses the same distance as color.Palette.Index.
+func closestOpaque(palette color.Palette, clr color.Color, transparent int) int {
+	cr, cg, cb, ca := clr.RGBA()
+	best, bestSum := 0, uint32(1<<32-1)
+
+	for i, v := range palette {
+		if i == transparent {
+			continue
+		}
+
+		vr, vg, vb, va := v.RGBA()
+		sum := sqDiff(cr, vr) + sqDiff(cg, vg) + sqDiff(cb, vb) + sqDiff(ca, va)
+		if sum < bestSum {
+			best, bestSum = i, sum
+		}
+	}
+
+	return best
+}
+
+// sqDiff returns the squared difference of two color channels, like the unexported function of image/color
+func sqDiff(x, y uint32) uint32 {
+	d := x - y
+	return (d * d) >> 2
+}
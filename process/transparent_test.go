@@ -0,0 +1,47 @@
+package process
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestTransparentIndex(t *testing.T) {
+	if i := TransparentIndex(testPalette(4)); i != -1 {
+		t.Errorf("a palette without a transparent color gives index %d", i)
+	}
+
+	palette := append(testPalette(4), color.RGBA{}, color.NRGBA{255, 0, 0, 0})
+	if i := TransparentIndex(palette); i != 4 {
+		t.Errorf("transparent index %d, want the first transparent color at 4", i)
+	}
+}
+
+// TestTransparentDithering checks that pixels below AlphaThreshold get the transparent color, and that the
+// other pixels are dithered as opaque colors without ever getting it
+func TestTransparentDithering(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			// the left half is (almost) transparent, the right half translucent or opaque
+			alpha := uint8(x * 8)
+			if x >= 8 {
+				alpha = uint8(128 + (x-8)*16)
+			}
+			img.Set(x, y, color.NRGBA{uint8(x * 16), uint8(y * 32), 200, alpha})
+		}
+	}
+
+	palette := append(testPalette(4), color.RGBA{0, 0, 255, 255}, color.RGBA{})
+	transparent := TransparentIndex(palette)
+
+	paletted := ApplyErrorDiffusion(img, palette, &FloydSteinBerg)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			isTransparent := int(paletted.ColorIndexAt(x, y)) == transparent
+			if wantTransparent := x < 8; isTransparent != wantTransparent {
+				t.Errorf("pixel %d,%d with alpha %d: transparent %v, want %v", x, y, img.RGBAAt(x, y).A, isTransparent, wantTransparent)
+			}
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mielpeeters/dither/colorpalette"
+)
+
+// projectFile is the config file of a dither project, the same one as the config file with the default flags
+// (see configFile). The project is in its [project] table, with the name and the input and output folders,
+// and its tasks are in [task.name] tables:
+//
+//	[project]
+//	name = "holiday"
+//	input = "input"
+//	output = "output"
+//
+//	[task.images]
+//	description = "dither every jpg image in the input folder"
+//	each = "*.jpg"
+//	args = ["image", "-p", "{input}", "-o", "{output}/{name}.png"]
+//
+// The flags at the top of the file are the defaults of the commands that the tasks run, which are run from
+// within the project directory, where they read the same file.
+const projectFile = configFile
+
+// project is the configuration of a dither project, as created by `dither init`
+type project struct {
+	Name string
+	// Input and Output are the folders with the source images and the results
+	Input  string
+	Output string
+	// Tasks maps task names to what `dither run <task>` does
+	Tasks map[string]task
+}
+
+// task is one step of a project, like a target in a Makefile
+type task struct {
+	Description string
+	// Depends lists the tasks that are run before this one
+	Depends []string
+	// Each is a glob pattern of files in the input folder. If set, the task is run once for every match.
+	Each string
+	// Args are the arguments for dither. {input} is replaced by the matched input file,
+	// {name} by its name without extension and {output} by the output folder.
+	Args []string
+}
+
+// defaultProject returns the project file that `dither init` starts a project with
+func defaultProject(name string) string {
+	return `# the default flags of the commands of the tasks (and of dither itself, within the project directory)
+scale = 4
+k = 8
+seed = 1
+
+[project]
+name = ` + strconv.Quote(name) + `
+input = "input"
+output = "output"
+
+[task.images]
+description = "dither every jpg image in the input folder"
+each = "*.jpg"
+args = ["image", "-p", "{input}", "-o", "{output}/{name}.png"]
+
+[task.videos]
+description = "create a dithered gif video from every animated gif in the input folder"
+each = "*.gif"
+args = ["gif", "-frames", "{input}", "-o", "{output}/{name}.gif"]
+
+[task.all]
+description = "run all of the tasks"
+depends = ["images", "videos"]
+`
+}
+
+// readProject reads the project of the config file at path
+func readProject(path string) (project, error) {
+	cfg, err := readConfig(path)
+	if err != nil {
+		return project{}, err
+	}
+
+	table, ok := cfg["project"]
+	if !ok {
+		return project{}, fmt.Errorf("%s has no [project] table (run `dither init` first)", path)
+	}
+
+	config := project{Input: ".", Output: ".", Tasks: map[string]task{}}
+	for key, value := range table {
+		if value.list != nil {
+			return project{}, fmt.Errorf("%s: [project] %s is a string, not an array", path, key)
+		}
+		switch key {
+		case "name":
+			config.Name = value.text
+		case "input":
+			config.Input = value.text
+		case "output":
+			config.Output = value.text
+		default:
+			return project{}, fmt.Errorf("%s: [project] has no key %s", path, key)
+		}
+	}
+
+	for name, table := range cfg {
+		if !strings.HasPrefix(name, "task.") {
+			continue
+		}
+		name = strings.TrimPrefix(name, "task.")
+
+		var current task
+		for key, value := range table {
+			switch key {
+			case "description", "each":
+				if value.list != nil {
+					return project{}, fmt.Errorf("%s: [task.%s] %s is a string, not an array", path, name, key)
+				}
+				if key == "description" {
+					current.Description = value.text
+				} else {
+					current.Each = value.text
+				}
+			case "depends", "args":
+				if value.list == nil {
+					return project{}, fmt.Errorf("%s: [task.%s] %s is an array of strings", path, name, key)
+				}
+				if key == "depends" {
+					current.Depends = value.list
+				} else {
+					current.Args = value.list
+				}
+			default:
+				return project{}, fmt.Errorf("%s: [task.%s] has no key %s", path, name, key)
+			}
+		}
+		config.Tasks[name] = current
+	}
+
+	return config, nil
+}
+
+// initProject creates the directory of a new dither project, with a project file,
+// a palette library and the input and output folders
+func initProject(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither init <project directory>")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("provide the directory of the new project")
+	}
+	dir := flags.Arg(0)
+
+	if _, err := os.Stat(filepath.Join(dir, projectFile)); err == nil {
+		return fmt.Errorf("%s already has a %s", dir, projectFile)
+	}
+
+	for _, folder := range []string{"input", "output"} {
+		if err := os.MkdirAll(filepath.Join(dir, folder), 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, projectFile), []byte(defaultProject(filepath.Base(dir))), 0644); err != nil {
+		return err
+	}
+
+	// the palette library, which -palette reads from the project directory
+	starter, _ := colorpalette.Named("pico-8")
+	starter.Name = "project"
+	library, err := json.MarshalIndent([]colorpalette.ColorPalette{starter}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, libraryFile), library, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("created project %s\n", dir)
+	fmt.Printf("put images in %s, and run `dither run` from within %s\n", filepath.Join(dir, "input"), dir)
+
+	return nil
+}
+
+// runProject runs a task of the project in the current directory (by default "all"),
+// after the tasks it depends on
+func runProject(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	list := flags.Bool("list", false, "list the tasks of the project")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither run [-list] [task]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if _, err := os.Stat(projectFile); err != nil {
+		return fmt.Errorf("no dither project here (run `dither init` first): %w", err)
+	}
+	config, err := readProject(projectFile)
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		names := make([]string, 0, len(config.Tasks))
+		for name := range config.Tasks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("%-12s %s\n", name, config.Tasks[name].Description)
+		}
+		return nil
+	}
+
+	name := "all"
+	if flags.NArg() > 0 {
+		name = flags.Arg(0)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	return config.run(name, executable, map[string]bool{}, map[string]bool{})
+}
+
+// run runs the task with the given name, after its dependencies.
+// done holds the tasks that already ran, running those that are still busy (to detect cycles).
+func (config *project) run(name, executable string, done, running map[string]bool) error {
+	if done[name] {
+		return nil
+	}
+	if running[name] {
+		return fmt.Errorf("task %q depends on itself", name)
+	}
+
+	current, ok := config.Tasks[name]
+	if !ok {
+		return fmt.Errorf("unknown task %q", name)
+	}
+
+	running[name] = true
+	for _, dependency := range current.Depends {
+		if err := config.run(dependency, executable, done, running); err != nil {
+			return err
+		}
+	}
+	running[name] = false
+
+	if len(current.Args) > 0 {
+		inputs := []string{""}
+		if current.Each != "" {
+			matches, err := filepath.Glob(filepath.Join(config.Input, current.Each))
+			if err != nil {
+				return err
+			}
+			inputs = matches
+		}
+
+		for _, input := range inputs {
+			replacer := strings.NewReplacer(
+				"{input}", input,
+				"{name}", strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)),
+				"{output}", config.Output,
+			)
+
+			args := make([]string, len(current.Args))
+			for i, arg := range current.Args {
+				args[i] = replacer.Replace(arg)
+			}
+
+			fmt.Printf("[%s] dither %s\n", name, strings.Join(args, " "))
+
+			cmd := exec.Command(executable, args...)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("task %q failed: %w", name, err)
+			}
+		}
+	}
+
+	done[name] = true
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadProject(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     project
+		// err is a part of the error, if reading the project fails
+		err string
+	}{
+		{
+			name:     "defaults",
+			contents: "k = 8\n\n[project]\n",
+			want:     project{Input: ".", Output: ".", Tasks: map[string]task{}},
+		},
+		{
+			name: "tasks",
+			contents: `[project]
+name = "holiday"
+input = "photos"
+output = "dithered"
+
+[task.images]
+description = "dither the photos"
+each = "*.jpg"
+args = ["image", "-p", "{input}", "-o", "{output}/{name}.png"]
+
+[task.all]
+depends = ["images"]
+`,
+			want: project{Name: "holiday", Input: "photos", Output: "dithered", Tasks: map[string]task{
+				"images": {Description: "dither the photos", Each: "*.jpg", Args: []string{"image", "-p", "{input}", "-o", "{output}/{name}.png"}},
+				"all":    {Depends: []string{"images"}},
+			}},
+		},
+		{name: "no project", contents: "k = 8\n", err: "has no [project] table"},
+		{name: "array in project", contents: "[project]\nname = [\"a\"]\n", err: "[project] name is a string, not an array"},
+		{name: "unknown key in project", contents: "[project]\nk = 8\n", err: "[project] has no key k"},
+		{name: "array description", contents: "[project]\n[task.a]\ndescription = [\"a\"]\n", err: "[task.a] description is a string, not an array"},
+		{name: "string args", contents: "[project]\n[task.a]\nargs = \"image\"\n", err: "[task.a] args is an array of strings"},
+		{name: "unknown key in task", contents: "[project]\n[task.a]\nrun = \"image\"\n", err: "[task.a] has no key run"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := readProject(writeConfig(t, test.contents))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("error %v, want one with %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config, test.want) {
+				t.Errorf("read %+v, want %+v", config, test.want)
+			}
+		})
+	}
+}
+
+// TestInitProject checks that a new project has the folders, a project file that can be read and a palette library
+func TestInitProject(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "holiday")
+	if err := initProject([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"input", "output", libraryFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	config, err := readProject(filepath.Join(dir, projectFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Name != "holiday" || config.Input != "input" || config.Output != "output" {
+		t.Errorf("the project is %+v", config)
+	}
+	if all := config.Tasks["all"]; !reflect.DeepEqual(all.Depends, []string{"images", "videos"}) {
+		t.Errorf("the all task depends on %q", all.Depends)
+	}
+
+	if err := initProject([]string{dir}); err == nil || !strings.Contains(err.Error(), "already has a "+projectFile) {
+		t.Errorf("initializing the project again gave error %v", err)
+	}
+}
+
+// TestRunProjectOrder checks that the tasks run after their dependencies, once, and that cycles and unknown tasks are reported
+func TestRunProjectOrder(t *testing.T) {
+	config := project{Tasks: map[string]task{
+		"all":    {Depends: []string{"images", "videos"}},
+		"images": {Depends: []string{"setup"}},
+		"videos": {Depends: []string{"setup"}},
+		"setup":  {},
+		"loop":   {Depends: []string{"again"}},
+		"again":  {Depends: []string{"loop"}},
+		"broken": {Depends: []string{"missing"}},
+	}}
+
+	tests := []struct {
+		task string
+		// done lists the tasks that ran
+		done []string
+		err  string
+	}{
+		{task: "all", done: []string{"all", "images", "setup", "videos"}},
+		{task: "images", done: []string{"images", "setup"}},
+		{task: "loop", err: `task "loop" depends on itself`},
+		{task: "broken", err: `unknown task "missing"`},
+	}
+
+	for _, test := range tests {
+		done := map[string]bool{}
+		err := config.run(test.task, "", done, map[string]bool{})
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: error %v, want %q", test.task, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.task, err)
+		}
+
+		want := map[string]bool{}
+		for _, name := range test.done {
+			want[name] = true
+		}
+		if !reflect.DeepEqual(done, want) {
+			t.Errorf("%s: ran %v, want %v", test.task, done, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/mielpeeters/dither/qrgif"
+)
+
+// qrCommand embeds a video in a qr code, which stays readable while parts of it show the video
+func qrCommand(args []string) error {
+	flags := flag.NewFlagSet("qr", flag.ExitOnError)
+	framesDir := flags.String("frames", "", "directory with the frames of a video (frame_%05d.jpg), an animated gif or a video file (using ffmpeg)")
+	outputPath := flags.String("o", "output.gif", "path to the output gif video")
+	content := flags.String("content", "", "text (like a url) that the qr code holds")
+	change := flags.Float64("change", 0.3, "fraction of the pixels of the code that show the video instead")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither qr -frames video.mp4 -content https://example.com -o output.gif")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *framesDir == "" {
+		problems.add("provide the frames of the video (-frames)")
+	}
+	if *content == "" {
+		problems.add("provide the content of the code (-content)")
+	}
+	if *change < 0 || *change > 1 {
+		problems.add("the fraction (-change) needs to be between 0 and 1")
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+
+	source, err := openFrames(*framesDir)
+	if err != nil {
+		return err
+	}
+	if closer, ok := source.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	qrg := qrgif.NewQRGif(*framesDir, *outputPath, *content, *change)
+	qrg.Seed = seed
+	qrg.EmbedVideoFrom(source)
+	logf(1, "saved %s", *outputPath)
+
+	return nil
+}
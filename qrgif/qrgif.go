@@ -2,11 +2,9 @@ package qrgif
 
 import (
 	"image"
+	"io"
 	"log"
 	"math/rand"
-	"os"
-	"path/filepath"
-	"regexp"
 	"runtime"
 	"sync"
 	"time"
@@ -14,14 +12,14 @@ import (
 	"github.com/mielpeeters/dither/colorpalette"
 	"github.com/mielpeeters/dither/gifeo"
 	"github.com/mielpeeters/dither/imgutil"
-	"github.com/mielpeeters/dither/needle"
 	"github.com/mielpeeters/dither/process"
 	"github.com/skip2/go-qrcode"
 )
 
 // QRGif represents the qr gif video
 type QRGif struct {
-	// VideoPath is the directory of the frames, stored in format frame_%05d.jpg
+	// VideoPath is the directory of the frames, stored in format frame_%05d.jpg, see EmbedVideo.
+	// EmbedVideoFrom reads the frames of other videos.
 	VideoPath string
 
 	OutputPath string
@@ -30,6 +28,12 @@ type QRGif struct {
 
 	ChangeFraction float64
 
+	// Seed is the seed of the random choice of pixels that show the video instead of the code.
+	// Every frame gets its own source, seeded with Seed plus the frame number, so the result doesn't depend
+	// on the order in which the frames are handled. NewQRGif sets it to the current time.
+	Seed int64
+
+	mu     sync.Mutex
 	frames []*image.Paletted
 
 	codeimg *image.Paletted
@@ -53,85 +57,81 @@ func NewQRGif(videoPath, outputPath, content string, changeFraction float64) *QR
 
 	paletted := process.ApplyErrorDiffusion(rgbaImg, colorpalette.BW(), &process.JarvisJudiceNinke)
 
-	rand.Seed(time.Now().UnixNano())
-
 	return &QRGif{
 		VideoPath:      videoPath,
 		Code:           code,
 		codeimg:        paletted,
 		OutputPath:     outputPath,
 		ChangeFraction: changeFraction,
+		Seed:           time.Now().UnixNano(),
 	}
 }
 
 // EmbedVideo embeds the Video into the QRCode
 func (qrg *QRGif) EmbedVideo() {
+	qrg.EmbedVideoFrom(gifeo.NewDirSource(qrg.VideoPath))
+}
 
-	pattern := "frame_[0-9]{5}\\.jpg"
-
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		panic(err)
-	}
-
-	frame := 0
-
-	// paths maps frame numbers to their paths
-	paths := make(map[int]string, 0)
-
-	// add an entry that maps frame count to the path string per matching path
-	filepath.Walk(qrg.VideoPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && re.MatchString(info.Name()) {
-			paths[frame] = path
-			frame++
-		}
-		return nil
-	})
-
-	// frames keeps the processed frames in a slice
-	qrg.frames = make([]*image.Paletted, len(paths))
-
-	// make a slice of the keys of the paths map (frame numbers)
-	// will be used to spread the multithreaded load
-	keys := make([]int, len(paths))
-	i := 0
-	for key := range paths {
-		keys[i] = key
-		i++
-	}
+// qrFrame is a frame read from a FrameSource, to be embedded by one of the workers
+type qrFrame struct {
+	number int
+	img    image.Image
+}
 
-	// divide the frameNumbers in chunks, each to be dealth with by one thread
-	frameNumbers := needle.ChunkSlice(keys, runtime.GOMAXPROCS(0))
+// EmbedVideoFrom embeds the frames of any FrameSource (like a video decoded by ffmpeg) into the QRCode.
+// Frames that can't be read are left out.
+func (qrg *QRGif) EmbedVideoFrom(source gifeo.FrameSource) {
+	// frames keeps the processed frames in a slice, it grows as frames are read
+	qrg.frames = []*image.Paletted{}
 
-	// start multithreaded processing of frames
+	// start multithreaded processing of frames, the frames are read one by one and handed to the workers
+	jobs := make(chan qrFrame)
 	wg := sync.WaitGroup{}
 
-	for i := range frameNumbers {
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
 		wg.Add(1)
-		go func(myFrameNumbers *[]int) {
-			for _, j := range *myFrameNumbers {
-				// here, all of the frames that are my responsibility will be dealth with
-				qrg.handleFrame((paths)[j], j)
+		go func() {
+			for job := range jobs {
+				qrg.handleFrame(job.img, job.number)
 			}
 			wg.Done()
-		}(&frameNumbers[i])
+		}()
+	}
+
+	for number := 0; ; number++ {
+		img, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+
+		qrg.mu.Lock()
+		qrg.frames = append(qrg.frames, nil)
+		qrg.mu.Unlock()
+
+		if err != nil {
+			continue
+		}
+		jobs <- qrFrame{number, img}
 	}
 
 	// wait for all child threads to finish
+	close(jobs)
 	wg.Wait()
 
-	gifeo.EncodeGIF(qrg.frames, qrg.OutputPath, 8)
+	frames := []*image.Paletted{}
+	for _, frame := range qrg.frames {
+		if frame != nil {
+			frames = append(frames, frame)
+		}
+	}
+	if len(frames) == 0 {
+		log.Fatal("qrgif: none of the frames of the video could be read")
+	}
+
+	gifeo.EncodeGIF(frames, qrg.OutputPath, 8)
 }
 
-func (qrg *QRGif) handleFrame(inputPath string, no int) {
-	// open the input image
-	img, err := imgutil.OpenImage(inputPath)
-	if err != nil {
-		return
-	}
+func (qrg *QRGif) handleFrame(img image.Image, no int) {
 	// scale the image down with a given scale
 	scaledImage := process.Resize(img, 49, 49)
 
@@ -142,6 +142,7 @@ func (qrg *QRGif) handleFrame(inputPath string, no int) {
 	}
 
 	adjusted := 0
+	rng := rand.New(rand.NewSource(qrg.Seed + int64(no)))
 
 	// apply QR code filter on top
 	for x := 0; x < 49; x++ {
@@ -150,7 +151,7 @@ func (qrg *QRGif) handleFrame(inputPath string, no int) {
 
 			if !mask(x-4, y-4) {
 				if paletted.ColorIndexAt(x, y) != qrg.codeimg.ColorIndexAt(x, y) && paletted.ColorIndexAt(x, y) != 1 {
-					if rand.Float64() < qrg.ChangeFraction && adjusted < int(qrg.ChangeFraction*41*30) {
+					if rng.Float64() < qrg.ChangeFraction && adjusted < int(qrg.ChangeFraction*41*30) {
 						adjusted++
 						imagePixel = true
 						// qrg.ChangeFraction = orig * 5
@@ -164,7 +165,9 @@ func (qrg *QRGif) handleFrame(inputPath string, no int) {
 		}
 	}
 
+	qrg.mu.Lock()
 	qrg.frames[no] = paletted
+	qrg.mu.Unlock()
 }
 
 func mask(x, y int) bool {
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"time"
+)
+
+// seed is the -seed of every subcommand, which seeds all of the randomness of a run (the sampling and the random
+// starts of creating palettes, and the pixels of a qr code that show the video), so that the same command
+// gives byte-identical outputs. Without -seed, one is picked from the current time, see applySeed.
+var seed int64
+
+// addSeedFlag registers -seed on flags
+func addSeedFlag(flags *flag.FlagSet) {
+	flags.Int64Var(&seed, "seed", 0, "seed of the randomness, the same seed gives the same outputs (0 picks one, which -v prints)")
+}
+
+// applySeed picks a seed if -seed isn't given, and prints it with -v, so that the run can be repeated
+func applySeed() {
+	if seed != 0 {
+		return
+	}
+
+	seed = time.Now().UnixNano()
+	logf(2, "seed %d (run with -seed %d to repeat this run)", seed, seed)
+}
+
+// seededRand returns a source of randomness seeded with seed, for stream, which distinguishes the sources of
+// the parts of a run (like the images of a batch), so that they don't depend on the order they are done in
+func seededRand(stream int) *rand.Rand {
+	return rand.New(rand.NewSource(seed + int64(stream)))
+}
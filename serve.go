@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/mielpeeters/dither/colorpalette"
+	"github.com/mielpeeters/dither/imgutil"
+	"github.com/mielpeeters/dither/process"
+)
+
+// playgroundPage is the page of the playground, see serveCommand
+//
+//go:embed web/playground.html
+var playgroundPage []byte
+
+// maxUpload is the largest image in bytes that can be uploaded to the playground
+const maxUpload = 32 << 20
+
+// maxSourceFactor is how many times larger than -max-size (in both directions) an uploaded image can be.
+// The size of the image is read before it is decoded, so that a small file of a huge image can't fill the memory.
+const maxSourceFactor = 4
+
+// playground serves the page to dither images in, and dithers the images that it uploads
+type playground struct {
+	// file is the palette file of -palette-file, with the palettes to offer next to the built-in ones
+	file string
+	// maxSize is the largest width and height that images are dithered at
+	maxSize int
+}
+
+// serveCommand serves the playground: a web page to upload an image to, and to dither it with a palette and
+// algorithm of choice, with a live preview
+func serveCommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8080", "address to serve the playground at, like :8080 to reach it from other machines")
+	file := flags.String("palette-file", "", "palette file (.json, .gpl or .hex) with palettes to offer next to the built-in ones, instead of the palette library")
+	maxSize := flags.Int("max-size", 1024, "largest width and height in pixels that images are dithered at")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: dither serve [-addr localhost:8080] [-palette-file palettes.json]")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	problems := newFlagErrors(flags)
+	if *maxSize < 1 {
+		problems.add("the largest size (-max-size) needs to be at least 1")
+	}
+	if flags.NArg() > 0 {
+		problems.add("serve takes no arguments, not %q", flags.Args())
+	}
+	if err := problems.err(); err != nil {
+		return err
+	}
+
+	pg := &playground{file: *file, maxSize: *maxSize}
+	if _, err := pg.palettes(); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", pg.page)
+	mux.HandleFunc("/options", pg.options)
+	mux.HandleFunc("/dither", pg.dither)
+	server := &http.Server{Addr: *addr, Handler: mux}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	logf(1, "serving the playground at http://%s", *addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// palettes returns the palettes to offer: the built-in ones, and those of -palette-file or the palette library
+func (pg *playground) palettes() ([]colorpalette.ColorPalette, error) {
+	palettes := []colorpalette.ColorPalette{}
+	for _, name := range colorpalette.Names() {
+		palette, _ := colorpalette.Named(name)
+		palettes = append(palettes, palette)
+	}
+
+	path := pg.file
+	if path == "" {
+		path = findLibrary()
+	}
+	if path == "" {
+		return palettes, nil
+	}
+
+	library, err := readPaletteFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(palettes, library...), nil
+}
+
+// page serves the page of the playground
+func (pg *playground) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(playgroundPage)
+}
+
+// paletteOption is a palette that the page offers, with its colors in hex notation
+type paletteOption struct {
+	Name   string   `json:"name"`
+	Colors []string `json:"colors"`
+}
+
+// options serves the palettes and dithering algorithms that the page offers, as json
+func (pg *playground) options(w http.ResponseWriter, r *http.Request) {
+	palettes, err := pg.palettes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	options := struct {
+		Palettes []paletteOption `json:"palettes"`
+		Dithers  []string        `json:"dithers"`
+		MaxSize  int             `json:"maxSize"`
+	}{Dithers: ditherNames(), MaxSize: pg.maxSize}
+	for _, palette := range palettes {
+		options.Palettes = append(options.Palettes, paletteOption{Name: palette.Name, Colors: palette.ToHex()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// dither dithers the uploaded image (the form field image) with the palette (or k colors created from the image),
+// the dithering algorithm and the width of the form, and serves it as a png
+func (pg *playground) dither(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "upload the image with a POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "no image was uploaded: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		http.Error(w, "the image can't be read: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit := maxSourceFactor * pg.maxSize; config.Width*config.Height > limit*limit {
+		http.Error(w, fmt.Sprintf("the image of %dx%d pixels is too large, the playground reads images of at most %d pixels", config.Width, config.Height, limit*limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	img, _, err := imgutil.Decode(file)
+	if err != nil {
+		http.Error(w, "the image can't be read: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the progress of creating the palette isn't printed for every request
+	options := paletteOptions{name: r.FormValue("palette"), quantizer: "kmeans", quiet: true}
+	if _, builtin := colorpalette.Named(options.name); options.name != "" && !builtin {
+		options.file = pg.file
+	}
+	options.k, err = strconv.Atoi(r.FormValue("k"))
+	if options.creates() && (err != nil || options.k < 1 || options.k > 256) {
+		http.Error(w, "the amount of colors needs to be between 1 and 256", http.StatusBadRequest)
+		return
+	}
+	algorithm, ok := ditherWithName(r.FormValue("dither"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("%q is not a dithering algorithm", r.FormValue("dither")), http.StatusBadRequest)
+		return
+	}
+	width, _ := strconv.Atoi(r.FormValue("width"))
+
+	done := newTiming().stage("dither", header.Filename)
+	defer done()
+
+	width, height := pg.fit(img.Bounds(), width)
+	scaled := process.Resize(img, width, height)
+
+	// every request gets settings of its own, so that images are dithered at the same time
+	if options.settings, err = options.newSettings(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	palette, index, err := options.palette(r.Context(), scaled)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(palette) > 256 {
+		http.Error(w, fmt.Sprintf("the playground dithers with at most 256 colors, %q has %d", options.name, len(palette)), http.StatusBadRequest)
+		return
+	}
+
+	paletted, err := algorithm.paletted(r.Context(), scaled, palette, options.colorIndex(palette, index), nil)
+	if err != nil {
+		// the page cancelled the request for a newer one
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, paletted)
+}
+
+// fit returns the size to dither an image with bounds at: width pixels wide (or its own width with 0),
+// keeping the aspect ratio, and within the largest size
+func (pg *playground) fit(bounds image.Rectangle, width int) (int, int) {
+	if width < 1 || width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	width, height := process.FitSize(bounds, width, 0)
+
+	if width > pg.maxSize {
+		width, height = process.FitSize(bounds, pg.maxSize, 0)
+	}
+	if height > pg.maxSize {
+		width, height = process.FitSize(bounds, 0, pg.maxSize)
+	}
+
+	return width, height
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testUpload returns an encoded png of width x height pixels, a gradient
+func testUpload(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(255 * x / width), uint8(255 * y / height), 128, 255})
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return encoded.Bytes()
+}
+
+// uploadRequest returns a POST of a form with the fields, and with the image as its image field if it isn't nil
+func uploadRequest(t *testing.T, fields map[string]string, upload []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	if upload != nil {
+		part, err := form.CreateFormFile("image", "upload.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(upload)
+	}
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/dither", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+
+	return r
+}
+
+func TestPlaygroundDither(t *testing.T) {
+	pg := &playground{maxSize: 16}
+	valid := map[string]string{"palette": "pico-8", "dither": "floyd-steinberg", "width": "20"}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+		// message is a part of the body
+		message string
+	}{
+		{
+			name:    "not a post",
+			request: httptest.NewRequest(http.MethodGet, "/dither", nil),
+			status:  http.StatusMethodNotAllowed,
+			message: "upload the image with a POST",
+		},
+		{
+			name:    "no image",
+			request: uploadRequest(t, valid, nil),
+			status:  http.StatusBadRequest,
+			message: "no image was uploaded",
+		},
+		{
+			name:    "no colors",
+			request: uploadRequest(t, map[string]string{"k": "0", "dither": "atkinson"}, testUpload(t, 40, 30)),
+			status:  http.StatusBadRequest,
+			message: "the amount of colors needs to be between 1 and 256",
+		},
+		{
+			name:    "too many colors",
+			request: uploadRequest(t, map[string]string{"k": "300", "dither": "atkinson"}, testUpload(t, 40, 30)),
+			status:  http.StatusBadRequest,
+			message: "the amount of colors needs to be between 1 and 256",
+		},
+		{
+			name:    "unknown dither",
+			request: uploadRequest(t, map[string]string{"palette": "pico-8", "dither": "nope"}, testUpload(t, 40, 30)),
+			status:  http.StatusBadRequest,
+			message: `"nope" is not a dithering algorithm`,
+		},
+		{
+			// the limit is maxSourceFactor*maxSize = 64 pixels in both directions, so 4096 pixels
+			name:    "too large",
+			request: uploadRequest(t, valid, testUpload(t, 100, 50)),
+			status:  http.StatusRequestEntityTooLarge,
+			message: "the image of 100x50 pixels is too large",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			pg.dither(recorder, test.request)
+
+			if recorder.Code != test.status {
+				t.Errorf("status %d, want %d: %s", recorder.Code, test.status, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), test.message) {
+				t.Errorf("body %q, want one with %q", recorder.Body, test.message)
+			}
+		})
+	}
+}
+
+// TestPlaygroundDitherValid checks that a valid upload is served as a png of the size that fit chooses
+func TestPlaygroundDitherValid(t *testing.T) {
+	pg := &playground{maxSize: 16}
+
+	for _, fields := range []map[string]string{
+		{"palette": "pico-8", "dither": "floyd-steinberg", "width": "20"},
+		{"k": "4", "dither": "bayer4"},
+	} {
+		recorder := httptest.NewRecorder()
+		pg.dither(recorder, uploadRequest(t, fields, testUpload(t, 40, 30)))
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%v: status %d: %s", fields, recorder.Code, recorder.Body)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "image/png" {
+			t.Errorf("%v: content type %q, want image/png", fields, contentType)
+		}
+
+		img, err := png.Decode(recorder.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		width, height := pg.fit(image.Rect(0, 0, 40, 30), 20)
+		if width != 16 || height != 12 {
+			t.Fatalf("fit gave %dx%d, want the largest size of 16x12", width, height)
+		}
+		if size := img.Bounds().Size(); size != image.Pt(width, height) {
+			t.Errorf("%v: the image is %v, want %dx%d", fields, size, width, height)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+
+	"github.com/mielpeeters/dither/process"
+)
+
+// sizeOptions are the flags that choose the size an input is dithered at: scaled down by -scale,
+// or resized to -width and -height
+type sizeOptions struct {
+	scale  int
+	width  int
+	height int
+	// command is the subcommand of the flags, for the usageError of checkImage
+	command string
+	// flags are the flags of the subcommand, to tell which of the size flags were given
+	flags *flag.FlagSet
+}
+
+// addSizeFlags registers the flags that choose the size on flags, with the given usage of -scale
+func addSizeFlags(flags *flag.FlagSet, scaleUsage string) *sizeOptions {
+	size := sizeOptions{command: flags.Name(), flags: flags}
+
+	flags.IntVar(&size.scale, "scale", 1, scaleUsage)
+	flags.IntVar(&size.width, "width", 0, "width in pixels to resize to instead of scaling down with -scale, keeping the aspect ratio without -height")
+	flags.IntVar(&size.height, "height", 0, "height in pixels to resize to instead of scaling down with -scale, keeping the aspect ratio without -width")
+
+	return &size
+}
+
+// check adds the problems with the size to problems
+func (size *sizeOptions) check(problems *flagErrors) {
+	if size.scale < 1 {
+		problems.add("the scale (-scale) needs to be at least 1")
+	}
+	if size.width < 0 || size.height < 0 {
+		problems.add("the width (-width) and height (-height) can't be negative")
+	}
+	if size.resizes() && givenFlags(size.flags)["scale"] {
+		problems.add("-scale and -width or -height each choose the size, give only one of them")
+	}
+}
+
+// checkImage returns a usageError if -scale would scale an image of the given bounds down to nothing,
+// which check can't tell before the image is read
+func (size *sizeOptions) checkImage(bounds image.Rectangle) error {
+	if size.resizes() || bounds.Dx()/size.scale > 0 && bounds.Dy()/size.scale > 0 {
+		return nil
+	}
+
+	return &usageError{command: size.command, problems: []string{
+		fmt.Sprintf("the scale (-scale) %d is larger than the %dx%d image, which leaves no pixels", size.scale, bounds.Dx(), bounds.Dy()),
+	}}
+}
+
+// resizes returns whether the size is chosen with -width or -height instead of -scale
+func (size *sizeOptions) resizes() bool {
+	return size.width > 0 || size.height > 0
+}
+
+// apply returns img scaled down by -scale, or resized to -width and -height.
+// It returns the error of checkImage if the scale leaves no pixels.
+func (size *sizeOptions) apply(img image.Image) (*image.RGBA, error) {
+	if err := size.checkImage(img.Bounds()); err != nil {
+		return nil, err
+	}
+
+	if !size.resizes() {
+		return process.Downscale(img, size.scale), nil
+	}
+
+	width, height := process.FitSize(img.Bounds(), size.width, size.height)
+	return process.Resize(img, width, height), nil
+}
+
+// apply64 returns img at the size of apply, with 16 bits per channel
+func (size *sizeOptions) apply64(img image.Image) *image.RGBA64 {
+	if !size.resizes() {
+		return process.DownscaleRGBA64(img, size.scale)
+	}
+
+	width, height := process.FitSize(img.Bounds(), size.width, size.height)
+	return process.ResizeRGBA64(img, width, height)
+}
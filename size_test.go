@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestSizeCheck(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{args: []string{"-scale", "4"}},
+		{args: []string{"-width", "200"}},
+		{args: []string{"-width", "200", "-height", "100"}},
+		{args: []string{"-scale", "0"}, want: []string{"the scale (-scale) needs to be at least 1"}},
+		{args: []string{"-height", "-1"}, want: []string{"the width (-width) and height (-height) can't be negative"}},
+		{args: []string{"-scale", "4", "-width", "200"}, want: []string{"-scale and -width or -height each choose the size, give only one of them"}},
+		// giving -scale conflicts, even with the default of 1
+		{args: []string{"-scale", "1", "-height", "100"}, want: []string{"-scale and -width or -height each choose the size, give only one of them"}},
+	}
+
+	for _, test := range tests {
+		flags := flag.NewFlagSet("image", flag.ContinueOnError)
+		size := addSizeFlags(flags, "")
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+
+		problems := newFlagErrors(flags)
+		size.check(problems)
+		if !reflect.DeepEqual(problems.problems, test.want) {
+			t.Errorf("%q: got the problems %q, want %q", test.args, problems.problems, test.want)
+		}
+	}
+}
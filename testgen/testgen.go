@@ -0,0 +1,111 @@
+// Package testgen generates synthetic images of which the optimal palette is known in advance.
+// They can be used to validate the quality of the clustering algorithms in this module.
+package testgen
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// MinSeparation is the minimal distance between two cluster centers, expressed in standard deviations.
+// Clusters that are further apart than a few standard deviations are easily separable,
+// so that their centers are the optimal palette.
+var MinSeparation = 8.0
+
+// maxTries is the amount of times a new random center is drawn, before giving up on the separation
+const maxTries = 1000
+
+// GaussianClusters generates a width x height image, with pixel colors drawn from k Gaussian color clusters,
+// each with standard deviation sigma on the R, G and B channels. Each cluster occupies an equal share of
+// vertical stripes of the image.
+//
+// The returned palette contains the k cluster centers, which is the optimal k-color palette for the image.
+// Use a seeded rng to get the same image every time.
+func GaussianClusters(k, width, height int, sigma float64, rng *rand.Rand) (*image.RGBA, color.Palette) {
+	centers := make([][3]float64, 0, k)
+
+	// keep the centers away from the edges of the color cube, so clusters aren't clipped
+	margin := math.Min(3*sigma, 127)
+
+	for len(centers) < k {
+		var center [3]float64
+		for try := 0; try < maxTries; try++ {
+			for i := range center {
+				center[i] = margin + rng.Float64()*(255-2*margin)
+			}
+
+			if separated(center, centers, MinSeparation*sigma) {
+				break
+			}
+		}
+
+		centers = append(centers, center)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		center := centers[x*k/width]
+		for y := 0; y < height; y++ {
+			img.SetRGBA(x, y, color.RGBA{
+				R: clamp(center[0] + rng.NormFloat64()*sigma),
+				G: clamp(center[1] + rng.NormFloat64()*sigma),
+				B: clamp(center[2] + rng.NormFloat64()*sigma),
+				A: 255,
+			})
+		}
+	}
+
+	palette := color.Palette{}
+	for _, center := range centers {
+		palette = append(palette, color.RGBA{clamp(center[0]), clamp(center[1]), clamp(center[2]), 255})
+	}
+
+	return img, palette
+}
+
+// MaxCenterError returns the largest euclidean RGB distance between a color of optimal and
+// the closest color of found. It is zero when found contains every optimal color exactly.
+func MaxCenterError(found, optimal color.Palette) float64 {
+	var maxError float64
+
+	for _, want := range optimal {
+		closest := math.Inf(1)
+		for _, got := range found {
+			closest = math.Min(closest, distance(want, got))
+		}
+		maxError = math.Max(maxError, closest)
+	}
+
+	return maxError
+}
+
+func separated(center [3]float64, centers [][3]float64, minDist float64) bool {
+	for _, other := range centers {
+		var dist float64
+		for i := range center {
+			dist += (center[i] - other[i]) * (center[i] - other[i])
+		}
+		if math.Sqrt(dist) < minDist {
+			return false
+		}
+	}
+
+	return true
+}
+
+func distance(left, right color.Color) float64 {
+	lr, lg, lb, _ := left.RGBA()
+	rr, rg, rb, _ := right.RGBA()
+
+	dr := float64(lr>>8) - float64(rr>>8)
+	dg := float64(lg>>8) - float64(rg>>8)
+	db := float64(lb>>8) - float64(rb>>8)
+
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+func clamp(value float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(value))))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// flagErrors collects the problems with the flags of a subcommand, so that they are all reported at once
+// instead of one per run
+type flagErrors struct {
+	command  string
+	problems []string
+}
+
+// newFlagErrors returns the flagErrors of the subcommand of flags
+func newFlagErrors(flags *flag.FlagSet) *flagErrors {
+	return &flagErrors{command: flags.Name()}
+}
+
+// add adds a problem, formatted like fmt.Sprintf
+func (fe *flagErrors) add(format string, args ...any) {
+	fe.problems = append(fe.problems, fmt.Sprintf(format, args...))
+}
+
+// err returns a usageError with all of the problems, or nil if there are none
+func (fe *flagErrors) err() error {
+	if len(fe.problems) == 0 {
+		return nil
+	}
+
+	return &usageError{command: fe.command, problems: fe.problems}
+}
+
+// usageError is an error in the flags a subcommand is run with. main exits with code 2 on it,
+// like the flag package does for flags it can't parse, and with code 1 on the other errors.
+type usageError struct {
+	command  string
+	problems []string
+}
+
+func (e *usageError) Error() string {
+	var message strings.Builder
+	if len(e.problems) == 1 {
+		fmt.Fprintf(&message, "%s\n", e.problems[0])
+	} else {
+		fmt.Fprintf(&message, "%d problems with the flags:\n", len(e.problems))
+		for _, problem := range e.problems {
+			fmt.Fprintf(&message, "  - %s\n", problem)
+		}
+	}
+	fmt.Fprintf(&message, "run `dither %s -h` for the flags", e.command)
+
+	return message.String()
+}
+
+// givenFlags returns the names of the flags that were given, on the command line or by the config file
+func givenFlags(flags *flag.FlagSet) map[string]bool {
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	return given
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mielpeeters/dither/imgutil"
+)
+
+func TestUsageError(t *testing.T) {
+	tests := []struct {
+		problems []string
+		want     string
+	}{
+		{
+			problems: []string{"provide an input image (-p)"},
+			want:     "provide an input image (-p)\nrun `dither image -h` for the flags",
+		},
+		{
+			problems: []string{"provide an input image (-p)", "the scale (-scale) needs to be at least 1"},
+			want:     "2 problems with the flags:\n  - provide an input image (-p)\n  - the scale (-scale) needs to be at least 1\nrun `dither image -h` for the flags",
+		},
+	}
+
+	for _, test := range tests {
+		problems := flagErrors{command: "image"}
+		for _, problem := range test.problems {
+			problems.add("%s", problem)
+		}
+		if got := problems.err().Error(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+
+	if err := (&flagErrors{command: "image"}).err(); err != nil {
+		t.Errorf("no problems gave error %v", err)
+	}
+}
+
+// TestFlagValidation checks that the commands report all of the problems with their flags at once, as a usageError
+func TestFlagValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(args []string) error
+		args []string
+		want []string
+	}{
+		{
+			name: "image without input",
+			run:  imageCommand,
+			want: []string{"provide an input image (-p)"},
+		},
+		{
+			name: "image watching stdin",
+			run:  imageCommand,
+			args: []string{"-p", "-", "-watch"},
+			want: []string{"stdin (-p -) can't be watched (-watch)"},
+		},
+		{
+			name: "image with several problems",
+			run:  imageCommand,
+			args: []string{"-p", "input.jpg", "-o", "output.gif", "-scale", "0", "-depth", "16", "-k", "0"},
+			want: []string{
+				"the scale (-scale) needs to be at least 1",
+				"a bit depth (-depth) of 16 needs a png or tiff output, not gif",
+				"the amount of colors (-k) needs to be at least 1",
+			},
+		},
+		{
+			name: "image with unknown names",
+			run:  imageCommand,
+			args: []string{"-p", "input.jpg", "-format", "heic", "-dither", "nope", "-quantizer", "nope"},
+			want: []string{
+				`the format (-format) needs to be one of ` + strings.Join(imgutil.Formats, ", ") + `, not "heic"`,
+				`the dithering algorithm (-dither) needs to be one of ` + strings.Join(ditherNames(), ", ") + `, not "nope"`,
+				`the quantizer (-quantizer) needs to be kmeans, bisecting, median-cut, octree or wu, not "nope"`,
+			},
+		},
+		{
+			name: "image with two palettes",
+			run:  imageCommand,
+			args: []string{"-p", "input.jpg", "-palette", "pico-8", "-duotone", "000000,fffff"},
+			want: []string{
+				`the duotone inks (-duotone) need to be hex colors: colorpalette: invalid hex color "fffff"`,
+				"-palette and -duotone each choose how the palette is made, give only one of them",
+			},
+		},
+		{
+			name: "image expanding a created palette",
+			run:  imageCommand,
+			args: []string{"-p", "input.jpg", "-expand", "16"},
+			want: []string{"-expand expands the palette of -palette or -palette-file, which isn't given"},
+		},
+		{
+			name: "batch",
+			run:  batchCommand,
+			args: []string{"-p", "photos", "-name", "output.png", "-workers", "-1", "-shared", "-quantizer", "wu"},
+			want: []string{
+				"the amount of workers (-workers) can't be negative",
+				"the file name of the outputs (-name) needs {name}, or all images are written to the same file",
+				"a shared palette (-shared) is created with kmeans, it can't be combined with -duotone or -quantizer",
+			},
+		},
+		{
+			name: "compare",
+			run:  compareCommand,
+			args: []string{"first.jpg", "second.jpg", "-dithers", "fs,nope", "-k", "8,0", "-blur", "-1"},
+			want: []string{
+				`compare takes one input image, as -p or the argument, not ["first.jpg" "second.jpg"]`,
+				"provide an input image",
+				"the radius of the blur (-blur) can't be negative",
+				`the dithering algorithms (-dithers) need to be some of ` + strings.Join(ditherNames(), ", ") + `, not "nope"`,
+				`the amounts of colors (-k) need to be between 1 and 256, not "0"`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keepSettings(t)
+
+			// an empty config file, so that the config file of the user doesn't change the flags
+			args := append([]string{"-config", writeConfig(t, "")}, test.args...)
+
+			var usage *usageError
+			if err := test.run(args); !errors.As(err, &usage) {
+				t.Fatalf("error %v, want a usageError", err)
+			}
+			if !reflect.DeepEqual(usage.problems, test.want) {
+				t.Errorf("got the problems\n%q\nwant\n%q", usage.problems, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mielpeeters/dither/gifeo"
+)
+
+// verbosity is the amount of output on stderr: 0 (-q) only errors, 1 the progress and results (the default),
+// 2 (-v) also a summary of the time every stage took, and 3 (-vv) also every stage as it is done
+var verbosity = 1
+
+// addVerbosityFlags registers -q, -v and -vv on flags, and returns the function that sets verbosity
+// from them after parsing
+func addVerbosityFlags(flags *flag.FlagSet) func() error {
+	quiet := flags.Bool("q", false, "only print errors")
+	verbose := flags.Bool("v", false, "also print how long every stage took")
+	veryVerbose := flags.Bool("vv", false, "also print every stage as it is done")
+
+	return func() error {
+		if *quiet && (*verbose || *veryVerbose) {
+			return errors.New("-q can't be combined with -v or -vv")
+		}
+
+		switch {
+		case *quiet:
+			verbosity = 0
+		case *veryVerbose:
+			verbosity = 3
+		case *verbose:
+			verbosity = 2
+		}
+
+		if verbosity < 1 {
+			gifeo.Verbosity = 0
+		}
+
+		return nil
+	}
+}
+
+// logf prints to stderr if verbosity is at least level
+func logf(level int, format string, args ...any) {
+	if verbosity >= level {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// timing adds up how long the stages of a run (like decode, palette and dither) take, for -v and -vv.
+// The stages can be timed by several goroutines at once.
+type timing struct {
+	mu        sync.Mutex
+	start     time.Time
+	names     []string
+	durations map[string]time.Duration
+}
+
+// newTiming starts timing a run
+func newTiming() *timing {
+	return &timing{start: time.Now(), durations: map[string]time.Duration{}}
+}
+
+// stage starts timing the stage name of subject (like the path of an image, which may be empty),
+// and returns the function that ends it
+func (t *timing) stage(name, subject string) func() {
+	start := time.Now()
+
+	return func() {
+		elapsed := time.Since(start)
+
+		t.mu.Lock()
+		if _, ok := t.durations[name]; !ok {
+			t.names = append(t.names, name)
+		}
+		t.durations[name] += elapsed
+		t.mu.Unlock()
+
+		if subject != "" {
+			logf(3, "%s: %s took %v", subject, name, roundDuration(elapsed))
+		} else {
+			logf(3, "%s took %v", name, roundDuration(elapsed))
+		}
+	}
+}
+
+// summary prints the total time of every stage, in the order they were first done, and of the whole run
+func (t *timing) summary() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parts := make([]string, 0, len(t.names)+1)
+	for _, name := range t.names {
+		parts = append(parts, fmt.Sprintf("%s %v", name, roundDuration(t.durations[name])))
+	}
+	parts = append(parts, fmt.Sprintf("total %v", roundDuration(time.Since(t.start))))
+
+	logf(2, "%s", strings.Join(parts, ", "))
+}
+
+// roundDuration rounds d to milliseconds, or to microseconds if it is shorter than that
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is the release version of the binary, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// checksumsAsset is the release asset that lists the SHA-256 checksum of every binary of the release,
+// one "<checksum>  <asset name>" line per binary
+const checksumsAsset = "checksums.txt"
+
+// releasesURL is the GitHub API endpoint for the latest release of this module
+const releasesURL = "https://api.github.com/repos/mielpeeters/dither/releases/latest"
+
+// feature is an optional capability of the binary, which may or may not be available
+type feature struct {
+	name      string
+	available bool
+	note      string
+}
+
+// features lists the optional capabilities, and whether they can be used on this system
+func features() []feature {
+	_, err := exec.LookPath("ffmpeg")
+	ffmpeg := feature{name: "ffmpeg", available: err == nil, note: "video input and output"}
+	if err != nil {
+		ffmpeg.note = "not found in PATH"
+	}
+
+	_, err = exec.LookPath("cwebp")
+	webp := feature{name: "webp", available: err == nil, note: "webp output"}
+	if err != nil {
+		webp.note = "cwebp not found in PATH, webp images can only be read"
+	}
+
+	return []feature{ffmpeg, webp}
+}
+
+// printVersion prints the version, build provenance and optional features of the binary
+func printVersion() {
+	fmt.Printf("dither %s\n", version)
+	fmt.Printf("  go:       %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Printf("  module:   %s %s\n", info.Main.Path, info.Main.Version)
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				fmt.Printf("  revision: %s\n", setting.Value)
+			case "vcs.time":
+				fmt.Printf("  built at: %s\n", setting.Value)
+			case "vcs.modified":
+				if setting.Value == "true" {
+					fmt.Printf("  modified: uncommitted changes\n")
+				}
+			}
+		}
+	}
+
+	fmt.Println("features:")
+	for _, f := range features() {
+		state := "no "
+		if f.available {
+			state = "yes"
+		}
+		fmt.Printf("  %-7s %s (%s)\n", f.name, state, f.note)
+	}
+}
+
+// release is the part of the GitHub release API response that is used
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetName returns the name of the release asset built for this platform
+func assetName() string {
+	name := fmt.Sprintf("dither_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// update checks the latest GitHub release, and replaces the running binary with it if it is newer
+func update(args []string) error {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	check := flags.Bool("check", false, "only check whether an update is available")
+	flags.Parse(args)
+
+	client := http.Client{Timeout: time.Minute}
+
+	resp, err := client.Get(releasesURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("checking for updates failed: %s", resp.Status)
+	}
+
+	var latest release
+	if err := json.NewDecoder(resp.Body).Decode(&latest); err != nil {
+		return err
+	}
+
+	newer, err := isNewer(latest.TagName, version)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		fmt.Printf("dither %s is up to date\n", version)
+		return nil
+	}
+
+	fmt.Printf("dither %s is available (current: %s)\n", latest.TagName, version)
+	if *check {
+		return nil
+	}
+
+	downloadURL, checksumsURL := "", ""
+	for _, asset := range latest.Assets {
+		switch asset.Name {
+		case assetName():
+			downloadURL = asset.URL
+		case checksumsAsset:
+			checksumsURL = asset.URL
+		}
+	}
+	if downloadURL == "" {
+		return fmt.Errorf("release %s has no binary for %s/%s", latest.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	if checksumsURL == "" {
+		return fmt.Errorf("release %s has no %s to verify the binary with", latest.TagName, checksumsAsset)
+	}
+
+	checksum, err := releaseChecksum(&client, checksumsURL, assetName())
+	if err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceBinary(&client, downloadURL, checksum, executable); err != nil {
+		return err
+	}
+
+	fmt.Printf("updated to dither %s\n", latest.TagName)
+	return nil
+}
+
+// parseVersion parses a semantic version like v1.2.3 or 1.2.3-rc.1 into its major, minor and patch
+// numbers and its pre-release
+func parseVersion(v string) ([3]int, string, error) {
+	var numbers [3]int
+
+	core := strings.TrimPrefix(v, "v")
+	// build metadata doesn't take part in the ordering
+	core, _, _ = strings.Cut(core, "+")
+	core, pre, _ := strings.Cut(core, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return numbers, "", fmt.Errorf("%q is not a semantic version", v)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return numbers, "", fmt.Errorf("%q is not a semantic version", v)
+		}
+		numbers[i] = n
+	}
+
+	return numbers, pre, nil
+}
+
+// comparePrerelease orders two pre-releases of the same version by their dot separated identifiers:
+// numeric identifiers compare as numbers and before alphanumeric ones, and no pre-release comes last
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// isNewer reports whether the release version is newer than the current one. A current version that
+// isn't a release, like that of a dev build, is older than any release.
+func isNewer(release, current string) (bool, error) {
+	releaseNumbers, releasePre, err := parseVersion(release)
+	if err != nil {
+		return false, fmt.Errorf("latest release: %v", err)
+	}
+
+	currentNumbers, currentPre, err := parseVersion(current)
+	if err != nil {
+		return true, nil
+	}
+
+	for i := range releaseNumbers {
+		if releaseNumbers[i] != currentNumbers[i] {
+			return releaseNumbers[i] > currentNumbers[i], nil
+		}
+	}
+
+	return comparePrerelease(releasePre, currentPre) > 0, nil
+}
+
+// releaseChecksum downloads the checksums file at url, and returns the SHA-256 checksum of the asset in it
+func releaseChecksum(client *http.Client, url, asset string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading the checksums failed: %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 1<<20))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks binary files with a * before the name
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != asset {
+			continue
+		}
+
+		checksum, err := hex.DecodeString(fields[0])
+		if err != nil || len(checksum) != sha256.Size {
+			return nil, fmt.Errorf("malformed checksum of %s", asset)
+		}
+		return checksum, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("the release has no checksum of %s", asset)
+}
+
+// replaceBinary downloads the binary at url next to executable, checks that its SHA-256 checksum
+// is checksum, and swaps it in place
+func replaceBinary(client *http.Client, url string, checksum []byte, executable string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading the update failed: %s", resp.Status)
+	}
+
+	// download next to the executable, so the final rename stays on the same filesystem
+	tmp, err := os.CreateTemp(filepath.Dir(executable), ".dither-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if !bytes.Equal(hash.Sum(nil), checksum) {
+		return errors.New("the downloaded binary doesn't match the checksum of the release, not updating")
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	// a running binary can't be overwritten on every platform, but it can be moved aside
+	old := executable + ".old"
+	os.Remove(old)
+	if err := os.Rename(executable, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), executable); err != nil {
+		// put the original binary back
+		if restoreErr := os.Rename(old, executable); restoreErr != nil {
+			return fmt.Errorf("%v, and restoring the original binary failed: %v", err, restoreErr)
+		}
+		return err
+	}
+	os.Remove(old)
+
+	return nil
+}
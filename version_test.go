@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		numbers [3]int
+		pre     string
+		err     bool
+	}{
+		{version: "v1.2.3", numbers: [3]int{1, 2, 3}},
+		{version: "1.2.3", numbers: [3]int{1, 2, 3}},
+		{version: "v1.0.0-rc.1", numbers: [3]int{1, 0, 0}, pre: "rc.1"},
+		{version: "v1.0.0+build.5", numbers: [3]int{1, 0, 0}},
+		{version: "v1.0.0-beta+exp.sha.5114f85", numbers: [3]int{1, 0, 0}, pre: "beta"},
+		{version: "dev", err: true},
+		{version: "", err: true},
+		{version: "v1.2", err: true},
+		{version: "v1.2.3.4", err: true},
+		{version: "v1.x.3", err: true},
+		{version: "v1.-2.3", err: true},
+	}
+
+	for _, test := range tests {
+		numbers, pre, err := parseVersion(test.version)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: parsed as %v %q, want an error", test.version, numbers, pre)
+			}
+			continue
+		}
+		if err != nil || numbers != test.numbers || pre != test.pre {
+			t.Errorf("%q: got %v %q (error %v), want %v %q", test.version, numbers, pre, err, test.numbers, test.pre)
+		}
+	}
+}
+
+func TestComparePrerelease(t *testing.T) {
+	// every pre-release comes before the next one
+	ordered := []string{"alpha", "alpha.1", "alpha.beta", "beta", "beta.2", "beta.11", "rc.1", "rc.2", "rc.10", ""}
+	for i := range ordered {
+		for j := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := comparePrerelease(ordered[i], ordered[j]); got != want {
+				t.Errorf("comparing %q with %q gave %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	// numeric identifiers come before alphanumeric ones
+	if got := comparePrerelease("1", "a"); got != -1 {
+		t.Errorf("comparing 1 with a gave %d, want -1", got)
+	}
+}
+
+func TestIsNewer(t *testing.T) {
+	tests := []struct {
+		release, current string
+		newer            bool
+		err              bool
+	}{
+		{release: "v1.0.0", current: "v1.0.0-rc.1", newer: true},
+		{release: "v1.0.0-rc.1", current: "v1.0.0"},
+		{release: "v1.0.0-rc.10", current: "v1.0.0-rc.2", newer: true},
+		{release: "v1.0.0", current: "v1.0.0"},
+		{release: "v1.0.0+build.2", current: "v1.0.0+build.1"},
+		{release: "v1.10.0", current: "v1.9.3", newer: true},
+		{release: "v1.9.3", current: "v1.10.0"},
+		{release: "v2.0.0", current: "v1.99.99", newer: true},
+		{release: "v0.1.0", current: "dev", newer: true},
+		{release: "latest", current: "v1.0.0", err: true},
+	}
+
+	for _, test := range tests {
+		newer, err := isNewer(test.release, test.current)
+		if (err != nil) != test.err {
+			t.Errorf("%s over %s: error %v", test.release, test.current, err)
+			continue
+		}
+		if newer != test.newer {
+			t.Errorf("%s over %s: newer is %v, want %v", test.release, test.current, newer, test.newer)
+		}
+	}
+}
+
+func TestReleaseChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("binary"))
+	checksum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name      string
+		checksums string
+		status    int
+		// err is a part of the error, if there is one
+		err string
+	}{
+		{name: "text", checksums: fmt.Sprintf("%x  dither-linux-arm64\n%s  dither-linux-amd64\n", sha256.Sum256(nil), checksum)},
+		{name: "binary marker", checksums: checksum + " *dither-linux-amd64\n"},
+		{name: "missing", checksums: checksum + "  dither-darwin-arm64\n", err: "the release has no checksum of dither-linux-amd64"},
+		{name: "malformed hex", checksums: "xyz" + checksum[3:] + "  dither-linux-amd64\n", err: "malformed checksum of dither-linux-amd64"},
+		{name: "short", checksums: checksum[:32] + "  dither-linux-amd64\n", err: "malformed checksum"},
+		{name: "not found", status: http.StatusNotFound, err: "downloading the checksums failed: 404"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.status != 0 {
+					w.WriteHeader(test.status)
+				}
+				w.Write([]byte(test.checksums))
+			}))
+			defer server.Close()
+
+			got, err := releaseChecksum(server.Client(), server.URL+"/"+checksumsAsset, "dither-linux-amd64")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("error %v, want one with %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, sum[:]) {
+				t.Errorf("checksum %x, want %s", got, checksum)
+			}
+		})
+	}
+}
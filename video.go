@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"image"
+
+	"github.com/mielpeeters/dither/gifeo"
+)
+
+// addVideoFlags registers the flags of an mp4 or webm output on flags
+func addVideoFlags(flags *flag.FlagSet) *gifeo.VideoOptions {
+	video := gifeo.VideoOptions{}
+
+	flags.StringVar(&video.Codec, "codec", "", "ffmpeg codec of an mp4 or webm output (by default libx264 for mp4, libvpx-vp9 for webm)")
+	flags.IntVar(&video.FPS, "fps", 0, "frame rate of an mp4 or webm output (by default every frame of the shortest delay is shown once)")
+	flags.IntVar(&video.CRF, "crf", 0, "quality of an mp4 or webm output, lower is better and larger: up to 51 for libx264, 63 for libvpx-vp9 (by default that of ffmpeg)")
+
+	return &video
+}
+
+// checkVideo adds the problems with the video flags to problems, for the output at outputPath
+func checkVideo(problems *flagErrors, video *gifeo.VideoOptions, outputPath string, given map[string]bool) {
+	if video.FPS < 0 {
+		problems.add("the frame rate (-fps) can't be negative")
+	}
+	if video.CRF < 0 {
+		problems.add("the quality (-crf) can't be negative")
+	}
+	if !gifeo.IsVideo(outputPath) && (given["codec"] || given["fps"] || given["crf"]) {
+		problems.add("-codec, -fps and -crf only apply to an mp4 or webm output, not to %s", outputPath)
+	}
+}
+
+// checkFFmpeg returns an error when the output at outputPath is a video, but ffmpeg isn't installed to encode it.
+// It is checked before the frames are made, which can take a while.
+func checkFFmpeg(outputPath string) error {
+	if gifeo.IsVideo(outputPath) {
+		return gifeo.CheckFFmpeg()
+	}
+	return nil
+}
+
+// encodeFrames saves the frames at outputPath, all with the same delay in 100ths of a second:
+// as an mp4 or webm video with ffmpeg, or as a gif
+func encodeFrames(frames []*image.Paletted, outputPath string, delay int, video *gifeo.VideoOptions) error {
+	delays := make([]int, len(frames))
+	for i := range delays {
+		delays[i] = delay
+	}
+
+	return gifeo.Encode(frames, delays, outputPath, *video)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchInterval is how often -watch checks the inputs for changes
+var watchInterval = 500 * time.Millisecond
+
+// fileState is what -watch compares to notice that a file changed
+type fileState struct {
+	modified time.Time
+	size     int64
+}
+
+// watch runs run, and runs it again every time the files at input (a file, a directory or a glob pattern) change,
+// until an interrupt. The errors of run are printed instead of returned, as the next change may fix them.
+func watch(input string, run func() error) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	last := snapshot(input)
+	for {
+		if err := run(); err != nil {
+			logf(0, "%v", err)
+		}
+		logf(1, "watching %s for changes, interrupt to stop", input)
+
+		for changed := false; !changed; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			current := snapshot(input)
+			changed = !sameSnapshot(last, current)
+			last = current
+		}
+	}
+}
+
+// snapshot returns the state of the files at input: the file itself, the files in the directory,
+// or the files that match the glob pattern
+func snapshot(input string) map[string]fileState {
+	paths := []string{input}
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		paths, _ = filepath.Glob(filepath.Join(input, "*"))
+	} else if matches, err := filepath.Glob(input); err == nil && len(matches) > 0 {
+		paths = matches
+	}
+
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			states[path] = fileState{info.ModTime(), info.Size()}
+		}
+	}
+
+	return states
+}
+
+// sameSnapshot reports whether no file was added, removed or changed between the snapshots
+func sameSnapshot(left, right map[string]fileState) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for path, state := range left {
+		if other, ok := right[path]; !ok || !other.modified.Equal(state.modified) || other.size != state.size {
+			return false
+		}
+	}
+
+	return true
+}